@@ -172,3 +172,32 @@ func autoMigrationPG(cfg *agentConfig.Config) error {
 
 	return nil
 }
+
+// MarkGeneratedColumns read schema of tables to mark columns generated by database in models of config,
+// so dashboard doesn't insert values sent for them
+func MarkGeneratedColumns(cfg *agentConfig.Config) error {
+	switch cfg.DBType {
+	case pgDriver:
+		return markGeneratedColumnsPG(cfg)
+	default:
+		return fmt.Errorf("using not support database type: %s", cfg.DBType)
+	}
+}
+
+func markGeneratedColumnsPG(cfg *agentConfig.Config) error {
+	for _, d := range cfg.Databases {
+		db, err := gorm.Open("postgres", cfg.DBConnectionString(d.DBName))
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		// columns of models are shared with config, they are updated in place
+		err = drivers.NewPGStore(d.DBName, cfg.DBSchemaName, db).MarkGeneratedColumns(d.ModelList)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/dwarvesf/smithy/common/database"
 )
@@ -58,9 +59,15 @@ type ColumnSchema struct {
 	IsNullable             string
 	Order                  string
 	ColumnDefault          string
+	IsIdentity             string
 	IsPrimary              bool
 }
 
+// IsGenerated check value of column is generated by database, ex: serial or identity column
+func (c ColumnSchema) IsGenerated() bool {
+	return strings.HasPrefix(c.ColumnDefault, "nextval(") || c.IsIdentity == "YES"
+}
+
 // ColumnSchemas array of ColumnSchema
 type ColumnSchemas []ColumnSchema
 
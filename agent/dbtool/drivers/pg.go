@@ -51,6 +51,9 @@ func (s *pgStore) MissingColumns(tableDefinitions []database.Model) ([]agentConf
 	res := []agentConfig.MissingColumns{}
 	colDefs := database.Models(tableDefinitions).ColumnsByTableName()
 	for tblName, columns := range colDefs {
+		// computed columns are read by their expressions, they aren't created in table
+		columns = database.Columns(columns).Stored()
+
 		// check not created table
 		{
 			if _, ok := existColumns[tblName]; !ok {
@@ -112,6 +115,28 @@ func (s *pgStore) existColumnsByTableName() (agentConfig.ExistingColumnByTableNa
 	return cmap, nil
 }
 
+// MarkGeneratedColumns set IsGenerated of columns whose values are generated by database,
+// ex: serial or identity columns, columns of tables which aren't created are left unchanged
+func (s *pgStore) MarkGeneratedColumns(modelList []database.Model) error {
+	existColumns, err := s.existColumnsByTableName()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range modelList {
+		existCols := agentConfig.ColumnSchemas(existColumns[m.TableName]).GroupByColumnName()
+		for i, col := range m.Columns {
+			for _, c := range existCols[col.Name] {
+				if c.IsGenerated() {
+					m.Columns[i].IsGenerated = true
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 func (s *pgStore) existTableNames() ([]string, error) {
 	tmp := []struct {
 		TableName string
@@ -144,7 +169,7 @@ func (s *pgStore) getSchemaOfTable(tableName, databaseName string) ([]agentConfi
 	}
 	cs := []agentConfig.ColumnSchema{}
 	return cs, s.db.Table("columns").
-		Select("column_name, udt_name, is_nullable, character_maximum_length, ordinal_position as order, column_default").
+		Select("column_name, udt_name, is_nullable, character_maximum_length, ordinal_position as order, column_default, is_identity").
 		Where("table_name = ? AND table_catalog = ?", tableName, databaseName).
 		Scan(&cs).Error
 }
@@ -10,6 +10,7 @@ type DBTool interface {
 	MissingColumns(models []database.Model) ([]agentConfig.MissingColumns, error)
 	Verify(modelList []database.Model) error
 	AutoMigrate([]agentConfig.MissingColumns) error
+	MarkGeneratedColumns(modelList []database.Model) error
 	RemoveACLUser(username string) error
 	CreateACLUser(user *database.User, forceCreate bool) error
 	CreateUserWithACL(models []database.Model, user *database.User, forceCreate bool) error
@@ -3,8 +3,11 @@ package auth
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/jwtauth"
@@ -12,6 +15,8 @@ import (
 	backendConfig "github.com/dwarvesf/smithy/backend/config"
 	"github.com/dwarvesf/smithy/backend/domain"
 	"github.com/dwarvesf/smithy/backend/service"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
 )
 
 const (
@@ -67,9 +72,11 @@ func (jwt *JWT) VerifierHandler() func(http.Handler) http.Handler {
 type URIType int
 
 const (
-	URITypeAgentSync URIType = 1
-	URITypeCRUD      URIType = 2
-	URITypeGroup     URIType = 3
+	URITypeAgentSync   URIType = 1
+	URITypeCRUD        URIType = 2
+	URITypeGroup       URIType = 3
+	URITypeTransaction URIType = 4
+	URITypeView        URIType = 5
 )
 
 // parse uri => type, dbName, tableName, method, ok
@@ -83,12 +90,25 @@ func parseURI(uri string) (URIType, string, string, string, bool) {
 		return URITypeGroup, "", "", "", true
 	}
 
+	// permissions of each operation are checked by transaction endpoint
+	if len(uriParts) == 4 && uriParts[1] == "databases" && strings.Split(uriParts[3], "?")[0] == "transaction" {
+		return URITypeTransaction, uriParts[2], "", "transaction", true
+	}
+
+	// views run SQL written by admin reading any table, so only admin can execute or export them
+	if len(uriParts) == 6 && uriParts[1] == "databases" && uriParts[3] == "view" {
+		method := strings.Split(uriParts[5], "?")[0]
+		if method == "execute" || method == "export" {
+			return URITypeView, uriParts[2], "", method, true
+		}
+	}
+
 	if len(uriParts) <= 5 {
 		return URITypeAgentSync, "", "", "", true
 	}
 
 	// dbName, tableName, method
-	return URITypeCRUD, uriParts[2], uriParts[4], uriParts[5], true
+	return URITypeCRUD, uriParts[2], uriParts[4], strings.Split(uriParts[5], "?")[0], true
 }
 
 //Authorization return json in middleware authorization
@@ -116,32 +136,51 @@ func Authorization(cfg *backendConfig.Config, s service.Service) func(next http.
 					return
 				}
 
-				// check dbName is invalid in agent config
-				model, ok := cfg.ModelMap[dbName]
-				if !ok {
-					encodeJSONError(ErrInvalidDatabaseName, w)
+				permission, err := authorizeTable(cfg, s, userName, dbName, tableName, method)
+				if err != nil {
+					encodeJSONError(err, w)
 					return
 				}
 
-				// check table name is invalid in agent config
-				tableInfo, ok := model[tableName]
-				if !ok {
-					encodeJSONError(ErrInvalidTableName, w)
+				// cached results are shared by users having the same permissions
+				r = r.WithContext(sqlmapper.WithPermissionKey(r.Context(), permissionKey(permission)))
+			} else if uriType == URITypeTransaction || uriType == URITypeView {
+				if claims["role"] != Admin && (uriType == URITypeView || claims["role"] != User) {
+					encodeJSONError(ErrUnauthorized, w)
 					return
 				}
 
-				// get permission (user && group)
-				finalPermission, err := s.UserService.GetPermissionUserAndGroup(&domain.User{Username: userName}, dbName, tableName)
-				if err != nil {
+				if _, ok := cfg.ModelMap[dbName]; !ok {
+					encodeJSONError(ErrInvalidDatabaseName, w)
+					return
+				}
+			}
+
+			// columns without read or write grant of user are hidden from queries and payloads,
+			// rows outside row filters of user can't be read or written, tables written by nested
+			// writes and on_delete must be permitted to user
+			permissions := newUserPermissions(cfg, s, userName)
+			ctx := sqlmapper.WithColumnPermission(r.Context(), permissions)
+			ctx = sqlmapper.WithTablePermission(ctx, permissions)
+			r = r.WithContext(sqlmapper.WithRowFilter(ctx, permissions))
+
+			// other databases still work when a database can't be connected
+			if dbName != "" {
+				if err := cfg.DBError(dbName); err != nil {
 					encodeJSONError(err, w)
 					return
 				}
+			}
 
-				// user just can access the url when user has user permisstion or table permisstion
-				if err := authorizeCRUD(method, finalPermission, tableInfo.ACL); err != nil {
+			if dbName != "" && hasMaskedColumns(cfg.ModelMap[dbName]) {
+				unmasked, err := canUnmask(s, claims["role"], userName)
+				if err != nil {
 					encodeJSONError(err, w)
 					return
 				}
+				if !unmasked {
+					r = r.WithContext(sqlmapper.WithMask(r.Context()))
+				}
 			}
 			// Token is authenticated, pass it through
 			next.ServeHTTP(w, r)
@@ -149,6 +188,132 @@ func Authorization(cfg *backendConfig.Config, s service.Service) func(next http.
 	}
 }
 
+// userPermissions resolve column grants and row filters of a user, permissions of a table are loaded once per request
+type userPermissions struct {
+	cfg      *backendConfig.Config
+	s        service.Service
+	userName string
+
+	mu     sync.Mutex
+	user   *domain.User
+	tables map[string]*domain.Permission
+}
+
+func newUserPermissions(cfg *backendConfig.Config, s service.Service, userName string) *userPermissions {
+	return &userPermissions{cfg: cfg, s: s, userName: userName, tables: make(map[string]*domain.Permission)}
+}
+
+// permission get permission of user on table, nil is returned when it can't be loaded
+func (p *userPermissions) permission(dbName, tableName string) *domain.Permission {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := dbName + "/" + tableName
+	if per, ok := p.tables[key]; ok {
+		return per
+	}
+
+	per, err := p.s.UserService.GetPermissionUserAndGroup(&domain.User{Username: p.userName}, dbName, tableName)
+	if err != nil {
+		per = nil
+	}
+	p.tables[key] = per
+
+	return per
+}
+
+// CanRead implement sqlmapper.ColumnPermission, columns are denied when permission can't be loaded
+// or user can't select rows of table
+func (p *userPermissions) CanRead(dbName, tableName, column string) bool {
+	per := p.permission(dbName, tableName)
+	return per != nil && per.Select && per.CanRead(column)
+}
+
+// CanWrite implement sqlmapper.ColumnPermission
+func (p *userPermissions) CanWrite(dbName, tableName, column string) bool {
+	per := p.permission(dbName, tableName)
+	return per != nil && per.CanWrite(column)
+}
+
+// AuthorizeTable implement sqlmapper.TablePermission, action is checked like a request of its method
+func (p *userPermissions) AuthorizeTable(dbName, tableName, action string) error {
+	model, ok := p.cfg.ModelMap[dbName][tableName]
+	if !ok {
+		return ErrInvalidTableName
+	}
+
+	per := p.permission(dbName, tableName)
+	if per == nil {
+		return ErrUnauthorized
+	}
+
+	return authorizeCRUD(action, per, model.ACL)
+}
+
+// RowFilters implement sqlmapper.RowFilter, attributes of user in filters are replaced by their values
+func (p *userPermissions) RowFilters(dbName, tableName string) ([]sqlmapper.Filter, error) {
+	per := p.permission(dbName, tableName)
+	if per == nil {
+		return nil, ErrUnauthorized
+	}
+	if len(per.RowFilters) == 0 {
+		return nil, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.user == nil {
+		user, err := p.s.UserService.Find(&domain.User{Username: p.userName})
+		if err != nil {
+			return nil, err
+		}
+		p.user = user
+	}
+
+	res := []sqlmapper.Filter{}
+	for _, f := range per.RowFilters {
+		v, err := f.Resolve(p.user)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, sqlmapper.Filter{Operator: "=", ColumnName: f.ColumnName, Value: v})
+	}
+
+	return res, nil
+}
+
+func hasMaskedColumns(models map[string]database.Model) bool {
+	for _, m := range models {
+		for _, col := range m.Columns {
+			if col.Mask != "" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// canUnmask check user sees values of masked columns: admin or member of a group with unmask
+func canUnmask(s service.Service, role interface{}, userName string) (bool, error) {
+	if role == Admin {
+		return true, nil
+	}
+
+	groups, err := s.GroupService.FindByUser(&domain.User{Username: userName})
+	if err != nil {
+		return false, err
+	}
+
+	for _, group := range groups {
+		if group.Unmask || group.Role == Admin {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 //RequireAdmin return authorization if is admin
 func RequireAdmin(s service.Service) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -206,29 +371,95 @@ func Authenticator(next http.Handler) http.Handler {
 }
 
 func encodeJSONError(err error, w http.ResponseWriter) {
-	w.WriteHeader(http.StatusUnauthorized)
+	code := http.StatusUnauthorized
+	if sc, ok := err.(interface{ StatusCode() int }); ok {
+		code = sc.StatusCode()
+	}
+	w.WriteHeader(code)
 	// enforce json response
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{
 		"error": err.Error(),
 	})
 }
 
+// AuthorizeTable check user has permission to run method (create, update, delete, ...) on a table
+func AuthorizeTable(cfg *backendConfig.Config, s service.Service, userName, dbName, tableName, method string) error {
+	_, err := authorizeTable(cfg, s, userName, dbName, tableName, method)
+	return err
+}
+
+// authorizeTable return permission of user on table when user can run method on it
+func authorizeTable(cfg *backendConfig.Config, s service.Service, userName, dbName, tableName, method string) (*domain.Permission, error) {
+	// check dbName is invalid in agent config
+	model, ok := cfg.ModelMap[dbName]
+	if !ok {
+		return nil, ErrInvalidDatabaseName
+	}
+
+	// check table name is invalid in agent config
+	tableInfo, ok := model[tableName]
+	if !ok {
+		return nil, ErrInvalidTableName
+	}
+
+	// get permission (user && group)
+	finalPermission, err := s.UserService.GetPermissionUserAndGroup(&domain.User{Username: userName}, dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// user just can access the url when user has user permisstion or table permisstion
+	if err := authorizeCRUD(method, finalPermission, tableInfo.ACL); err != nil {
+		return nil, err
+	}
+
+	return finalPermission, nil
+}
+
+// permissionKey identify permissions of a user on a table
+func permissionKey(p *domain.Permission) string {
+	key := fmt.Sprintf("%s/%s:%t,%t,%t,%t", p.DatabaseName, p.TableName, p.Select, p.Insert, p.Update, p.Delete)
+
+	columns := []string{}
+	for _, c := range p.Columns {
+		columns = append(columns, fmt.Sprintf("%s=%t,%t", c.ColumnName, c.Read, c.Write))
+	}
+	sort.Strings(columns)
+
+	return key + ";" + strings.Join(columns, ";")
+}
+
 func authorizeCRUD(method string, acl *domain.Permission, ACLTable string) error {
 	// if user hadn't user permisstion or table permisstion. They would be rejected
 	switch method {
-	case "query":
+	case "query", "export", "revisions":
 		if !acl.Select || !strings.ContainsAny(ACLTable, "r") {
 			return ErrUnauthorized
 		}
-	case "create":
+	case "create", "bulk-create":
 		if !acl.Insert || !strings.ContainsAny(ACLTable, "c") {
 			return ErrUnauthorized
 		}
-	case "update":
+	case "update", "bulk-update":
 		if !acl.Update || !strings.ContainsAny(ACLTable, "u") {
 			return ErrUnauthorized
 		}
-	case "delete":
+	case "import":
+		// import may insert or update rows, permission of its mode is checked by import endpoint
+		if (!acl.Insert || !strings.ContainsAny(ACLTable, "c")) && (!acl.Update || !strings.ContainsAny(ACLTable, "u")) {
+			return ErrUnauthorized
+		}
+	case "upsert":
+		// upsert may create or update a row
+		if !acl.Insert || !acl.Update || !strings.ContainsAny(ACLTable, "c") || !strings.ContainsAny(ACLTable, "u") {
+			return ErrUnauthorized
+		}
+	case "lookup":
+		// options of a foreign key are searched while reading or writing a row
+		if !(acl.Select && strings.ContainsAny(ACLTable, "r")) && !(acl.Insert && strings.ContainsAny(ACLTable, "c")) && !(acl.Update && strings.ContainsAny(ACLTable, "u")) {
+			return ErrUnauthorized
+		}
+	case "delete", "bulk-delete", "restore", "delete-preview":
 		if !acl.Delete || !strings.ContainsAny(ACLTable, "d") {
 			return ErrUnauthorized
 		}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"testing"
+
+	backendConfig "github.com/dwarvesf/smithy/backend/config"
+	"github.com/dwarvesf/smithy/backend/domain"
+	"github.com/dwarvesf/smithy/backend/service"
+	userSrv "github.com/dwarvesf/smithy/backend/service/user"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// permissionUserService return the same permission for every table
+type permissionUserService struct {
+	userSrv.Service
+	permission domain.Permission
+}
+
+func (s permissionUserService) GetPermissionUserAndGroup(p *domain.User, dbName string, tableName string) (*domain.Permission, error) {
+	per := s.permission
+	return &per, nil
+}
+
+func TestUserPermissions(t *testing.T) {
+	cfg := &backendConfig.Config{ModelMap: map[string]map[string]database.Model{
+		"fortress": {"users": {TableName: "users", ACL: "crud"}},
+	}}
+
+	tests := []struct {
+		name         string
+		permission   domain.Permission
+		wantRead     bool
+		wantQueryErr error
+	}{
+		{
+			name:       "select permission",
+			permission: domain.Permission{Select: true},
+			wantRead:   true,
+		},
+		{
+			name:         "without select permission columns can't be read",
+			permission:   domain.Permission{Insert: true},
+			wantRead:     false,
+			wantQueryErr: ErrUnauthorized,
+		},
+		{
+			name:         "hidden column",
+			permission:   domain.Permission{Select: true, Columns: []domain.ColumnPermission{{ColumnName: "name", Read: false}}},
+			wantRead:     false,
+			wantQueryErr: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := service.Service{UserService: permissionUserService{permission: tt.permission}}
+			p := newUserPermissions(cfg, s, "ann")
+
+			if got := p.CanRead("fortress", "users", "name"); got != tt.wantRead {
+				t.Errorf("CanRead() = %v, want %v", got, tt.wantRead)
+			}
+			if err := p.AuthorizeTable("fortress", "users", "query"); err != tt.wantQueryErr {
+				t.Errorf("AuthorizeTable() error = %v, want %v", err, tt.wantQueryErr)
+			}
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package backend
 
 import (
+	"context"
 	"errors"
 
 	backendConfig "github.com/dwarvesf/smithy/backend/config"
+	"github.com/dwarvesf/smithy/backend/hook"
 	"github.com/dwarvesf/smithy/backend/sqlmapper"
 	sqlmapperDrv "github.com/dwarvesf/smithy/backend/sqlmapper/drivers"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
 )
 
 // NewConfig check dashboard config is correct
@@ -30,16 +33,48 @@ func checkConfig(c *backendConfig.Config) error {
 
 // NewSQLMapper create new new sqlmapper to working with request query
 func NewSQLMapper(c *backendConfig.Config) (sqlmapper.Mapper, error) {
+	var (
+		m   sqlmapper.Mapper
+		err error
+	)
+
+	// hooks write rows with their own dblib, cached results of tables they write are removed too
+	var cache sqlmapper.CacheInvalidator
+	onWrite := func(_ context.Context, dbName, tableName string) {
+		if cache != nil {
+			cache.Invalidate(dbName, tableName)
+		}
+	}
+
 	switch c.DBType {
 	case "postgres":
-		return sqlmapperDrv.NewPGHookStore(
-			sqlmapperDrv.NewPGStore(c.DBs(), c.ModelMap),
+		m, err = sqlmapperDrv.NewPGHookStore(
+			sqlmapperDrv.NewPGStore(c.DBs, c.Replicas, c.ModelMap),
+			c.ModelMap,
+			c.DBs,
+			onWrite,
+		)
+	case backendConfig.MemoryDBType:
+		// rows are lost when dashboard stops, hooks read and write the same rows
+		db := memory.New(c.ModelMap)
+		m = sqlmapperDrv.NewHookStore(
+			sqlmapperDrv.NewMemoryStore(db, c.ModelMap),
 			c.ModelMap,
-			c.DBs(),
+			hook.NewAnkoScriptEngineWithLib(hook.NewNotifyLib(hook.NewMemoryLib(db, c.ModelMap), onWrite)),
 		)
 	default:
 		return nil, errors.New("uknown DB Driver")
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.QueryCacheSize > 0 {
+		m = sqlmapperDrv.NewCacheStore(m, c.ModelMap, c.QueryCacheSize)
+		cache = m.(sqlmapper.CacheInvalidator)
+	}
+
+	return m, nil
 }
 
 // SyncPersistent load available config in persistent
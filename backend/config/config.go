@@ -44,17 +44,57 @@ type Config struct {
 	AgentURL            string `yaml:"agent_url"`
 	PersistenceSupport  string `yaml:"persistence_support"`
 	PersistenceFileName string `yaml:"persistence_file_name"`
+	QueryMaxRows        int    `yaml:"query_max_rows"`   // max rows returned by a query or view, DefaultQueryMaxRows is used when it is 0
+	QueryCacheSize      int    `yaml:"query_cache_size"` // max results cached of queries and views, cache is disabled when it is 0
+
+	DBSettings map[string]DBSetting `yaml:"db_settings"` // settings of each database by db_name
 
 	database.ConnectionInfo `yaml:"-"`
 	Databases               []database.Database                  `yaml:"-" json:"databases_list,omitempty"`
 	ModelMap                map[string]map[string]database.Model `yaml:"-" json:"-"`
 	Version                 Version                              `yaml:"-" json:"version"`
 	db                      map[string]*gorm.DB
+	replicas                map[string]*Replicas
+	dbErrors                map[string]error // databases can't be connected
+	dbVersion               int              // increased when connections are reopened by UpdateDB
+	dbLease                 *dbLease         // requests started with current connections
+	retiredLeases           []*dbLease       // replaced connections still used by requests, oldest first
+	dbMu                    sync.RWMutex
 	Authentication          *Authentication `yaml:"authentication" json:"authentication"`
 
 	sync.Mutex `yaml:"-"`
 }
 
+// MemoryDBType db_type of databases kept in memory by sqlmapper, no connection is opened for them
+const MemoryDBType = "memory"
+
+// DefaultQueryMaxRows max rows returned by a query when query_max_rows isn't configured
+const DefaultQueryMaxRows = 100000
+
+// MaxRows return max rows returned by a query or view
+func (c *Config) MaxRows() int {
+	if c.QueryMaxRows <= 0 {
+		return DefaultQueryMaxRows
+	}
+
+	return c.QueryMaxRows
+}
+
+// DBSetting settings of a database connection in dashboard
+type DBSetting struct {
+	StatementTimeout int       `yaml:"statement_timeout"` // milliseconds, a longer statement is cancelled by database. No timeout when it is 0
+	Replicas         []Replica `yaml:"replicas"`          // read replicas, they are connected with user and password of primary
+	MaxOpenConns     int       `yaml:"max_open_conns"`    // max connections of pool, unlimited when it is 0
+	MaxIdleConns     int       `yaml:"max_idle_conns"`    // max idle connections of pool, default of database/sql is used when it is 0
+	ConnMaxLifetime  int       `yaml:"conn_max_lifetime"` // seconds, a connection is closed after it. Connections are reused forever when it is 0
+}
+
+// Replica address of a read replica
+type Replica struct {
+	DBHostname string `yaml:"db_hostname"`
+	DBPort     string `yaml:"db_port"`
+}
+
 // Version version of backend config
 type Version struct {
 	Checksum string    `json:"checksum"`
@@ -81,14 +121,50 @@ func (w *Wrapper) SyncConfig() *Config {
 
 // DB get db connection from config
 func (c *Config) DB(dbName string) *gorm.DB {
-	return c.db[dbName]
+	return c.DBs()[dbName]
 }
 
-// DBs get db connection from config
+// DBs get db connection from config, a database can't be connected isn't in map.
+// Map is replaced when connections are reopened, so it must not be kept
 func (c *Config) DBs() map[string]*gorm.DB {
+	c.dbMu.RLock()
+	defer c.dbMu.RUnlock()
 	return c.db
 }
 
+// Replicas get read replicas of databases from config, a database without replicas isn't in map
+func (c *Config) Replicas() map[string]*Replicas {
+	c.dbMu.RLock()
+	defer c.dbMu.RUnlock()
+	return c.replicas
+}
+
+// DBError return an UnavailableError when a database can't be connected
+func (c *Config) DBError(dbName string) error {
+	c.dbMu.RLock()
+	defer c.dbMu.RUnlock()
+	if err, ok := c.dbErrors[dbName]; ok {
+		return UnavailableError{DBName: dbName, Err: err}
+	}
+
+	return nil
+}
+
+// UnavailableError database can't be connected, other databases of dashboard still work
+type UnavailableError struct {
+	DBName string
+	Err    error
+}
+
+func (e UnavailableError) Error() string {
+	return fmt.Sprintf("database %s is unavailable: %v", e.DBName, e.Err)
+}
+
+// StatusCode .
+func (UnavailableError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
 // CheckSum to checksum md5 when agent-sync check version
 func (c *Config) CheckSum() (string, error) {
 	buff, err := json.Marshal(c)
@@ -197,7 +273,8 @@ func (c *Config) UpdateConfig(cfg *Config) error {
 		}
 	}
 
-	return c.UpdateDB()
+	c.UpdateDB()
+	return nil
 }
 
 // ChangeVersion get config in persistent by version number
@@ -211,23 +288,140 @@ func (c *Config) ChangeVersion(id int) error {
 	return c.UpdateConfig(cfg)
 }
 
-// UpdateDB update db connection
-func (c *Config) UpdateDB() error {
-	c.db = make(map[string]*gorm.DB)
+// UpdateDB open connections of databases, connections of previous config are closed once requests
+// using them are released, see AcquireDBs. A database can't be connected is marked unavailable instead
+// of failing the update, it is connected again by health check
+func (c *Config) UpdateDB() {
+	dbs := make(map[string]*gorm.DB)
+	replicas := make(map[string]*Replicas)
+	dbErrors := make(map[string]error)
+	conn := c.connector()
 	for i := range c.Databases {
-		newDB, err := c.openNewDBConnection(c.Databases[i].DBName)
+		if conn.DBType == MemoryDBType {
+			break
+		}
+
+		dbName := c.Databases[i].DBName
+		newDB, err := conn.open(dbName, c.DBHostname, c.DBPort)
 		if err != nil {
-			// TODO: add nicer error
-			return err
+			dbErrors[dbName] = err
+			continue
+		}
+		dbs[dbName] = newDB
+
+		if r := conn.openReplicas(dbName); r != nil {
+			replicas[dbName] = r
 		}
-		c.db[c.Databases[i].DBName] = newDB
 	}
 
-	return nil
+	c.dbMu.Lock()
+	old := c.currentLease()
+	old.dbs, old.replicas = c.db, c.replicas
+	c.retiredLeases = append(c.retiredLeases, old)
+	c.dbLease = &dbLease{}
+	c.db, c.replicas, c.dbErrors = dbs, replicas, dbErrors
+	c.dbVersion++
+	released := c.releasedLeases()
+	c.dbMu.Unlock()
+
+	for _, l := range released {
+		closeDBs(l.dbs, l.replicas)
+	}
+}
+
+// dbLease count requests started while a set of connections is current
+type dbLease struct {
+	refs     int
+	dbs      map[string]*gorm.DB // connections replaced by UpdateDB, set when lease is retired
+	replicas map[string]*Replicas
+}
+
+// AcquireDBs mark connections in use until release is called, ex: by a request streaming rows.
+// A request may read connections current at any time until it is released, so connections replaced
+// by UpdateDB are closed when every request started before they were replaced is released
+func (c *Config) AcquireDBs() (release func()) {
+	c.dbMu.Lock()
+	l := c.currentLease()
+	l.refs++
+	c.dbMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.dbMu.Lock()
+			l.refs--
+			released := c.releasedLeases()
+			c.dbMu.Unlock()
+
+			for _, l := range released {
+				closeDBs(l.dbs, l.replicas)
+			}
+		})
+	}
+}
+
+// currentLease return lease of current connections, dbMu must be locked
+func (c *Config) currentLease() *dbLease {
+	if c.dbLease == nil {
+		c.dbLease = &dbLease{}
+	}
+
+	return c.dbLease
+}
+
+// releasedLeases remove retired leases no request uses from oldest, a lease is kept while an older one
+// is used, requests of the older lease may have read its connections. dbMu must be locked
+func (c *Config) releasedLeases() []*dbLease {
+	res := []*dbLease{}
+	for len(c.retiredLeases) > 0 && c.retiredLeases[0].refs == 0 {
+		res = append(res, c.retiredLeases[0])
+		c.retiredLeases = c.retiredLeases[1:]
+	}
+
+	return res
+}
+
+// connector open connections with a copy of connection info, so it can be used
+// while config is updated
+type connector struct {
+	database.ConnectionInfo
+	settings map[string]DBSetting
+}
+
+func (c *Config) connector() connector {
+	return connector{ConnectionInfo: c.ConnectionInfo, settings: c.DBSettings}
+}
+
+// openReplicas connect to replicas of a database, an unreachable replica is skipped,
+// reads go to other replicas or primary
+func (c connector) openReplicas(dbName string) *Replicas {
+	dbs := []*gorm.DB{}
+	for _, r := range c.settings[dbName].Replicas {
+		if replica, err := c.open(dbName, r.DBHostname, r.DBPort); err == nil {
+			dbs = append(dbs, replica)
+		}
+	}
+	if len(dbs) == 0 {
+		return nil
+	}
+
+	return newReplicas(dbs)
+}
+
+// closeDBs close connection pools, close waits for running queries
+func closeDBs(dbs map[string]*gorm.DB, replicas map[string]*Replicas) {
+	for _, db := range dbs {
+		db.Close()
+	}
+	for _, r := range replicas {
+		for _, db := range r.dbs {
+			db.Close()
+		}
+	}
 }
 
 // TODO: extend for using mutiple DB
-func (c *Config) openNewDBConnection(dbName string) (*gorm.DB, error) {
+func (c connector) open(dbName, host, port string) (*gorm.DB, error) {
 	sslmode := "disable"
 	if c.DBSSLModeOption == "enable" {
 		sslmode = "require"
@@ -238,11 +432,32 @@ func (c *Config) openNewDBConnection(dbName string) (*gorm.DB, error) {
 		dbName,
 		sslmode,
 		c.DBPassword,
-		c.DBHostname,
-		c.DBPort,
+		host,
+		port,
 	)
 
-	return gorm.Open("postgres", dbstring)
+	// statement_timeout is sent as a run-time parameter, so it's applied to every connection of pool
+	setting := c.settings[dbName]
+	if setting.StatementTimeout > 0 {
+		dbstring += fmt.Sprintf(" statement_timeout=%d", setting.StatementTimeout)
+	}
+
+	db, err := gorm.Open("postgres", dbstring)
+	if err != nil {
+		return nil, err
+	}
+
+	if setting.MaxOpenConns > 0 {
+		db.DB().SetMaxOpenConns(setting.MaxOpenConns)
+	}
+	if setting.MaxIdleConns > 0 {
+		db.DB().SetMaxIdleConns(setting.MaxIdleConns)
+	}
+	if setting.ConnMaxLifetime > 0 {
+		db.DB().SetConnMaxLifetime(time.Duration(setting.ConnMaxLifetime) * time.Second)
+	}
+
+	return db, nil
 }
 
 // Authentication use to authenticate
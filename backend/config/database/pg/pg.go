@@ -42,6 +42,10 @@ func SeedCreateTable(db *gorm.DB) error {
 		domain.User{},
 		domain.Group{},
 		domain.Permission{},
+		domain.ColumnPermission{},
+		domain.RowFilter{},
+		domain.AuditLog{},
+		domain.Revision{},
 	).Error
 
 	if err != nil {
@@ -54,6 +58,12 @@ func SeedCreateTable(db *gorm.DB) error {
 	if err := db.Model(&domain.Permission{}).AddForeignKey("group_id", "groups(id)", "RESTRICT", "RESTRICT").Error; err != nil {
 		return err
 	}
+	if err := db.Model(&domain.ColumnPermission{}).AddForeignKey("permission_id", "permissions(id)", "CASCADE", "RESTRICT").Error; err != nil {
+		return err
+	}
+	if err := db.Model(&domain.RowFilter{}).AddForeignKey("permission_id", "permissions(id)", "CASCADE", "RESTRICT").Error; err != nil {
+		return err
+	}
 
 	if err := db.Model(&domain.UserGroup{}).AddForeignKey("group_id", "groups(id)", "RESTRICT", "RESTRICT").Error; err != nil {
 		return err
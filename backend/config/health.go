@@ -0,0 +1,100 @@
+package config
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// HealthCheckInterval time between health checks of databases and replicas
+const HealthCheckInterval = 10 * time.Second
+
+// pingTimeout max time waiting for a database to answer a ping
+const pingTimeout = 5 * time.Second
+
+func ping(db *gorm.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	return db.DB().PingContext(ctx)
+}
+
+// CheckDBs check health of databases every interval. A database failed to answer a ping is
+// unavailable until it answers again, a database can't be connected by UpdateDB is connected again.
+// Replicas failed to answer aren't used until they answer again
+func (c *Config) CheckDBs(interval time.Duration) {
+	for range time.Tick(interval) {
+		c.checkDBs()
+	}
+}
+
+func (c *Config) checkDBs() {
+	c.Lock()
+	databases := c.Databases
+	conn := c.connector()
+	c.Unlock()
+
+	c.dbMu.RLock()
+	dbs, replicas, version := c.db, c.replicas, c.dbVersion
+	c.dbMu.RUnlock()
+
+	// connections are opened and pinged without lock, requests keep using current connections
+	connected := make(map[string]*gorm.DB)
+	connectedReplicas := make(map[string]*Replicas)
+	dbErrors := make(map[string]error)
+	for _, d := range databases {
+		if conn.DBType == MemoryDBType {
+			break
+		}
+
+		db, ok := dbs[d.DBName]
+		if !ok {
+			newDB, err := conn.open(d.DBName, conn.DBHostname, conn.DBPort)
+			if err != nil {
+				dbErrors[d.DBName] = err
+				continue
+			}
+			connected[d.DBName] = newDB
+			if r := conn.openReplicas(d.DBName); r != nil {
+				connectedReplicas[d.DBName] = r
+			}
+			continue
+		}
+
+		if err := ping(db); err != nil {
+			dbErrors[d.DBName] = err
+		}
+	}
+
+	for _, r := range replicas {
+		r.check()
+	}
+
+	c.dbMu.Lock()
+	defer c.dbMu.Unlock()
+
+	// connections are reopened by UpdateDB during check, result of check is outdated
+	if version != c.dbVersion {
+		closeDBs(connected, connectedReplicas)
+		return
+	}
+
+	if len(connected) > 0 {
+		newDBs := make(map[string]*gorm.DB)
+		for k, v := range c.db {
+			newDBs[k] = v
+		}
+		replicas := make(map[string]*Replicas)
+		for k, v := range c.replicas {
+			replicas[k] = v
+		}
+		for k, v := range connected {
+			newDBs[k] = v
+		}
+		for k, v := range connectedReplicas {
+			replicas[k] = v
+		}
+		c.db, c.replicas = newDBs, replicas
+	}
+	c.dbErrors = dbErrors
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+func TestUpdateDBUnavailable(t *testing.T) {
+	c := &Config{
+		ConnectionInfo: database.ConnectionInfo{
+			DBUsername: "postgres",
+			DBHostname: "127.0.0.1",
+			DBPort:     "1",
+		},
+		Databases: []database.Database{{DBName: "fortress"}},
+	}
+
+	c.UpdateDB()
+
+	err := c.DBError("fortress")
+	if err == nil {
+		t.Fatalf("Config.DBError() = nil, want error of unreachable database")
+	}
+	if code := err.(UnavailableError).StatusCode(); code != http.StatusServiceUnavailable {
+		t.Errorf("UnavailableError.StatusCode() = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	if c.DB("fortress") != nil {
+		t.Errorf("Config.DB() of unavailable database isn't nil")
+	}
+	if err := c.DBError("other"); err != nil {
+		t.Errorf("Config.DBError() of other database = %v, want nil", err)
+	}
+}
+
+func TestAcquireDBs(t *testing.T) {
+	c := &Config{ConnectionInfo: database.ConnectionInfo{DBType: MemoryDBType}}
+
+	first := c.AcquireDBs()
+	c.UpdateDB()
+	// second request may read connections of either config
+	second := c.AcquireDBs()
+	c.UpdateDB()
+
+	if len(c.retiredLeases) != 2 {
+		t.Fatalf("retired leases = %d, want 2 while requests are running", len(c.retiredLeases))
+	}
+
+	second()
+	if len(c.retiredLeases) != 2 {
+		t.Errorf("retired leases = %d, want 2 while a request of oldest connections is running", len(c.retiredLeases))
+	}
+
+	first()
+	first()
+	if len(c.retiredLeases) != 0 {
+		t.Errorf("retired leases = %d, want 0 after requests are released", len(c.retiredLeases))
+	}
+	if c.dbLease.refs != 0 {
+		t.Errorf("refs of current lease = %d, want 0", c.dbLease.refs)
+	}
+}
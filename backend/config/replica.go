@@ -0,0 +1,63 @@
+package config
+
+import (
+	"sync/atomic"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Replicas read replicas of a database, reads are spread over healthy replicas by round robin
+type Replicas struct {
+	dbs     []*gorm.DB
+	healthy []int32 // 1 when replica answered its last ping
+	next    uint32
+}
+
+func newReplicas(dbs []*gorm.DB) *Replicas {
+	healthy := make([]int32, len(dbs))
+	for i := range healthy {
+		healthy[i] = 1
+	}
+
+	return &Replicas{dbs: dbs, healthy: healthy}
+}
+
+// Pick return next healthy replica, nil is returned when there is no healthy replica
+func (r *Replicas) Pick() *gorm.DB {
+	if r == nil {
+		return nil
+	}
+
+	start := atomic.AddUint32(&r.next, 1)
+	for i := 0; i < len(r.dbs); i++ {
+		idx := (int(start) + i) % len(r.dbs)
+		if atomic.LoadInt32(&r.healthy[idx]) == 1 {
+			return r.dbs[idx]
+		}
+	}
+
+	return nil
+}
+
+// MarkDown stop using a replica until it answers a ping of health check
+func (r *Replicas) MarkDown(db *gorm.DB) {
+	if r == nil {
+		return
+	}
+
+	for i := range r.dbs {
+		if r.dbs[i] == db {
+			atomic.StoreInt32(&r.healthy[i], 0)
+		}
+	}
+}
+
+func (r *Replicas) check() {
+	for i, db := range r.dbs {
+		var healthy int32
+		if ping(db) == nil {
+			healthy = 1
+		}
+		atomic.StoreInt32(&r.healthy[i], healthy)
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+func TestReplicasPick(t *testing.T) {
+	db1, db2 := &gorm.DB{}, &gorm.DB{}
+
+	tests := []struct {
+		name     string
+		replicas *Replicas
+		down     []*gorm.DB
+		want     []*gorm.DB
+	}{
+		{
+			name:     "round robin",
+			replicas: newReplicas([]*gorm.DB{db1, db2}),
+			want:     []*gorm.DB{db2, db1, db2},
+		},
+		{
+			name:     "skip replica marked down",
+			replicas: newReplicas([]*gorm.DB{db1, db2}),
+			down:     []*gorm.DB{db1},
+			want:     []*gorm.DB{db2, db2, db2},
+		},
+		{
+			name:     "no healthy replica",
+			replicas: newReplicas([]*gorm.DB{db1, db2}),
+			down:     []*gorm.DB{db1, db2},
+			want:     []*gorm.DB{nil},
+		},
+		{
+			name:     "database without replicas",
+			replicas: nil,
+			want:     []*gorm.DB{nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, db := range tt.down {
+				tt.replicas.MarkDown(db)
+			}
+
+			for i, want := range tt.want {
+				if got := tt.replicas.Pick(); got != want {
+					t.Errorf("Replicas.Pick() call %d = %p, want %p", i, got, want)
+				}
+			}
+		})
+	}
+}
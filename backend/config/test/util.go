@@ -30,9 +30,10 @@ func CreateModelList() []database.Model {
 			TableName: randTableName,
 			Columns: []database.Column{
 				{
-					Name:      "id",
-					Type:      "int",
-					IsPrimary: true,
+					Name:        "id",
+					Type:        "int",
+					IsPrimary:   true,
+					IsGenerated: true,
 				},
 				{
 					Name:       "name",
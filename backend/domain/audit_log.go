@@ -0,0 +1,11 @@
+package domain
+
+// AuditLog record an action of a user on a database
+type AuditLog struct {
+	Model
+	Username     string `yaml:"username" json:"username"`
+	Action       string `yaml:"action" json:"action"`
+	DatabaseName string `yaml:"database" json:"database"`
+	TableName    string `yaml:"table" json:"table"`
+	Detail       string `yaml:"detail" json:"detail"`
+}
@@ -6,6 +6,7 @@ type Group struct {
 	Name        string       `yaml:"name" json:"name"`
 	Description string       `yaml:"description" json:"description"`
 	Role        string       `yaml:"role" json:"role"`
+	Unmask      bool         `yaml:"unmask" json:"unmask"` // members see values of masked columns
 	Users       []User       `gorm:"many2many:user_groups;" yaml:"-" json:"-"`
 	Permissions []Permission `yaml:"-" json:"-"`
 }
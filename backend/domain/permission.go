@@ -3,14 +3,26 @@ package domain
 // Permission .
 type Permission struct {
 	Model
-	DatabaseName string `yaml:"database" json:"database"`
-	TableName    string `yaml:"table" json:"table"`
-	Select       bool   `yaml:"select" json:"select"`
-	Insert       bool   `yaml:"insert" json:"insert"`
-	Update       bool   `yaml:"update" json:"update"`
-	Delete       bool   `yaml:"delete" json:"delete"`
-	UserID       UUID   `yaml:"user_id" json:"user_id"`
-	GroupID      UUID   `yaml:"group_id" json:"group_id"`
+	DatabaseName string             `yaml:"database" json:"database"`
+	TableName    string             `yaml:"table" json:"table"`
+	Select       bool               `yaml:"select" json:"select"`
+	Insert       bool               `yaml:"insert" json:"insert"`
+	Update       bool               `yaml:"update" json:"update"`
+	Delete       bool               `yaml:"delete" json:"delete"`
+	UserID       UUID               `yaml:"user_id" json:"user_id"`
+	GroupID      UUID               `yaml:"group_id" json:"group_id"`
+	Columns      []ColumnPermission `yaml:"columns" json:"columns"`
+	RowFilters   []RowFilter        `yaml:"row_filters" json:"row_filters"`
+}
+
+// ColumnPermission grant of a column of table in a permission, a column without grant
+// can be read and written as table permission allows
+type ColumnPermission struct {
+	Model
+	PermissionID UUID   `yaml:"-" json:"permission_id"`
+	ColumnName   string `yaml:"column" json:"column"`
+	Read         bool   `yaml:"read" json:"read"`
+	Write        bool   `yaml:"write" json:"write"`
 }
 
 // AND .
@@ -19,5 +31,46 @@ func (p Permission) AND(q Permission) Permission {
 	p.Insert = p.Insert && q.Insert
 	p.Update = p.Update && q.Update
 	p.Delete = p.Delete && q.Delete
+
+	columns := []ColumnPermission{}
+	existed := make(map[string]bool)
+	for _, c := range append(append([]ColumnPermission{}, p.Columns...), q.Columns...) {
+		if existed[c.ColumnName] {
+			continue
+		}
+		existed[c.ColumnName] = true
+		columns = append(columns, ColumnPermission{
+			ColumnName: c.ColumnName,
+			Read:       p.CanRead(c.ColumnName) && q.CanRead(c.ColumnName),
+			Write:      p.CanWrite(c.ColumnName) && q.CanWrite(c.ColumnName),
+		})
+	}
+	p.Columns = columns
+
+	// a row must match filters of user and all groups
+	p.RowFilters = append(append([]RowFilter{}, p.RowFilters...), q.RowFilters...)
+
 	return p
 }
+
+// CanRead check column isn't hidden by a column grant
+func (p Permission) CanRead(column string) bool {
+	for _, c := range p.Columns {
+		if c.ColumnName == column {
+			return c.Read
+		}
+	}
+
+	return true
+}
+
+// CanWrite check column isn't made read-only by a column grant
+func (p Permission) CanWrite(column string) bool {
+	for _, c := range p.Columns {
+		if c.ColumnName == column {
+			return c.Write
+		}
+	}
+
+	return true
+}
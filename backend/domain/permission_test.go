@@ -0,0 +1,66 @@
+package domain
+
+import "testing"
+
+func TestPermissionAND(t *testing.T) {
+	user := Permission{Select: true, Update: true, Columns: []ColumnPermission{
+		{ColumnName: "salary", Read: false, Write: false},
+		{ColumnName: "email", Read: true, Write: true},
+	}}
+	group := Permission{Select: true, Update: false, Columns: []ColumnPermission{
+		{ColumnName: "email", Read: true, Write: false},
+	}}
+
+	p := user.AND(group)
+
+	tests := []struct {
+		column    string
+		wantRead  bool
+		wantWrite bool
+	}{
+		{column: "salary", wantRead: false, wantWrite: false},
+		{column: "email", wantRead: true, wantWrite: false},
+		{column: "name", wantRead: true, wantWrite: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.column, func(t *testing.T) {
+			if got := p.CanRead(tt.column); got != tt.wantRead {
+				t.Errorf("Permission.CanRead() = %v, want %v", got, tt.wantRead)
+			}
+			if got := p.CanWrite(tt.column); got != tt.wantWrite {
+				t.Errorf("Permission.CanWrite() = %v, want %v", got, tt.wantWrite)
+			}
+		})
+	}
+
+	if !p.Select || p.Update {
+		t.Errorf("Permission.AND() = select %v update %v, want select true update false", p.Select, p.Update)
+	}
+}
+
+func TestRowFilterResolve(t *testing.T) {
+	u := &User{Username: "alice", Claims: Claims{"region": "north"}}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "username", value: "{{username}}", want: "alice"},
+		{name: "claim", value: "{{ claims.region }}", want: "north"},
+		{name: "constant", value: "active", want: "active"},
+		{name: "unknown claim", value: "{{claims.team}}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RowFilter{ColumnName: "c", Value: tt.value}.Resolve(u)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RowFilter.Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("RowFilter.Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package domain
+
+// Actions of a revision
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+)
+
+// Revision changes of a record written through sqlmapper, only changed columns are kept
+type Revision struct {
+	Model
+	DatabaseName string   `json:"database"`
+	TableName    string   `json:"table"`
+	RecordKey    string   `json:"record_key"` // json of primary columns of the record
+	Action       string   `json:"action"`
+	Username     string   `json:"username"`
+	Before       JSONText `sql:"type:text" json:"before"`
+	After        JSONText `sql:"type:text" json:"after"`
+}
+
+// JSONText json stored as text, it is encoded as raw json
+type JSONText string
+
+// MarshalJSON encode json text as it is
+func (j JSONText) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+
+	return []byte(j), nil
+}
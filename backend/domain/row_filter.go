@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RowFilter limit rows of a table in a permission to rows whose column equals value. Value can
+// reference attributes of user: {{username}}, {{role}}, {{email}} or {{claims.<name>}}
+type RowFilter struct {
+	Model
+	PermissionID UUID   `yaml:"-" json:"permission_id"`
+	ColumnName   string `yaml:"column" json:"column"`
+	Value        string `yaml:"value" json:"value"`
+}
+
+var userAttributeRegexp = regexp.MustCompile(`{{\s*([\w.]+)\s*}}`)
+
+// Resolve return value of filter for user, an unknown attribute is an error,
+// so a row filter never matches rows by mistake
+func (f RowFilter) Resolve(u *User) (string, error) {
+	var err error
+	res := userAttributeRegexp.ReplaceAllStringFunc(f.Value, func(s string) string {
+		name := userAttributeRegexp.FindStringSubmatch(s)[1]
+		v, ok := u.Attribute(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("unknown user attribute %s in row filter of column %s", name, f.ColumnName)
+		}
+		return v
+	})
+
+	return res, err
+}
+
+// Attribute get attribute of user referenced by a row filter
+func (u *User) Attribute(name string) (string, bool) {
+	switch name {
+	case "username":
+		return u.Username, true
+	case "role":
+		return u.Role, true
+	case "email":
+		return u.Email, true
+	}
+
+	if strings.HasPrefix(name, "claims.") {
+		v, ok := u.Claims[strings.TrimPrefix(name, "claims.")]
+		return v, ok
+	}
+
+	return "", false
+}
@@ -1,6 +1,10 @@
 package domain
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
 	"github.com/jinzhu/gorm"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -16,6 +20,7 @@ type User struct {
 	Role           string       `yaml:"role" json:"role"`
 	Email          string       `yaml:"email" json:"email"`
 	ConfirmCode    string       `yaml:"confirm_code" json:"confirm_code"`
+	Claims         Claims       `sql:"type:text" yaml:"claims" json:"claims"` // custom attributes referenced by row filters
 	Groups         []Group      `gorm:"many2many:user_groups;" yaml:"-" json:"-"`
 	Permissions    []Permission `yaml:"-" json:"-"`
 }
@@ -37,3 +42,34 @@ func (u *User) BeforeSave(scope *gorm.Scope) error {
 
 	return nil
 }
+
+// Claims custom attributes of a user, ex: region of a regional manager
+type Claims map[string]string
+
+// Value .
+func (c Claims) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(c)
+	return string(b), err
+}
+
+// Scan .
+func (c *Claims) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("can't scan %T into claims", src)
+	}
+
+	return json.Unmarshal(b, c)
+}
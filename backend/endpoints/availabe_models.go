@@ -6,6 +6,7 @@ import (
 	"github.com/go-kit/kit/endpoint"
 
 	"github.com/dwarvesf/smithy/backend/service"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
 	"github.com/dwarvesf/smithy/common/database"
 )
 
@@ -32,7 +33,7 @@ type AvailableModelsResponse struct {
 
 func makeAvailableModelsEndpoint(s service.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		data := s.SyncConfig().Databases
+		data := permittedModels(ctx, s.SyncConfig().Databases)
 
 		return AvailableModelsResponse{
 			Status:             "success",
@@ -41,3 +42,27 @@ func makeAvailableModelsEndpoint(s service.Service) endpoint.Endpoint {
 			AvailableHookTypes: availableHookTypes}, nil
 	}
 }
+
+// permittedModels copy databases with columns user can't read removed,
+// columns user can't write are marked read-only
+func permittedModels(ctx context.Context, dbs []database.Database) []database.Database {
+	res := make([]database.Database, len(dbs))
+	for i, db := range dbs {
+		res[i] = db
+		res[i].ModelList = make([]database.Model, len(db.ModelList))
+		for j, m := range db.ModelList {
+			cols := []database.Column{}
+			for _, col := range m.Columns {
+				if !sqlmapper.CanRead(ctx, db.DBName, m.TableName, col.Name) {
+					continue
+				}
+				col.ReadOnly = col.ReadOnly || (!col.IsPrimary && !sqlmapper.CanWrite(ctx, db.DBName, m.TableName, col.Name))
+				cols = append(cols, col)
+			}
+			m.Columns = cols
+			res[i].ModelList[j] = m
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,27 @@
+package endpoints
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/dwarvesf/smithy/backend/service"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// CacheStatsResponse response for cache stats
+type CacheStatsResponse struct {
+	Enabled bool `json:"enabled"`
+	sqlmapper.CacheStats
+}
+
+func makeCacheStatsEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		r, ok := s.Mapper.(sqlmapper.CacheStatsReporter)
+		if !ok {
+			return CacheStatsResponse{Enabled: false}, nil
+		}
+
+		return CacheStatsResponse{Enabled: true, CacheStats: r.CacheStats()}, nil
+	}
+}
@@ -0,0 +1,116 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/dwarvesf/smithy/backend/service"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// DBBulkRequest request for db bulk create, update or delete data
+type DBBulkRequest struct {
+	TableName    string          `json:"-"`
+	DatabaseName string          `json:"-"`
+	Mode         string          `json:"mode"` // "all_or_nothing" (default) or "best_effort"
+	Fields       []interface{}   `json:"fields"`
+	Rows         [][]interface{} `json:"rows"`
+}
+
+// DBBulkResponse response for db bulk create, update or delete data
+type DBBulkResponse struct {
+	Status  string                `json:"status"`
+	Results sqlmapper.BulkResults `json:"results"`
+}
+
+func (r DBBulkRequest) rowDatas() ([]sqlmapper.RowData, error) {
+	res := []sqlmapper.RowData{}
+	for _, row := range r.Rows {
+		rowData, err := sqlmapper.MakeRowData(r.Fields, row)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rowData)
+	}
+
+	return res, nil
+}
+
+func makeDBBulkResponse(results sqlmapper.BulkResults) DBBulkResponse {
+	status := "failed"
+	if results.IsSuccess() {
+		status = "success"
+	} else {
+		for _, r := range results {
+			if r.Status == sqlmapper.BulkStatusSuccess {
+				status = "partial"
+				break
+			}
+		}
+	}
+
+	return DBBulkResponse{status, results}
+}
+
+func makeDBBulkCreateEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBBulkRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+		s := s.WithRevisions(userNameFromContext(ctx))
+
+		rows, err := req.rowDatas()
+		if err != nil {
+			return nil, err
+		}
+
+		results, err := s.BulkCreate(ctx, req.DatabaseName, req.TableName, rows, req.Mode)
+		if err != nil {
+			return nil, err
+		}
+
+		return makeDBBulkResponse(results), nil
+	}
+}
+
+func makeDBBulkUpdateEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBBulkRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+		s := s.WithRevisions(userNameFromContext(ctx))
+
+		rows, err := req.rowDatas()
+		if err != nil {
+			return nil, err
+		}
+
+		results, err := s.BulkUpdate(ctx, req.DatabaseName, req.TableName, rows, req.Mode)
+		if err != nil {
+			return nil, err
+		}
+
+		return makeDBBulkResponse(results), nil
+	}
+}
+
+func makeDBBulkDeleteEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBBulkRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+		s := s.WithRevisions(userNameFromContext(ctx))
+
+		results, err := s.BulkDelete(ctx, req.DatabaseName, req.TableName, req.Fields, req.Rows, req.Mode)
+		if err != nil {
+			return nil, err
+		}
+
+		return makeDBBulkResponse(results), nil
+	}
+}
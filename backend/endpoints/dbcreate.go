@@ -30,13 +30,14 @@ func makeDBCreateEndpoint(s service.Service) endpoint.Endpoint {
 		if !ok {
 			return nil, errors.New("failed to make type assertion")
 		}
+		s := s.WithRevisions(userNameFromContext(ctx))
 
 		rowData, err := sqlmapper.MakeRowData(req.Fields, req.Data)
 		if err != nil {
 			return nil, err
 		}
 
-		data, err := s.Create(req.DatabaseName, req.TableName, rowData)
+		data, err := s.Create(ctx, req.DatabaseName, req.TableName, rowData)
 		if err != nil {
 			return nil, err
 		}
@@ -7,6 +7,7 @@ import (
 	"github.com/go-kit/kit/endpoint"
 
 	"github.com/dwarvesf/smithy/backend/service"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
 )
 
 type deleteFilter struct {
@@ -32,11 +33,62 @@ func makeDBDeleteEndpoint(s service.Service) endpoint.Endpoint {
 		if !ok {
 			return nil, errors.New("failed to make type assertion")
 		}
+		s := s.WithRevisions(userNameFromContext(ctx))
 
-		if err := s.Delete(req.DatabaseName, req.TableName, req.Filter.Fields, req.Filter.Data); err != nil {
+		if err := s.Delete(ctx, req.DatabaseName, req.TableName, req.Filter.Fields, req.Filter.Data); err != nil {
 			return nil, err
 		}
 
 		return DBDeleteResponse{"success"}, nil
 	}
 }
+
+// DBRestoreRequest request for db restore soft-deleted data
+type DBRestoreRequest struct {
+	TableName    string       `json:"-"`
+	DatabaseName string       `json:"-"`
+	Filter       deleteFilter `json:"filter"`
+}
+
+// DBRestoreResponse response for db restore soft-deleted data
+type DBRestoreResponse struct {
+	Status string `json:"status"`
+}
+
+func makeDBRestoreEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBRestoreRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+		s := s.WithRevisions(userNameFromContext(ctx))
+
+		if err := s.Restore(ctx, req.DatabaseName, req.TableName, req.Filter.Fields, req.Filter.Data); err != nil {
+			return nil, err
+		}
+
+		return DBRestoreResponse{"success"}, nil
+	}
+}
+
+// DBDeletePreviewResponse response for db delete preview
+type DBDeletePreviewResponse struct {
+	Status string                 `json:"status"`
+	Data   sqlmapper.DeleteImpact `json:"data"`
+}
+
+func makeDBDeletePreviewEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBDeleteRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+
+		impact, err := s.DeletePreview(ctx, req.DatabaseName, req.TableName, req.Filter.Fields, req.Filter.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		return DBDeletePreviewResponse{"success", impact}, nil
+	}
+}
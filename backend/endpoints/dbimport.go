@@ -0,0 +1,78 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-kit/kit/endpoint"
+
+	jwtAuth "github.com/dwarvesf/smithy/backend/auth"
+	"github.com/dwarvesf/smithy/backend/importer"
+	"github.com/dwarvesf/smithy/backend/service"
+)
+
+// DBImportRequest request for importing a csv or ndjson file into a table
+type DBImportRequest struct {
+	TableName    string
+	DatabaseName string
+	File         io.ReadCloser
+	importer.Options
+}
+
+// DBImportResponse response for importing a file into a table
+type DBImportResponse struct {
+	Status  string           `json:"status"`
+	Summary importer.Summary `json:"summary"`
+}
+
+func makeDBImportEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBImportRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+		s := s.WithRevisions(userNameFromContext(ctx))
+		defer req.File.Close()
+
+		// rows are inserted or updated depend on mode, so permission is checked here
+		methods := []string{"create"}
+		switch req.Mode {
+		case importer.ModeUpdate:
+			methods = []string{"update"}
+		case importer.ModeUpsert:
+			methods = []string{"create", "update"}
+		}
+
+		userName := userNameFromContext(ctx)
+
+		cfg := s.SyncConfig()
+		for _, method := range methods {
+			if err := jwtAuth.AuthorizeTable(cfg, s, userName, req.DatabaseName, req.TableName, method); err != nil {
+				return nil, err
+			}
+		}
+
+		model, ok := cfg.ModelMap[req.DatabaseName][req.TableName]
+		if !ok {
+			return nil, fmt.Errorf("uknown database_name/table_name %s/%s", req.DatabaseName, req.TableName)
+		}
+
+		// rows written before an error are returned with it as importer.Error
+		summary, err := importer.Import(ctx, s.Mapper, req.DatabaseName, model, req.File, req.Options)
+		if err != nil {
+			return nil, err
+		}
+
+		status := "success"
+		if summary.Failed > 0 {
+			status = "failed"
+			if summary.Inserted+summary.Updated > 0 {
+				status = "partial"
+			}
+		}
+
+		return DBImportResponse{status, summary}, nil
+	}
+}
@@ -0,0 +1,117 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-kit/kit/endpoint"
+
+	jwtAuth "github.com/dwarvesf/smithy/backend/auth"
+	"github.com/dwarvesf/smithy/backend/service"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+const (
+	defaultLookupLimit = 20
+	maxLookupLimit     = 100
+)
+
+// DBLookupRequest request searching rows referenced by a foreign key column
+type DBLookupRequest struct {
+	DatabaseName string
+	TableName    string
+	Column       string // foreign key column
+	Search       string // matched with name_display_column of referenced table
+	Limit        int
+}
+
+// LookupOption a row which can be referenced, labeled by name_display_column of its table
+type LookupOption struct {
+	ID    interface{} `json:"id"`
+	Label interface{} `json:"label"`
+}
+
+// DBLookupResponse response for lookup
+type DBLookupResponse struct {
+	Status  string         `json:"status"`
+	Options []LookupOption `json:"options"`
+}
+
+func makeDBLookupEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBLookupRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+
+		cfg := s.SyncConfig()
+		fk, labelColumn, err := sqlmapper.LabelSource(cfg.ModelMap[req.DatabaseName], req.TableName, req.Column)
+		if err != nil {
+			return nil, err
+		}
+
+		// labels are rows of referenced table, user must be able to read it
+		if err := jwtAuth.AuthorizeTable(cfg, s, userNameFromContext(ctx), req.DatabaseName, fk.Table, "query"); err != nil {
+			return nil, err
+		}
+
+		limit := req.Limit
+		if limit <= 0 {
+			limit = defaultLookupLimit
+		}
+		if limit > maxLookupLimit {
+			limit = maxLookupLimit
+		}
+
+		q := sqlmapper.Query{
+			SourceDatabase: req.DatabaseName,
+			SourceTable:    fk.Table,
+			Fields:         []string{fk.ForeignColumn, labelColumn},
+			Order:          []string{labelColumn, "asc"},
+			Limit:          limit,
+		}
+		// a masked label can't be sorted or searched, sorting would reveal its value
+		if sqlmapper.MaskColumns(ctx, cfg.ModelMap[req.DatabaseName][fk.Table].Columns, []string{labelColumn}) != nil {
+			q.Order = []string{fk.ForeignColumn, "asc"}
+		}
+
+		// labels starting with search come first, then labels containing it
+		patterns := []string{""}
+		if req.Search != "" {
+			search := sqlmapper.EscapeLike(req.Search)
+			patterns = []string{search + "%", "%" + search + "%"}
+		}
+
+		options := []LookupOption{}
+		existed := make(map[string]bool)
+		for _, pattern := range patterns {
+			if pattern != "" {
+				q.Filter = sqlmapper.Filter{Operator: "ilike", ColumnName: labelColumn, Value: pattern}
+			}
+
+			_, data, err := s.Query(ctx, q)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, d := range data {
+				row := d.([]interface{})
+				if existed[fmt.Sprint(row[0])] || len(options) >= limit {
+					continue
+				}
+				existed[fmt.Sprint(row[0])] = true
+				options = append(options, LookupOption{ID: row[0], Label: row[1]})
+			}
+
+			if len(options) >= limit {
+				break
+			}
+		}
+
+		return DBLookupResponse{
+			Status:  "success",
+			Options: options,
+		}, nil
+	}
+}
@@ -6,9 +6,9 @@ import (
 
 	"github.com/go-kit/kit/endpoint"
 
+	jwtAuth "github.com/dwarvesf/smithy/backend/auth"
 	"github.com/dwarvesf/smithy/backend/service"
 	"github.com/dwarvesf/smithy/backend/sqlmapper"
-	"github.com/dwarvesf/smithy/common/database"
 )
 
 // DBQueryRequest request for db query
@@ -16,14 +16,6 @@ type DBQueryRequest struct {
 	sqlmapper.Query
 }
 
-// DBQueryResponse response for db query
-type DBQueryResponse struct {
-	Status  string            `json:"status"`
-	Columns []string          `json:"columns,omitempty"`
-	Rows    []interface{}     `json:"rows,omitempty"`
-	Cols    []database.Column `json:"cols,omitempty"`
-}
-
 func makeDBQueryEndpoint(s service.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req, ok := request.(DBQueryRequest)
@@ -31,21 +23,39 @@ func makeDBQueryEndpoint(s service.Service) endpoint.Endpoint {
 			return nil, errors.New("failed to make type assertion")
 		}
 
-		columnMeta, err := s.ColumnMetadata(req.Query)
-		if err != nil {
+		if err := authorizeLabels(ctx, s, req.Query); err != nil {
 			return nil, err
 		}
 
-		columns, data, err := s.Query(req.Query)
-		if err != nil {
-			return nil, err
+		// 1 row more than max rows is read, so response can tell the result is truncated
+		q := req.Query
+		if maxRows := s.SyncConfig().MaxRows(); q.Limit <= 0 || q.Limit > maxRows {
+			q.Limit = maxRows + 1
 		}
 
-		return DBQueryResponse{
-			Status:  "success",
-			Columns: columns,
-			Rows:    data,
-			Cols:    columnMeta,
+		// rows are streamed while response is encoded
+		return StreamResponse{
+			Stream: func(w sqlmapper.RowWriter) error {
+				return s.StreamQuery(ctx, q, w)
+			},
+			Include: q.IncludeFields(),
 		}, nil
 	}
 }
+
+// authorizeLabels check user can read tables labeling foreign keys of q
+func authorizeLabels(ctx context.Context, s service.Service, q sqlmapper.Query) error {
+	cfg := s.SyncConfig()
+	for _, col := range q.Labels {
+		fk, _, err := sqlmapper.LabelSource(cfg.ModelMap[q.SourceDatabase], q.SourceTable, col)
+		if err != nil {
+			return err
+		}
+
+		if err := jwtAuth.AuthorizeTable(cfg, s, userNameFromContext(ctx), q.SourceDatabase, fk.Table, "query"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
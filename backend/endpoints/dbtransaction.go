@@ -0,0 +1,54 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kit/kit/endpoint"
+
+	jwtAuth "github.com/dwarvesf/smithy/backend/auth"
+	"github.com/dwarvesf/smithy/backend/service"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// DBTransactionRequest request for running operations in a transaction
+type DBTransactionRequest struct {
+	DatabaseName string                `json:"-"`
+	Operations   []sqlmapper.Operation `json:"operations"`
+}
+
+// DBTransactionResponse response for running operations in a transaction
+type DBTransactionResponse struct {
+	Status  string              `json:"status"`
+	Results []sqlmapper.RowData `json:"results"`
+}
+
+func makeDBTransactionEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBTransactionRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+		s := s.WithRevisions(userNameFromContext(ctx))
+
+		if len(req.Operations) == 0 {
+			return nil, errors.New("missing operations")
+		}
+
+		userName := userNameFromContext(ctx)
+
+		// every operation is authorized before anything is written
+		for i, op := range req.Operations {
+			if err := jwtAuth.AuthorizeTable(s.SyncConfig(), s, userName, req.DatabaseName, op.TableName, op.Type); err != nil {
+				return nil, sqlmapper.OperationError{Index: i, Type: op.Type, TableName: op.TableName, Err: err}
+			}
+		}
+
+		results, err := s.Transaction(ctx, req.DatabaseName, req.Operations)
+		if err != nil {
+			return nil, err
+		}
+
+		return DBTransactionResponse{"success", results}, nil
+	}
+}
@@ -3,6 +3,8 @@ package endpoints
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 
 	"github.com/go-kit/kit/endpoint"
 
@@ -14,6 +16,7 @@ import (
 type DBUpdateRequest struct {
 	TableName    string        `json:"-"`
 	DatabaseName string        `json:"-"`
+	IfMatch      string        `json:"-"` // expected version of a table having version_column
 	Fields       []interface{} `json:"fields"`
 	Data         []interface{} `json:"data"`
 }
@@ -22,6 +25,17 @@ type DBUpdateRequest struct {
 type DBUpdateResponse struct {
 	Status string            `json:"status"`
 	Data   sqlmapper.RowData `json:"data"`
+	ETag   string            `json:"-"`
+}
+
+// Headers return ETag header holding new version of updated row
+func (r DBUpdateResponse) Headers() http.Header {
+	h := http.Header{}
+	if r.ETag != "" {
+		h.Set("ETag", r.ETag)
+	}
+
+	return h
 }
 
 func makeDBUpdateEndpoint(s service.Service) endpoint.Endpoint {
@@ -30,6 +44,7 @@ func makeDBUpdateEndpoint(s service.Service) endpoint.Endpoint {
 		if !ok {
 			return nil, errors.New("failed to make type assertion")
 		}
+		s := s.WithRevisions(userNameFromContext(ctx))
 
 		var err error
 		rowData, err := sqlmapper.MakeRowData(req.Fields, req.Data)
@@ -37,11 +52,21 @@ func makeDBUpdateEndpoint(s service.Service) endpoint.Endpoint {
 			return nil, err
 		}
 
-		data, err := s.Update(req.DatabaseName, req.TableName, rowData)
+		versionColumn := s.SyncConfig().ModelMap[req.DatabaseName][req.TableName].VersionColumn
+		if versionColumn != "" && req.IfMatch != "" {
+			rowData[versionColumn] = sqlmapper.ColData{Data: req.IfMatch}
+		}
+
+		data, err := s.Update(ctx, req.DatabaseName, req.TableName, rowData)
 		if err != nil {
 			return nil, err
 		}
 
-		return DBUpdateResponse{"success", data}, nil
+		res := DBUpdateResponse{Status: "success", Data: data}
+		if version, ok := data[versionColumn]; ok && versionColumn != "" {
+			res.ETag = fmt.Sprintf(`"%v"`, version.Data)
+		}
+
+		return res, nil
 	}
 }
@@ -0,0 +1,55 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/dwarvesf/smithy/backend/service"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// DBUpsertRequest request for db upsert data
+type DBUpsertRequest struct {
+	TableName       string        `json:"-"`
+	DatabaseName    string        `json:"-"`
+	Fields          []interface{} `json:"fields"`
+	Data            []interface{} `json:"data"`
+	ConflictColumns []string      `json:"conflict_columns"` // primary columns are used when it is empty
+	IfMatch         string        `json:"-"`                // expected version of an existing row of a table having version_column
+}
+
+// DBUpsertResponse response for db upsert data
+type DBUpsertResponse struct {
+	Status string            `json:"status"`
+	Action string            `json:"action"` // "inserted" or "updated"
+	Data   sqlmapper.RowData `json:"data"`
+}
+
+func makeDBUpsertEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBUpsertRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+		s := s.WithRevisions(userNameFromContext(ctx))
+
+		rowData, err := sqlmapper.MakeRowData(req.Fields, req.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		versionColumn := s.SyncConfig().ModelMap[req.DatabaseName][req.TableName].VersionColumn
+		if versionColumn != "" && req.IfMatch != "" {
+			rowData[versionColumn] = sqlmapper.ColData{Data: req.IfMatch}
+		}
+
+		data, action, err := s.Upsert(ctx, req.DatabaseName, req.TableName, rowData, req.ConflictColumns)
+		if err != nil {
+			return nil, err
+		}
+
+		return DBUpsertResponse{"success", action, data}, nil
+	}
+}
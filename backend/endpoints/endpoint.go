@@ -1,6 +1,9 @@
 package endpoints
 
 import (
+	"context"
+
+	"github.com/go-chi/jwtauth"
 	"github.com/go-kit/kit/endpoint"
 
 	endpointGroup "github.com/dwarvesf/smithy/backend/endpoints/group"
@@ -15,13 +18,28 @@ type Endpoints struct {
 	AvailableModels endpoint.Endpoint
 	AddHook         endpoint.Endpoint
 	DBQuery         endpoint.Endpoint
+	DBExport        endpoint.Endpoint
+	DBImport        endpoint.Endpoint
 	DBCreate        endpoint.Endpoint
 	DBUpdate        endpoint.Endpoint
 	DBDelete        endpoint.Endpoint
+	DBBulkCreate    endpoint.Endpoint
+	DBBulkUpdate    endpoint.Endpoint
+	DBBulkDelete    endpoint.Endpoint
+	DBUpsert        endpoint.Endpoint
+	DBRestore       endpoint.Endpoint
+	DBDeletePreview endpoint.Endpoint
+	DBTransaction   endpoint.Endpoint
+	DBLookup        endpoint.Endpoint
 	ListVersion     endpoint.Endpoint
 	RevertVersion   endpoint.Endpoint
 	Login           endpoint.Endpoint
 	ChangePassword  endpoint.Endpoint
+	CacheStats      endpoint.Endpoint
+
+	DBRevisionList    endpoint.Endpoint
+	DBRevisionDiff    endpoint.Endpoint
+	DBRevisionRestore endpoint.Endpoint
 
 	FindAccount   endpoint.Endpoint
 	SendEmail     endpoint.Endpoint
@@ -32,6 +50,7 @@ type Endpoints struct {
 	ViewList    endpoint.Endpoint
 	ViewDelete  endpoint.Endpoint
 	ViewExecute endpoint.Endpoint
+	ViewExport  endpoint.Endpoint
 
 	GroupCreate  endpoint.Endpoint
 	GroupFind    endpoint.Endpoint
@@ -48,6 +67,8 @@ type Endpoints struct {
 	PermissionFindByGroup endpoint.Endpoint
 	PermissionFindByUser  endpoint.Endpoint
 	PermissionUpdate      endpoint.Endpoint
+	PermissionColumns     endpoint.Endpoint
+	PermissionRowFilters  endpoint.Endpoint
 }
 
 // MakeServerEndpoints returns an Endpoints struct
@@ -55,24 +76,40 @@ func MakeServerEndpoints(s service.Service) Endpoints {
 	return Endpoints{
 		AgentSync:       makeAgentSyncEndpoint(s),
 		DBQuery:         makeDBQueryEndpoint(s),
+		DBExport:        makeDBExportEndpoint(s),
+		DBImport:        makeDBImportEndpoint(s),
 		DBCreate:        makeDBCreateEndpoint(s),
 		DBUpdate:        makeDBUpdateEndpoint(s),
 		DBDelete:        makeDBDeleteEndpoint(s),
+		DBBulkCreate:    makeDBBulkCreateEndpoint(s),
+		DBBulkUpdate:    makeDBBulkUpdateEndpoint(s),
+		DBBulkDelete:    makeDBBulkDeleteEndpoint(s),
+		DBUpsert:        makeDBUpsertEndpoint(s),
+		DBRestore:       makeDBRestoreEndpoint(s),
+		DBDeletePreview: makeDBDeletePreviewEndpoint(s),
+		DBTransaction:   makeDBTransactionEndpoint(s),
+		DBLookup:        makeDBLookupEndpoint(s),
 		AvailableModels: makeAvailableModelsEndpoint(s),
 		AddHook:         makeAddHookEndpoint(s),
 		ListVersion:     makeListVersionEndpoint(s),
 		RevertVersion:   makeRevertVersionEndpoint(s),
 		Login:           makeLoginEndpoint(s),
 		ChangePassword:  makeChangePasswordEndpoint(s),
+		CacheStats:      makeCacheStatsEndpoint(s),
 		FindAccount:     makeFindAccountEndpoint(s),
 		SendEmail:       makeSendEmailEndpoint(s),
 		ConfirmCode:     makeConfirmCodeEndpoint(s),
 		ResetPassword:   makeResetPasswordEndpoint(s),
 
+		DBRevisionList:    makeDBRevisionListEndpoint(s),
+		DBRevisionDiff:    makeDBRevisionDiffEndpoint(s),
+		DBRevisionRestore: makeDBRevisionRestoreEndpoint(s),
+
 		ViewAdd:     makeAddViewEndpoint(s),
 		ViewList:    makeListViewEndpoint(s),
 		ViewDelete:  makeDeleteViewEndpoint(s),
 		ViewExecute: makeExecuteViewEndpoint(s),
+		ViewExport:  makeExportViewEndpoint(s),
 
 		GroupCreate:  endpointGroup.MakeCreateGroupEndpoint(s),
 		GroupFind:    endpointGroup.MakeGroupFindEndpoint(s),
@@ -89,5 +126,14 @@ func MakeServerEndpoints(s service.Service) Endpoints {
 		PermissionFindByGroup: endpointPermission.MakePermissionFindByGroupEndpoint(s),
 		PermissionFindByUser:  endpointPermission.MakePermissionFindByUserEndpoint(s),
 		PermissionUpdate:      endpointPermission.MakeUpdatePermissionEndpoint(s),
+		PermissionColumns:     endpointPermission.MakeUpdateColumnsEndpoint(s),
+		PermissionRowFilters:  endpointPermission.MakeUpdateRowFiltersEndpoint(s),
 	}
 }
+
+// userNameFromContext get name of user sending request from jwt claims
+func userNameFromContext(ctx context.Context) string {
+	_, claims, _ := jwtauth.FromContext(ctx)
+	userName, _ := claims["username"].(string)
+	return userName
+}
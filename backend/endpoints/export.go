@@ -0,0 +1,125 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/dwarvesf/smithy/backend/domain"
+	"github.com/dwarvesf/smithy/backend/service"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// Formats of an export
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatXLSX   = "xlsx"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// DBExportRequest request for exporting a table query
+type DBExportRequest struct {
+	sqlmapper.Query
+	Format string `json:"-"`
+}
+
+// ExportViewRequest request for exporting result of a view
+type ExportViewRequest struct {
+	DatabaseName string `json:"-"`
+	SQLID        int    `json:"-"`
+	Format       string `json:"-"`
+}
+
+// ExportResponse response whose rows are written to an export file while response is encoded
+type ExportResponse struct {
+	StreamResponse
+	Format   string
+	FileName string
+}
+
+func makeDBExportEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBExportRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+
+		if err := checkExportFormat(req.Format); err != nil {
+			return nil, err
+		}
+
+		if err := authorizeLabels(ctx, s, req.Query); err != nil {
+			return nil, err
+		}
+
+		detail, err := json.Marshal(req.Query)
+		if err != nil {
+			return nil, err
+		}
+
+		return ExportResponse{
+			StreamResponse: StreamResponse{Stream: func(w sqlmapper.RowWriter) error {
+				if err := s.StreamQuery(ctx, req.Query, w); err != nil {
+					return err
+				}
+				return auditExport(ctx, s, req.SourceDatabase, req.SourceTable, req.Format, string(detail))
+			}},
+			Format:   req.Format,
+			FileName: req.SourceTable + "." + req.Format,
+		}, nil
+	}
+}
+
+func makeExportViewEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(ExportViewRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+
+		if err := checkExportFormat(req.Format); err != nil {
+			return nil, err
+		}
+
+		view, err := s.WriteReadDeleter.Read(req.SQLID)
+		if err != nil {
+			return nil, err
+		}
+
+		return ExportResponse{
+			StreamResponse: StreamResponse{Stream: func(w sqlmapper.RowWriter) error {
+				if err := s.StreamRawQuery(viewContext(ctx, view), req.DatabaseName, view.SQL, w); err != nil {
+					return err
+				}
+				return auditExport(ctx, s, req.DatabaseName, "", req.Format, fmt.Sprintf("view %d: %s", req.SQLID, view.SQL))
+			}},
+			Format:   req.Format,
+			FileName: "view_" + strconv.Itoa(req.SQLID) + "." + req.Format,
+		}, nil
+	}
+}
+
+func checkExportFormat(format string) error {
+	switch format {
+	case ExportFormatCSV, ExportFormatXLSX, ExportFormatNDJSON:
+		return nil
+	}
+
+	return fmt.Errorf("unknown export format %s, format must be csv, xlsx or ndjson", format)
+}
+
+// auditExport record who exported which data once all its rows are written, a file which can't be
+// recorded is aborted before it ends
+func auditExport(ctx context.Context, s service.Service, dbName, tableName, format, detail string) error {
+	return s.AuditService.Create(&domain.AuditLog{
+		Username:     userNameFromContext(ctx),
+		Action:       "export " + format,
+		DatabaseName: dbName,
+		TableName:    tableName,
+		Detail:       detail,
+	})
+}
@@ -0,0 +1,93 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	backendConfig "github.com/dwarvesf/smithy/backend/config"
+	"github.com/dwarvesf/smithy/backend/domain"
+	"github.com/dwarvesf/smithy/backend/service"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+var errStream = errors.New("connection reset")
+
+// streamMapper stream a single row, or fail after it with err
+type streamMapper struct {
+	sqlmapper.Mapper
+	err error
+}
+
+func (m streamMapper) StreamQuery(ctx context.Context, q sqlmapper.Query, w sqlmapper.RowWriter) error {
+	if err := w.WriteHeader(q.Fields, nil); err != nil {
+		return err
+	}
+	if err := w.WriteRow([]interface{}{1}); err != nil {
+		return err
+	}
+
+	return m.err
+}
+
+type auditLogs struct {
+	logs []domain.AuditLog
+}
+
+func (a *auditLogs) Create(l *domain.AuditLog) error {
+	a.logs = append(a.logs, *l)
+	return nil
+}
+
+type nopRowWriter struct{}
+
+func (nopRowWriter) WriteHeader(columns []string, cols []database.Column) error { return nil }
+func (nopRowWriter) WriteRow(row []interface{}) error                           { return nil }
+
+func TestDBExportAudit(t *testing.T) {
+	tests := []struct {
+		name      string
+		streamErr error
+		wantLogs  int
+	}{
+		{
+			name:     "recorded after all rows are written",
+			wantLogs: 1,
+		},
+		{
+			name:      "not recorded when stream fails",
+			streamErr: errStream,
+			wantLogs:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &auditLogs{}
+			s := service.Service{
+				Wrapper:      backendConfig.NewWrapper(&backendConfig.Config{}),
+				Mapper:       streamMapper{err: tt.streamErr},
+				AuditService: audit,
+			}
+
+			res, err := makeDBExportEndpoint(s)(context.Background(), DBExportRequest{
+				Query:  sqlmapper.Query{SourceDatabase: "fortress", SourceTable: "users", Fields: []string{"id"}},
+				Format: ExportFormatCSV,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(audit.logs) != 0 {
+				t.Fatal("export is recorded before its rows are written")
+			}
+
+			if err := res.(ExportResponse).Stream(nopRowWriter{}); err != tt.streamErr {
+				t.Fatalf("Stream() error = %v, want %v", err, tt.streamErr)
+			}
+			if len(audit.logs) != tt.wantLogs {
+				t.Errorf("audit logs = %d, want %d", len(audit.logs), tt.wantLogs)
+			}
+		})
+	}
+}
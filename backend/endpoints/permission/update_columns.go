@@ -0,0 +1,37 @@
+package permission
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/dwarvesf/smithy/backend/domain"
+	"github.com/dwarvesf/smithy/backend/service"
+)
+
+// UpdateColumnsRequest request for replacing column grants of a permission
+type UpdateColumnsRequest struct {
+	PermissionID domain.UUID               `json:"-"`
+	Columns      []domain.ColumnPermission `json:"columns"`
+}
+
+// MakeUpdateColumnsEndpoint .
+func MakeUpdateColumnsEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(UpdateColumnsRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+
+		permission, err := s.PermissionService.UpdateColumns(&domain.Permission{Model: domain.Model{ID: req.PermissionID}}, req.Columns)
+		if err != nil {
+			return nil, err
+		}
+
+		return UpdateResponse{
+			Status:     "success",
+			Permission: permission,
+		}, nil
+	}
+}
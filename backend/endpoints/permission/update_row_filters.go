@@ -0,0 +1,55 @@
+package permission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/dwarvesf/smithy/backend/domain"
+	"github.com/dwarvesf/smithy/backend/service"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// UpdateRowFiltersRequest request for replacing row filters of a permission
+type UpdateRowFiltersRequest struct {
+	PermissionID domain.UUID        `json:"-"`
+	RowFilters   []domain.RowFilter `json:"row_filters"`
+}
+
+// MakeUpdateRowFiltersEndpoint .
+func MakeUpdateRowFiltersEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(UpdateRowFiltersRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+
+		p, err := s.PermissionService.Find(&domain.Permission{Model: domain.Model{ID: req.PermissionID}})
+		if err != nil {
+			return nil, err
+		}
+
+		// column of a filter is put in SQL of queries of table, so it must be a column of table
+		colMap := database.Columns(s.SyncConfig().ModelMap[p.DatabaseName][p.TableName].Columns).GroupByName()
+		for _, f := range req.RowFilters {
+			if f.ColumnName == "" {
+				return nil, errors.New("missing column of row filter")
+			}
+			if _, ok := colMap[f.ColumnName]; !ok {
+				return nil, fmt.Errorf("unknown column %s of row filter in table %s", f.ColumnName, p.TableName)
+			}
+		}
+
+		permission, err := s.PermissionService.UpdateRowFilters(&domain.Permission{Model: domain.Model{ID: req.PermissionID}}, req.RowFilters)
+		if err != nil {
+			return nil, err
+		}
+
+		return UpdateResponse{
+			Status:     "success",
+			Permission: permission,
+		}, nil
+	}
+}
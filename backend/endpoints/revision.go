@@ -0,0 +1,236 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-kit/kit/endpoint"
+
+	jwtAuth "github.com/dwarvesf/smithy/backend/auth"
+	"github.com/dwarvesf/smithy/backend/domain"
+	"github.com/dwarvesf/smithy/backend/service"
+	revisionSrv "github.com/dwarvesf/smithy/backend/service/revision"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// DBRevisionListRequest request for listing revisions of a record, record is found by its primary columns
+type DBRevisionListRequest struct {
+	DatabaseName string
+	TableName    string
+	Key          map[string]interface{}
+}
+
+// DBRevisionListResponse response for listing revisions of a record
+type DBRevisionListResponse struct {
+	Status    string            `json:"status"`
+	Revisions []domain.Revision `json:"revisions"`
+}
+
+func makeDBRevisionListEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBRevisionListRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+
+		model, ok := s.SyncConfig().ModelMap[req.DatabaseName][req.TableName]
+		if !ok {
+			return nil, fmt.Errorf("uknown database_name/table_name %s/%s", req.DatabaseName, req.TableName)
+		}
+
+		key, err := revisionSrv.RecordKey(model, req.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions, err := readableRevisions(ctx, s, req.DatabaseName, req.TableName, key)
+		if err != nil {
+			return nil, err
+		}
+
+		return DBRevisionListResponse{"success", revisions}, nil
+	}
+}
+
+// DBRevisionDiffRequest request for comparing a record after two of its revisions
+type DBRevisionDiffRequest struct {
+	DatabaseName string
+	TableName    string
+	From         domain.UUID
+	To           domain.UUID
+}
+
+// DBRevisionDiffResponse response for comparing a record after two of its revisions
+type DBRevisionDiffResponse struct {
+	Status  string               `json:"status"`
+	Changes []revisionSrv.Change `json:"changes"`
+}
+
+func makeDBRevisionDiffEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBRevisionDiffRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+
+		from, err := findRevision(s, req.DatabaseName, req.TableName, req.From)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions, err := readableRevisions(ctx, s, req.DatabaseName, req.TableName, from.RecordKey)
+		if err != nil {
+			return nil, err
+		}
+
+		changes, err := revisionSrv.Diff(revisions, req.From, req.To)
+		if err != nil {
+			return nil, err
+		}
+
+		return DBRevisionDiffResponse{"success", changes}, nil
+	}
+}
+
+// DBRevisionRestoreRequest request for restoring a record as it was right after a revision
+type DBRevisionRestoreRequest struct {
+	DatabaseName string
+	TableName    string
+	RevisionID   domain.UUID
+}
+
+// DBRevisionRestoreResponse response for restoring a record as it was right after a revision
+type DBRevisionRestoreResponse struct {
+	Status string            `json:"status"`
+	Data   sqlmapper.RowData `json:"data"`
+}
+
+func makeDBRevisionRestoreEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DBRevisionRestoreRequest)
+		if !ok {
+			return nil, errors.New("failed to make type assertion")
+		}
+		userName := userNameFromContext(ctx)
+		s := s.WithRevisions(userName)
+
+		// record is restored by an update
+		cfg := s.SyncConfig()
+		if err := jwtAuth.AuthorizeTable(cfg, s, userName, req.DatabaseName, req.TableName, "update"); err != nil {
+			return nil, err
+		}
+
+		rev, err := findRevision(s, req.DatabaseName, req.TableName, req.RevisionID)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions, err := s.RevisionService.FindByRecord(req.DatabaseName, req.TableName, rev.RecordKey)
+		if err != nil {
+			return nil, err
+		}
+
+		model := cfg.ModelMap[req.DatabaseName][req.TableName]
+		values, err := revisionSrv.RestoreValues(model, revisions, req.RevisionID)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			return nil, errors.New("record wasn't changed after the revision")
+		}
+
+		key, err := recordKeyRow(rev.RecordKey)
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := s.FindRows(ctx, req.DatabaseName, req.TableName, key)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) != 1 {
+			return nil, errors.New("record doesn't exist anymore, it can't be restored")
+		}
+		current := rows[0]
+
+		row := sqlmapper.RowData{}
+		for col := range key {
+			row[col] = current[col]
+		}
+		for col, v := range values {
+			row[col] = sqlmapper.ColData{Name: col, Data: v}
+		}
+		if model.VersionColumn != "" {
+			row[model.VersionColumn] = current[model.VersionColumn]
+		}
+
+		data, err := s.Update(ctx, req.DatabaseName, req.TableName, row)
+		if err != nil {
+			return nil, err
+		}
+
+		return DBRevisionRestoreResponse{"success", data}, nil
+	}
+}
+
+// readableRevisions return revisions of a record as user of ctx can see them. When user has row filters
+// on the table, the record must still exist inside the filters
+func readableRevisions(ctx context.Context, s service.Service, dbName, tableName, recordKey string) ([]domain.Revision, error) {
+	filters, err := sqlmapper.RowFilters(ctx, dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(filters) > 0 {
+		key, err := recordKeyRow(recordKey)
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := s.FindRows(ctx, dbName, tableName, key)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return nil, sqlmapper.ForbiddenRowError{TableName: tableName}
+		}
+	}
+
+	revisions, err := s.RevisionService.FindByRecord(dbName, tableName, recordKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return revisionSrv.Readable(ctx, dbName, s.SyncConfig().ModelMap[dbName][tableName], revisions)
+}
+
+// recordKeyRow convert key of a record to a row of its primary columns
+func recordKeyRow(recordKey string) (sqlmapper.RowData, error) {
+	keyValues := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(recordKey), &keyValues); err != nil {
+		return nil, err
+	}
+
+	key := sqlmapper.RowData{}
+	for col, v := range keyValues {
+		key[col] = sqlmapper.ColData{Name: col, Data: v}
+	}
+
+	return key, nil
+}
+
+// findRevision find a revision of a table
+func findRevision(s service.Service, dbName, tableName string, id domain.UUID) (*domain.Revision, error) {
+	rev, err := s.RevisionService.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if rev.DatabaseName != dbName || rev.TableName != tableName {
+		return nil, fmt.Errorf("revision %s isn't of table %s", id.String(), tableName)
+	}
+
+	return rev, nil
+}
@@ -0,0 +1,9 @@
+package endpoints
+
+import "github.com/dwarvesf/smithy/backend/sqlmapper"
+
+// StreamResponse response whose rows are written one by one to http response
+type StreamResponse struct {
+	Stream  func(w sqlmapper.RowWriter) error
+	Include map[string][]string // fields of included tables, see sqlmapper.Query.IncludeFields
+}
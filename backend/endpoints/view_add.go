@@ -29,7 +29,7 @@ func makeAddViewEndpoint(s service.Service) endpoint.Endpoint {
 			return nil, errors.New("failed to make type assertion")
 		}
 
-		q, err := req.Validate(s.Mapper)
+		q, err := req.Validate(ctx, s.Mapper)
 		if err != nil {
 			return nil, err
 		}
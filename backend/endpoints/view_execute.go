@@ -3,11 +3,13 @@ package endpoints
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
 
 	"github.com/dwarvesf/smithy/backend/service"
-	"github.com/dwarvesf/smithy/common/database"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/view"
 )
 
 // ExecuteViewRequest request for add view
@@ -16,14 +18,6 @@ type ExecuteViewRequest struct {
 	SQLID        int    `json:"-"`
 }
 
-// ExecuteViewResponse response for add view
-type ExecuteViewResponse struct {
-	Status  string            `json:"status"`
-	Columns []string          `json:"columns,omitempty"`
-	Rows    []interface{}     `json:"rows,omitempty"`
-	Cols    []database.Column `json:"cols,omitempty"`
-}
-
 func makeExecuteViewEndpoint(s service.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req, ok := request.(ExecuteViewRequest)
@@ -36,16 +30,14 @@ func makeExecuteViewEndpoint(s service.Service) endpoint.Endpoint {
 			return nil, err
 		}
 
-		columns, columnMeta, data, err := s.RawQuery(req.DatabaseName, view.SQL)
-		if err != nil {
-			return nil, err
-		}
-
-		return ExecuteViewResponse{
-			Status:  "success",
-			Columns: columns,
-			Rows:    data,
-			Cols:    columnMeta,
-		}, nil
+		// rows are streamed while response is encoded
+		return StreamResponse{Stream: func(w sqlmapper.RowWriter) error {
+			return s.StreamRawQuery(viewContext(ctx, view), req.DatabaseName, view.SQL, w)
+		}}, nil
 	}
 }
+
+// viewContext return a context whose raw queries are cached for cache_ttl of view
+func viewContext(ctx context.Context, v *view.View) context.Context {
+	return sqlmapper.WithCacheTTL(ctx, time.Duration(v.CacheTTL)*time.Second)
+}
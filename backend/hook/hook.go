@@ -2,6 +2,7 @@ package hook
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,23 +17,23 @@ import (
 	"github.com/dwarvesf/smithy/common/database"
 )
 
-// ScriptEngine interface for running script
+// ScriptEngine interface for running script, a running script is interrupted
+// and its statements are cancelled when ctx is done
 type ScriptEngine interface {
-	Eval(ctx map[string]interface{}, content string) error
+	Eval(ctx context.Context, data map[string]interface{}, content string) error
 }
 
 type ankoScriptEngine struct {
-	engine *vm.Env
-	dblib  DBLib
+	dblib DBLib
 }
 
 // DBLib interface for lib in db
 type DBLib interface {
-	First(dbName, tableName, condition string) (map[interface{}]interface{}, error)
-	Where(dbName, tableName, condition string) ([]map[interface{}]interface{}, error)
-	Create(dbName, tableName string, data map[interface{}]interface{}) (map[interface{}]interface{}, error)
-	Update(dbName, tableName string, data map[interface{}]interface{}) (map[interface{}]interface{}, error)
-	Delete(dbName, tableName string, fields, data []interface{}) error
+	First(ctx context.Context, dbName, tableName, condition string) (map[interface{}]interface{}, error)
+	Where(ctx context.Context, dbName, tableName, condition string) ([]map[interface{}]interface{}, error)
+	Create(ctx context.Context, dbName, tableName string, data map[interface{}]interface{}) (map[interface{}]interface{}, error)
+	Update(ctx context.Context, dbName, tableName string, data map[interface{}]interface{}) (map[interface{}]interface{}, error)
+	Delete(ctx context.Context, dbName, tableName string, fields, data []interface{}) error
 }
 
 func toRowData(data map[interface{}]interface{}) sqlmapper.RowData {
@@ -44,11 +45,12 @@ func toRowData(data map[interface{}]interface{}) sqlmapper.RowData {
 	return res
 }
 
-func getJSONAPI(headers map[interface{}]interface{}, url string) (map[string]interface{}, error) {
+func getJSONAPI(ctx context.Context, headers map[interface{}]interface{}, url string) (map[string]interface{}, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 
 	// add json header
 	req.Header.Set("Content-Type", "application/json")
@@ -74,7 +76,7 @@ func getJSONAPI(headers map[interface{}]interface{}, url string) (map[string]int
 	return res, err
 }
 
-func postJSONAPI(data map[interface{}]interface{}, headers map[interface{}]interface{}, url string) (map[string]interface{}, error) {
+func postJSONAPI(ctx context.Context, data map[interface{}]interface{}, headers map[interface{}]interface{}, url string) (map[string]interface{}, error) {
 	buf, err := convertAnkoMapToJSON(data)
 	if err != nil {
 		return nil, err
@@ -84,6 +86,7 @@ func postJSONAPI(data map[interface{}]interface{}, headers map[interface{}]inter
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 
 	// add json header
 	req.Header.Set("Content-Type", "application/json")
@@ -145,78 +148,121 @@ func convertAnkoMapToJSON(m map[interface{}]interface{}) ([]byte, error) {
 	return json.Marshal(tmp)
 }
 
-func defineAPICallLib(env *vm.Env) error {
-	err := env.Define("json_post", postJSONAPI)
+func defineAPICallLib(ctx context.Context, env *vm.Env) error {
+	err := env.Define("json_post", func(data map[interface{}]interface{}, headers map[interface{}]interface{}, url string) (map[string]interface{}, error) {
+		return postJSONAPI(ctx, data, headers, url)
+	})
 	if err != nil {
 		return err
 	}
 
-	return env.Define("json_get", getJSONAPI)
+	return env.Define("json_get", func(headers map[interface{}]interface{}, url string) (map[string]interface{}, error) {
+		return getJSONAPI(ctx, headers, url)
+	})
 }
 
-func defineAnkoDBLib(env *vm.Env, lib DBLib) error {
-	err := env.Define("db_first", lib.First)
+func defineAnkoDBLib(ctx context.Context, env *vm.Env, lib DBLib) error {
+	err := env.Define("db_first", func(dbName, tableName, condition string) (map[interface{}]interface{}, error) {
+		return lib.First(ctx, dbName, tableName, condition)
+	})
 	if err != nil {
 		return err
 	}
-	err = env.Define("db_where", lib.Where)
+	err = env.Define("db_where", func(dbName, tableName, condition string) ([]map[interface{}]interface{}, error) {
+		return lib.Where(ctx, dbName, tableName, condition)
+	})
 	if err != nil {
 		return err
 	}
-	err = env.Define("db_create", lib.Create)
+	err = env.Define("db_create", func(dbName, tableName string, data map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+		return lib.Create(ctx, dbName, tableName, data)
+	})
 	if err != nil {
 		return err
 	}
-	err = env.Define("db_update", lib.Update)
+	err = env.Define("db_update", func(dbName, tableName string, data map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+		return lib.Update(ctx, dbName, tableName, data)
+	})
 	if err != nil {
 		return err
 	}
 
-	return env.Define("db_delete", lib.Delete)
+	return env.Define("db_delete", func(dbName, tableName string, fields, data []interface{}) error {
+		return lib.Delete(ctx, dbName, tableName, fields, data)
+	})
 }
 
 // NewAnkoScriptEngine engine for running a engine
-func NewAnkoScriptEngine(db map[string]*gorm.DB, modelMap map[string]map[string]database.Model) (ScriptEngine, error) {
+func NewAnkoScriptEngine(db func() map[string]*gorm.DB, modelMap map[string]map[string]database.Model) (ScriptEngine, error) {
+	return NewAnkoScriptEngineWithLib(NewPGLib(db, modelMap)), nil
+}
+
+// NewAnkoScriptEngineWithLib engine whose scripts access databases with dblib
+func NewAnkoScriptEngineWithLib(dblib DBLib) ScriptEngine {
+	return &ankoScriptEngine{
+		dblib: dblib,
+	}
+}
+
+type libCtx struct {
+	data map[string]interface{}
+}
+
+func (l *libCtx) Ctx() map[string]interface{} {
+	return l.data
+}
+
+// newEnv make an environment for a script, libs of the environment run with ctx.
+// Each script has its own root environment, because an interrupt is shared by all
+// environments of a root
+func (e *ankoScriptEngine) newEnv(ctx context.Context) (*vm.Env, error) {
 	env := vm.NewEnv()
 	err := env.Define("println", fmt.Println) // TODO: REMOVE THIS LATTER
 	if err != nil {
 		return nil, fmt.Errorf("define error: %v", err)
 	}
 
-	lib := NewPGLib(db, modelMap)
-	err = defineAnkoDBLib(env, lib)
+	err = defineAnkoDBLib(ctx, env, e.dblib)
 	if err != nil {
 		return nil, fmt.Errorf("define error: %v", err)
 	}
 
-	err = defineAPICallLib(env)
+	err = defineAPICallLib(ctx, env)
 	if err != nil {
 		return nil, fmt.Errorf("define error: %v", err)
 	}
 
-	return &ankoScriptEngine{
-		engine: env,
-		dblib:  lib,
-	}, nil
+	return env, nil
 }
 
-type libCtx struct {
-	data map[string]interface{}
-}
-
-func (l *libCtx) Ctx() map[string]interface{} {
-	return l.data
-}
+func (e *ankoScriptEngine) Eval(ctx context.Context, data map[string]interface{}, content string) error {
+	env, err := e.newEnv(ctx)
+	if err != nil {
+		return err
+	}
 
-func (e *ankoScriptEngine) Eval(ctx map[string]interface{}, content string) error {
-	l := libCtx{ctx}
-	env := e.engine.NewEnv()
-	err := env.Define("ctx", l.Ctx)
+	l := libCtx{data}
+	err = env.Define("ctx", l.Ctx)
 	if err != nil {
 		return err
 	}
 
+	// script is stopped at its next statement when ctx is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			vm.Interrupt(env)
+		case <-done:
+		}
+	}()
+
 	// TODO: implement string processor
 	_, err = env.Execute(content)
+	if err == vm.ErrInterrupt {
+		return ctx.Err()
+	}
+
 	return err
 }
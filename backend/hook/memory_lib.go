@@ -0,0 +1,255 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+type memoryLibImpl struct {
+	db       *memory.DB
+	modelMap map[string]map[string]database.Model
+}
+
+// NewMemoryLib dblib implement by in-memory databases, conditions are parsed by memory.ParseCondition
+func NewMemoryLib(db *memory.DB, modelMap map[string]map[string]database.Model) DBLib {
+	return &memoryLibImpl{
+		db:       db,
+		modelMap: modelMap,
+	}
+}
+
+// memoryDB read and write rows, it is implemented by *memory.DB and *memory.Tx
+type memoryDB interface {
+	Select(dbName, tableName string, match func(memory.Row) bool) ([]memory.Row, error)
+	Insert(dbName, tableName string, row memory.Row) (memory.Row, error)
+	Update(dbName, tableName string, match func(memory.Row) bool, values memory.Row) (int, error)
+	Delete(dbName, tableName string, match func(memory.Row) bool) (int, error)
+}
+
+// conn return transaction of ctx when hook is run inside a transaction of mapper, otherwise db
+func (s *memoryLibImpl) conn(ctx context.Context, dbName string) memoryDB {
+	if tx, ok := sqlmapper.TxFrom(ctx, dbName).(*memory.Tx); ok {
+		return tx
+	}
+
+	return s.db
+}
+
+// rows return rows of table matching condition as maps of all columns, masked columns are masked
+func (s *memoryLibImpl) rows(ctx context.Context, dbName, tableName, condition string) ([]map[interface{}]interface{}, error) {
+	model, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	cond, err := memory.ParseCondition(condition)
+	if err != nil {
+		return nil, err
+	}
+	if err := memory.CheckColumns(model, cond.Columns()); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.conn(ctx, dbName).Select(dbName, tableName, cond.Match)
+	if err != nil {
+		return nil, err
+	}
+
+	cols := database.Columns(model.Columns).Names()
+	masks := sqlmapper.MaskColumns(ctx, model.Columns, cols)
+	res := []map[interface{}]interface{}{}
+	for _, r := range rows {
+		values := make([]interface{}, len(cols))
+		for i, col := range cols {
+			values[i] = r[col]
+		}
+		sqlmapper.MaskRow(masks, values)
+
+		tmp := make(map[interface{}]interface{})
+		for i, col := range cols {
+			tmp[col] = values[i]
+		}
+		res = append(res, tmp)
+	}
+
+	return res, nil
+}
+
+func (s *memoryLibImpl) First(ctx context.Context, dbName string, tableName string, condition string) (map[interface{}]interface{}, error) {
+	rows, err := s.rows(ctx, dbName, tableName, condition)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("record not found")
+	}
+
+	return rows[0], nil
+}
+
+func (s *memoryLibImpl) Where(ctx context.Context, dbName string, tableName string, condition string) ([]map[interface{}]interface{}, error) {
+	rows, err := s.rows(ctx, dbName, tableName, condition)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+func (s *memoryLibImpl) Create(ctx context.Context, dbName string, tableName string, d map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+	model, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	row, err := toMemoryRow(d)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := s.conn(ctx, dbName).Insert(dbName, tableName, row)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordWrites(ctx, dbName, tableName, sqlmapper.WriteCreate, nil, s.writtenRows(ctx, model, created)); err != nil {
+		return nil, err
+	}
+
+	// update primary key if create success
+	for _, col := range model.Columns {
+		if col.IsPrimary {
+			d[col.Name] = created[col.Name]
+		}
+	}
+
+	return d, nil
+}
+
+func (s *memoryLibImpl) Update(ctx context.Context, dbName, tableName string, d map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+	model, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	row, err := toMemoryRow(d)
+	if err != nil {
+		return nil, err
+	}
+
+	primaryKeyMap := make(memory.Row)
+	for _, col := range model.Columns {
+		if v, ok := row[col.Name]; ok && col.IsPrimary {
+			primaryKeyMap[col.Name] = v
+			delete(row, col.Name)
+		}
+	}
+	if len(primaryKeyMap) == 0 {
+		return nil, errors.New("missing primary key")
+	}
+
+	match := func(r memory.Row) bool {
+		for k, v := range primaryKeyMap {
+			if !memory.Equal(r[k], v) {
+				return false
+			}
+		}
+		return true
+	}
+
+	db := s.conn(ctx, dbName)
+	before, err := db.Select(dbName, tableName, match)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := db.Update(dbName, tableName, match, row)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, errors.New("primary key is not exist")
+	}
+
+	after, err := db.Select(dbName, tableName, match)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordWrites(ctx, dbName, tableName, sqlmapper.WriteUpdate, s.writtenRows(ctx, model, before...), s.writtenRows(ctx, model, after...)); err != nil {
+		return nil, err
+	}
+
+	for k := range primaryKeyMap {
+		delete(d, k)
+	}
+
+	return d, nil
+}
+
+func (s *memoryLibImpl) Delete(ctx context.Context, dbName string, tableName string, fields, data []interface{}) error {
+	model, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+	if len(fields) != len(data) {
+		return errors.New("Fields and data isn't match")
+	}
+
+	match := func(r memory.Row) bool {
+		for i := range fields {
+			if !memory.Equal(r[fmt.Sprint(fields[i])], data[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	db := s.conn(ctx, dbName)
+	before, err := db.Select(dbName, tableName, match)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Delete(dbName, tableName, match); err != nil {
+		return err
+	}
+
+	return recordWrites(ctx, dbName, tableName, sqlmapper.WriteDelete, s.writtenRows(ctx, model, before...), nil)
+}
+
+// writtenRows return all stored columns of rows read before or after a write to record it,
+// nothing is returned when writes of ctx aren't recorded
+func (s *memoryLibImpl) writtenRows(ctx context.Context, model database.Model, rows ...memory.Row) []sqlmapper.RowData {
+	if !sqlmapper.RecordsWrites(ctx) {
+		return nil
+	}
+
+	res := []sqlmapper.RowData{}
+	for _, r := range rows {
+		row := make(sqlmapper.RowData)
+		for _, col := range database.Columns(model.Columns).Stored().Names() {
+			row[col] = sqlmapper.ColData{Name: col, Data: r[col]}
+		}
+		res = append(res, row)
+	}
+
+	return res
+}
+
+func toMemoryRow(data map[interface{}]interface{}) (memory.Row, error) {
+	res := make(memory.Row)
+	for k, v := range data {
+		col, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("column name %v must be a string", k)
+		}
+		res[col] = v
+	}
+
+	return res, nil
+}
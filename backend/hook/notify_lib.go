@@ -0,0 +1,47 @@
+package hook
+
+import (
+	"context"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// WriteFunc is called after rows of a table are written by a dblib
+type WriteFunc func(ctx context.Context, dbName, tableName string)
+
+type notifyLibImpl struct {
+	DBLib
+	onWrite WriteFunc
+}
+
+// NewNotifyLib wrap a dblib, so writes of hooks are seen like writes of mapper: a database written by lib
+// is marked written in session of ctx, and onWrite is called after each write, ex: to remove cached results
+func NewNotifyLib(lib DBLib, onWrite WriteFunc) DBLib {
+	return &notifyLibImpl{
+		DBLib:   lib,
+		onWrite: onWrite,
+	}
+}
+
+func (s *notifyLibImpl) Create(ctx context.Context, dbName, tableName string, data map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+	defer s.written(ctx, dbName, tableName)
+	return s.DBLib.Create(ctx, dbName, tableName, data)
+}
+
+func (s *notifyLibImpl) Update(ctx context.Context, dbName, tableName string, data map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+	defer s.written(ctx, dbName, tableName)
+	return s.DBLib.Update(ctx, dbName, tableName, data)
+}
+
+func (s *notifyLibImpl) Delete(ctx context.Context, dbName, tableName string, fields, data []interface{}) error {
+	defer s.written(ctx, dbName, tableName)
+	return s.DBLib.Delete(ctx, dbName, tableName, fields, data)
+}
+
+// written is called whatever result of a write is, a failed write may still write rows
+func (s *notifyLibImpl) written(ctx context.Context, dbName, tableName string) {
+	sqlmapper.MarkWritten(ctx, dbName)
+	if s.onWrite != nil {
+		s.onWrite(ctx, dbName, tableName)
+	}
+}
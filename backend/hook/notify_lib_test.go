@@ -0,0 +1,55 @@
+package hook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+func TestNotifyLib(t *testing.T) {
+	modelMap := map[string]map[string]database.Model{
+		"fortress": {
+			"users": {
+				TableName: "users",
+				Columns: []database.Column{
+					{Name: "id", Type: "int", IsPrimary: true, IsGenerated: true},
+					{Name: "name", Type: "string"},
+				},
+			},
+		},
+	}
+
+	written := []string{}
+	lib := NewNotifyLib(NewMemoryLib(memory.New(modelMap), modelMap), func(_ context.Context, dbName, tableName string) {
+		written = append(written, dbName+"/"+tableName)
+	})
+
+	ctx := sqlmapper.NewSession(context.Background())
+	if _, err := lib.Where(ctx, "fortress", "users", "name = 'ann'"); err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 0 || sqlmapper.IsWritten(ctx, "fortress") {
+		t.Errorf("a read is notified as a write: %v", written)
+	}
+
+	if _, err := lib.Create(ctx, "fortress", "users", map[interface{}]interface{}{"name": "ann"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lib.Update(ctx, "fortress", "users", map[interface{}]interface{}{"id": 1, "name": "anna"}); err != nil {
+		t.Fatal(err)
+	}
+	// a failed write is notified too, it may have written rows
+	_ = lib.Delete(ctx, "fortress", "users", []interface{}{"email"}, []interface{}{"a@x.com"})
+
+	want := []string{"fortress/users", "fortress/users", "fortress/users"}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("written = %v, want %v", written, want)
+	}
+	if !sqlmapper.IsWritten(ctx, "fortress") {
+		t.Error("database written by a hook isn't marked written in session")
+	}
+}
@@ -1,8 +1,11 @@
 package hook
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/dwarvesf/smithy/backend/sqlmapper"
@@ -12,26 +15,61 @@ import (
 )
 
 type pgLibImpl struct {
-	db       map[string]*gorm.DB
+	db       func() map[string]*gorm.DB
 	modelMap map[string]map[string]database.Model
 }
 
 // NewPGLib dblib implement by postgres
-func NewPGLib(db map[string]*gorm.DB, modelMap map[string]map[string]database.Model) DBLib {
+func NewPGLib(db func() map[string]*gorm.DB, modelMap map[string]map[string]database.Model) DBLib {
 	return &pgLibImpl{
 		db:       db,
 		modelMap: modelMap,
 	}
 }
 
-func (s *pgLibImpl) First(dbName string, tableName string, condition string) (map[interface{}]interface{}, error) {
+// gormDB return db of dbName whose statements run with ctx, they run in transaction of ctx
+// when hook is run inside a transaction of mapper
+func (s *pgLibImpl) gormDB(ctx context.Context, dbName string) (*gorm.DB, error) {
+	pool, ok := s.db()[dbName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	if tx, ok := sqlmapper.TxFrom(ctx, dbName).(*sql.Tx); ok {
+		return sqlmapper.WithTxContext(ctx, pool, tx)
+	}
+
+	return sqlmapper.WithContext(ctx, pool)
+}
+
+// conn return executor of statements of dbName, it is transaction of ctx when hook is run
+// inside a transaction of mapper
+func (s *pgLibImpl) conn(ctx context.Context, dbName string) (sqlmapper.SQLExecutor, error) {
+	pool, ok := s.db()[dbName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	if tx, ok := sqlmapper.TxFrom(ctx, dbName).(*sql.Tx); ok {
+		return tx, nil
+	}
+
+	return pool.DB(), nil
+}
+
+func (s *pgLibImpl) First(ctx context.Context, dbName string, tableName string, condition string) (map[interface{}]interface{}, error) {
 	model, ok := s.modelMap[dbName][tableName]
 	if !ok {
 		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
 	}
 	cols := database.Columns(model.Columns).Names()
-	colNames := strings.Join(cols, ",")
-	rows, err := s.db[dbName].Table(tableName).Select(colNames).Where(condition).Limit(1).Rows()
+	colNames := strings.Join(database.Columns(model.Columns).SelectExprs(cols), ",")
+	db, err := s.gormDB(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Table(tableName).Select(colNames).Where(condition).Limit(1).Rows()
 	if err != nil {
 		return nil, err
 	}
@@ -46,6 +84,7 @@ func (s *pgLibImpl) First(dbName string, tableName string, condition string) (ma
 	}
 
 	first := data[0].([]interface{}) // get only first element
+	sqlmapper.MaskRow(sqlmapper.MaskColumns(ctx, model.Columns, cols), first)
 	res := make(map[interface{}]interface{})
 	for i := range first {
 		res[cols[i]] = first[i]
@@ -54,14 +93,19 @@ func (s *pgLibImpl) First(dbName string, tableName string, condition string) (ma
 	return res, nil
 }
 
-func (s *pgLibImpl) Where(dbName string, tableName string, condition string) ([]map[interface{}]interface{}, error) {
+func (s *pgLibImpl) Where(ctx context.Context, dbName string, tableName string, condition string) ([]map[interface{}]interface{}, error) {
 	model, ok := s.modelMap[dbName][tableName]
 	if !ok {
 		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
 	}
 	cols := database.Columns(model.Columns).Names()
-	colNames := strings.Join(cols, ",")
-	rows, err := s.db[dbName].Table(tableName).Select(colNames).Where(condition).Rows()
+	colNames := strings.Join(database.Columns(model.Columns).SelectExprs(cols), ",")
+	db, err := s.gormDB(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Table(tableName).Select(colNames).Where(condition).Rows()
 	if err != nil {
 		return nil, err
 	}
@@ -75,8 +119,10 @@ func (s *pgLibImpl) Where(dbName string, tableName string, condition string) ([]
 		return nil, nil
 	}
 
+	masks := sqlmapper.MaskColumns(ctx, model.Columns, cols)
 	res := []map[interface{}]interface{}{}
 	for i := range data {
+		sqlmapper.MaskRow(masks, data[i].([]interface{}))
 		tmp := make(map[interface{}]interface{})
 		for j := range cols {
 			tmp[cols[j]] = data[i].([]interface{})[j] // row is a []interface{}
@@ -88,42 +134,91 @@ func (s *pgLibImpl) Where(dbName string, tableName string, condition string) ([]
 	return res, nil
 }
 
-func (s *pgLibImpl) Create(dbName string, tableName string, d map[interface{}]interface{}) (map[interface{}]interface{}, error) {
-	db := s.db[dbName].DB()
+func (s *pgLibImpl) Create(ctx context.Context, dbName string, tableName string, d map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+	model, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	db, err := s.conn(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
 	row := toRowData(d)
 
 	cols, data := row.ColumnsAndData()
 
 	phs := strmangle.Placeholders(true, len(cols), 1, 1)
 
-	execQuery := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING id;",
+	returning := []string{}
+	for _, col := range model.Columns {
+		if col.IsPrimary {
+			returning = append(returning, col.Name)
+		}
+	}
+
+	execQuery := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
 		tableName,
 		strings.Join(cols, ","),
 		phs)
+	// a row of a table without primary key can't be read again, so it isn't recorded
+	if len(returning) == 0 {
+		_, err := db.ExecContext(ctx, execQuery, data...)
+		return d, err
+	}
+	execQuery += " RETURNING " + strings.Join(returning, ",")
 
-	res := db.QueryRow(execQuery, data...)
+	values := make([]interface{}, len(returning))
+	pointers := make([]interface{}, len(returning))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
 
-	var id int64
-	err := res.Scan(&id)
-	if err != nil {
+	if err := db.QueryRowContext(ctx, execQuery, data...).Scan(pointers...); err != nil {
 		return nil, err
 	}
 
-	// update id if create success
-	d["id"] = id
+	// update primary key if create success
+	primaryKeyMap := make(sqlmapper.RowData)
+	for i, col := range returning {
+		if b, ok := values[i].([]byte); ok {
+			values[i] = string(b)
+		}
+		d[col] = values[i]
+		primaryKeyMap[col] = sqlmapper.ColData{Data: values[i]}
+	}
+
+	where, args := primaryKeyCondition(primaryKeyMap)
+	after, err := s.writtenRows(ctx, db, dbName, tableName, where, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordWrites(ctx, dbName, tableName, sqlmapper.WriteCreate, nil, after); err != nil {
+		return nil, err
+	}
 
 	return d, nil
 }
 
-func (s *pgLibImpl) Update(dbName, tableName string, d map[interface{}]interface{}) (map[interface{}]interface{}, error) {
-	db := s.db[dbName].DB()
+func (s *pgLibImpl) Update(ctx context.Context, dbName, tableName string, d map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+	db, err := s.conn(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
 	row := toRowData(d)
 
 	primaryKeyMap, err := s.getPrimaryKeyMap(row, dbName, tableName)
 	if err != nil {
 		return nil, err
 	}
-	if exist, _ := s.isPrimaryKeyExist(dbName, tableName, primaryKeyMap); !exist {
+	if len(primaryKeyMap) == 0 {
+		return nil, errors.New("missing primary key")
+	}
+	exist, err := s.isPrimaryKeyExist(ctx, db, tableName, primaryKeyMap)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
 		return nil, errors.New("primary key is not exist")
 	}
 
@@ -136,22 +231,37 @@ func (s *pgLibImpl) Update(dbName, tableName string, d map[interface{}]interface
 
 	params := []string{}
 	for colName, colData := range primaryKeyMap {
-		params = append(params, fmt.Sprintf("%s = '%v'", colName, colData.Data))
+		data = append(data, colData.Data)
+		params = append(params, fmt.Sprintf("%s = $%d", colName, len(data)))
 		delete(d, colName)
 	}
 
+	where, args := primaryKeyCondition(primaryKeyMap)
+	before, err := s.writtenRows(ctx, db, dbName, tableName, where, args)
+	if err != nil {
+		return nil, err
+	}
+
 	execQuery := fmt.Sprintf("UPDATE %s SET %s WHERE %s",
 		tableName,
 		strings.Join(rowQuery, ","),
 		strings.Join(params, " AND "))
 
-	if _, err := db.Exec(execQuery, data...); err != nil {
+	if _, err := db.ExecContext(ctx, execQuery, data...); err != nil {
+		return nil, err
+	}
+
+	after, err := s.writtenRows(ctx, db, dbName, tableName, where, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordWrites(ctx, dbName, tableName, sqlmapper.WriteUpdate, before, after); err != nil {
 		return nil, err
 	}
 
 	return d, nil
 }
-func (s *pgLibImpl) Delete(dbName string, tableName string, fields, data []interface{}) error {
+func (s *pgLibImpl) Delete(ctx context.Context, dbName string, tableName string, fields, data []interface{}) error {
 	execPostfix := fmt.Sprintf("DELETE FROM %s WHERE", tableName)
 
 	if len(fields) != len(data) {
@@ -167,10 +277,66 @@ func (s *pgLibImpl) Delete(dbName string, tableName string, fields, data []inter
 
 	exec := fmt.Sprintf("%s %s", execPostfix, strings.Join(param, " AND "))
 
-	if _, err := s.db[dbName].DB().Exec(exec); err != nil {
+	db, err := s.conn(ctx, dbName)
+	if err != nil {
+		return err
+	}
+
+	before, err := s.writtenRows(ctx, db, dbName, tableName, strings.Join(param, " AND "), nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, exec); err != nil {
 		return errors.New("delete error")
 	}
-	return nil
+	return recordWrites(ctx, dbName, tableName, sqlmapper.WriteDelete, before, nil)
+}
+
+// writtenRows read all stored columns of rows of tableName matching where, they are read before and after
+// a write to record it. Nothing is read when writes of ctx aren't recorded
+func (s *pgLibImpl) writtenRows(ctx context.Context, db sqlmapper.SQLExecutor, dbName, tableName, where string, args []interface{}) ([]sqlmapper.RowData, error) {
+	if !sqlmapper.RecordsWrites(ctx) {
+		return nil, nil
+	}
+	cols := database.Columns(s.modelMap[dbName][tableName].Columns).Stored().Names()
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(cols, ", "), tableName, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []sqlmapper.RowData{}
+	err = sqlmapper.ScanRows(rows, func(row []interface{}) error {
+		r := make(sqlmapper.RowData)
+		for i, col := range cols {
+			v := row[i]
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			r[col] = sqlmapper.ColData{Name: col, Data: v}
+		}
+		res = append(res, r)
+		return nil
+	})
+
+	return res, err
+}
+
+// primaryKeyCondition make condition of a row from its primary columns
+func primaryKeyCondition(primaryKeyMap sqlmapper.RowData) (string, []interface{}) {
+	cols := primaryKeyMap.Columns()
+	sort.Strings(cols)
+
+	params := []string{}
+	args := []interface{}{}
+	for _, col := range cols {
+		args = append(args, primaryKeyMap[col].Data)
+		params = append(params, fmt.Sprintf("%s = $%d", col, len(args)))
+	}
+
+	return strings.Join(params, " AND "), args
 }
 
 func (s *pgLibImpl) isPrimaryKey(dbName, colName, tableName string) bool {
@@ -197,20 +363,17 @@ func (s *pgLibImpl) getPrimaryKeyMap(row sqlmapper.RowData, dbName, tableName st
 	return primaryKeyMap, nil
 }
 
-func (s *pgLibImpl) isPrimaryKeyExist(dbName, tableName string, primaryKeyMap sqlmapper.RowData) (bool, error) {
-	db, ok := s.db[dbName]
-	if !ok {
-		return false, errors.New("DB not exist!")
-	}
-	data := struct {
-		Result bool
-	}{}
-
+func (s *pgLibImpl) isPrimaryKeyExist(ctx context.Context, db sqlmapper.SQLExecutor, tableName string, primaryKeyMap sqlmapper.RowData) (bool, error) {
 	params := []string{}
+	values := []interface{}{}
 	for colName, colData := range primaryKeyMap {
-		params = append(params, fmt.Sprintf("%s = '%v'", colName, colData.Data))
+		values = append(values, colData.Data)
+		params = append(params, fmt.Sprintf("%s = $%d", colName, len(values)))
 	}
-	execQuery := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s) as result", tableName, strings.Join(params, " AND "))
+	execQuery := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s)", tableName, strings.Join(params, " AND "))
+
+	var exist bool
+	err := db.QueryRowContext(ctx, execQuery, values...).Scan(&exist)
 
-	return data.Result, db.Raw(execQuery).Scan(&data).Error
+	return exist, err
 }
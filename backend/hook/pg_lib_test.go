@@ -3,6 +3,7 @@
 package hook
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
@@ -86,9 +87,9 @@ func Test_pgLibImpl_First(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewPGLib(cfg.DBs(), cfg.ModelMap)
+			s := NewPGLib(cfg.DBs, cfg.ModelMap)
 
-			got, err := s.First(tt.args.databaseName, tt.args.tableName, tt.args.condition)
+			got, err := s.First(context.Background(), tt.args.databaseName, tt.args.tableName, tt.args.condition)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("pgLibImpl.First() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -178,9 +179,9 @@ func Test_pgLibImpl_Where(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewPGLib(cfg.DBs(), cfg.ModelMap)
+			s := NewPGLib(cfg.DBs, cfg.ModelMap)
 
-			got, err := s.Where(tt.args.databaseName, tt.args.tableName, tt.args.condition)
+			got, err := s.Where(context.Background(), tt.args.databaseName, tt.args.tableName, tt.args.condition)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("pgLibImpl.Where() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -258,9 +259,9 @@ func Test_pgLibImpl_Create(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewPGLib(cfg.DBs(), cfg.ModelMap)
+			s := NewPGLib(cfg.DBs, cfg.ModelMap)
 
-			got, err := s.Create(tt.args.databaseName, tt.args.tableName, tt.args.d)
+			got, err := s.Create(context.Background(), tt.args.databaseName, tt.args.tableName, tt.args.d)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("pgLibImpl.Create() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -357,8 +358,8 @@ func Test_pgLibImpl_Update(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewPGLib(cfg.DBs(), cfg.ModelMap)
-			got, err := s.Update(tt.args.databaseName, tt.args.tableName, tt.args.d)
+			s := NewPGLib(cfg.DBs, cfg.ModelMap)
+			got, err := s.Update(context.Background(), tt.args.databaseName, tt.args.tableName, tt.args.d)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("pgLibImpl.Update() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -449,13 +450,13 @@ func Test_pgLibImpl_Delete(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewPGLib(cfg.DBs(), cfg.ModelMap)
+			s := NewPGLib(cfg.DBs, cfg.ModelMap)
 
-			if err := s.Delete(tt.args.databaseName, tt.args.tableName, tt.args.fields, tt.args.data); (err != nil) != tt.wantErr {
+			if err := s.Delete(context.Background(), tt.args.databaseName, tt.args.tableName, tt.args.fields, tt.args.data); (err != nil) != tt.wantErr {
 				t.Errorf("pgLibImpl.Delete() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if tt.testCorrect {
-				_, err := s.First(utilDB.DBName, "users", "id = 1") // check record users already deleted in database
+				_, err := s.First(context.Background(), utilDB.DBName, "users", "id = 1") // check record users already deleted in database
 				if err == nil {
 					t.Error("pgLibImpl.Delete() not delete record in database ")
 				}
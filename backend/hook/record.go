@@ -0,0 +1,31 @@
+package hook
+
+import (
+	"context"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// recordWrites record rows of tableName written by a hook, before and after hold the same rows read before
+// and after the write. A created row has no before and a deleted row has no after
+func recordWrites(ctx context.Context, dbName, tableName, action string, before, after []sqlmapper.RowData) error {
+	n := len(before)
+	if len(after) > n {
+		n = len(after)
+	}
+
+	for i := 0; i < n; i++ {
+		w := sqlmapper.RowWrite{DBName: dbName, TableName: tableName, Action: action}
+		if i < len(before) {
+			w.Before = before[i]
+		}
+		if i < len(after) {
+			w.After = after[i]
+		}
+		if err := sqlmapper.RecordWrite(ctx, w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
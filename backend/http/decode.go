@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi"
 
@@ -29,6 +31,133 @@ func decodeDBQueryRequest(ctx context.Context, r *http.Request) (interface{}, er
 	return req, err
 }
 
+func decodeDBExportRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.DBExportRequest
+	dbName := chi.URLParam(r, "db_name")
+	tableName := chi.URLParam(r, "table_name")
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	defer r.Body.Close()
+
+	req.SourceTable = tableName
+	req.SourceDatabase = dbName
+	req.Format = exportFormat(r)
+
+	return req, err
+}
+
+// max memory used to parse an import file, larger file is stored in temporary files
+const maxImportMemory = 32 << 20
+
+func decodeDBImportRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.DBImportRequest
+	req.DatabaseName = chi.URLParam(r, "db_name")
+	req.TableName = chi.URLParam(r, "table_name")
+
+	if err := r.ParseMultipartForm(maxImportMemory); err != nil {
+		return nil, err
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	req.File = file
+
+	// format is detected by file extension when it isn't set
+	req.Format = r.FormValue("format")
+	if req.Format == "" {
+		req.Format = strings.TrimPrefix(filepath.Ext(header.Filename), ".")
+	}
+	req.Mode = r.FormValue("mode")
+
+	if v := r.FormValue("mapping"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Mapping); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	if v := r.FormValue("dry_run"); v != "" {
+		if req.DryRun, err = strconv.ParseBool(v); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	if v := r.FormValue("batch_size"); v != "" {
+		if req.BatchSize, err = strconv.Atoi(v); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+func decodeDBRevisionListRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	req := endpoints.DBRevisionListRequest{
+		DatabaseName: chi.URLParam(r, "db_name"),
+		TableName:    chi.URLParam(r, "table_name"),
+		Key:          make(map[string]interface{}),
+	}
+
+	// primary columns of record are sent in query string, ex: ?id=1
+	for k, v := range r.URL.Query() {
+		req.Key[k] = v[0]
+	}
+
+	return req, nil
+}
+
+func decodeDBLookupRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	req := endpoints.DBLookupRequest{
+		DatabaseName: chi.URLParam(r, "db_name"),
+		TableName:    chi.URLParam(r, "table_name"),
+		Column:       r.URL.Query().Get("column"),
+		Search:       r.URL.Query().Get("q"),
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		var err error
+		if req.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+func decodeDBRevisionDiffRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	req := endpoints.DBRevisionDiffRequest{
+		DatabaseName: chi.URLParam(r, "db_name"),
+		TableName:    chi.URLParam(r, "table_name"),
+	}
+
+	var err error
+	if req.From, err = domain.UUIDFromString(r.URL.Query().Get("from")); err != nil {
+		return nil, err
+	}
+	if req.To, err = domain.UUIDFromString(r.URL.Query().Get("to")); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func decodeDBRevisionRestoreRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := domain.UUIDFromString(chi.URLParam(r, "revision_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoints.DBRevisionRestoreRequest{
+		DatabaseName: chi.URLParam(r, "db_name"),
+		TableName:    chi.URLParam(r, "table_name"),
+		RevisionID:   id,
+	}, nil
+}
+
 func decodeDBCreateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	var req endpoints.DBCreateRequest
 	dbName := chi.URLParam(r, "db_name")
@@ -53,6 +182,7 @@ func decodeDBUpdateRequest(ctx context.Context, r *http.Request) (interface{}, e
 
 	req.TableName = tableName
 	req.DatabaseName = dbName
+	req.IfMatch = strings.Trim(r.Header.Get("If-Match"), `"`)
 
 	return req, err
 }
@@ -80,6 +210,60 @@ func decodeDBDeleteRequest(ctx context.Context, r *http.Request) (interface{}, e
 	return req, err
 }
 
+func decodeDBRestoreRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.DBRestoreRequest
+	dbName := chi.URLParam(r, "db_name")
+	tableName := chi.URLParam(r, "table_name")
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	defer r.Body.Close()
+
+	req.TableName = tableName
+	req.DatabaseName = dbName
+
+	return req, err
+}
+
+func decodeDBBulkRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.DBBulkRequest
+	dbName := chi.URLParam(r, "db_name")
+	tableName := chi.URLParam(r, "table_name")
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	defer r.Body.Close()
+
+	req.TableName = tableName
+	req.DatabaseName = dbName
+
+	return req, err
+}
+
+func decodeDBUpsertRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.DBUpsertRequest
+	dbName := chi.URLParam(r, "db_name")
+	tableName := chi.URLParam(r, "table_name")
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	defer r.Body.Close()
+
+	req.TableName = tableName
+	req.DatabaseName = dbName
+	req.IfMatch = strings.Trim(r.Header.Get("If-Match"), `"`)
+
+	return req, err
+}
+
+func decodeDBTransactionRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.DBTransactionRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	defer r.Body.Close()
+
+	req.DatabaseName = chi.URLParam(r, "db_name")
+
+	return req, err
+}
+
 func decodeRevertVersion(ctx context.Context, r *http.Request) (interface{}, error) {
 	var req endpoints.RevertVersionResquest
 
@@ -154,6 +338,30 @@ func decodeExecuteView(ctx context.Context, r *http.Request) (interface{}, error
 	return req, nil
 }
 
+func decodeExportView(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.ExportViewRequest
+	dbName := chi.URLParam(r, "db_name")
+	sqlID, err := strconv.Atoi(chi.URLParam(r, "sql_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	req.DatabaseName = dbName
+	req.SQLID = sqlID
+	req.Format = exportFormat(r)
+
+	return req, nil
+}
+
+// exportFormat get format of an export from query string, csv is used by default
+func exportFormat(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f
+	}
+
+	return endpoints.ExportFormatCSV
+}
+
 func decodeCreateGroup(ctx context.Context, r *http.Request) (interface{}, error) {
 	req := endpointGroup.CreateRequest{}
 
@@ -310,6 +518,38 @@ func decodePermissionUpdate(ctx context.Context, r *http.Request) (interface{},
 	return req, err
 }
 
+func decodePermissionColumns(ctx context.Context, r *http.Request) (interface{}, error) {
+	req := endpointPermission.UpdateColumnsRequest{}
+
+	permissionID, err := domain.UUIDFromString(chi.URLParam(r, "permission_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	defer r.Body.Close()
+
+	req.PermissionID = permissionID
+
+	return req, err
+}
+
+func decodePermissionRowFilters(ctx context.Context, r *http.Request) (interface{}, error) {
+	req := endpointPermission.UpdateRowFiltersRequest{}
+
+	permissionID, err := domain.UUIDFromString(chi.URLParam(r, "permission_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	defer r.Body.Close()
+
+	req.PermissionID = permissionID
+
+	return req, err
+}
+
 func decodeSendEmailRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	req := endpoints.SendEmailRequest{}
 
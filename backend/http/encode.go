@@ -6,6 +6,9 @@ import (
 	"net/http"
 
 	kithttp "github.com/go-kit/kit/transport/http"
+
+	"github.com/dwarvesf/smithy/backend/importer"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
 )
 
 func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
@@ -30,8 +33,27 @@ func encodeJSONError(_ context.Context, err error, w http.ResponseWriter) {
 		code = sc.StatusCode()
 	}
 	w.WriteHeader(code)
-	// enforce json response
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+	res := map[string]interface{}{
 		"error": err.Error(),
-	})
+	}
+	// failed operation of a transaction is returned, its error decide data and fields
+	if opErr, ok := err.(sqlmapper.OperationError); ok {
+		res["operation"] = opErr.Index
+		err = opErr.Err
+	}
+	// current row is returned, so client can merge its changes
+	if conflict, ok := err.(sqlmapper.ConflictError); ok {
+		res["data"] = conflict.Current
+	}
+	// rows imported before the error are returned, they are committed
+	if impErr, ok := err.(importer.Error); ok {
+		res["summary"] = impErr.Summary
+		err = impErr.Err
+	}
+	// every invalid field is listed, so client can highlight them
+	if invalid, ok := err.(sqlmapper.ValidationError); ok {
+		res["fields"] = invalid.Fields
+	}
+	// enforce json response
+	_ = json.NewEncoder(w).Encode(res)
 }
@@ -0,0 +1,344 @@
+package http
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/dwarvesf/smithy/backend/endpoints"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+const (
+	xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	// max rows of a xlsx sheet, header included
+	xlsxMaxRows = 1048576
+)
+
+// encodeExportResponse write rows of an ExportResponse as a file in requested format
+func encodeExportResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res, ok := response.(endpoints.ExportResponse)
+	if !ok {
+		return httptransport.EncodeJSONResponse(ctx, w, response)
+	}
+
+	ew := &exportWriter{w: w, format: res.Format, fileName: res.FileName}
+	err := res.Stream(ew)
+	if err == nil {
+		return ew.close()
+	}
+
+	// nothing was written, so a normal error response can be sent
+	if !ew.started {
+		encodeError(ctx, err, w)
+		return nil
+	}
+
+	// file is partly sent, abort response so client can't take it as a complete file
+	panic(http.ErrAbortHandler)
+}
+
+// fileWriter write rows in a file format
+type fileWriter interface {
+	header(titles []string) error
+	row(values []interface{}) error
+	close() error
+}
+
+// exportWriter implement sqlmapper.RowWriter for an export file
+type exportWriter struct {
+	w        http.ResponseWriter
+	format   string
+	fileName string
+	file     fileWriter
+	count    int
+	started  bool
+}
+
+func (e *exportWriter) WriteHeader(columns []string, cols []database.Column) error {
+	e.started = true
+
+	switch e.format {
+	case endpoints.ExportFormatXLSX:
+		e.w.Header().Set("Content-Type", xlsxContentType)
+		e.file = &xlsxWriter{zip: zip.NewWriter(e.w)}
+	case endpoints.ExportFormatNDJSON:
+		e.w.Header().Set("Content-Type", ndjsonContentType)
+		e.file = &ndjsonWriter{w: e.w}
+	default:
+		e.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		e.file = &csvWriter{w: csv.NewWriter(e.w)}
+	}
+
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.fileName))
+	e.w.WriteHeader(http.StatusOK)
+
+	return e.file.header(exportTitles(columns, cols))
+}
+
+func (e *exportWriter) WriteRow(row []interface{}) error {
+	if err := e.file.row(row); err != nil {
+		return err
+	}
+
+	e.count++
+	if f, ok := e.w.(http.Flusher); ok && e.count%flushSize == 0 {
+		if c, ok := e.file.(*csvWriter); ok {
+			c.w.Flush()
+		}
+		f.Flush()
+	}
+
+	return nil
+}
+
+func (e *exportWriter) close() error {
+	if e.file == nil {
+		return nil
+	}
+
+	return e.file.close()
+}
+
+// exportTitles use display name of columns as titles, an included table is titled by its name
+func exportTitles(columns []string, cols []database.Column) []string {
+	res := []string{}
+	for i, c := range columns {
+		if i < len(cols) && cols[i].Name == c {
+			c = cols[i].Title()
+		}
+		res = append(res, c)
+	}
+
+	return res
+}
+
+// formatCell format a value of a csv cell, text starting like a formula is escaped by escapeFormula
+func formatCell(v interface{}) string {
+	switch v.(type) {
+	case int, int32, int64, float32, float64:
+		return formatValue(v)
+	}
+
+	return escapeFormula(formatValue(v))
+}
+
+// escapeFormula prefix text starting with =, +, - or @ with ', so spreadsheet apps show it
+// as text instead of running it as a formula
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+// formatValue format a value as text by its type, included rows are formatted as json
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339)
+	case bool:
+		return strconv.FormatBool(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case int, int32, int64:
+		return fmt.Sprint(val)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) header(titles []string) error {
+	record := make([]string, len(titles))
+	for i, t := range titles {
+		record[i] = escapeFormula(t)
+	}
+
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) row(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = formatCell(v)
+	}
+
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonWriter struct {
+	w      io.Writer
+	titles []string
+}
+
+func (n *ndjsonWriter) header(titles []string) error {
+	n.titles = titles
+	return nil
+}
+
+// row write a row as an object keyed by titles, keys keep order of columns
+func (n *ndjsonWriter) row(values []interface{}) error {
+	buf := bytes.NewBufferString("{")
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+
+		key, err := json.Marshal(n.titles[i])
+		if err != nil {
+			return err
+		}
+		val, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteString("}\n")
+
+	_, err := n.w.Write(buf.Bytes())
+	return err
+}
+
+func (n *ndjsonWriter) close() error {
+	return nil
+}
+
+// xlsxWriter write a workbook with a single sheet, rows are written to the sheet as they come
+type xlsxWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer
+	count int
+}
+
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+func (x *xlsxWriter) header(titles []string) error {
+	for _, p := range xlsxParts {
+		f, err := x.zip.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.content); err != nil {
+			return err
+		}
+	}
+
+	// sheet must be the last part, so it can be written row by row
+	sheet, err := x.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	x.sheet = sheet
+
+	if _, err := io.WriteString(x.sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(titles))
+	for i, t := range titles {
+		values[i] = t
+	}
+
+	return x.row(values)
+}
+
+func (x *xlsxWriter) row(values []interface{}) error {
+	if x.count >= xlsxMaxRows {
+		return fmt.Errorf("xlsx can't contain more than %d rows", xlsxMaxRows)
+	}
+	x.count++
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `<row r="%d">`, x.count)
+	for _, v := range values {
+		switch val := v.(type) {
+		case nil:
+			buf.WriteString(`<c/>`)
+		case int, int32, int64, float32, float64:
+			fmt.Fprintf(buf, `<c><v>%s</v></c>`, formatValue(val))
+		case bool:
+			b := 0
+			if val {
+				b = 1
+			}
+			fmt.Fprintf(buf, `<c t="b"><v>%d</v></c>`, b)
+		case time.Time:
+			xlsxText(buf, val.Format("2006-01-02 15:04:05"))
+		default:
+			xlsxText(buf, formatValue(val))
+		}
+	}
+	buf.WriteString(`</row>`)
+
+	_, err := x.sheet.Write(buf.Bytes())
+	return err
+}
+
+// xlsxText write an inline string cell, text starting like a formula is escaped by escapeFormula
+func xlsxText(buf *bytes.Buffer, s string) {
+	buf.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+	xml.EscapeText(buf, []byte(escapeFormula(s)))
+	buf.WriteString(`</t></is></c>`)
+}
+
+func (x *xlsxWriter) close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+
+	return x.zip.Close()
+}
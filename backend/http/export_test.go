@@ -0,0 +1,198 @@
+package http
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/endpoints"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+func exportResponse(format string) endpoints.ExportResponse {
+	return endpoints.ExportResponse{
+		StreamResponse: endpoints.StreamResponse{Stream: func(w sqlmapper.RowWriter) error {
+			if err := w.WriteHeader([]string{"name", "id", "note", "active"}, nil); err != nil {
+				return err
+			}
+			for _, row := range [][]interface{}{
+				{`Ann "A", <b> & co`, 1, "line1\nline2", true},
+				{nil, int64(2), "", false},
+			} {
+				if err := w.WriteRow(row); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		Format:   format,
+		FileName: "users." + format,
+	}
+}
+
+func TestEncodeExportResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{
+			name:   "csv quote values",
+			format: endpoints.ExportFormatCSV,
+			want: "name,id,note,active\n" +
+				"\"Ann \"\"A\"\", <b> & co\",1,\"line1\nline2\",true\n" +
+				",2,,false\n",
+		},
+		{
+			name:   "ndjson keep order of columns",
+			format: endpoints.ExportFormatNDJSON,
+			want: `{"name":"Ann \"A\", \u003cb\u003e \u0026 co","id":1,"note":"line1\nline2","active":true}` + "\n" +
+				`{"name":null,"id":2,"note":"","active":false}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := encodeExportResponse(context.Background(), w, exportResponse(tt.format)); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+			if got, want := w.Header().Get("Content-Disposition"), `attachment; filename="users.`+tt.format+`"`; got != want {
+				t.Errorf("Content-Disposition = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestEncodeExportResponseXLSX(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := encodeExportResponse(context.Background(), w, exportResponse(endpoints.ExportFormatXLSX)); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("xlsx isn't a zip file: %v", err)
+	}
+
+	var sheet []byte
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// every part must be well-formed xml
+		d := xml.NewDecoder(bytes.NewReader(b))
+		for {
+			if _, err = d.Token(); err != nil {
+				break
+			}
+		}
+		if err != io.EOF {
+			t.Errorf("part %s isn't well-formed: %v", f.Name, err)
+		}
+
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			sheet = b
+		}
+	}
+	if sheet == nil {
+		t.Fatal("xlsx doesn't contain a sheet")
+	}
+	if !strings.Contains(string(sheet), "&lt;b&gt; &amp; co") {
+		t.Errorf("text of sheet isn't escaped: %s", sheet)
+	}
+
+	var got struct {
+		Rows []struct {
+			Cells []struct {
+				Type  string `xml:"t,attr"`
+				Value string `xml:"v"`
+				Text  string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal(sheet, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Rows) != 3 {
+		t.Fatalf("rows of sheet = %d, want 3", len(got.Rows))
+	}
+
+	cells := got.Rows[1].Cells
+	if cells[0].Text != `Ann "A", <b> & co` || cells[1].Value != "1" || cells[2].Text != "line1\nline2" || cells[3].Type != "b" || cells[3].Value != "1" {
+		t.Errorf("cells of first row = %+v", cells)
+	}
+	if cells := got.Rows[2].Cells; cells[0].Type != "" || cells[0].Text != "" || cells[1].Value != "2" {
+		t.Errorf("cells of second row = %+v", cells)
+	}
+}
+
+func TestXLSXWriterRowLimit(t *testing.T) {
+	x := &xlsxWriter{zip: zip.NewWriter(&bytes.Buffer{})}
+	if err := x.header([]string{"id"}); err != nil {
+		t.Fatal(err)
+	}
+
+	x.count = xlsxMaxRows - 1
+	if err := x.row([]interface{}{1}); err != nil {
+		t.Errorf("row() of the last row error = %v", err)
+	}
+	if err := x.row([]interface{}{2}); err == nil {
+		t.Error("row() over max rows of a sheet should fail")
+	}
+}
+
+func TestExportEscapeFormula(t *testing.T) {
+	row := []interface{}{"=SUM(A1:A2)", "+1", "-1", "@cmd", "a=b", int64(-1), -1.5}
+
+	t.Run("csv", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		c := &csvWriter{w: csv.NewWriter(buf)}
+		if err := c.header([]string{"=title"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.row(row); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.close(); err != nil {
+			t.Fatal(err)
+		}
+
+		want := "'=title\n'=SUM(A1:A2),'+1,'-1,'@cmd,a=b,-1,-1.5\n"
+		if got := buf.String(); got != want {
+			t.Errorf("csv = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		x := &xlsxWriter{zip: zip.NewWriter(&bytes.Buffer{}), sheet: buf}
+		if err := x.row(row); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []string{">&#39;=SUM(A1:A2)<", ">&#39;+1<", ">&#39;-1<", ">&#39;@cmd<", ">a=b<", "<v>-1</v>", "<v>-1.5</v>"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("sheet row %s doesn't contain %s", buf.String(), want)
+			}
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"net/http"
 	"os"
 
@@ -15,6 +16,7 @@ import (
 	backendConfig "github.com/dwarvesf/smithy/backend/config"
 	"github.com/dwarvesf/smithy/backend/endpoints"
 	"github.com/dwarvesf/smithy/backend/service"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
 )
 
 // NewHTTPHandler http handler
@@ -33,9 +35,22 @@ func NewHTTPHandler(endpoints endpoints.Endpoints,
 		r.Use(cors.Handler)
 	}
 
+	// connections used by a request aren't closed by a config sync until it is finished, ex: a long stream
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			release := cfg.AcquireDBs()
+			defer release()
+			next.ServeHTTP(w, req)
+		})
+	})
+
 	options := []httptransport.ServerOption{
 		httptransport.ServerErrorLogger(logger),
 		httptransport.ServerErrorEncoder(encodeError),
+		// reads after a write of a request go to primary instead of a replica
+		httptransport.ServerBefore(func(ctx context.Context, _ *http.Request) context.Context {
+			return sqlmapper.NewSession(ctx)
+		}),
 	}
 
 	r.Get("/_warm", httptransport.NewServer(
@@ -97,16 +112,44 @@ func NewHTTPHandler(endpoints endpoints.Endpoints,
 					r.Post("/execute", httptransport.NewServer(
 						endpoints.ViewExecute,
 						decodeExecuteView,
-						httptransport.EncodeJSONResponse,
+						encodeStreamResponse(cfg),
+						append(options, httptransport.ServerBefore(httptransport.PopulateRequestContext))...,
+					).ServeHTTP)
+
+					r.Post("/export", httptransport.NewServer(
+						endpoints.ViewExport,
+						decodeExportView,
+						encodeExportResponse,
 						options...,
 					).ServeHTTP)
 				})
 			})
 
+			r.Post("/transaction", httptransport.NewServer(
+				endpoints.DBTransaction,
+				decodeDBTransactionRequest,
+				httptransport.EncodeJSONResponse,
+				options...,
+			).ServeHTTP)
+
 			r.Route("/table/{table_name}", func(r chi.Router) {
 				r.Post("/query", httptransport.NewServer( // Post query for case a query have more than 2048 character
 					endpoints.DBQuery,
 					decodeDBQueryRequest,
+					encodeStreamResponse(cfg),
+					append(options, httptransport.ServerBefore(httptransport.PopulateRequestContext))...,
+				).ServeHTTP)
+
+				r.Post("/export", httptransport.NewServer(
+					endpoints.DBExport,
+					decodeDBExportRequest,
+					encodeExportResponse,
+					options...,
+				).ServeHTTP)
+
+				r.Post("/import", httptransport.NewServer(
+					endpoints.DBImport,
+					decodeDBImportRequest,
 					httptransport.EncodeJSONResponse,
 					options...,
 				).ServeHTTP)
@@ -131,6 +174,78 @@ func NewHTTPHandler(endpoints endpoints.Endpoints,
 					httptransport.EncodeJSONResponse,
 					options...,
 				).ServeHTTP)
+
+				r.Post("/bulk-create", httptransport.NewServer(
+					endpoints.DBBulkCreate,
+					decodeDBBulkRequest,
+					httptransport.EncodeJSONResponse,
+					options...,
+				).ServeHTTP)
+
+				r.Put("/bulk-update", httptransport.NewServer(
+					endpoints.DBBulkUpdate,
+					decodeDBBulkRequest,
+					httptransport.EncodeJSONResponse,
+					options...,
+				).ServeHTTP)
+
+				r.Delete("/bulk-delete", httptransport.NewServer(
+					endpoints.DBBulkDelete,
+					decodeDBBulkRequest,
+					httptransport.EncodeJSONResponse,
+					options...,
+				).ServeHTTP)
+
+				r.Post("/upsert", httptransport.NewServer(
+					endpoints.DBUpsert,
+					decodeDBUpsertRequest,
+					httptransport.EncodeJSONResponse,
+					options...,
+				).ServeHTTP)
+
+				r.Post("/delete-preview", httptransport.NewServer(
+					endpoints.DBDeletePreview,
+					decodeDBDeleteRequest,
+					httptransport.EncodeJSONResponse,
+					options...,
+				).ServeHTTP)
+
+				r.Put("/restore", httptransport.NewServer(
+					endpoints.DBRestore,
+					decodeDBRestoreRequest,
+					httptransport.EncodeJSONResponse,
+					options...,
+				).ServeHTTP)
+
+				r.Get("/lookup", httptransport.NewServer(
+					endpoints.DBLookup,
+					decodeDBLookupRequest,
+					httptransport.EncodeJSONResponse,
+					options...,
+				).ServeHTTP)
+
+				r.Route("/revisions", func(r chi.Router) {
+					r.Get("/", httptransport.NewServer(
+						endpoints.DBRevisionList,
+						decodeDBRevisionListRequest,
+						httptransport.EncodeJSONResponse,
+						options...,
+					).ServeHTTP)
+
+					r.Get("/diff", httptransport.NewServer(
+						endpoints.DBRevisionDiff,
+						decodeDBRevisionDiffRequest,
+						httptransport.EncodeJSONResponse,
+						options...,
+					).ServeHTTP)
+
+					r.Post("/{revision_id}/restore", httptransport.NewServer(
+						endpoints.DBRevisionRestore,
+						decodeDBRevisionRestoreRequest,
+						httptransport.EncodeJSONResponse,
+						options...,
+					).ServeHTTP)
+				})
 			})
 		})
 
@@ -141,6 +256,13 @@ func NewHTTPHandler(endpoints endpoints.Endpoints,
 			options...,
 		).ServeHTTP)
 
+		r.Get("/cache-stats", httptransport.NewServer(
+			endpoints.CacheStats,
+			httptransport.NopRequestDecoder,
+			httptransport.EncodeJSONResponse,
+			options...,
+		).ServeHTTP)
+
 		r.Post("/hooks", httptransport.NewServer(
 			endpoints.AddHook,
 			decodeAddHookRequest,
@@ -265,6 +387,20 @@ func NewHTTPHandler(endpoints endpoints.Endpoints,
 					httptransport.EncodeJSONResponse,
 					options...,
 				).ServeHTTP)
+
+				r.Put("/columns", httptransport.NewServer(
+					endpoints.PermissionColumns,
+					decodePermissionColumns,
+					httptransport.EncodeJSONResponse,
+					options...,
+				).ServeHTTP)
+
+				r.Put("/row-filters", httptransport.NewServer(
+					endpoints.PermissionRowFilters,
+					decodePermissionRowFilters,
+					httptransport.EncodeJSONResponse,
+					options...,
+				).ServeHTTP)
 			})
 		})
 	})
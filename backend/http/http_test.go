@@ -128,14 +128,15 @@ func TestAuthorized(t *testing.T) {
 	defer clearACL()
 
 	// re-connect with DBusername = agent_db_manager
-	err := cfg.UpdateDB()
-	if err != nil {
-		t.Fatalf("Fail to update connection. %s", err.Error())
-	}
+	cfg.UpdateDB()
 
 	for _, dbase := range cfg.Databases {
+		if err := cfg.DBError(dbase.DBName); err != nil {
+			t.Fatalf("Fail to update connection. %s", err.Error())
+		}
+
 		// set schema for current db connection
-		err = cfg.DB(dbase.DBName).Exec("SET search_path TO " + dbase.SchemaName).Error
+		err := cfg.DB(dbase.DBName).Exec("SET search_path TO " + dbase.SchemaName).Error
 		if err != nil {
 			t.Fatalf("Fail to set search_path to created schema. %s", err.Error())
 		}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	backendConfig "github.com/dwarvesf/smithy/backend/config"
+	"github.com/dwarvesf/smithy/backend/endpoints"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	// rows are flushed to client after each flushSize rows
+	flushSize = 100
+)
+
+// encodeStreamResponse write rows of a StreamResponse as soon as they are read, as a chunked JSON
+// object or NDJSON when client accepts application/x-ndjson
+func encodeStreamResponse(cfg *backendConfig.Config) httptransport.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		res, ok := response.(endpoints.StreamResponse)
+		if !ok {
+			return httptransport.EncodeJSONResponse(ctx, w, response)
+		}
+
+		accept, _ := ctx.Value(httptransport.ContextKeyRequestAccept).(string)
+		sw := &rowStreamWriter{
+			w:       w,
+			ndjson:  strings.Contains(accept, ndjsonContentType),
+			maxRows: cfg.MaxRows(),
+			include: res.Include,
+		}
+
+		err := res.Stream(sw)
+		// nothing was written, so a normal error response can be sent
+		if err != nil && !sw.started {
+			encodeError(ctx, err, w)
+			return nil
+		}
+
+		return sw.close(err)
+	}
+}
+
+// rowStreamWriter implement sqlmapper.RowWriter for http response
+type rowStreamWriter struct {
+	w         http.ResponseWriter
+	ndjson    bool
+	maxRows   int
+	include   map[string][]string
+	count     int
+	started   bool
+	truncated bool
+}
+
+func (s *rowStreamWriter) WriteHeader(columns []string, cols []database.Column) error {
+	s.started = true
+	header := map[string]interface{}{
+		"status":  "success",
+		"columns": columns,
+		"cols":    cols,
+	}
+	if len(s.include) > 0 {
+		header["include"] = s.include
+	}
+
+	if s.ndjson {
+		s.w.Header().Set("Content-Type", ndjsonContentType)
+		s.w.WriteHeader(http.StatusOK)
+		return json.NewEncoder(s.w).Encode(header)
+	}
+
+	s.w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	s.w.WriteHeader(http.StatusOK)
+
+	b, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	// open rows array at the end of header object
+	_, err = io.WriteString(s.w, string(b[:len(b)-1])+`,"rows":[`)
+	return err
+}
+
+func (s *rowStreamWriter) WriteRow(row []interface{}) error {
+	// a query reads max rows + 1, a view is stopped here after max rows
+	if s.count >= s.maxRows {
+		s.truncated = true
+		return sqlmapper.ErrStopStream
+	}
+
+	b, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case s.ndjson:
+		b = append(b, '\n')
+	case s.count > 0:
+		b = append([]byte{','}, b...)
+	}
+
+	if _, err := s.w.Write(b); err != nil {
+		return err
+	}
+
+	s.count++
+	if f, ok := s.w.(http.Flusher); ok && s.count%flushSize == 0 {
+		f.Flush()
+	}
+
+	return nil
+}
+
+// close end the response, an error happened after rows were written is sent at the end
+func (s *rowStreamWriter) close(err error) error {
+	tail := map[string]interface{}{}
+	if s.truncated {
+		tail["truncated"] = true
+	}
+	if err != nil {
+		tail["error"] = err.Error()
+	}
+
+	if s.ndjson {
+		if len(tail) == 0 {
+			return nil
+		}
+		return json.NewEncoder(s.w).Encode(tail)
+	}
+
+	end := "]"
+	for k, v := range tail {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		end += `,"` + k + `":` + string(b)
+	}
+
+	_, err = io.WriteString(s.w, end+"}\n")
+	return err
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	backendConfig "github.com/dwarvesf/smithy/backend/config"
+	"github.com/dwarvesf/smithy/backend/endpoints"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// streamRows return a StreamResponse writing n rows, err is returned after rows are written
+func streamRows(n int, err error) endpoints.StreamResponse {
+	return endpoints.StreamResponse{Stream: func(w sqlmapper.RowWriter) error {
+		if err := w.WriteHeader([]string{"id"}, nil); err != nil {
+			return err
+		}
+		for i := 1; i <= n; i++ {
+			if err := w.WriteRow([]interface{}{i}); err != nil {
+				if err == sqlmapper.ErrStopStream {
+					return nil
+				}
+				return err
+			}
+		}
+		return err
+	}}
+}
+
+func TestEncodeStreamResponse(t *testing.T) {
+	cfg := &backendConfig.Config{QueryMaxRows: 2}
+
+	tests := []struct {
+		name     string
+		accept   string
+		response endpoints.StreamResponse
+		want     string
+	}{
+		{
+			name:     "rows under max rows",
+			response: streamRows(2, nil),
+			want:     `{"cols":null,"columns":["id"],"status":"success","rows":[[1],[2]]}` + "\n",
+		},
+		{
+			name:     "rows over max rows are truncated",
+			response: streamRows(3, nil),
+			want:     `{"cols":null,"columns":["id"],"status":"success","rows":[[1],[2]],"truncated":true}` + "\n",
+		},
+		{
+			name:     "ndjson rows over max rows are truncated",
+			accept:   ndjsonContentType,
+			response: streamRows(3, nil),
+			want:     `{"cols":null,"columns":["id"],"status":"success"}` + "\n" + "[1]\n[2]\n" + `{"truncated":true}` + "\n",
+		},
+		{
+			name:     "error after rows is sent at the end",
+			response: streamRows(1, errors.New("scan failed")),
+			want:     `{"cols":null,"columns":["id"],"status":"success","rows":[[1]],"error":"scan failed"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx := context.WithValue(context.Background(), httptransport.ContextKeyRequestAccept, tt.accept)
+
+			if err := encodeStreamResponse(cfg)(ctx, w, tt.response); err != nil {
+				t.Fatal(err)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
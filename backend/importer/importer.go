@@ -0,0 +1,402 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/drivers"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// Formats of an import file
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Modes of an import
+const (
+	ModeInsert = "insert" // every row is inserted
+	ModeUpdate = "update" // rows are matched by primary columns and updated
+	ModeUpsert = "upsert" // rows are matched by primary columns and updated, or inserted when they don't exist
+)
+
+// DefaultBatchSize number of rows written by a bulk operation when batch size isn't set
+const DefaultBatchSize = 500
+
+// max number of row errors listed in a summary, failed rows are still counted
+const maxErrors = 1000
+
+// Options of an import
+type Options struct {
+	Format    string            `json:"format"`
+	Mode      string            `json:"mode"`
+	Mapping   map[string]string `json:"mapping"` // column of file => column of model, unmapped columns are ignored
+	DryRun    bool              `json:"dry_run"`
+	BatchSize int               `json:"batch_size"`
+}
+
+// RowError error of a row, rows are counted from 1 and csv header isn't counted
+type RowError struct {
+	Row    int                    `json:"row"`
+	Error  string                 `json:"error"`
+	Fields []sqlmapper.FieldError `json:"fields,omitempty"`
+}
+
+// Summary result of an import, in dry run inserted and updated are rows would be written
+type Summary struct {
+	DryRun   bool       `json:"dry_run"`
+	Total    int        `json:"total"`
+	Inserted int        `json:"inserted"`
+	Updated  int        `json:"updated"`
+	Failed   int        `json:"failed"`
+	Errors   []RowError `json:"errors"`
+}
+
+func (s *Summary) fail(row int, msg string, fields []sqlmapper.FieldError) {
+	s.Failed++
+	if len(s.Errors) < maxErrors {
+		s.Errors = append(s.Errors, RowError{row, msg, fields})
+	}
+}
+
+// failWith fail a row by error of its write, fields of a validation error are listed
+func (s *Summary) failWith(row int, err error) {
+	if verr, ok := err.(sqlmapper.ValidationError); ok {
+		s.fail(row, "invalid row", verr.Fields)
+		return
+	}
+	s.fail(row, err.Error(), nil)
+}
+
+// count count a written row
+func (s *Summary) count(updated bool) {
+	if updated {
+		s.Updated++
+	} else {
+		s.Inserted++
+	}
+}
+
+// Error error stopping an import, Summary has rows read and written before it. Batches written
+// before the error are committed
+type Error struct {
+	Summary Summary
+	Err     error
+}
+
+func (e Error) Error() string {
+	return e.Err.Error()
+}
+
+func (o Options) verify() (Options, error) {
+	switch o.Mode {
+	case "":
+		o.Mode = ModeInsert
+	case ModeInsert, ModeUpdate, ModeUpsert:
+	default:
+		return o, fmt.Errorf("unknown import mode %s, mode must be insert, update or upsert", o.Mode)
+	}
+
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+
+	return o, nil
+}
+
+// Import read rows of a csv or ndjson file and write them to a table with bulk operations of mapper,
+// or upsert of each row in upsert mode, so hooks of the table are run. Each row is validated against
+// model before writing, invalid rows are reported and skipped. In dry run rows are checked like
+// their write would check them, and nothing is written.
+// An error stopping the import is returned as Error with rows counted until it
+func Import(ctx context.Context, m sqlmapper.Mapper, dbName string, model database.Model, r io.Reader, opts Options) (Summary, error) {
+	res, err := importRows(ctx, m, dbName, model, r, opts)
+	if err != nil {
+		return res, Error{res, err}
+	}
+
+	return res, nil
+}
+
+func importRows(ctx context.Context, m sqlmapper.Mapper, dbName string, model database.Model, r io.Reader, opts Options) (Summary, error) {
+	res := Summary{DryRun: opts.DryRun, Errors: []RowError{}}
+	opts, err := opts.verify()
+	if err != nil {
+		return res, err
+	}
+
+	reader, err := newRecordReader(opts.Format, r)
+	if err != nil {
+		return res, err
+	}
+
+	imp := &importer{m, dbName, model, opts, &res, nil, nil}
+	for {
+		rec, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+
+		res.Total++
+		if e, ok := err.(recordError); ok {
+			res.fail(res.Total, e.Error(), nil)
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+
+		row, fields, err := imp.makeRow(rec)
+		if err != nil {
+			return res, err
+		}
+		if len(fields) > 0 {
+			res.fail(res.Total, "invalid row", fields)
+			continue
+		}
+
+		if err := imp.add(ctx, res.Total, row); err != nil {
+			return res, err
+		}
+	}
+
+	return res, imp.flush(ctx)
+}
+
+// importer write valid rows of an import in batches
+type importer struct {
+	m       sqlmapper.Mapper
+	dbName  string
+	model   database.Model
+	opts    Options
+	summary *Summary
+	rows    []sqlmapper.RowData
+	rowNums []int
+}
+
+func (imp *importer) add(ctx context.Context, rowNum int, row sqlmapper.RowData) error {
+	imp.rows = append(imp.rows, row)
+	imp.rowNums = append(imp.rowNums, rowNum)
+	if len(imp.rows) < imp.opts.BatchSize {
+		return nil
+	}
+
+	return imp.flush(ctx)
+}
+
+func (imp *importer) flush(ctx context.Context) error {
+	if len(imp.rows) == 0 {
+		return nil
+	}
+	rows, rowNums := imp.rows, imp.rowNums
+	imp.rows, imp.rowNums = nil, nil
+
+	if imp.opts.DryRun {
+		return imp.verify(ctx, rows, rowNums)
+	}
+	if imp.opts.Mode == ModeUpsert {
+		imp.upsert(ctx, rows, rowNums)
+		return nil
+	}
+
+	var results sqlmapper.BulkResults
+	var err error
+	if imp.opts.Mode == ModeUpdate {
+		results, err = imp.m.BulkUpdate(ctx, imp.dbName, imp.model.TableName, rows, sqlmapper.BulkBestEffort)
+	} else {
+		results, err = imp.m.BulkCreate(ctx, imp.dbName, imp.model.TableName, rows, sqlmapper.BulkBestEffort)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if r.Status == sqlmapper.BulkStatusSuccess {
+			imp.summary.count(imp.opts.Mode == ModeUpdate)
+			continue
+		}
+		imp.summary.fail(rowNums[r.Index], r.Error, nil)
+	}
+
+	return nil
+}
+
+// upsert write rows one by one, each row is inserted or updated in its own transaction
+func (imp *importer) upsert(ctx context.Context, rows []sqlmapper.RowData, rowNums []int) {
+	for i, row := range rows {
+		_, action, err := imp.m.Upsert(ctx, imp.dbName, imp.model.TableName, row, nil)
+		if err != nil {
+			imp.summary.failWith(rowNums[i], err)
+			continue
+		}
+		imp.summary.count(action == sqlmapper.UpsertUpdated)
+	}
+}
+
+// verify check rows of a dry run, in upsert mode a row is checked as an update when its primary key exists
+func (imp *importer) verify(ctx context.Context, rows []sqlmapper.RowData, rowNums []int) error {
+	for i, row := range rows {
+		update := imp.opts.Mode == ModeUpdate
+		if imp.opts.Mode == ModeUpsert {
+			key := sqlmapper.RowData{}
+			for _, col := range imp.model.Columns {
+				if col.IsPrimary {
+					key[col.Name] = row[col.Name]
+				}
+			}
+			found, err := imp.m.FindRows(ctx, imp.dbName, imp.model.TableName, key)
+			if err != nil {
+				return err
+			}
+			update = len(found) > 0
+		}
+
+		if err := drivers.VerifyRow(ctx, imp.dbName, imp.model, row, update); err != nil {
+			imp.summary.failWith(rowNums[i], err)
+			continue
+		}
+		imp.summary.count(update)
+	}
+
+	return nil
+}
+
+// makeRow map a record of file to columns of model and convert values by column type,
+// invalid values are returned as field errors
+func (imp *importer) makeRow(rec map[string]interface{}) (sqlmapper.RowData, []sqlmapper.FieldError, error) {
+	row := sqlmapper.RowData{}
+	fields := []sqlmapper.FieldError{}
+
+	keys := []string{}
+	for key := range rec {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v := rec[key]
+		name := key
+		if len(imp.opts.Mapping) > 0 {
+			var ok bool
+			if name, ok = imp.opts.Mapping[key]; !ok {
+				continue
+			}
+		}
+
+		col, ok := findColumn(imp.model.Columns, name)
+		if !ok {
+			return nil, nil, fmt.Errorf("column %s of file doesn't match any column of table %s", key, imp.model.TableName)
+		}
+		if col.IsComputed() {
+			fields = append(fields, sqlmapper.FieldError{Field: col.Name, Message: "is computed, it can't be set"})
+			continue
+		}
+
+		data, err := convertValue(v, col)
+		if err != nil {
+			fields = append(fields, sqlmapper.FieldError{Field: col.Name, Message: err.Error()})
+			continue
+		}
+		row[col.Name] = sqlmapper.ColData{Name: col.Name, Data: data}
+	}
+
+	for _, col := range imp.model.Columns {
+		if _, ok := row[col.Name]; ok && row[col.Name].Data != nil {
+			continue
+		}
+
+		switch {
+		case imp.opts.Mode != ModeInsert && col.IsPrimary:
+			fields = append(fields, sqlmapper.FieldError{Field: col.Name, Message: fmt.Sprintf("is required to find the row to %s", imp.opts.Mode)})
+		case imp.opts.Mode == ModeInsert && isRequired(imp.model, col):
+			fields = append(fields, sqlmapper.FieldError{Field: col.Name, Message: "is required"})
+		}
+	}
+
+	fields = append(fields, sqlmapper.ValidateRow(row, imp.model.Columns, "")...)
+
+	return row, fields, nil
+}
+
+// findColumn find a column by its name or display name
+func findColumn(columns []database.Column, name string) (database.Column, bool) {
+	for _, col := range columns {
+		if col.Name == name || (col.DisplayName != "" && col.DisplayName == name) {
+			return col, true
+		}
+	}
+
+	return database.Column{}, false
+}
+
+// isRequired check a column must have a value when a row is inserted
+func isRequired(model database.Model, col database.Column) bool {
+	if col.IsNullable || col.IsPrimary || col.DefaultValue != "" || col.IsComputed() {
+		return false
+	}
+
+	switch col.Name {
+	case model.VersionColumn:
+		return false
+	case database.CreatedAtColumn, database.UpdatedAtColumn:
+		return !model.ManagedTimestamps
+	}
+
+	return true
+}
+
+// convertValue convert a value read from file to type of column
+func convertValue(v interface{}, col database.Column) (interface{}, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}, []interface{}:
+		return nil, errors.New("must be a single value")
+	case json.Number:
+		switch col.Type {
+		case "int":
+			n, err := val.Int64()
+			if err != nil {
+				return nil, errors.New("must be an integer")
+			}
+			return n, nil
+		case "string":
+			return val.String(), nil
+		default:
+			return val.Float64()
+		}
+	case string:
+		s := strings.TrimSpace(val)
+		switch col.Type {
+		case "int":
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, errors.New("must be an integer")
+			}
+			return n, nil
+		case "float":
+			n, err := strconv.ParseFloat(s, 64)
+			if err != nil || math.IsNaN(n) {
+				return nil, errors.New("must be a number")
+			}
+			return n, nil
+		case "bool":
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, errors.New("must be true or false")
+			}
+			return b, nil
+		}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,210 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+var errDown = errors.New("database is down")
+
+// bulkMapper record rows written by bulk create or upsert, rows named "fail" are failed and a batch
+// having a row named "down" can't be written. Rows whose id is 1 exist
+type bulkMapper struct {
+	sqlmapper.Mapper
+	batches [][]sqlmapper.RowData
+}
+
+func (m *bulkMapper) BulkCreate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string) (sqlmapper.BulkResults, error) {
+	for _, r := range rows {
+		if r["name"].Data == "down" {
+			return nil, errDown
+		}
+	}
+	m.batches = append(m.batches, rows)
+	res := sqlmapper.MakeBulkResults(len(rows))
+	for i, r := range rows {
+		if r["name"].Data == "fail" {
+			res.Fail(i, mode, errors.New("failed by hook"))
+			continue
+		}
+		res[i].Status = sqlmapper.BulkStatusSuccess
+	}
+
+	return res, nil
+}
+
+func (m *bulkMapper) Upsert(ctx context.Context, dbName, tableName string, row sqlmapper.RowData, conflictColumns []string) (sqlmapper.RowData, string, error) {
+	m.batches = append(m.batches, []sqlmapper.RowData{row})
+	if row["name"].Data == "fail" {
+		return nil, "", sqlmapper.ValidationError{Fields: []sqlmapper.FieldError{{Field: "name", Message: "is taken"}}}
+	}
+	if row["id"].Data == int64(1) {
+		return row, sqlmapper.UpsertUpdated, nil
+	}
+
+	return row, sqlmapper.UpsertInserted, nil
+}
+
+func (m *bulkMapper) FindRows(ctx context.Context, dbName, tableName string, where sqlmapper.RowData) ([]sqlmapper.RowData, error) {
+	if where["id"].Data == int64(1) {
+		return []sqlmapper.RowData{where}, nil
+	}
+
+	return nil, nil
+}
+
+func TestImport(t *testing.T) {
+	model := database.Model{
+		TableName: "users",
+		Columns: []database.Column{
+			{Name: "id", Type: "int", IsPrimary: true},
+			{Name: "name", Type: "string", DisplayName: "Full name"},
+			{Name: "age", Type: "int", IsNullable: true},
+		},
+	}
+
+	type args struct {
+		file string
+		opts Options
+	}
+	tests := []struct {
+		name        string
+		args        args
+		want        Summary
+		wantBatches int
+		wantErr     bool
+	}{
+		{
+			name: "csv with display names",
+			args: args{
+				file: "Full name,age\nHieu,20\nfail,\nAn,x\n,30\n",
+				opts: Options{Format: FormatCSV, BatchSize: 1},
+			},
+			want: Summary{
+				Total:    4,
+				Inserted: 1,
+				Failed:   3,
+				Errors: []RowError{
+					{Row: 2, Error: "failed by hook"},
+					{Row: 3, Error: "invalid row", Fields: []sqlmapper.FieldError{{Field: "age", Message: "must be an integer"}}},
+					{Row: 4, Error: "invalid row", Fields: []sqlmapper.FieldError{{Field: "name", Message: "is required"}}},
+				},
+			},
+			wantBatches: 2,
+		},
+		{
+			name: "ndjson with mapping in dry run",
+			args: args{
+				file: "{\"n\": \"Hieu\", \"a\": 20, \"skipped\": true}\n\n{\"n\": \"An\", \"a\": 1.5}\n",
+				opts: Options{Format: FormatNDJSON, DryRun: true, Mapping: map[string]string{"n": "name", "a": "age"}},
+			},
+			want: Summary{
+				DryRun:   true,
+				Total:    2,
+				Inserted: 1,
+				Failed:   1,
+				Errors: []RowError{
+					{Row: 2, Error: "invalid row", Fields: []sqlmapper.FieldError{{Field: "age", Message: "must be an integer"}}},
+				},
+			},
+		},
+		{
+			name: "update requires primary columns",
+			args: args{
+				file: "id,name\n,Hieu\n",
+				opts: Options{Format: FormatCSV, Mode: ModeUpdate, DryRun: true},
+			},
+			want: Summary{
+				DryRun: true,
+				Total:  1,
+				Failed: 1,
+				Errors: []RowError{
+					{Row: 1, Error: "invalid row", Fields: []sqlmapper.FieldError{{Field: "id", Message: "is required to find the row to update"}}},
+				},
+			},
+		},
+		{
+			name: "upsert counts inserted and updated rows",
+			args: args{
+				file: "id,name\n1,Hieu\n2,An\n3,fail\n",
+				opts: Options{Format: FormatCSV, Mode: ModeUpsert},
+			},
+			want: Summary{
+				Total:    3,
+				Inserted: 1,
+				Updated:  1,
+				Failed:   1,
+				Errors: []RowError{
+					{Row: 3, Error: "invalid row", Fields: []sqlmapper.FieldError{{Field: "name", Message: "is taken"}}},
+				},
+			},
+			wantBatches: 3,
+		},
+		{
+			name: "dry run checks rows like their write",
+			args: args{
+				file: "id,age\n1,20\n2,30\n",
+				opts: Options{Format: FormatCSV, Mode: ModeUpsert, DryRun: true},
+			},
+			want: Summary{
+				DryRun:  true,
+				Total:   2,
+				Updated: 1,
+				Failed:  1,
+				Errors: []RowError{
+					{Row: 2, Error: "invalid row", Fields: []sqlmapper.FieldError{{Field: "name", Message: "is required"}}},
+				},
+			},
+		},
+		{
+			name: "rows written before an error are counted",
+			args: args{
+				file: "name\nHieu\ndown\n",
+				opts: Options{Format: FormatCSV, BatchSize: 1},
+			},
+			want: Summary{
+				Total:    2,
+				Inserted: 1,
+				Errors:   []RowError{},
+			},
+			wantBatches: 1,
+			wantErr:     true,
+		},
+		{
+			name: "unknown column",
+			args: args{
+				file: "email\nhieu@dwarvesv.com\n",
+				opts: Options{Format: FormatCSV},
+			},
+			want: Summary{
+				Total:  1,
+				Errors: []RowError{},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &bulkMapper{}
+			got, err := Import(context.Background(), m, "fortress", model, strings.NewReader(tt.args.file), tt.args.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Import() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !reflect.DeepEqual(err.(Error).Summary, tt.want) {
+				t.Errorf("Import() error summary = %+v, want %+v", err.(Error).Summary, tt.want)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Import() = %+v, want %+v", got, tt.want)
+			}
+			if len(m.batches) != tt.wantBatches {
+				t.Errorf("Import() wrote %d batches, want %d", len(m.batches), tt.wantBatches)
+			}
+		})
+	}
+}
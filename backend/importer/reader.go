@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// max length of a NDJSON line
+const maxLineSize = 10 * 1024 * 1024
+
+// recordReader read records of a file keyed by its columns, io.EOF is returned at the end
+type recordReader interface {
+	next() (map[string]interface{}, error)
+}
+
+// recordError a record can't be read, other records can still be read
+type recordError struct {
+	msg string
+}
+
+func (e recordError) Error() string {
+	return e.msg
+}
+
+func newRecordReader(format string, r io.Reader) (recordReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatNDJSON:
+		s := bufio.NewScanner(r)
+		s.Buffer(make([]byte, 64*1024), maxLineSize)
+		return &ndjsonReader{s}, nil
+	default:
+		return nil, fmt.Errorf("unknown import format %s, format must be csv or ndjson", format)
+	}
+}
+
+// csvReader read a csv file whose first line is header, an empty cell is read as null
+type csvReader struct {
+	r       *csv.Reader
+	headers []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	headers, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("missing header of csv file")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &csvReader{cr, headers}, nil
+}
+
+func (c *csvReader) next() (map[string]interface{}, error) {
+	rec, err := c.r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rec) != len(c.headers) {
+		return nil, recordError{fmt.Sprintf("expected %d cells, got %d", len(c.headers), len(rec))}
+	}
+
+	res := make(map[string]interface{})
+	for i, h := range c.headers {
+		if rec[i] == "" {
+			res[h] = nil
+			continue
+		}
+		res[h] = rec[i]
+	}
+
+	return res, nil
+}
+
+// ndjsonReader read a file with a json object for each line, empty lines are skipped
+type ndjsonReader struct {
+	s *bufio.Scanner
+}
+
+func (n *ndjsonReader) next() (map[string]interface{}, error) {
+	for n.s.Scan() {
+		line := bytes.TrimSpace(n.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		d := json.NewDecoder(bytes.NewReader(line))
+		d.UseNumber()
+
+		res := make(map[string]interface{})
+		if err := d.Decode(&res); err != nil {
+			return nil, recordError{fmt.Sprintf("invalid json object: %v", err)}
+		}
+
+		return res, nil
+	}
+
+	if err := n.s.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
@@ -0,0 +1,23 @@
+package audit
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"github.com/dwarvesf/smithy/backend/domain"
+)
+
+type pgService struct {
+	db *gorm.DB
+}
+
+// NewPGService .
+func NewPGService(db *gorm.DB) Service {
+	return &pgService{
+		db: db,
+	}
+}
+
+// Create implement Create for Audit service
+func (s *pgService) Create(a *domain.AuditLog) error {
+	return s.db.Create(a).Error
+}
@@ -0,0 +1,8 @@
+package audit
+
+import "github.com/dwarvesf/smithy/backend/domain"
+
+// Service interface for audit service
+type Service interface {
+	Create(a *domain.AuditLog) error
+}
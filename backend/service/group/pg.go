@@ -36,6 +36,7 @@ func (s *pgService) Update(p *domain.Group) (*domain.Group, error) {
 	if p.Description != "" {
 		old.Description = p.Description
 	}
+	old.Unmask = p.Unmask
 
 	return &old, s.db.Save(&old).Error
 }
@@ -83,7 +84,7 @@ func (s *pgService) Delete(p *domain.Group) error {
 // GetPermission implement get permission for User service
 func (s *pgService) GetPermission(p *domain.Group, dbName string, tableName string) ([]domain.Permission, error) {
 	pers := []domain.Permission{}
-	if err := s.db.Where("group_id = ? AND database_name = ? AND table_name = ?", p.ID, dbName, tableName).Find(&pers).Error; err != nil {
+	if err := s.db.Preload("Columns").Preload("RowFilters").Where("group_id = ? AND database_name = ? AND table_name = ?", p.ID, dbName, tableName).Find(&pers).Error; err != nil {
 		if err != gorm.ErrRecordNotFound {
 			return nil, err
 		}
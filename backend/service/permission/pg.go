@@ -32,10 +32,70 @@ func (s *pgService) Update(p *domain.Permission) (*domain.Permission, error) {
 	return &old, s.db.Save(&old).Error
 }
 
+// UpdateColumns replace column grants of a permission
+func (s *pgService) UpdateColumns(p *domain.Permission, columns []domain.ColumnPermission) (*domain.Permission, error) {
+	old := domain.Permission{Model: domain.Model{ID: p.ID}}
+	if err := s.db.Find(&old).Error; err != nil {
+		return nil, err
+	}
+
+	tx := s.db.Begin()
+	if err := tx.Where("permission_id = ?", old.ID).Delete(domain.ColumnPermission{}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	old.Columns = []domain.ColumnPermission{}
+	for _, c := range columns {
+		c.ID = domain.UUID{}
+		c.PermissionID = old.ID
+		if err := tx.Create(&c).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		old.Columns = append(old.Columns, c)
+	}
+
+	return &old, tx.Commit().Error
+}
+
+// UpdateRowFilters replace row filters of a permission
+func (s *pgService) UpdateRowFilters(p *domain.Permission, filters []domain.RowFilter) (*domain.Permission, error) {
+	old := domain.Permission{Model: domain.Model{ID: p.ID}}
+	if err := s.db.Find(&old).Error; err != nil {
+		return nil, err
+	}
+
+	tx := s.db.Begin()
+	if err := tx.Where("permission_id = ?", old.ID).Delete(domain.RowFilter{}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	old.RowFilters = []domain.RowFilter{}
+	for _, f := range filters {
+		f.ID = domain.UUID{}
+		f.PermissionID = old.ID
+		if err := tx.Create(&f).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		old.RowFilters = append(old.RowFilters, f)
+	}
+
+	return &old, tx.Commit().Error
+}
+
+// Find get a permission by id
+func (s *pgService) Find(p *domain.Permission) (*domain.Permission, error) {
+	res := domain.Permission{Model: domain.Model{ID: p.ID}}
+	return &res, s.db.Find(&res).Error
+}
+
 // FindByUser implement get permission for a user
 func (s *pgService) FindByUser(p *domain.User) ([]domain.Permission, error) {
 	pers := []domain.Permission{}
-	if err := s.db.Where("user_id = ?", p.ID).Find(&pers).Error; err != nil {
+	if err := s.db.Preload("Columns").Preload("RowFilters").Where("user_id = ?", p.ID).Find(&pers).Error; err != nil {
 		if err != gorm.ErrRecordNotFound {
 			return nil, err
 		}
@@ -47,7 +107,7 @@ func (s *pgService) FindByUser(p *domain.User) ([]domain.Permission, error) {
 // FindByGroup implement get permission for a user
 func (s *pgService) FindByGroup(p *domain.Group) ([]domain.Permission, error) {
 	pers := []domain.Permission{}
-	if err := s.db.Where("group_id = ?", p.ID).Find(&pers).Error; err != nil {
+	if err := s.db.Preload("Columns").Preload("RowFilters").Where("group_id = ?", p.ID).Find(&pers).Error; err != nil {
 		if err != gorm.ErrRecordNotFound {
 			return nil, err
 		}
@@ -5,6 +5,9 @@ import "github.com/dwarvesf/smithy/backend/domain"
 // Service interface for project service
 type Service interface {
 	Update(p *domain.Permission) (*domain.Permission, error)
+	UpdateColumns(p *domain.Permission, columns []domain.ColumnPermission) (*domain.Permission, error)
+	UpdateRowFilters(p *domain.Permission, filters []domain.RowFilter) (*domain.Permission, error)
+	Find(p *domain.Permission) (*domain.Permission, error)
 	FindByUser(p *domain.User) ([]domain.Permission, error)
 	FindByGroup(p *domain.Group) ([]domain.Permission, error)
 }
@@ -0,0 +1,136 @@
+package revision
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/dwarvesf/smithy/backend/domain"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// revisionStore record a revision for each row written through a mapper
+type revisionStore struct {
+	sqlmapper.Mapper
+	service  Service
+	modelMap map[string]map[string]database.Model
+	userName string
+
+	mu   sync.Mutex
+	last time.Time // creation time of the last revision
+}
+
+// NewMapper wrap a mapper, so rows created, updated or deleted by user are recorded as revisions. Rows are
+// recorded in transaction of the write, including rows written by relationships, on_delete and hooks,
+// a revision which can't be recorded fails the write
+func NewMapper(m sqlmapper.Mapper, s Service, modelMap map[string]map[string]database.Model, userName string) sqlmapper.Mapper {
+	return &revisionStore{
+		Mapper:   m,
+		service:  s,
+		modelMap: modelMap,
+		userName: userName,
+	}
+}
+
+func (s *revisionStore) Create(ctx context.Context, dbName, tableName string, d sqlmapper.RowData) (sqlmapper.RowData, error) {
+	return s.Mapper.Create(sqlmapper.WithWriteRecorder(ctx, s), dbName, tableName, d)
+}
+
+func (s *revisionStore) Update(ctx context.Context, dbName, tableName string, d sqlmapper.RowData) (sqlmapper.RowData, error) {
+	return s.Mapper.Update(sqlmapper.WithWriteRecorder(ctx, s), dbName, tableName, d)
+}
+
+func (s *revisionStore) Delete(ctx context.Context, dbName, tableName string, fields, data []interface{}) error {
+	return s.Mapper.Delete(sqlmapper.WithWriteRecorder(ctx, s), dbName, tableName, fields, data)
+}
+
+func (s *revisionStore) Restore(ctx context.Context, dbName, tableName string, fields, data []interface{}) error {
+	return s.Mapper.Restore(sqlmapper.WithWriteRecorder(ctx, s), dbName, tableName, fields, data)
+}
+
+func (s *revisionStore) Upsert(ctx context.Context, dbName, tableName string, d sqlmapper.RowData, conflictColumns []string) (sqlmapper.RowData, string, error) {
+	return s.Mapper.Upsert(sqlmapper.WithWriteRecorder(ctx, s), dbName, tableName, d, conflictColumns)
+}
+
+func (s *revisionStore) BulkCreate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string) (sqlmapper.BulkResults, error) {
+	return s.Mapper.BulkCreate(sqlmapper.WithWriteRecorder(ctx, s), dbName, tableName, rows, mode)
+}
+
+func (s *revisionStore) BulkUpdate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string) (sqlmapper.BulkResults, error) {
+	return s.Mapper.BulkUpdate(sqlmapper.WithWriteRecorder(ctx, s), dbName, tableName, rows, mode)
+}
+
+func (s *revisionStore) BulkDelete(ctx context.Context, dbName, tableName string, fields []interface{}, rows [][]interface{}, mode string) (sqlmapper.BulkResults, error) {
+	return s.Mapper.BulkDelete(sqlmapper.WithWriteRecorder(ctx, s), dbName, tableName, fields, rows, mode)
+}
+
+func (s *revisionStore) Transaction(ctx context.Context, dbName string, ops []sqlmapper.Operation) ([]sqlmapper.RowData, error) {
+	return s.Mapper.Transaction(sqlmapper.WithWriteRecorder(ctx, s), dbName, ops)
+}
+
+// RecordWrite save changed columns of a written row as a revision, rows of tables without primary key
+// aren't recorded
+func (s *revisionStore) RecordWrite(ctx context.Context, w sqlmapper.RowWrite) error {
+	model := s.modelMap[w.DBName][w.TableName]
+	row := w.After
+	if row == nil {
+		row = w.Before
+	}
+
+	keyValues := make(map[string]interface{})
+	for _, col := range model.Columns {
+		if col.IsPrimary {
+			keyValues[col.Name] = row[col.Name].Data
+		}
+	}
+	if len(keyValues) == 0 {
+		return nil
+	}
+
+	before, changed := changedColumns(w.Before, w.After)
+	if len(before) == 0 && len(changed) == 0 {
+		return nil
+	}
+
+	key, err := RecordKey(model, keyValues)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	a, err := json.Marshal(changed)
+	if err != nil {
+		return err
+	}
+
+	return s.service.Create(&domain.Revision{
+		Model:        domain.Model{CreatedAt: s.now()},
+		DatabaseName: w.DBName,
+		TableName:    w.TableName,
+		RecordKey:    key,
+		Action:       w.Action,
+		Username:     s.userName,
+		Before:       domain.JSONText(b),
+		After:        domain.JSONText(a),
+	})
+}
+
+// now return creation time of a revision, revisions recorded through the mapper keep their order
+// even when they are recorded in the same microsecond
+func (s *revisionStore) now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := time.Now().Truncate(time.Microsecond)
+	if !t.After(s.last) {
+		t = s.last.Add(time.Microsecond)
+	}
+	s.last = t
+
+	return t
+}
@@ -0,0 +1,68 @@
+package revision
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/domain"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// revisionList keep created revisions in memory
+type revisionList struct {
+	Service
+	revisions []domain.Revision
+}
+
+func (l *revisionList) Create(r *domain.Revision) error {
+	l.revisions = append(l.revisions, *r)
+	return nil
+}
+
+func TestRecordWrite(t *testing.T) {
+	modelMap := map[string]map[string]database.Model{
+		"fortress": {
+			"users": {TableName: "users", Columns: []database.Column{{Name: "id", IsPrimary: true}, {Name: "name"}}},
+			"logs":  {TableName: "logs", Columns: []database.Column{{Name: "body"}}},
+		},
+	}
+	list := &revisionList{}
+	s := NewMapper(nil, list, modelMap, "admin").(*revisionStore)
+	ctx := context.Background()
+	ann := sqlmapper.RowData{"id": {Data: 1}, "name": {Data: "ann"}}
+	anna := sqlmapper.RowData{"id": {Data: 1}, "name": {Data: "anna"}}
+
+	for _, w := range []sqlmapper.RowWrite{
+		{DBName: "fortress", TableName: "users", Action: sqlmapper.WriteCreate, After: ann},
+		{DBName: "fortress", TableName: "users", Action: sqlmapper.WriteUpdate, Before: ann, After: ann},
+		{DBName: "fortress", TableName: "logs", Action: sqlmapper.WriteCreate, After: sqlmapper.RowData{"body": {Data: "x"}}},
+		{DBName: "fortress", TableName: "users", Action: sqlmapper.WriteUpdate, Before: ann, After: anna},
+		{DBName: "fortress", TableName: "users", Action: sqlmapper.WriteDelete, Before: anna},
+	} {
+		if err := s.RecordWrite(ctx, w); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// unchanged rows and rows of tables without primary key aren't recorded
+	want := []struct {
+		action        string
+		before, after domain.JSONText
+	}{
+		{domain.RevisionCreate, `{}`, `{"id":1,"name":"ann"}`},
+		{domain.RevisionUpdate, `{"name":"ann"}`, `{"name":"anna"}`},
+		{domain.RevisionDelete, `{"id":1,"name":"anna"}`, `{}`},
+	}
+	if len(list.revisions) != len(want) {
+		t.Fatalf("revisions = %+v, want %d revisions", list.revisions, len(want))
+	}
+	for i, r := range list.revisions {
+		if r.Action != want[i].action || r.Before != want[i].before || r.After != want[i].after || r.RecordKey != `{"id":"1"}` || r.Username != "admin" {
+			t.Errorf("revision %d = %+v, want %+v", i, r, want[i])
+		}
+		if i > 0 && !r.CreatedAt.After(list.revisions[i-1].CreatedAt) {
+			t.Errorf("revision %d isn't created after revision %d", i, i-1)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package revision
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"github.com/dwarvesf/smithy/backend/domain"
+)
+
+type pgService struct {
+	db *gorm.DB
+}
+
+// NewPGService .
+func NewPGService(db *gorm.DB) Service {
+	return &pgService{
+		db: db,
+	}
+}
+
+// Create implement Create for Revision service
+func (s *pgService) Create(r *domain.Revision) error {
+	return s.db.Create(r).Error
+}
+
+// Find implement Find for Revision service
+func (s *pgService) Find(id domain.UUID) (*domain.Revision, error) {
+	r := &domain.Revision{}
+	return r, s.db.Where("id = ?", id).First(r).Error
+}
+
+// FindByRecord implement get revisions of a record, oldest revision first. Revisions created at the same time
+// are ordered by id, so their order is stable
+func (s *pgService) FindByRecord(dbName, tableName, recordKey string) ([]domain.Revision, error) {
+	res := []domain.Revision{}
+	err := s.db.Where("database_name = ? AND table_name = ? AND record_key = ?", dbName, tableName, recordKey).
+		Order("created_at, id").
+		Find(&res).Error
+
+	return res, err
+}
@@ -0,0 +1,231 @@
+package revision
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/dwarvesf/smithy/backend/domain"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// Change values of a column in two states of a record
+type Change struct {
+	Column string      `json:"column"`
+	From   interface{} `json:"from"`
+	To     interface{} `json:"to"`
+}
+
+// RecordKey make key of a record from its primary columns, values are kept as text so
+// a key made from url query is the same as a key made from a row
+func RecordKey(model database.Model, row map[string]interface{}) (string, error) {
+	key := make(map[string]string)
+	for _, col := range model.Columns {
+		if !col.IsPrimary {
+			continue
+		}
+
+		v, ok := row[col.Name]
+		if !ok || v == nil {
+			return "", fmt.Errorf("missing primary column %s of table %s", col.Name, model.TableName)
+		}
+		key[col.Name] = fmt.Sprint(v)
+	}
+
+	if len(key) == 0 {
+		return "", fmt.Errorf("table %s has no primary column", model.TableName)
+	}
+
+	b, err := json.Marshal(key)
+	return string(b), err
+}
+
+// changedColumns return values of changed columns before and after a write,
+// a nil row means the record doesn't exist
+func changedColumns(before, after sqlmapper.RowData) (map[string]interface{}, map[string]interface{}) {
+	b, a := make(map[string]interface{}), make(map[string]interface{})
+	for col, v := range after {
+		old, ok := before[col]
+		if before != nil && ok && reflect.DeepEqual(old.Data, v.Data) {
+			continue
+		}
+		if before == nil && v.Data == nil {
+			continue
+		}
+
+		a[col] = v.Data
+		if before != nil {
+			b[col] = old.Data
+		}
+	}
+
+	if after == nil {
+		for col, v := range before {
+			b[col] = v.Data
+		}
+	}
+
+	return b, a
+}
+
+// values decode before and after values of a revision
+func values(r domain.Revision) (map[string]interface{}, map[string]interface{}, error) {
+	before, after := make(map[string]interface{}), make(map[string]interface{})
+	if r.Before != "" {
+		if err := json.Unmarshal([]byte(r.Before), &before); err != nil {
+			return nil, nil, err
+		}
+	}
+	if r.After != "" {
+		if err := json.Unmarshal([]byte(r.After), &after); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return before, after, nil
+}
+
+func indexOf(revisions []domain.Revision, id domain.UUID) int {
+	for i, r := range revisions {
+		if r.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Diff return columns which are different between the record right after revision from and
+// right after revision to. Revisions are all revisions of the record, oldest first
+func Diff(revisions []domain.Revision, from, to domain.UUID) ([]Change, error) {
+	i, j := indexOf(revisions, from), indexOf(revisions, to)
+	if i < 0 || j < 0 {
+		return nil, errors.New("revisions aren't of the same record")
+	}
+
+	reversed := i > j
+	if reversed {
+		i, j = j, i
+	}
+
+	fromValues, toValues := make(map[string]interface{}), make(map[string]interface{})
+	for _, r := range revisions[i+1 : j+1] {
+		before, after, err := values(r)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range []map[string]interface{}{before, after} {
+			for col := range m {
+				// value right after revision i is before value of the first later change
+				if _, ok := fromValues[col]; !ok {
+					fromValues[col] = before[col]
+				}
+				toValues[col] = after[col]
+			}
+		}
+	}
+
+	res := []Change{}
+	for col := range fromValues {
+		if reflect.DeepEqual(fromValues[col], toValues[col]) {
+			continue
+		}
+
+		c := Change{col, fromValues[col], toValues[col]}
+		if reversed {
+			c.From, c.To = c.To, c.From
+		}
+		res = append(res, c)
+	}
+	sort.Slice(res, func(a, b int) bool { return res[a].Column < res[b].Column })
+
+	return res, nil
+}
+
+// RestoreValues return values of columns changed after a revision, as they were right after
+// the revision. Primary columns and columns set by server aren't restored
+func RestoreValues(model database.Model, revisions []domain.Revision, id domain.UUID) (map[string]interface{}, error) {
+	i := indexOf(revisions, id)
+	if i < 0 {
+		return nil, errors.New("revision isn't of the record")
+	}
+
+	skipped := map[string]bool{model.VersionColumn: true}
+	if model.ManagedTimestamps {
+		skipped[database.CreatedAtColumn] = true
+		skipped[database.UpdatedAtColumn] = true
+	}
+	for _, col := range model.Columns {
+		if col.IsPrimary {
+			skipped[col.Name] = true
+		}
+	}
+
+	res := make(map[string]interface{})
+	for _, r := range revisions[i+1:] {
+		before, after, err := values(r)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range []map[string]interface{}{before, after} {
+			for col := range m {
+				if _, ok := res[col]; !ok && !skipped[col] {
+					res[col] = before[col]
+				}
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Readable hide values of revisions a user of ctx can't see, columns without read grant
+// are removed and masked columns are masked
+func Readable(ctx context.Context, dbName string, model database.Model, revisions []domain.Revision) ([]domain.Revision, error) {
+	colMap := database.Columns(model.Columns).GroupByName()
+	hide := func(m map[string]interface{}) {
+		for col, v := range m {
+			if !sqlmapper.CanRead(ctx, dbName, model.TableName, col) {
+				delete(m, col)
+				continue
+			}
+			if cols, ok := colMap[col]; ok && sqlmapper.IsMasked(ctx) {
+				m[col] = cols[0].MaskValue(v)
+			}
+		}
+	}
+
+	res := []domain.Revision{}
+	for _, r := range revisions {
+		before, after, err := values(r)
+		if err != nil {
+			return nil, err
+		}
+		hide(before)
+		hide(after)
+
+		if r.Before != "" {
+			b, err := json.Marshal(before)
+			if err != nil {
+				return nil, err
+			}
+			r.Before = domain.JSONText(b)
+		}
+		if r.After != "" {
+			b, err := json.Marshal(after)
+			if err != nil {
+				return nil, err
+			}
+			r.After = domain.JSONText(b)
+		}
+		res = append(res, r)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,129 @@
+package revision
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/domain"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+func makeRevisions() []domain.Revision {
+	return []domain.Revision{
+		{Model: domain.Model{ID: domain.NewUUID()}, Action: domain.RevisionCreate, After: `{"id":1,"name":"Hieu","age":20}`},
+		{Model: domain.Model{ID: domain.NewUUID()}, Action: domain.RevisionUpdate, Before: `{"name":"Hieu"}`, After: `{"name":"Hieu Phan"}`},
+		{Model: domain.Model{ID: domain.NewUUID()}, Action: domain.RevisionUpdate, Before: `{"age":20,"updated_at":"2018-06-01"}`, After: `{"age":21,"updated_at":"2018-07-01"}`},
+		{Model: domain.Model{ID: domain.NewUUID()}, Action: domain.RevisionUpdate, Before: `{"name":"Hieu Phan"}`, After: `{"name":"Hieu"}`},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	revisions := makeRevisions()
+	tests := []struct {
+		name    string
+		from    domain.UUID
+		to      domain.UUID
+		want    []Change
+		wantErr bool
+	}{
+		{
+			name: "changes between revisions",
+			from: revisions[1].ID,
+			to:   revisions[2].ID,
+			want: []Change{
+				{Column: "age", From: float64(20), To: float64(21)},
+				{Column: "updated_at", From: "2018-06-01", To: "2018-07-01"},
+			},
+		},
+		{
+			name: "column changed back is not different",
+			from: revisions[0].ID,
+			to:   revisions[3].ID,
+			want: []Change{
+				{Column: "age", From: float64(20), To: float64(21)},
+				{Column: "updated_at", From: "2018-06-01", To: "2018-07-01"},
+			},
+		},
+		{
+			name: "newer revision first",
+			from: revisions[2].ID,
+			to:   revisions[1].ID,
+			want: []Change{
+				{Column: "age", From: float64(21), To: float64(20)},
+				{Column: "updated_at", From: "2018-07-01", To: "2018-06-01"},
+			},
+		},
+		{
+			name:    "revision of another record",
+			from:    revisions[0].ID,
+			to:      domain.NewUUID(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diff(revisions, tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Diff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreValues(t *testing.T) {
+	revisions := makeRevisions()
+	model := database.Model{
+		ManagedTimestamps: true,
+		Columns:           []database.Column{{Name: "id", IsPrimary: true}, {Name: "name"}, {Name: "age"}},
+	}
+
+	got, err := RestoreValues(model, revisions, revisions[1].ID)
+	if err != nil {
+		t.Fatalf("RestoreValues() error = %v", err)
+	}
+
+	want := map[string]interface{}{"age": float64(20), "name": "Hieu Phan"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RestoreValues() = %v, want %v", got, want)
+	}
+}
+
+// hiddenAge deny reading column age
+type hiddenAge struct{}
+
+func (hiddenAge) CanRead(dbName, tableName, column string) bool  { return column != "age" }
+func (hiddenAge) CanWrite(dbName, tableName, column string) bool { return column != "age" }
+
+func TestReadable(t *testing.T) {
+	model := database.Model{
+		TableName: "users",
+		Columns: []database.Column{
+			{Name: "id", IsPrimary: true},
+			{Name: "name", Mask: database.MaskFull},
+			{Name: "age"},
+		},
+	}
+	ctx := sqlmapper.WithMask(sqlmapper.WithColumnPermission(context.Background(), hiddenAge{}))
+
+	got, err := Readable(ctx, "fortress", model, makeRevisions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ before, after domain.JSONText }{
+		{"", `{"id":1,"name":"****"}`},
+		{`{"name":"****"}`, `{"name":"****"}`},
+		{`{"updated_at":"2018-06-01"}`, `{"updated_at":"2018-07-01"}`},
+		{`{"name":"****"}`, `{"name":"****"}`},
+	}
+	for i, r := range got {
+		if r.Before != want[i].before || r.After != want[i].after {
+			t.Errorf("Readable()[%d] = %s %s, want %s %s", i, r.Before, r.After, want[i].before, want[i].after)
+		}
+	}
+}
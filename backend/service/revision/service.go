@@ -0,0 +1,10 @@
+package revision
+
+import "github.com/dwarvesf/smithy/backend/domain"
+
+// Service interface for revision service
+type Service interface {
+	Create(r *domain.Revision) error
+	Find(id domain.UUID) (*domain.Revision, error)
+	FindByRecord(dbName, tableName, recordKey string) ([]domain.Revision, error)
+}
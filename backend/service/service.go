@@ -3,8 +3,10 @@ package service
 import (
 	"github.com/dwarvesf/smithy/backend"
 	backendConfig "github.com/dwarvesf/smithy/backend/config"
+	auditSrv "github.com/dwarvesf/smithy/backend/service/audit"
 	groupSrv "github.com/dwarvesf/smithy/backend/service/group"
 	permissionSrv "github.com/dwarvesf/smithy/backend/service/permission"
+	revisionSrv "github.com/dwarvesf/smithy/backend/service/revision"
 	userSrv "github.com/dwarvesf/smithy/backend/service/user"
 	"github.com/dwarvesf/smithy/backend/sqlmapper"
 	"github.com/dwarvesf/smithy/backend/view"
@@ -19,6 +21,8 @@ type Service struct {
 	UserService       userSrv.Service
 	GroupService      groupSrv.Service
 	PermissionService permissionSrv.Service
+	AuditService      auditSrv.Service
+	RevisionService   revisionSrv.Service
 }
 
 // NewService new dashboard handler
@@ -37,5 +41,13 @@ func NewService(cfg *backendConfig.Config, db *gorm.DB) (Service, error) {
 		UserService:       userSrv.NewPGService(db),
 		GroupService:      groupSrv.NewPGService(db),
 		PermissionService: permissionSrv.NewPGService(db),
+		AuditService:      auditSrv.NewPGService(db),
+		RevisionService:   revisionSrv.NewPGService(db),
 	}, nil
 }
+
+// WithRevisions return a copy of service, rows written by its mapper are recorded as revisions of user
+func (s Service) WithRevisions(userName string) Service {
+	s.Mapper = revisionSrv.NewMapper(s.Mapper, s.RevisionService, s.SyncConfig().ModelMap, userName)
+	return s
+}
@@ -60,6 +60,10 @@ func (s *pgService) Update(p *domain.User) (*domain.User, error) {
 		old.ConfirmCode = p.ConfirmCode
 	}
 
+	if p.Claims != nil {
+		old.Claims = p.Claims
+	}
+
 	return old, s.db.Save(old).Error
 }
 
@@ -97,7 +101,7 @@ func (s *pgService) Delete(p *domain.User) error {
 // GetPermission implement get permission for User service
 func (s *pgService) GetPermission(p *domain.User, dbName string, tableName string) ([]domain.Permission, error) {
 	pers := []domain.Permission{}
-	if err := s.db.Where("user_id = ? AND database_name = ? AND table_name = ?", p.ID, dbName, tableName).Find(&pers).Error; err != nil {
+	if err := s.db.Preload("Columns").Preload("RowFilters").Where("user_id = ? AND database_name = ? AND table_name = ?", p.ID, dbName, tableName).Find(&pers).Error; err != nil {
 		if err != gorm.ErrRecordNotFound {
 			return nil, err
 		}
@@ -116,7 +120,7 @@ func (s *pgService) GetPermissionUserAndGroup(p *domain.User, dbName string, tab
 
 	// get user permission
 	userPermission := &domain.Permission{}
-	if err := s.db.Where("user_id = ? AND database_name = ? AND table_name = ?", p.ID, dbName, tableName).First(userPermission).Error; err != nil {
+	if err := s.db.Preload("Columns").Preload("RowFilters").Where("user_id = ? AND database_name = ? AND table_name = ?", p.ID, dbName, tableName).First(userPermission).Error; err != nil {
 		if err != gorm.ErrRecordNotFound {
 			return nil, err
 		}
@@ -130,7 +134,7 @@ func (s *pgService) GetPermissionUserAndGroup(p *domain.User, dbName string, tab
 	groupPermissions := []domain.Permission{}
 	for _, group := range groups {
 		g := domain.Permission{}
-		if err := s.db.Where("group_id = ? AND database_name = ? AND table_name = ?", group.ID, dbName, tableName).First(&g).Error; err != nil {
+		if err := s.db.Preload("Columns").Preload("RowFilters").Where("group_id = ? AND database_name = ? AND table_name = ?", group.ID, dbName, tableName).First(&g).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				continue
 			} else {
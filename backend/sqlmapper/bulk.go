@@ -0,0 +1,77 @@
+package sqlmapper
+
+import "fmt"
+
+// Modes of a bulk operation
+const (
+	BulkAllOrNothing = "all_or_nothing" // any failed row rollback the whole operation
+	BulkBestEffort   = "best_effort"    // failed rows are skipped, other rows are still committed
+)
+
+// Status of a row in bulk operation
+const (
+	BulkStatusSuccess    = "success"
+	BulkStatusFailed     = "failed"
+	BulkStatusRolledBack = "rolled_back" // row was written but the transaction was rolled back
+	BulkStatusSkipped    = "skipped"     // row was not processed because another row failed
+)
+
+// BulkResult result of a row in bulk operation
+type BulkResult struct {
+	Index  int     `json:"index"`
+	Status string  `json:"status"`
+	Data   RowData `json:"data,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// BulkResults results of all rows in a bulk operation
+type BulkResults []BulkResult
+
+// IsSuccess check all rows in a bulk operation are success
+func (rs BulkResults) IsSuccess() bool {
+	for _, r := range rs {
+		if r.Status != BulkStatusSuccess {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MakeBulkResults make results for n rows with skipped status
+func MakeBulkResults(n int) BulkResults {
+	res := make(BulkResults, n)
+	for i := range res {
+		res[i] = BulkResult{Index: i, Status: BulkStatusSkipped}
+	}
+
+	return res
+}
+
+// Fail mark a row as failed, in all_or_nothing mode success rows are marked as rolled back
+func (rs BulkResults) Fail(i int, mode string, err error) {
+	rs[i].Status = BulkStatusFailed
+	rs[i].Data = nil
+	rs[i].Error = err.Error()
+	if mode != BulkAllOrNothing {
+		return
+	}
+
+	for j := range rs {
+		if rs[j].Status == BulkStatusSuccess {
+			rs[j].Status = BulkStatusRolledBack
+		}
+	}
+}
+
+// VerifyBulkMode check mode of a bulk operation, all_or_nothing is used by default
+func VerifyBulkMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return BulkAllOrNothing, nil
+	case BulkAllOrNothing, BulkBestEffort:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown bulk mode %s", mode)
+	}
+}
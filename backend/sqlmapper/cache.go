@@ -0,0 +1,52 @@
+package sqlmapper
+
+import (
+	"context"
+	"time"
+)
+
+// CacheStats statistics of a mapper caching results of queries and views
+type CacheStats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Entries       int    `json:"entries"`
+	Invalidations uint64 `json:"invalidations"` // entries removed by writes
+}
+
+// CacheStatsReporter is implemented by a mapper caching results
+type CacheStatsReporter interface {
+	CacheStats() CacheStats
+}
+
+// CacheInvalidator is implemented by a mapper caching results, Invalidate remove results reading
+// tables written outside the mapper, ex: by hooks
+type CacheInvalidator interface {
+	Invalidate(dbName string, tableNames ...string)
+}
+
+type cacheTTLKey struct{}
+
+type permissionKey struct{}
+
+// WithCacheTTL return a context whose raw queries are cached for ttl, ex: a view
+func WithCacheTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, cacheTTLKey{}, ttl)
+}
+
+// CacheTTL get ttl of raw queries of ctx, raw queries aren't cached when it is 0
+func CacheTTL(ctx context.Context) time.Duration {
+	ttl, _ := ctx.Value(cacheTTLKey{}).(time.Duration)
+	return ttl
+}
+
+// WithPermissionKey return a context carrying key of permissions of user sending request,
+// results cached for a key aren't shared with requests of other keys
+func WithPermissionKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, permissionKey{}, key)
+}
+
+// PermissionKey get key of permissions of ctx
+func PermissionKey(ctx context.Context) string {
+	key, _ := ctx.Value(permissionKey{}).(string)
+	return key
+}
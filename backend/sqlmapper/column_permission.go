@@ -0,0 +1,79 @@
+package sqlmapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ColumnPermission decide columns of tables a user can read and write
+type ColumnPermission interface {
+	CanRead(dbName, tableName, column string) bool
+	CanWrite(dbName, tableName, column string) bool
+}
+
+type columnPermissionKey struct{}
+
+// WithColumnPermission return a context whose queries and writes are restricted to columns allowed by p
+func WithColumnPermission(ctx context.Context, p ColumnPermission) context.Context {
+	return context.WithValue(ctx, columnPermissionKey{}, p)
+}
+
+// CanRead check column of table can be read in ctx, every column can be read when ctx has no column permission
+func CanRead(ctx context.Context, dbName, tableName, column string) bool {
+	p, ok := ctx.Value(columnPermissionKey{}).(ColumnPermission)
+	return !ok || p.CanRead(dbName, tableName, column)
+}
+
+// CanWrite check column of table can be written in ctx, every column can be written when ctx has no column permission
+func CanWrite(ctx context.Context, dbName, tableName, column string) bool {
+	p, ok := ctx.Value(columnPermissionKey{}).(ColumnPermission)
+	return !ok || p.CanWrite(dbName, tableName, column)
+}
+
+// ForbiddenColumnError is returned when a column is read or written without its column grant
+type ForbiddenColumnError struct {
+	TableName string
+	Column    string
+	Action    string // read or written
+}
+
+func (e ForbiddenColumnError) Error() string {
+	return fmt.Sprintf("column %s of table %s can't be %s", e.Column, e.TableName, e.Action)
+}
+
+// StatusCode return status 403 for a forbidden column
+func (ForbiddenColumnError) StatusCode() int {
+	return http.StatusForbidden
+}
+
+// CheckQuery check columns selected, filtered and ordered by q and its includes can be read in ctx
+func CheckQuery(ctx context.Context, q Query) error {
+	columns := append([]string{}, q.Fields...)
+	if !q.Filter.IsZero() {
+		columns = append(columns, q.Filter.ColumnName)
+	}
+	if len(q.Order) > 0 {
+		columns = append(columns, q.Order[0])
+	}
+
+	for _, c := range columns {
+		if !CanRead(ctx, q.SourceDatabase, q.SourceTable, c) {
+			return ForbiddenColumnError{TableName: q.SourceTable, Column: c, Action: "read"}
+		}
+	}
+
+	// rows of an included table are read like a query of it
+	for _, inc := range q.Include {
+		if err := AuthorizeTable(ctx, q.SourceDatabase, inc.Table, "query"); err != nil {
+			return err
+		}
+		for _, c := range inc.Fields {
+			if !CanRead(ctx, q.SourceDatabase, inc.Table, c) {
+				return ForbiddenColumnError{TableName: inc.Table, Column: c, Action: "read"}
+			}
+		}
+	}
+
+	return nil
+}
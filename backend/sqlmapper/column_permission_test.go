@@ -0,0 +1,75 @@
+package sqlmapper
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var errNoSelect = errors.New("no select")
+
+// fakePermission hide columns and deny queries of tables
+type fakePermission struct {
+	hidden   map[string]bool // table.column
+	noSelect map[string]bool // table
+}
+
+func (p fakePermission) CanRead(dbName, tableName, column string) bool {
+	return !p.hidden[tableName+"."+column]
+}
+
+func (p fakePermission) CanWrite(dbName, tableName, column string) bool {
+	return true
+}
+
+func (p fakePermission) AuthorizeTable(dbName, tableName, action string) error {
+	if p.noSelect[tableName] {
+		return errNoSelect
+	}
+
+	return nil
+}
+
+func TestCheckQuery(t *testing.T) {
+	p := fakePermission{
+		hidden:   map[string]bool{"users.email": true, "books.price": true},
+		noSelect: map[string]bool{"notes": true},
+	}
+	ctx := WithTablePermission(WithColumnPermission(context.Background(), p), p)
+
+	tests := []struct {
+		name    string
+		q       Query
+		wantErr error
+	}{
+		{
+			name: "readable columns and includes",
+			q:    Query{Fields: []string{"id", "name"}, Include: []Include{{Table: "books", Fields: []string{"title"}}}},
+		},
+		{
+			name:    "order by a hidden column",
+			q:       Query{Fields: []string{"id"}, Order: []string{"email", "asc"}},
+			wantErr: ForbiddenColumnError{TableName: "users", Column: "email", Action: "read"},
+		},
+		{
+			name:    "hidden field of an included table",
+			q:       Query{Fields: []string{"id"}, Include: []Include{{Table: "books", Fields: []string{"price"}}}},
+			wantErr: ForbiddenColumnError{TableName: "books", Column: "price", Action: "read"},
+		},
+		{
+			name:    "included table without select permission",
+			q:       Query{Fields: []string{"id"}, Include: []Include{{Table: "notes", Fields: []string{"id"}}}},
+			wantErr: errNoSelect,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.q.SourceDatabase, tt.q.SourceTable = "fortress", "users"
+			if err := CheckQuery(ctx, tt.q); !reflect.DeepEqual(err, tt.wantErr) {
+				t.Errorf("CheckQuery() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
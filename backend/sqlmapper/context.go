@@ -0,0 +1,65 @@
+package sqlmapper
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jinzhu/gorm"
+)
+
+// WithContext return a gorm db whose statements are bound to ctx, a running statement is
+// cancelled on the server when ctx is done. gorm doesn't accept a context, so the connection
+// pool of db is wrapped
+func WithContext(ctx context.Context, db *gorm.DB) (*gorm.DB, error) {
+	return gorm.Open(db.Dialect().GetName(), contextDB{ctx: ctx, db: db.DB()})
+}
+
+// WithTxContext return a gorm db like WithContext, whose statements run in tx
+func WithTxContext(ctx context.Context, db *gorm.DB, tx *sql.Tx) (*gorm.DB, error) {
+	return gorm.Open(db.Dialect().GetName(), contextDB{ctx: ctx, db: tx})
+}
+
+// SQLExecutor run statements with a context, it is implemented by *sql.DB and *sql.Tx
+type SQLExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// contextDB implement gorm.SQLCommon by running statements with a context
+type contextDB struct {
+	ctx context.Context
+	db  SQLExecutor
+}
+
+func (c contextDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.db.ExecContext(c.ctx, query, args...)
+}
+
+func (c contextDB) Prepare(query string) (*sql.Stmt, error) {
+	return c.db.PrepareContext(c.ctx, query)
+}
+
+func (c contextDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.QueryContext(c.ctx, query, args...)
+}
+
+func (c contextDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.db.QueryRowContext(c.ctx, query, args...)
+}
+
+type txKey struct {
+	dbName string
+}
+
+// WithTx return a context carrying the open transaction of mapper on dbName, hooks run with it
+// read and write in tx, so their writes are committed or rolled back with the transaction
+func WithTx(ctx context.Context, dbName string, tx interface{}) context.Context {
+	return context.WithValue(ctx, txKey{dbName}, tx)
+}
+
+// TxFrom return transaction of dbName carried by ctx, nil when there is none
+func TxFrom(ctx context.Context, dbName string) interface{} {
+	return ctx.Value(txKey{dbName})
+}
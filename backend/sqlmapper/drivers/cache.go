@@ -0,0 +1,465 @@
+package drivers
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// cacheStore cache results of queries and views of a mapper, entries of a table are removed
+// when it is written through the mapper
+type cacheStore struct {
+	sqlmapper.Mapper
+	modelMap map[string]map[string]database.Model
+	size     int
+
+	sync.Mutex
+	entries *list.List // most recently used first
+	keys    map[string]*list.Element
+	gen     uint64 // increased by each invalidation, so a result read before a write isn't stored after it
+	stats   sqlmapper.CacheStats
+
+	tablePatterns sync.Map // table name => *regexp.Regexp matching it in a raw sql, see rawTables
+}
+
+type cacheEntry struct {
+	key       string
+	dbName    string
+	tables    map[string]bool // tables read by entry, entry is removed by any write of dbName when it is empty
+	expiresAt time.Time
+	value     interface{}
+}
+
+// queryResult result of Query
+type queryResult struct {
+	columns []string
+	data    []interface{}
+}
+
+// rawResult result of RawQuery
+type rawResult struct {
+	columns []string
+	cols    []database.Column
+	data    []interface{}
+}
+
+// streamResult rows written by StreamQuery or StreamRawQuery
+type streamResult struct {
+	columns []string
+	cols    []database.Column
+	rows    [][]interface{}
+}
+
+// NewCacheStore wrap a mapper with a cache of at most size results. Queries of a model are cached for
+// cache_ttl of model, raw queries are cached for CacheTTL of ctx. Results are keyed by query and
+// permission key of ctx
+func NewCacheStore(store sqlmapper.Mapper, modelMap map[string]map[string]database.Model, size int) sqlmapper.Mapper {
+	return &cacheStore{
+		Mapper:   store,
+		modelMap: modelMap,
+		size:     size,
+		entries:  list.New(),
+		keys:     make(map[string]*list.Element),
+	}
+}
+
+func (s *cacheStore) CacheStats() sqlmapper.CacheStats {
+	s.Lock()
+	defer s.Unlock()
+
+	stats := s.stats
+	stats.Entries = s.entries.Len()
+	return stats
+}
+
+func (s *cacheStore) Query(ctx context.Context, q sqlmapper.Query) ([]string, []interface{}, error) {
+	ttl := s.modelTTL(q.SourceDatabase, q.SourceTable)
+	if ttl <= 0 {
+		return s.Mapper.Query(ctx, q)
+	}
+
+	// cached rows are only returned to a user allowed to read their columns
+	if err := sqlmapper.CheckQuery(ctx, q); err != nil {
+		return nil, nil, err
+	}
+
+	key, err := s.queryKey(ctx, "query", q)
+	if err != nil {
+		return nil, nil, err
+	}
+	if v, ok := s.get(key); ok {
+		res := v.(queryResult)
+		return res.columns, res.data, nil
+	}
+
+	gen := s.generation()
+	columns, data, err := s.Mapper.Query(ctx, q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.set(gen, key, q.SourceDatabase, s.queryTables(q), ttl, queryResult{columns, data})
+	return columns, data, nil
+}
+
+func (s *cacheStore) StreamQuery(ctx context.Context, q sqlmapper.Query, w sqlmapper.RowWriter) error {
+	ttl := s.modelTTL(q.SourceDatabase, q.SourceTable)
+	if ttl <= 0 {
+		return s.Mapper.StreamQuery(ctx, q, w)
+	}
+
+	// cached rows are only returned to a user allowed to read their columns
+	if err := sqlmapper.CheckQuery(ctx, q); err != nil {
+		return err
+	}
+
+	key, err := s.queryKey(ctx, "stream", q)
+	if err != nil {
+		return err
+	}
+	if v, ok := s.get(key); ok {
+		return v.(*streamResult).replay(w)
+	}
+
+	gen := s.generation()
+	rec := &recordWriter{w: w, res: &streamResult{}}
+	if err := s.Mapper.StreamQuery(ctx, q, rec); err != nil {
+		return err
+	}
+
+	// a stream stopped by writer doesn't have all rows
+	if !rec.stopped {
+		s.set(gen, key, q.SourceDatabase, s.queryTables(q), ttl, rec.res)
+	}
+	return nil
+}
+
+func (s *cacheStore) RawQuery(ctx context.Context, dbName string, sql string) ([]string, []database.Column, []interface{}, error) {
+	ttl := sqlmapper.CacheTTL(ctx)
+	if ttl <= 0 {
+		return s.Mapper.RawQuery(ctx, dbName, sql)
+	}
+
+	key := rawKey(ctx, "raw", dbName, sql)
+	if v, ok := s.get(key); ok {
+		res := v.(rawResult)
+		return res.columns, res.cols, res.data, nil
+	}
+
+	gen := s.generation()
+	columns, cols, data, err := s.Mapper.RawQuery(ctx, dbName, sql)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	s.set(gen, key, dbName, s.rawTables(dbName, sql), ttl, rawResult{columns, cols, data})
+	return columns, cols, data, nil
+}
+
+func (s *cacheStore) StreamRawQuery(ctx context.Context, dbName string, sql string, w sqlmapper.RowWriter) error {
+	ttl := sqlmapper.CacheTTL(ctx)
+	if ttl <= 0 {
+		return s.Mapper.StreamRawQuery(ctx, dbName, sql, w)
+	}
+
+	key := rawKey(ctx, "streamraw", dbName, sql)
+	if v, ok := s.get(key); ok {
+		return v.(*streamResult).replay(w)
+	}
+
+	gen := s.generation()
+	rec := &recordWriter{w: w, res: &streamResult{}}
+	if err := s.Mapper.StreamRawQuery(ctx, dbName, sql, rec); err != nil {
+		return err
+	}
+
+	if !rec.stopped {
+		s.set(gen, key, dbName, s.rawTables(dbName, sql), ttl, rec.res)
+	}
+	return nil
+}
+
+func (s *cacheStore) Create(ctx context.Context, dbName, tableName string, d sqlmapper.RowData) (sqlmapper.RowData, error) {
+	defer s.Invalidate(dbName, tableName)
+	return s.Mapper.Create(ctx, dbName, tableName, d)
+}
+
+func (s *cacheStore) Update(ctx context.Context, dbName, tableName string, d sqlmapper.RowData) (sqlmapper.RowData, error) {
+	defer s.Invalidate(dbName, tableName)
+	return s.Mapper.Update(ctx, dbName, tableName, d)
+}
+
+func (s *cacheStore) Delete(ctx context.Context, dbName, tableName string, fields, data []interface{}) error {
+	defer s.Invalidate(dbName, tableName)
+	return s.Mapper.Delete(ctx, dbName, tableName, fields, data)
+}
+
+func (s *cacheStore) Upsert(ctx context.Context, dbName, tableName string, d sqlmapper.RowData, conflictColumns []string) (sqlmapper.RowData, string, error) {
+	defer s.Invalidate(dbName, tableName)
+	return s.Mapper.Upsert(ctx, dbName, tableName, d, conflictColumns)
+}
+
+func (s *cacheStore) Restore(ctx context.Context, dbName, tableName string, fields, data []interface{}) error {
+	defer s.Invalidate(dbName, tableName)
+	return s.Mapper.Restore(ctx, dbName, tableName, fields, data)
+}
+
+func (s *cacheStore) Transaction(ctx context.Context, dbName string, ops []sqlmapper.Operation) ([]sqlmapper.RowData, error) {
+	tables := []string{}
+	for _, op := range ops {
+		tables = append(tables, op.TableName)
+	}
+	defer s.Invalidate(dbName, tables...)
+	return s.Mapper.Transaction(ctx, dbName, ops)
+}
+
+// rows of a bulk may be written even when it fails, so entries are removed whatever result is
+func (s *cacheStore) BulkCreate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string) (sqlmapper.BulkResults, error) {
+	defer s.Invalidate(dbName, tableName)
+	return s.Mapper.BulkCreate(ctx, dbName, tableName, rows, mode)
+}
+
+func (s *cacheStore) BulkUpdate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string) (sqlmapper.BulkResults, error) {
+	defer s.Invalidate(dbName, tableName)
+	return s.Mapper.BulkUpdate(ctx, dbName, tableName, rows, mode)
+}
+
+func (s *cacheStore) BulkDelete(ctx context.Context, dbName, tableName string, fields []interface{}, rows [][]interface{}, mode string) (sqlmapper.BulkResults, error) {
+	defer s.Invalidate(dbName, tableName)
+	return s.Mapper.BulkDelete(ctx, dbName, tableName, fields, rows, mode)
+}
+
+func (s *cacheStore) modelTTL(dbName, tableName string) time.Duration {
+	return time.Duration(s.modelMap[dbName][tableName].CacheTTL) * time.Second
+}
+
+func (s *cacheStore) get(key string) (interface{}, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	e, ok := s.keys[key]
+	if !ok {
+		s.stats.Misses++
+		return nil, false
+	}
+
+	entry := e.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		s.remove(e)
+		s.stats.Misses++
+		return nil, false
+	}
+
+	s.entries.MoveToFront(e)
+	s.stats.Hits++
+	return entry.value, true
+}
+
+func (s *cacheStore) generation() uint64 {
+	s.Lock()
+	defer s.Unlock()
+	return s.gen
+}
+
+// set store a result read since generation gen, it is dropped when a write happened meanwhile
+func (s *cacheStore) set(gen uint64, key, dbName string, tables []string, ttl time.Duration, value interface{}) {
+	s.Lock()
+	defer s.Unlock()
+
+	if gen != s.gen {
+		return
+	}
+
+	if e, ok := s.keys[key]; ok {
+		s.remove(e)
+	}
+
+	entry := &cacheEntry{
+		key:       key,
+		dbName:    dbName,
+		tables:    make(map[string]bool),
+		expiresAt: time.Now().Add(ttl),
+		value:     value,
+	}
+	for _, t := range tables {
+		entry.tables[t] = true
+	}
+	s.keys[key] = s.entries.PushFront(entry)
+
+	// least recently used entries are evicted
+	for s.entries.Len() > s.size {
+		s.remove(s.entries.Back())
+	}
+}
+
+func (s *cacheStore) remove(e *list.Element) {
+	s.entries.Remove(e)
+	delete(s.keys, e.Value.(*cacheEntry).key)
+}
+
+// Invalidate remove entries reading written tables or tables related to them,
+// rows of related tables may be changed by cascade or relate-fields
+func (s *cacheStore) Invalidate(dbName string, tableNames ...string) {
+	affected := make(map[string]bool)
+	for _, tableName := range tableNames {
+		affected[tableName] = true
+		for _, r := range s.modelMap[dbName][tableName].Relationship {
+			affected[r.Table] = true
+			if r.Through != "" {
+				affected[r.Through] = true
+			}
+		}
+		for name, m := range s.modelMap[dbName] {
+			for _, r := range m.Relationship {
+				if r.Table == tableName || r.Through == tableName {
+					affected[name] = true
+				}
+			}
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.gen++
+	for e := s.entries.Front(); e != nil; {
+		next := e.Next()
+		entry := e.Value.(*cacheEntry)
+		if entry.dbName == dbName && (len(entry.tables) == 0 || intersect(entry.tables, affected)) {
+			s.remove(e)
+			s.stats.Invalidations++
+		}
+		e = next
+	}
+}
+
+func intersect(a, b map[string]bool) bool {
+	for k := range a {
+		if b[k] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// queryTables tables read by a query, including tables labeling foreign keys
+func (s *cacheStore) queryTables(q sqlmapper.Query) []string {
+	tables := []string{q.SourceTable}
+	for _, inc := range q.Include {
+		tables = append(tables, inc.Table)
+	}
+	for _, col := range q.Labels {
+		if fk, _, err := sqlmapper.LabelSource(s.modelMap[q.SourceDatabase], q.SourceTable, col); err == nil {
+			tables = append(tables, fk.Table)
+		}
+	}
+
+	return tables
+}
+
+// rawTables models of dbName named in a raw sql, no table is returned when none is found
+// so entry is removed by any write of dbName
+func (s *cacheStore) rawTables(dbName, sql string) []string {
+	tables := []string{}
+	for tableName := range s.modelMap[dbName] {
+		if s.tablePattern(tableName).MatchString(sql) {
+			tables = append(tables, tableName)
+		}
+	}
+
+	return tables
+}
+
+// tablePattern return regexp matching tableName as a word, it is compiled once for a table
+// and kept across model syncs since it depends only on table name
+func (s *cacheStore) tablePattern(tableName string) *regexp.Regexp {
+	if re, ok := s.tablePatterns.Load(tableName); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	re, _ := s.tablePatterns.LoadOrStore(tableName, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(tableName)+`\b`))
+	return re.(*regexp.Regexp)
+}
+
+// queryKey key of a query, fields of query are encoded in a fixed order by json
+func (s *cacheStore) queryKey(ctx context.Context, kind string, q sqlmapper.Query) (string, error) {
+	// rows of a query depend on row filters of user on source and included tables
+	filters := map[string][]sqlmapper.Filter{}
+	for _, table := range s.queryTables(q) {
+		f, err := sqlmapper.RowFilters(ctx, q.SourceDatabase, table)
+		if err != nil {
+			return "", err
+		}
+		if len(f) > 0 {
+			filters[table] = f
+		}
+	}
+
+	b, _ := json.Marshal(q)
+	fb, _ := json.Marshal(filters)
+	return strings.Join([]string{kind, q.SourceDatabase, q.SourceTable, userKey(ctx), string(fb), string(b)}, "\x00"), nil
+}
+
+// rawKey key of a raw sql, whitespaces and trailing semicolons don't change key
+func rawKey(ctx context.Context, kind, dbName, sql string) string {
+	normalized := strings.TrimRight(strings.Join(strings.Fields(sql), " "), "; ")
+	return strings.Join([]string{kind, dbName, userKey(ctx), normalized}, "\x00")
+}
+
+// userKey identify what user of ctx can see: permissions and masked columns
+func userKey(ctx context.Context) string {
+	return fmt.Sprintf("%s:%t", sqlmapper.PermissionKey(ctx), sqlmapper.IsMasked(ctx))
+}
+
+// recordWriter record rows written to w
+type recordWriter struct {
+	w       sqlmapper.RowWriter
+	res     *streamResult
+	stopped bool // w returned an error, rows after it aren't read
+}
+
+func (r *recordWriter) WriteHeader(columns []string, cols []database.Column) error {
+	r.res.columns, r.res.cols = columns, cols
+	if err := r.w.WriteHeader(columns, cols); err != nil {
+		r.stopped = true
+		return err
+	}
+
+	return nil
+}
+
+func (r *recordWriter) WriteRow(row []interface{}) error {
+	if err := r.w.WriteRow(row); err != nil {
+		r.stopped = true
+		return err
+	}
+
+	r.res.rows = append(r.res.rows, row)
+	return nil
+}
+
+func (res *streamResult) replay(w sqlmapper.RowWriter) error {
+	if err := w.WriteHeader(res.columns, res.cols); err != nil {
+		return err
+	}
+
+	for _, row := range res.rows {
+		if err := w.WriteRow(row); err != nil {
+			if err == sqlmapper.ErrStopStream {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
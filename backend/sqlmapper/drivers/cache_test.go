@@ -0,0 +1,164 @@
+package drivers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// countStore count queries reaching database
+type countStore struct {
+	sqlmapper.Mapper
+	queries int
+}
+
+func (s *countStore) Query(ctx context.Context, q sqlmapper.Query) ([]string, []interface{}, error) {
+	s.queries++
+	return q.Fields, []interface{}{[]interface{}{s.queries}}, nil
+}
+
+func (s *countStore) Create(ctx context.Context, dbName, tableName string, d sqlmapper.RowData) (sqlmapper.RowData, error) {
+	return d, nil
+}
+
+// regionFilter filter rows of users by a region
+type regionFilter string
+
+func (f regionFilter) RowFilters(dbName, tableName string) ([]sqlmapper.Filter, error) {
+	if tableName != "users" {
+		return nil, nil
+	}
+
+	return []sqlmapper.Filter{{Operator: "=", ColumnName: "region", Value: string(f)}}, nil
+}
+
+func TestCacheStore(t *testing.T) {
+	modelMap := map[string]map[string]database.Model{
+		"fortress": {
+			"users": {TableName: "users", CacheTTL: 60, Relationship: []database.Relationship{{Table: "books", Type: database.RelationshipHasMany}}},
+			"books": {TableName: "books", CacheTTL: 60},
+			"notes": {TableName: "notes"},
+		},
+	}
+	users := sqlmapper.Query{SourceDatabase: "fortress", SourceTable: "users", Fields: []string{"id"}}
+	notes := sqlmapper.Query{SourceDatabase: "fortress", SourceTable: "notes", Fields: []string{"id"}}
+	userCtx := sqlmapper.WithPermissionKey(context.Background(), "user")
+	adminCtx := sqlmapper.WithPermissionKey(context.Background(), "admin")
+
+	tests := []struct {
+		name        string
+		run         func(m sqlmapper.Mapper)
+		wantQueries int
+		wantStats   sqlmapper.CacheStats
+	}{
+		{
+			name: "same query is read from cache",
+			run: func(m sqlmapper.Mapper) {
+				m.Query(userCtx, users)
+				m.Query(userCtx, users)
+			},
+			wantQueries: 1,
+			wantStats:   sqlmapper.CacheStats{Hits: 1, Misses: 1, Entries: 1},
+		},
+		{
+			name: "results aren't shared by different permissions",
+			run: func(m sqlmapper.Mapper) {
+				m.Query(userCtx, users)
+				m.Query(adminCtx, users)
+			},
+			wantQueries: 2,
+			wantStats:   sqlmapper.CacheStats{Misses: 2, Entries: 2},
+		},
+		{
+			name: "results aren't shared by different row filters",
+			run: func(m sqlmapper.Mapper) {
+				m.Query(sqlmapper.WithRowFilter(userCtx, regionFilter("north")), users)
+				m.Query(sqlmapper.WithRowFilter(userCtx, regionFilter("south")), users)
+			},
+			wantQueries: 2,
+			wantStats:   sqlmapper.CacheStats{Misses: 2, Entries: 2},
+		},
+		{
+			name: "model without cache_ttl isn't cached",
+			run: func(m sqlmapper.Mapper) {
+				m.Query(userCtx, notes)
+				m.Query(userCtx, notes)
+			},
+			wantQueries: 2,
+		},
+		{
+			name: "write of related table removes entries",
+			run: func(m sqlmapper.Mapper) {
+				m.Query(userCtx, users)
+				m.Create(userCtx, "fortress", "books", sqlmapper.RowData{})
+				m.Query(userCtx, users)
+			},
+			wantQueries: 2,
+			wantStats:   sqlmapper.CacheStats{Misses: 2, Entries: 1, Invalidations: 1},
+		},
+		{
+			name: "least recently used entry is evicted",
+			run: func(m sqlmapper.Mapper) {
+				m.Query(userCtx, users)
+				m.Query(adminCtx, users)
+				m.Query(userCtx, users)
+				m.Query(sqlmapper.WithPermissionKey(context.Background(), "other"), users)
+				m.Query(userCtx, users)
+			},
+			wantQueries: 3,
+			wantStats:   sqlmapper.CacheStats{Hits: 2, Misses: 3, Entries: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &countStore{}
+			m := NewCacheStore(store, modelMap, 2)
+
+			tt.run(m)
+
+			if store.queries != tt.wantQueries {
+				t.Errorf("queries = %d, want %d", store.queries, tt.wantQueries)
+			}
+			if got := m.(sqlmapper.CacheStatsReporter).CacheStats(); got != tt.wantStats {
+				t.Errorf("cacheStore.CacheStats() = %+v, want %+v", got, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestRawKey(t *testing.T) {
+	ctx := context.Background()
+	a := rawKey(ctx, "raw", "fortress", "SELECT *\n  FROM users;")
+	b := rawKey(ctx, "raw", "fortress", "SELECT * FROM users")
+	if a != b {
+		t.Errorf("rawKey() = %q and %q, want same key", a, b)
+	}
+}
+
+func TestRawTables(t *testing.T) {
+	modelMap := map[string]map[string]database.Model{
+		"fortress": {
+			"users": {TableName: "users"},
+			"books": {TableName: "books"},
+		},
+	}
+	s := NewCacheStore(&countStore{}, modelMap, 2).(*cacheStore)
+
+	if got := s.rawTables("fortress", "SELECT * FROM Users JOIN user_books ON true"); !reflect.DeepEqual(got, []string{"users"}) {
+		t.Errorf("rawTables() = %v, want [users]", got)
+	}
+
+	// a table added by a model sync is matched, patterns of other tables are reused
+	users := s.tablePattern("users")
+	modelMap["fortress"]["user_books"] = database.Model{TableName: "user_books"}
+	got := s.rawTables("fortress", "SELECT * FROM user_books")
+	if !reflect.DeepEqual(got, []string{"user_books"}) {
+		t.Errorf("rawTables() after sync = %v, want [user_books]", got)
+	}
+	if s.tablePattern("users") != users {
+		t.Error("pattern of users is compiled again")
+	}
+}
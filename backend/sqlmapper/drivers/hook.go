@@ -0,0 +1,325 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/dwarvesf/smithy/backend/hook"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+type hookStore struct {
+	store      sqlmapper.Mapper
+	hookEngine hook.ScriptEngine
+	modelMap   map[string]map[string]database.Model
+}
+
+// NewPGHookStore new pg implement for hook, onWrite is called after a table is written by a hook
+func NewPGHookStore(store sqlmapper.Mapper, modelMap map[string]map[string]database.Model, db func() map[string]*gorm.DB, onWrite hook.WriteFunc) (sqlmapper.Mapper, error) {
+	scriptEngine := hook.NewAnkoScriptEngineWithLib(hook.NewNotifyLib(hook.NewPGLib(db, modelMap), onWrite))
+	return NewHookStore(store, modelMap, scriptEngine), nil
+}
+
+// NewHookStore run hooks of models with hookEngine around writes of store
+func NewHookStore(store sqlmapper.Mapper, modelMap map[string]map[string]database.Model, hookEngine hook.ScriptEngine) sqlmapper.Mapper {
+	return &hookStore{
+		store:      store,
+		hookEngine: hookEngine,
+		modelMap:   modelMap,
+	}
+}
+
+func (s *hookStore) Query(ctx context.Context, q sqlmapper.Query) ([]string, []interface{}, error) {
+	return s.store.Query(ctx, q)
+}
+
+func (s *hookStore) FindRows(ctx context.Context, dbName, tableName string, where sqlmapper.RowData) ([]sqlmapper.RowData, error) {
+	return s.store.FindRows(ctx, dbName, tableName, where)
+}
+
+func (s *hookStore) StreamQuery(ctx context.Context, q sqlmapper.Query, w sqlmapper.RowWriter) error {
+	return s.store.StreamQuery(ctx, q, w)
+}
+
+func (s *hookStore) StreamRawQuery(ctx context.Context, dbName string, sql string, w sqlmapper.RowWriter) error {
+	return s.store.StreamRawQuery(ctx, dbName, sql, w)
+}
+
+func (s *hookStore) RawQuery(ctx context.Context, dbName string, sql string) ([]string, []database.Column, []interface{}, error) {
+	return s.store.RawQuery(ctx, dbName, sql)
+}
+
+func (s *hookStore) ColumnMetadata(q sqlmapper.Query) ([]database.Column, error) {
+	return s.store.ColumnMetadata(q)
+}
+
+func (s *hookStore) ColumnMetadataByRows(q *sql.Rows) ([]database.Column, error) {
+	return s.store.ColumnMetadataByRows(q)
+}
+
+func (s *hookStore) Create(ctx context.Context, dbName string, tableName string, row sqlmapper.RowData) (sqlmapper.RowData, error) {
+	data := row.ToCtx()
+
+	model, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	if model.IsBeforeCreateEnable() {
+		err := s.hookEngine.Eval(ctx, data, model.Hooks.BeforeCreate.Content)
+		if err != nil {
+			return nil, err
+		}
+		row = sqlmapper.Ctx(data).ToRowData()
+	}
+
+	res, err := s.store.Create(ctx, dbName, tableName, row)
+	if err != nil {
+		return nil, err
+	}
+
+	if model.IsAfterCreateEnable() {
+		err := s.hookEngine.Eval(ctx, data, model.Hooks.AfterCreate.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		return sqlmapper.Ctx(data).ToRowData(), nil
+	}
+
+	return res, nil
+}
+
+func (s *hookStore) Delete(ctx context.Context, dbName string, tableName string, fields, data []interface{}) error {
+	model, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	if model.IsBeforeDeleteEnable() {
+		err := s.hookEngine.Eval(ctx, nil, model.Hooks.BeforeDelete.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	res := s.store.Delete(ctx, dbName, tableName, fields, data)
+
+	if model.IsAfterDeleteEnable() {
+		err := s.hookEngine.Eval(ctx, nil, model.Hooks.AfterDelete.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return res
+}
+
+func (s *hookStore) Update(ctx context.Context, dbName, tableName string, d sqlmapper.RowData) (sqlmapper.RowData, error) {
+	model, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+	if model.IsBeforeUpdateEnable() {
+		err := s.hookEngine.Eval(ctx, nil, model.Hooks.BeforeUpdate.Content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := s.store.Update(ctx, dbName, tableName, d)
+	if err != nil {
+		return nil, err
+	}
+
+	if model.IsAfterUpdateEnable() {
+		err := s.hookEngine.Eval(ctx, nil, model.Hooks.AfterUpdate.Content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func (s *hookStore) Upsert(ctx context.Context, dbName, tableName string, row sqlmapper.RowData, conflictColumns []string) (sqlmapper.RowData, string, error) {
+	store, ok := s.store.(upserter)
+	if !ok {
+		return nil, "", errors.New("store doesn't support upsert with hooks")
+	}
+
+	// the existing row is looked up in the transaction of upsert, so hooks of the path actually
+	// taken are run, their db_* calls run in the same transaction
+	return store.upsert(ctx, dbName, tableName, row, conflictColumns, s.operationHooks(dbName))
+}
+
+func (s *hookStore) Restore(ctx context.Context, dbName, tableName string, fields, data []interface{}) error {
+	return s.store.Restore(ctx, dbName, tableName, fields, data)
+}
+
+func (s *hookStore) DeletePreview(ctx context.Context, dbName, tableName string, fields, data []interface{}) (sqlmapper.DeleteImpact, error) {
+	return s.store.DeletePreview(ctx, dbName, tableName, fields, data)
+}
+
+func (s *hookStore) Transaction(ctx context.Context, dbName string, ops []sqlmapper.Operation) ([]sqlmapper.RowData, error) {
+	store, ok := s.store.(transactor)
+	if !ok {
+		return nil, errors.New("store doesn't support transaction with hooks")
+	}
+
+	// hooks of each operation are run inside the transaction, their db_* calls too, so a failed
+	// operation or hook rollback all operations and writes of hooks
+	return store.transaction(ctx, dbName, ops, s.operationHooks(dbName))
+}
+
+// operationHooks return hooks of tables of dbName run around operations of a transaction or an upsert
+func (s *hookStore) operationHooks(dbName string) operationHooks {
+	return operationHooks{
+		before: func(ctx context.Context, op sqlmapper.Operation, row sqlmapper.RowData) (sqlmapper.RowData, error) {
+			model, ok := s.modelMap[dbName][op.TableName]
+			if !ok {
+				return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, op.TableName)
+			}
+
+			switch {
+			case op.Type == sqlmapper.OperationCreate && model.IsBeforeCreateEnable():
+				data := row.ToCtx()
+				if err := s.hookEngine.Eval(ctx, data, model.Hooks.BeforeCreate.Content); err != nil {
+					return nil, err
+				}
+				return sqlmapper.Ctx(data).ToRowData(), nil
+			case op.Type == sqlmapper.OperationUpdate && model.IsBeforeUpdateEnable():
+				return row, s.hookEngine.Eval(ctx, nil, model.Hooks.BeforeUpdate.Content)
+			case op.Type == sqlmapper.OperationDelete && model.IsBeforeDeleteEnable():
+				return row, s.hookEngine.Eval(ctx, nil, model.Hooks.BeforeDelete.Content)
+			}
+
+			return row, nil
+		},
+		after: func(ctx context.Context, op sqlmapper.Operation, row sqlmapper.RowData) (sqlmapper.RowData, error) {
+			model := s.modelMap[dbName][op.TableName]
+
+			switch {
+			case op.Type == sqlmapper.OperationCreate && model.IsAfterCreateEnable():
+				data := row.ToCtx()
+				if err := s.hookEngine.Eval(ctx, data, model.Hooks.AfterCreate.Content); err != nil {
+					return nil, err
+				}
+				return sqlmapper.Ctx(data).ToRowData(), nil
+			case op.Type == sqlmapper.OperationUpdate && model.IsAfterUpdateEnable():
+				return row, s.hookEngine.Eval(ctx, nil, model.Hooks.AfterUpdate.Content)
+			case op.Type == sqlmapper.OperationDelete && model.IsAfterDeleteEnable():
+				return row, s.hookEngine.Eval(ctx, nil, model.Hooks.AfterDelete.Content)
+			}
+
+			return row, nil
+		},
+	}
+}
+
+func (s *hookStore) Explain(ctx context.Context, dbName string, sql string) (interface{}, error) {
+	return s.store.Explain(ctx, dbName, sql)
+}
+
+func (s *hookStore) BulkCreate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string) (sqlmapper.BulkResults, error) {
+	model, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	store, ok := s.store.(bulkWriter)
+	if !ok {
+		return nil, errors.New("store doesn't support bulk operations with hooks")
+	}
+
+	return store.bulkCreate(ctx, dbName, tableName, rows, mode, rowHooks{
+		before: func(ctx context.Context, i int) error {
+			if !model.IsBeforeCreateEnable() {
+				return nil
+			}
+
+			data := rows[i].ToCtx()
+			if err := s.hookEngine.Eval(ctx, data, model.Hooks.BeforeCreate.Content); err != nil {
+				return err
+			}
+			rows[i] = sqlmapper.Ctx(data).ToRowData()
+
+			return nil
+		},
+		after: func(ctx context.Context, i int, row sqlmapper.RowData) (sqlmapper.RowData, error) {
+			if !model.IsAfterCreateEnable() {
+				return row, nil
+			}
+
+			data := row.ToCtx()
+			if err := s.hookEngine.Eval(ctx, data, model.Hooks.AfterCreate.Content); err != nil {
+				return nil, err
+			}
+
+			return sqlmapper.Ctx(data).ToRowData(), nil
+		},
+	})
+}
+
+func (s *hookStore) BulkUpdate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string) (sqlmapper.BulkResults, error) {
+	model, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	store, ok := s.store.(bulkWriter)
+	if !ok {
+		return nil, errors.New("store doesn't support bulk operations with hooks")
+	}
+
+	return store.bulkUpdate(ctx, dbName, tableName, rows, mode, rowHooks{
+		before: func(ctx context.Context, i int) error {
+			if !model.IsBeforeUpdateEnable() {
+				return nil
+			}
+
+			return s.hookEngine.Eval(ctx, nil, model.Hooks.BeforeUpdate.Content)
+		},
+		after: func(ctx context.Context, i int, row sqlmapper.RowData) (sqlmapper.RowData, error) {
+			if !model.IsAfterUpdateEnable() {
+				return row, nil
+			}
+
+			return row, s.hookEngine.Eval(ctx, nil, model.Hooks.AfterUpdate.Content)
+		},
+	})
+}
+
+func (s *hookStore) BulkDelete(ctx context.Context, dbName, tableName string, fields []interface{}, rows [][]interface{}, mode string) (sqlmapper.BulkResults, error) {
+	model, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	store, ok := s.store.(bulkWriter)
+	if !ok {
+		return nil, errors.New("store doesn't support bulk operations with hooks")
+	}
+
+	return store.bulkDelete(ctx, dbName, tableName, fields, rows, mode, rowHooks{
+		before: func(ctx context.Context, i int) error {
+			if !model.IsBeforeDeleteEnable() {
+				return nil
+			}
+
+			return s.hookEngine.Eval(ctx, nil, model.Hooks.BeforeDelete.Content)
+		},
+		after: func(ctx context.Context, i int, row sqlmapper.RowData) (sqlmapper.RowData, error) {
+			if !model.IsAfterDeleteEnable() {
+				return row, nil
+			}
+
+			return row, s.hookEngine.Eval(ctx, nil, model.Hooks.AfterDelete.Content)
+		},
+	})
+}
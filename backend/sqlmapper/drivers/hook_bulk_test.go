@@ -0,0 +1,91 @@
+package drivers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+func TestHookStoreBulkCreate(t *testing.T) {
+	ctx := context.Background()
+	// after hook write a note, then fail for user "bad"
+	createNote := func(h *database.Hooks) {
+		h.AfterCreate = database.Hook{Enable: true, Content: `
+db_create("fortress", "notes", {"body": "created " + ctx()["name"]})
+if ctx()["name"] == "bad" {
+	throw "bad user"
+}`}
+	}
+
+	tests := []struct {
+		name       string
+		mode       string
+		names      []string
+		wantStatus []string
+		wantNotes  []interface{}
+		wantUsers  int
+	}{
+		{
+			name:       "failed after hook rollback all rows and writes of hooks",
+			mode:       sqlmapper.BulkAllOrNothing,
+			names:      []string{"dan", "bad"},
+			wantStatus: []string{sqlmapper.BulkStatusRolledBack, sqlmapper.BulkStatusFailed},
+			wantNotes:  []interface{}{"hello"},
+			wantUsers:  3,
+		},
+		{
+			name:       "failed after hook rollback only its row in best_effort",
+			mode:       sqlmapper.BulkBestEffort,
+			names:      []string{"dan", "bad", "eve"},
+			wantStatus: []string{sqlmapper.BulkStatusSuccess, sqlmapper.BulkStatusFailed, sqlmapper.BulkStatusSuccess},
+			wantNotes:  []interface{}{"hello", "created dan", "created eve"},
+			wantUsers:  5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, db := newTestHookStore(t, createNote)
+
+			rows := []sqlmapper.RowData{}
+			for _, name := range tt.names {
+				rows = append(rows, sqlmapper.RowData{"name": {Data: name}})
+			}
+			res, err := m.BulkCreate(ctx, "fortress", "users", rows, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			status := []string{}
+			for _, r := range res {
+				status = append(status, r.Status)
+			}
+			if !reflect.DeepEqual(status, tt.wantStatus) {
+				t.Errorf("status = %v, want %v", status, tt.wantStatus)
+			}
+
+			notes, err := db.Select("fortress", "notes", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []interface{}{}
+			for _, n := range notes {
+				got = append(got, n["body"])
+			}
+			if !reflect.DeepEqual(got, tt.wantNotes) {
+				t.Errorf("notes = %v, want %v", got, tt.wantNotes)
+			}
+
+			users, err := db.Select("fortress", "users", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != tt.wantUsers {
+				t.Errorf("users = %d, want %d", len(users), tt.wantUsers)
+			}
+		})
+	}
+}
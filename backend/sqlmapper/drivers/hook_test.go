@@ -0,0 +1,79 @@
+package drivers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/hook"
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// newTestHookStore make a hook store over a memory store, hooks of users are set by setHooks
+func newTestHookStore(t *testing.T, setHooks func(h *database.Hooks)) (sqlmapper.Mapper, *memory.DB) {
+	modelMap := testModelMap()
+	users := modelMap["fortress"]["users"]
+	setHooks(&users.Hooks)
+	modelMap["fortress"]["users"] = users
+
+	m, db := newTestMemoryStoreOf(t, modelMap)
+	engine := hook.NewAnkoScriptEngineWithLib(hook.NewMemoryLib(db, modelMap))
+
+	return NewHookStore(m, modelMap, engine), db
+}
+
+func TestHookStoreTransaction(t *testing.T) {
+	ctx := context.Background()
+	createNote := func(h *database.Hooks) {
+		h.AfterCreate = database.Hook{Enable: true, Content: `db_create("fortress", "notes", {"body": "user created"})`}
+	}
+
+	tests := []struct {
+		name    string
+		ops     []sqlmapper.Operation
+		want    []interface{}
+		wantErr bool
+	}{
+		{
+			name: "writes of hooks are committed with operations",
+			ops: []sqlmapper.Operation{
+				{Type: sqlmapper.OperationCreate, TableName: "users", Fields: []interface{}{"name"}, Data: []interface{}{"dan"}},
+			},
+			want: []interface{}{"hello", "user created"},
+		},
+		{
+			name: "writes of hooks are rolled back with a failed operation",
+			ops: []sqlmapper.Operation{
+				{Type: sqlmapper.OperationCreate, TableName: "users", Fields: []interface{}{"name"}, Data: []interface{}{"dan"}},
+				{Type: sqlmapper.OperationUpdate, TableName: "users", Fields: []interface{}{"id", "name", "version"}, Data: []interface{}{1, "anna", 9}},
+			},
+			want:    []interface{}{"hello"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, db := newTestHookStore(t, createNote)
+
+			_, err := m.Transaction(ctx, "fortress", tt.ops)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transaction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			notes, err := db.Select("fortress", "notes", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []interface{}{}
+			for _, n := range notes {
+				got = append(got, n["body"])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("notes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
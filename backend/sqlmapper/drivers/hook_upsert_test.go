@@ -0,0 +1,59 @@
+package drivers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+func TestHookStoreUpsert(t *testing.T) {
+	ctx := context.Background()
+	// hooks write the path they are run for
+	setHooks := func(h *database.Hooks) {
+		h.BeforeCreate = database.Hook{Enable: true, Content: `db_create("fortress", "notes", {"body": "before create"})`}
+		h.BeforeUpdate = database.Hook{Enable: true, Content: `db_create("fortress", "notes", {"body": "before update"})`}
+		h.AfterUpdate = database.Hook{Enable: true, Content: `db_create("fortress", "notes", {"body": "after update"})`}
+	}
+
+	tests := []struct {
+		name      string
+		row       sqlmapper.RowData
+		wantNotes []interface{}
+	}{
+		{
+			name:      "hooks of create are run for a new row",
+			row:       sqlmapper.RowData{"name": {Data: "dan"}},
+			wantNotes: []interface{}{"hello", "before create"},
+		},
+		{
+			name:      "hooks of update are run for an existing row",
+			row:       sqlmapper.RowData{"name": {Data: "bob"}, "version": {Data: 1}},
+			wantNotes: []interface{}{"hello", "before update", "after update"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, db := newTestHookStore(t, setHooks)
+
+			if _, _, err := m.Upsert(ctx, "fortress", "users", tt.row, []string{"name"}); err != nil {
+				t.Fatal(err)
+			}
+
+			notes, err := db.Select("fortress", "notes", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []interface{}{}
+			for _, n := range notes {
+				got = append(got, n["body"])
+			}
+			if !reflect.DeepEqual(got, tt.wantNotes) {
+				t.Errorf("notes = %v, want %v", got, tt.wantNotes)
+			}
+		})
+	}
+}
@@ -0,0 +1,501 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+type memoryStore struct {
+	modelStore
+	db *memory.DB
+}
+
+// NewMemoryStore . Rows are kept in db, so the dashboard works without a database. Raw queries, views
+// and explain accept a SELECT of a single table, see memory.ParseSelect. Values of computed columns are null
+func NewMemoryStore(db *memory.DB, modelMap map[string]map[string]database.Model) sqlmapper.Mapper {
+	return &memoryStore{
+		modelStore: modelStore{modelMap: modelMap},
+		db:         db,
+	}
+}
+
+// memorySelecter is implemented by both *memory.DB and *memory.Tx
+type memorySelecter interface {
+	Select(dbName, tableName string, match func(memory.Row) bool) ([]memory.Row, error)
+}
+
+// rowMatch return matcher of rows whose columns equal to values of where
+func rowMatch(where sqlmapper.RowData) func(memory.Row) bool {
+	return func(r memory.Row) bool {
+		for col, colData := range where {
+			if !memory.Equal(r[col], colData.Data) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// filterMatch return matcher of rows allowed by filters, nil is returned when there is no filter
+func filterMatch(filters []sqlmapper.Filter) func(memory.Row) bool {
+	if len(filters) == 0 {
+		return nil
+	}
+
+	return func(r memory.Row) bool {
+		for _, f := range filters {
+			if !memory.Equal(r[f.ColumnName], f.Value) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// andMatch return matcher of rows matching all matchers, nil matchers are skipped
+func andMatch(matches ...func(memory.Row) bool) func(memory.Row) bool {
+	return func(r memory.Row) bool {
+		for _, m := range matches {
+			if m != nil && !m(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func notDeleted(r memory.Row) bool {
+	return r[database.SoftDeleteColumn] == nil
+}
+
+func (s *memoryStore) Query(ctx context.Context, q sqlmapper.Query) ([]string, []interface{}, error) {
+	data, err := s.queryRows(ctx, q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(data) == 0 {
+		return q.Columns(), nil, nil
+	}
+
+	return q.Columns(), data, nil
+}
+
+func (s *memoryStore) StreamQuery(ctx context.Context, q sqlmapper.Query, w sqlmapper.RowWriter) error {
+	cols, err := s.ColumnMetadata(q)
+	if err != nil {
+		return err
+	}
+	for i, c := range sqlmapper.MaskColumns(ctx, cols, q.Fields) {
+		cols[i].ReadOnly = cols[i].ReadOnly || c != nil
+	}
+
+	if err := w.WriteHeader(q.ResultColumns(q.Columns()), cols); err != nil {
+		return err
+	}
+
+	data, err := s.queryRows(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range data {
+		if err := w.WriteRow(row.([]interface{})); err != nil {
+			if err == sqlmapper.ErrStopStream {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queryRows run a query, rows are returned like rows of pgStore: fields, then included rows and labels
+func (s *memoryStore) queryRows(ctx context.Context, q sqlmapper.Query) ([]interface{}, error) {
+	if err := sqlmapper.CheckQuery(ctx, q); err != nil {
+		return nil, err
+	}
+
+	m, ok := s.modelMap[q.SourceDatabase][q.SourceTable]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", q.SourceDatabase, q.SourceTable)
+	}
+	if _, err := q.ColumnMetadata(m.Columns); err != nil {
+		return nil, err
+	}
+	if err := sqlmapper.CheckMaskedQuery(ctx, m.Columns, q); err != nil {
+		return nil, err
+	}
+
+	includes, err := s.makeIncludePlans(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := s.makeLabelPlans(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := s.queryMatch(ctx, q, m)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Select(q.SourceDatabase, q.SourceTable, match)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(q.Order) > 0 {
+		if len(q.Order) != 2 {
+			return nil, fmt.Errorf("error require 2 elements: column name and 'asc' if ascending order, 'desc' if descending order")
+		}
+		if err := memory.CheckColumns(m, q.Order[:1]); err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(q.Order[1]) {
+		case "asc", "desc":
+		default:
+			return nil, fmt.Errorf("unknown order direction %s", q.Order[1])
+		}
+		memory.Sort(rows, []memory.Order{{Column: q.Order[0], Desc: strings.EqualFold(q.Order[1], "desc")}})
+	}
+
+	if q.Offset >= len(rows) {
+		rows = nil
+	} else if q.Offset > 0 {
+		rows = rows[q.Offset:]
+	}
+	if q.Limit > 0 && q.Limit < len(rows) {
+		rows = rows[:q.Limit]
+	}
+
+	related := make([]map[string][]interface{}, len(includes))
+	for i, inc := range includes {
+		if related[i], err = s.includedRows(q.SourceDatabase, inc, rows); err != nil {
+			return nil, err
+		}
+	}
+
+	labelValues := make([]map[string]interface{}, len(labels))
+	for i, l := range labels {
+		if labelValues[i], err = s.labelValues(q.SourceDatabase, l, rows); err != nil {
+			return nil, err
+		}
+	}
+
+	masks := sqlmapper.MaskColumns(ctx, m.Columns, q.Fields)
+	res := []interface{}{}
+	for _, r := range rows {
+		row := []interface{}{}
+		for _, f := range q.Fields {
+			row = append(row, r[f])
+		}
+		sqlmapper.MaskRow(masks, row)
+
+		for i, inc := range includes {
+			var rel []interface{}
+			if key := r[inc.localColumn]; key != nil {
+				rel = related[i][memory.Key(key)]
+			}
+
+			if inc.relationship == database.RelationshipBelongsTo {
+				if len(rel) > 0 {
+					row = append(row, rel[0])
+				} else {
+					row = append(row, nil)
+				}
+				continue
+			}
+
+			if rel == nil {
+				rel = []interface{}{}
+			}
+			row = append(row, rel)
+		}
+
+		for i, l := range labels {
+			var v interface{}
+			if key := r[l.column]; key != nil {
+				v = labelValues[i][memory.Key(key)]
+			}
+			row = append(row, v)
+		}
+
+		res = append(res, row)
+	}
+
+	return res, nil
+}
+
+// queryMatch return matcher of rows selected by filter, soft delete and row filters of a query
+func (s *memoryStore) queryMatch(ctx context.Context, q sqlmapper.Query, m database.Model) (func(memory.Row) bool, error) {
+	matches := []func(memory.Row) bool{}
+	if !q.IncludeDeleted && s.isSoftDelete(q.SourceDatabase, q.SourceTable) {
+		matches = append(matches, notDeleted)
+	}
+
+	if !q.Filter.IsZero() {
+		if err := memory.CheckColumns(m, []string{q.Filter.ColumnName}); err != nil {
+			return nil, err
+		}
+
+		col, v := q.Filter.ColumnName, q.Filter.Value
+		switch q.Filter.Operator {
+		case "=":
+			matches = append(matches, func(r memory.Row) bool { return memory.Equal(r[col], v) })
+		case "ilike":
+			pattern := fmt.Sprint(v)
+			matches = append(matches, func(r memory.Row) bool { return r[col] != nil && memory.Like(r[col], pattern, true) })
+		default:
+			return nil, fmt.Errorf("unknown filter operator %s", q.Filter.Operator)
+		}
+	}
+
+	filters, err := sqlmapper.RowFilters(ctx, q.SourceDatabase, q.SourceTable)
+	if err != nil {
+		return nil, err
+	}
+
+	return andMatch(append(matches, filterMatch(filters))...), nil
+}
+
+// includedRows load rows of an included table related to rows, result is grouped by key
+func (s *memoryStore) includedRows(dbName string, inc includePlan, rows []memory.Row) (map[string][]interface{}, error) {
+	res := make(map[string][]interface{})
+	keys := make(map[string]bool)
+	for _, r := range rows {
+		if key := r[inc.localColumn]; key != nil {
+			keys[memory.Key(key)] = true
+		}
+	}
+	if len(keys) == 0 {
+		return res, nil
+	}
+
+	matches := []func(memory.Row) bool{
+		func(r memory.Row) bool { return r[inc.remoteColumn] != nil && keys[memory.Key(r[inc.remoteColumn])] },
+		filterMatch(inc.filters),
+	}
+	if inc.skipDeleted {
+		matches = append(matches, notDeleted)
+	}
+
+	related, err := s.db.Select(dbName, inc.Table, andMatch(matches...))
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []memory.Order{}
+	for _, col := range inc.order {
+		orders = append(orders, memory.Order{Column: col})
+	}
+	memory.Sort(related, orders)
+
+	for _, r := range related {
+		key := memory.Key(r[inc.remoteColumn])
+		if inc.Limit > 0 && inc.relationship == database.RelationshipHasMany && len(res[key]) >= inc.Limit {
+			continue
+		}
+
+		row := []interface{}{}
+		for _, f := range inc.Fields {
+			row = append(row, r[f])
+		}
+		sqlmapper.MaskRow(inc.masks, row)
+		res[key] = append(res[key], row)
+	}
+
+	return res, nil
+}
+
+// labelValues load labels of referenced rows of rows by key
+func (s *memoryStore) labelValues(dbName string, l labelPlan, rows []memory.Row) (map[string]interface{}, error) {
+	res := make(map[string]interface{})
+	keys := make(map[string]bool)
+	for _, r := range rows {
+		if key := r[l.column]; key != nil {
+			keys[memory.Key(key)] = true
+		}
+	}
+	if len(keys) == 0 {
+		return res, nil
+	}
+
+	referenced, err := s.db.Select(dbName, l.table, andMatch(
+		func(r memory.Row) bool { return r[l.keyColumn] != nil && keys[memory.Key(r[l.keyColumn])] },
+		filterMatch(l.filters),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range referenced {
+		row := []interface{}{r[l.labelColumn]}
+		sqlmapper.MaskRow([]*database.Column{l.mask}, row)
+		res[memory.Key(r[l.keyColumn])] = row[0]
+	}
+
+	return res, nil
+}
+
+// FindRows find rows whose columns equal to values of where, all stored columns of model are returned.
+// Soft-deleted rows are included
+func (s *memoryStore) FindRows(ctx context.Context, dbName, tableName string, where sqlmapper.RowData) ([]sqlmapper.RowData, error) {
+	m, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+	if len(where) == 0 {
+		return nil, errors.New("missing condition to find rows")
+	}
+
+	cols := database.Columns(m.Columns).Stored().Names()
+	for _, colName := range where.Columns() {
+		if err := checkColumnFieldIsValid(cols, colName); err != nil {
+			return nil, err
+		}
+	}
+
+	filters, err := sqlmapper.RowFilters(ctx, dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Select(dbName, tableName, andMatch(rowMatch(where), filterMatch(filters)))
+	if err != nil {
+		return nil, err
+	}
+
+	res := []sqlmapper.RowData{}
+	for _, r := range rows {
+		res = append(res, s.storedRow(dbName, tableName, r))
+	}
+
+	return res, nil
+}
+
+// storedRow return all stored columns of a row
+func (s *memoryStore) storedRow(dbName, tableName string, r memory.Row) sqlmapper.RowData {
+	res := make(sqlmapper.RowData)
+	for _, col := range database.Columns(s.modelMap[dbName][tableName].Columns).Stored().Names() {
+		res[col] = sqlmapper.ColData{Name: col, Data: r[col]}
+	}
+
+	return res
+}
+
+// rawQuery run a SELECT of a table of dbName, metadata of result columns are taken from model
+func (s *memoryStore) rawQuery(dbName, query string) ([]string, []database.Column, [][]interface{}, error) {
+	if _, ok := s.modelMap[dbName]; !ok {
+		return nil, nil, nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	stmt, err := memory.ParseSelect(query)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cols, rows, err := s.db.Run(dbName, stmt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	colMap := database.Columns(s.modelMap[dbName][stmt.Table].Columns).GroupByName()
+	fields := stmt.Fields
+	if fields == nil {
+		for _, col := range cols {
+			fields = append(fields, memory.Field{Column: col, Name: col})
+		}
+	}
+
+	colMeta := []database.Column{}
+	for _, f := range fields {
+		col := colMap[f.Column][0]
+		col.Name = f.Name
+		colMeta = append(colMeta, col)
+	}
+
+	return cols, colMeta, rows, nil
+}
+
+func (s *memoryStore) RawQuery(ctx context.Context, dbName string, sql string) ([]string, []database.Column, []interface{}, error) {
+	cols, colMeta, rows, err := s.rawQuery(dbName, sql)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var data []interface{}
+	masks := sqlmapper.MaskColumns(ctx, s.maskedColumns(dbName), cols)
+	for _, row := range rows {
+		sqlmapper.MaskRow(masks, row)
+		data = append(data, row)
+	}
+
+	return cols, colMeta, data, nil
+}
+
+func (s *memoryStore) StreamRawQuery(ctx context.Context, dbName string, sql string, w sqlmapper.RowWriter) error {
+	cols, colMeta, rows, err := s.rawQuery(dbName, sql)
+	if err != nil {
+		return err
+	}
+
+	if err := w.WriteHeader(cols, colMeta); err != nil {
+		return err
+	}
+
+	masks := sqlmapper.MaskColumns(ctx, s.maskedColumns(dbName), cols)
+	for _, row := range rows {
+		sqlmapper.MaskRow(masks, row)
+		if err := w.WriteRow(row); err != nil {
+			if err == sqlmapper.ErrStopStream {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStore) ColumnMetadataByRows(rows *sql.Rows) ([]database.Column, error) {
+	return nil, errors.New("memory database doesn't have sql rows")
+}
+
+// Explain check a SELECT can be run, its plan is a sequential scan of its table in format of postgres
+func (s *memoryStore) Explain(ctx context.Context, dbName string, sql string) (interface{}, error) {
+	if _, ok := s.modelMap[dbName]; !ok {
+		return nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	stmt, err := memory.ParseSelect(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt.Limit = 0
+	if _, _, err := s.db.Run(dbName, stmt); err != nil {
+		return nil, err
+	}
+
+	return []interface{}{
+		map[string]interface{}{
+			"Plan": map[string]interface{}{
+				"Node Type":      "Seq Scan",
+				"Parallel Aware": false,
+				"Relation Name":  stmt.Table,
+				"Alias":          stmt.Table,
+			},
+		},
+	}, nil
+}
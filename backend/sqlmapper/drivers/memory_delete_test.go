@@ -0,0 +1,125 @@
+package drivers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// setOnDelete set on_delete of books of users, user_id of books is nullable for set_null
+func setOnDelete(modelMap map[string]map[string]database.Model, onDelete string) {
+	users := modelMap["fortress"]["users"]
+	users.Relationship = []database.Relationship{
+		{Table: "books", Type: database.RelationshipHasMany, OnDelete: onDelete},
+		{Table: "profiles", Type: database.RelationshipHasOne, OnDelete: database.OnDeleteCascade},
+	}
+	modelMap["fortress"]["users"] = users
+
+	books := modelMap["fortress"]["books"]
+	books.Columns = append([]database.Column{}, books.Columns...)
+	books.Columns[1].IsNullable = true
+	modelMap["fortress"]["books"] = books
+}
+
+func TestMemoryStoreDeleteOnDelete(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		onDelete     string
+		wantBooks    []memory.Row
+		wantProfiles int
+		wantErr      bool
+	}{
+		{
+			name:     "restrict reject delete of referenced rows",
+			onDelete: database.OnDeleteRestrict,
+			wantBooks: []memory.Row{
+				{"id": int64(1), "user_id": int64(1), "title": "go"},
+				{"id": int64(2), "user_id": int64(1), "title": "sql"},
+				{"id": int64(3), "user_id": int64(2), "title": "yaml"},
+			},
+			wantProfiles: 1,
+			wantErr:      true,
+		},
+		{
+			name:     "set_null clear foreign keys of dependent rows",
+			onDelete: database.OnDeleteSetNull,
+			wantBooks: []memory.Row{
+				{"id": int64(1), "user_id": nil, "title": "go"},
+				{"id": int64(2), "user_id": nil, "title": "sql"},
+				{"id": int64(3), "user_id": int64(2), "title": "yaml"},
+			},
+		},
+		{
+			name:      "cascade delete dependent rows",
+			onDelete:  database.OnDeleteCascade,
+			wantBooks: []memory.Row{{"id": int64(3), "user_id": int64(2), "title": "yaml"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modelMap := testModelMap()
+			m, db := newTestMemoryStoreOf(t, modelMap)
+			setOnDelete(modelMap, tt.onDelete)
+
+			err := m.Delete(ctx, "fortress", "users", []interface{}{"id"}, []interface{}{1})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Delete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			books, err := db.Select("fortress", "books", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(books, tt.wantBooks) {
+				t.Errorf("rows of books = %v, want %v", books, tt.wantBooks)
+			}
+
+			// profiles are cascaded in the same transaction, so they are kept when delete fails
+			profiles, err := db.Select("fortress", "profiles", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(profiles) != tt.wantProfiles {
+				t.Errorf("rows of profiles = %d, want %d", len(profiles), tt.wantProfiles)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreDeletePreview(t *testing.T) {
+	modelMap := testModelMap()
+	m, db := newTestMemoryStoreOf(t, modelMap)
+	setOnDelete(modelMap, database.OnDeleteSetNull)
+
+	got, err := m.DeletePreview(context.Background(), "fortress", "users", []interface{}{"id"}, []interface{}{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := sqlmapper.DeleteImpact{
+		Table: "users",
+		Count: 1,
+		Dependents: []sqlmapper.DeleteImpact{
+			{Table: "books", Columns: []string{"user_id"}, OnDelete: database.OnDeleteSetNull, Count: 2, Dependents: []sqlmapper.DeleteImpact{}},
+			{Table: "profiles", Columns: []string{"user_id"}, OnDelete: database.OnDeleteCascade, Count: 1, Dependents: []sqlmapper.DeleteImpact{}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DeletePreview() = %+v, want %+v", got, want)
+	}
+
+	books, err := db.Select("fortress", "books", func(r memory.Row) bool { return memory.Equal(r["user_id"], 1) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 2 {
+		t.Errorf("DeletePreview() changed books of user, %d books left", len(books))
+	}
+}
@@ -0,0 +1,63 @@
+package drivers
+
+import (
+	"context"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+)
+
+// rowsToRecord read rows of tableName matching match before they are written, nothing is read when
+// writes of ctx aren't recorded
+func (s *memoryStore) rowsToRecord(ctx context.Context, tx *memory.Tx, dbName, tableName string, match func(memory.Row) bool) ([]memory.Row, error) {
+	if !sqlmapper.RecordsWrites(ctx) {
+		return nil, nil
+	}
+
+	return tx.Select(dbName, tableName, match)
+}
+
+// recordRows record rows read by rowsToRecord after they are written by action
+func (s *memoryStore) recordRows(ctx context.Context, tx *memory.Tx, dbName, tableName, action string, before []memory.Row) error {
+	for _, r := range before {
+		if err := s.recordRow(ctx, tx, dbName, tableName, action, r, s.primaryKeyOf(dbName, tableName, r)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// primaryKeyOf return values of primary columns of a row
+func (s *memoryStore) primaryKeyOf(dbName, tableName string, r memory.Row) sqlmapper.RowData {
+	res := make(sqlmapper.RowData)
+	for _, col := range primaryColumnNames(s.modelMap[dbName][tableName]) {
+		res[col] = sqlmapper.ColData{Data: r[col]}
+	}
+
+	return res
+}
+
+// recordRow read a row by its primary key after it is written by action, then record it with its
+// values before the write, before is nil for a created row. Rows of tables without primary key aren't recorded
+func (s *memoryStore) recordRow(ctx context.Context, tx *memory.Tx, dbName, tableName, action string, before memory.Row, primaryKeyMap sqlmapper.RowData) error {
+	pks := primaryColumnNames(s.modelMap[dbName][tableName])
+	if !sqlmapper.RecordsWrites(ctx) || len(pks) == 0 || len(primaryKeyMap) != len(pks) {
+		return nil
+	}
+
+	rows, err := tx.Select(dbName, tableName, rowMatch(primaryKeyMap))
+	if err != nil {
+		return err
+	}
+
+	w := sqlmapper.RowWrite{DBName: dbName, TableName: tableName, Action: action}
+	if before != nil {
+		w.Before = s.storedRow(dbName, tableName, before)
+	}
+	if len(rows) == 1 {
+		w.After = s.storedRow(dbName, tableName, rows[0])
+	}
+
+	return sqlmapper.RecordWrite(ctx, w)
+}
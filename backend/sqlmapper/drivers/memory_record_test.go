@@ -0,0 +1,133 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+var errRecord = errors.New("can't record")
+
+// writeLog record written rows as "action table id", a write of table failOn can't be recorded
+type writeLog struct {
+	writes []string
+	failOn string
+}
+
+func (l *writeLog) RecordWrite(ctx context.Context, w sqlmapper.RowWrite) error {
+	if w.TableName == l.failOn {
+		return errRecord
+	}
+
+	row := w.After
+	if row == nil {
+		row = w.Before
+	}
+	l.writes = append(l.writes, fmt.Sprintf("%s %s %v", w.Action, w.TableName, row["id"].Data))
+
+	return nil
+}
+
+func TestMemoryStoreRecordWrites(t *testing.T) {
+	noHooks := func(h *database.Hooks) {}
+
+	tests := []struct {
+		name    string
+		hooks   func(h *database.Hooks)
+		failOn  string
+		run     func(ctx context.Context, m sqlmapper.Mapper) error
+		wantErr error
+		want    []string
+	}{
+		{
+			name:  "related rows of a nested create",
+			hooks: noHooks,
+			run: func(ctx context.Context, m sqlmapper.Mapper) error {
+				_, err := m.Create(ctx, "fortress", "users", sqlmapper.RowData{
+					"name":  {Data: "dan"},
+					"books": {Data: []sqlmapper.RowData{{"title": {Data: "json"}}}},
+				})
+				return err
+			},
+			want: []string{"create users 4", "create books 4"},
+		},
+		{
+			name:  "dependent rows of a cascade delete",
+			hooks: noHooks,
+			run: func(ctx context.Context, m sqlmapper.Mapper) error {
+				return m.Delete(ctx, "fortress", "users", []interface{}{"id"}, []interface{}{1})
+			},
+			want: []string{"delete books 1", "delete books 2", "delete profiles 1", "delete users 1"},
+		},
+		{
+			name: "rows written by hooks",
+			hooks: func(h *database.Hooks) {
+				h.AfterUpdate = database.Hook{Enable: true, Content: `db_create("fortress", "notes", {"body": "user updated"})`}
+			},
+			run: func(ctx context.Context, m sqlmapper.Mapper) error {
+				_, err := m.Update(ctx, "fortress", "users", sqlmapper.RowData{"id": {Data: 2}, "name": {Data: "bobby"}, "version": {Data: 1}})
+				return err
+			},
+			want: []string{"update users 2", "create notes 2"},
+		},
+		{
+			name:  "rows referenced by an earlier operation of a transaction",
+			hooks: noHooks,
+			run: func(ctx context.Context, m sqlmapper.Mapper) error {
+				_, err := m.Transaction(ctx, "fortress", []sqlmapper.Operation{
+					{Type: sqlmapper.OperationCreate, TableName: "books", Fields: []interface{}{"user_id", "title"}, Data: []interface{}{2, "toml"}},
+					{Type: sqlmapper.OperationDelete, TableName: "books", Fields: []interface{}{"id"}, Data: []interface{}{map[string]interface{}{sqlmapper.RefKey: "0.id"}}},
+				})
+				return err
+			},
+			want: []string{"create books 4", "delete books 4"},
+		},
+		{
+			name:    "a write whose row can't be recorded is rolled back",
+			hooks:   noHooks,
+			failOn:  "books",
+			wantErr: errRecord,
+			run: func(ctx context.Context, m sqlmapper.Mapper) error {
+				_, err := m.Update(ctx, "fortress", "users", sqlmapper.RowData{
+					"id":      {Data: 2},
+					"name":    {Data: "bobby"},
+					"version": {Data: 1},
+					"books":   {Data: []sqlmapper.RowData{{"title": {Data: "json"}}}},
+				})
+				return err
+			},
+			want: []string{"update users 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, db := newTestHookStore(t, tt.hooks)
+			log := &writeLog{failOn: tt.failOn}
+
+			if err := tt.run(sqlmapper.WithWriteRecorder(context.Background(), log), m); err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(log.writes, tt.want) {
+				t.Errorf("recorded writes = %v, want %v", log.writes, tt.want)
+			}
+
+			if tt.wantErr == nil {
+				return
+			}
+			bobs, err := db.Select("fortress", "users", func(r memory.Row) bool { return r["name"] == "bob" })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(bobs) != 1 {
+				t.Error("updated row isn't rolled back")
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package drivers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// relationshipModelMap return test models with tags of books linked through book_tags
+func relationshipModelMap() map[string]map[string]database.Model {
+	modelMap := testModelMap()
+	books := modelMap["fortress"]["books"]
+	books.Relationship = append(books.Relationship, database.Relationship{Table: "tags", Type: database.RelationshipManyToMany, Through: "book_tags"})
+	modelMap["fortress"]["books"] = books
+
+	modelMap["fortress"]["tags"] = database.Model{
+		TableName: "tags",
+		Columns: []database.Column{
+			{Name: "id", Type: "int", IsPrimary: true, IsGenerated: true},
+			{Name: "name", Type: "string"},
+		},
+	}
+	modelMap["fortress"]["book_tags"] = database.Model{
+		TableName: "book_tags",
+		Columns: []database.Column{
+			{Name: "id", Type: "int", IsPrimary: true, IsGenerated: true},
+			{Name: "book_id", Type: "int", ForeignKey: database.ForeignKey{Table: "books", ForeignColumn: "id"}},
+			{Name: "tag_id", Type: "int", ForeignKey: database.ForeignKey{Table: "tags", ForeignColumn: "id"}},
+		},
+	}
+
+	return modelMap
+}
+
+func TestMemoryStoreNestedWrite(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		models  func(modelMap map[string]map[string]database.Model)
+		run     func(m sqlmapper.Mapper) error
+		table   string
+		want    []memory.Row
+		wantErr bool
+	}{
+		{
+			name: "belongs_to row is created first and fill foreign key",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Create(ctx, "fortress", "books", sqlmapper.RowData{
+					"title": {Data: "json"},
+					"users": {Data: []sqlmapper.RowData{{"name": {Data: "dan"}}}},
+				})
+				return err
+			},
+			table: "books",
+			want: []memory.Row{
+				{"id": int64(1), "user_id": int64(1), "title": "go"},
+				{"id": int64(2), "user_id": int64(1), "title": "sql"},
+				{"id": int64(3), "user_id": int64(2), "title": "yaml"},
+				{"id": int64(4), "user_id": int64(4), "title": "json"},
+			},
+		},
+		{
+			name: "many_to_many rows are linked through join table once",
+			run: func(m sqlmapper.Mapper) error {
+				for i := 0; i < 2; i++ {
+					_, err := m.Create(ctx, "fortress", "tags", sqlmapper.RowData{"name": {Data: "tech"}})
+					if err != nil {
+						return err
+					}
+				}
+				_, err := m.Create(ctx, "fortress", "books", sqlmapper.RowData{
+					"user_id": {Data: 2},
+					"title":   {Data: "json"},
+					"tags":    {Data: []sqlmapper.RowData{{"id": {Data: 1}, "name": {Data: "tech"}}, {"id": {Data: 1}, "name": {Data: "tech"}}, {"name": {Data: "new"}}}},
+				})
+				return err
+			},
+			table: "book_tags",
+			want: []memory.Row{
+				{"id": int64(1), "book_id": int64(4), "tag_id": int64(1)},
+				{"id": int64(2), "book_id": int64(4), "tag_id": int64(3)},
+			},
+		},
+		{
+			name: "has_one accept only 1 row",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Create(ctx, "fortress", "users", sqlmapper.RowData{
+					"name":     {Data: "dan"},
+					"profiles": {Data: []sqlmapper.RowData{{"bio": {Data: "a"}}, {"bio": {Data: "b"}}}},
+				})
+				return err
+			},
+			table:   "profiles",
+			want:    []memory.Row{{"id": int64(1), "user_id": int64(1), "bio": "gopher"}},
+			wantErr: true,
+		},
+		{
+			name: "many_to_many without through table",
+			models: func(modelMap map[string]map[string]database.Model) {
+				books := modelMap["fortress"]["books"]
+				books.Relationship = []database.Relationship{{Table: "tags", Type: database.RelationshipManyToMany}}
+				modelMap["fortress"]["books"] = books
+			},
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Create(ctx, "fortress", "books", sqlmapper.RowData{
+					"user_id": {Data: 2},
+					"title":   {Data: "json"},
+					"tags":    {Data: []sqlmapper.RowData{{"name": {Data: "new"}}}},
+				})
+				return err
+			},
+			table:   "tags",
+			want:    []memory.Row{},
+			wantErr: true,
+		},
+		{
+			name: "unknown relationship type",
+			models: func(modelMap map[string]map[string]database.Model) {
+				users := modelMap["fortress"]["users"]
+				users.Relationship = []database.Relationship{{Table: "books", Type: "has_few"}}
+				modelMap["fortress"]["users"] = users
+			},
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Create(ctx, "fortress", "users", sqlmapper.RowData{
+					"name":  {Data: "dan"},
+					"books": {Data: []sqlmapper.RowData{{"title": {Data: "json"}}}},
+				})
+				return err
+			},
+			table: "users",
+			want: []memory.Row{
+				{"id": int64(1), "name": "ann", "region": "north", "email": "ann@x.com", "version": int64(1)},
+				{"id": int64(2), "name": "bob", "region": "south", "version": int64(1)},
+				{"id": int64(3), "name": "cat", "region": "north", "version": int64(1)},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modelMap := relationshipModelMap()
+			m, db := newTestMemoryStoreOf(t, modelMap)
+			// models are read on every call, so they are changed after seeding
+			if tt.models != nil {
+				tt.models(modelMap)
+			}
+
+			err := tt.run(m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got, err := db.Select("fortress", tt.table, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows of %s = %v, want %v", tt.table, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+)
+
+// tableFilters filter rows of each table by its own filters
+type tableFilters map[string][]sqlmapper.Filter
+
+func (f tableFilters) RowFilters(dbName, tableName string) ([]sqlmapper.Filter, error) {
+	return f[tableName], nil
+}
+
+var errDenied = errors.New("denied")
+
+// deniedActions deny an action on a table
+type deniedActions map[string]string
+
+func (d deniedActions) AuthorizeTable(dbName, tableName, action string) error {
+	if d[tableName] == action {
+		return errDenied
+	}
+
+	return nil
+}
+
+func TestMemoryStoreRowFilterWrite(t *testing.T) {
+	ctx := context.Background()
+	southUsers := sqlmapper.WithRowFilter(ctx, regionFilter("south"))
+	booksOfBob := sqlmapper.WithRowFilter(ctx, tableFilters{"books": {{Operator: "=", ColumnName: "user_id", Value: 2}}})
+	books := []memory.Row{
+		{"id": int64(1), "user_id": int64(1), "title": "go"},
+		{"id": int64(2), "user_id": int64(1), "title": "sql"},
+		{"id": int64(3), "user_id": int64(2), "title": "yaml"},
+	}
+
+	tests := []struct {
+		name    string
+		run     func(m sqlmapper.Mapper) error
+		wantErr error
+		table   string
+		want    []memory.Row
+	}{
+		{
+			name: "create without a filtered column set it from filter",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Create(southUsers, "fortress", "users", sqlmapper.RowData{"name": {Data: "dan"}})
+				return err
+			},
+			table: "users",
+			want: []memory.Row{
+				{"id": int64(1), "name": "ann", "region": "north", "email": "ann@x.com", "version": int64(1)},
+				{"id": int64(2), "name": "bob", "region": "south", "version": int64(1)},
+				{"id": int64(3), "name": "cat", "region": "north", "version": int64(1)},
+				{"id": int64(4), "name": "dan", "region": "south", "version": int64(1)},
+			},
+		},
+		{
+			name: "create outside row filters",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Create(southUsers, "fortress", "users", sqlmapper.RowData{"name": {Data: "dan"}, "region": {Data: "north"}})
+				return err
+			},
+			wantErr: sqlmapper.ForbiddenRowError{TableName: "users"},
+		},
+		{
+			name: "nested write linking a related row outside its row filters",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Update(booksOfBob, "fortress", "users", sqlmapper.RowData{
+					"id":      {Data: 2},
+					"version": {Data: 1},
+					"books":   {Data: []sqlmapper.RowData{{"id": {Data: 1}}}},
+				})
+				return err
+			},
+			wantErr: sqlmapper.ForbiddenRowError{TableName: "books"},
+			table:   "books",
+			want:    books,
+		},
+		{
+			name: "nested create on a table without create permission",
+			run: func(m sqlmapper.Mapper) error {
+				ctx := sqlmapper.WithTablePermission(ctx, deniedActions{"books": "create"})
+				_, err := m.Update(ctx, "fortress", "users", sqlmapper.RowData{
+					"id":      {Data: 2},
+					"version": {Data: 1},
+					"books":   {Data: []sqlmapper.RowData{{"title": {Data: "rust"}}}},
+				})
+				return err
+			},
+			wantErr: errDenied,
+			table:   "books",
+			want:    books,
+		},
+		{
+			name: "cascade delete on a table without delete permission",
+			run: func(m sqlmapper.Mapper) error {
+				ctx := sqlmapper.WithTablePermission(ctx, deniedActions{"books": "delete"})
+				return m.Delete(ctx, "fortress", "users", []interface{}{"id"}, []interface{}{1})
+			},
+			wantErr: errDenied,
+			table:   "books",
+			want:    books,
+		},
+		{
+			name: "cascade delete without dependent rows doesn't need their permission",
+			run: func(m sqlmapper.Mapper) error {
+				ctx := sqlmapper.WithTablePermission(ctx, deniedActions{"books": "delete"})
+				return m.Delete(ctx, "fortress", "users", []interface{}{"id"}, []interface{}{3})
+			},
+			table: "books",
+			want:  books,
+		},
+		{
+			name: "cascade delete of dependent rows outside their row filters",
+			run: func(m sqlmapper.Mapper) error {
+				return m.Delete(booksOfBob, "fortress", "users", []interface{}{"id"}, []interface{}{1})
+			},
+			wantErr: sqlmapper.ForbiddenRowError{TableName: "books"},
+			table:   "books",
+			want:    books,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, db := newTestMemoryStore(t)
+
+			if err := tt.run(m); !reflect.DeepEqual(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.table == "" {
+				return
+			}
+
+			got, err := db.Select("fortress", tt.table, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows of %s = %v, want %v", tt.table, got, tt.want)
+			}
+		})
+	}
+}
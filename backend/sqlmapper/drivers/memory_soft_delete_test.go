@@ -0,0 +1,122 @@
+package drivers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+func TestMemoryStoreSoftDelete(t *testing.T) {
+	ctx := context.Background()
+	notes := sqlmapper.Query{SourceDatabase: "fortress", SourceTable: "notes", Fields: []string{"id", "body"}}
+	deleteNote := func(m sqlmapper.Mapper) error {
+		return m.Delete(ctx, "fortress", "notes", []interface{}{"id"}, []interface{}{1})
+	}
+
+	tests := []struct {
+		name           string
+		run            func(m sqlmapper.Mapper) error
+		includeDeleted bool
+		want           []interface{}
+		wantErr        bool
+	}{
+		{
+			name: "deleted rows are hidden from queries",
+			run:  deleteNote,
+			want: nil,
+		},
+		{
+			name:           "deleted rows are queried with include_deleted",
+			run:            deleteNote,
+			includeDeleted: true,
+			want:           []interface{}{[]interface{}{int64(1), "hello"}},
+		},
+		{
+			name: "restored rows are queried again",
+			run: func(m sqlmapper.Mapper) error {
+				if err := deleteNote(m); err != nil {
+					return err
+				}
+				return m.Restore(ctx, "fortress", "notes", []interface{}{"id"}, []interface{}{1})
+			},
+			want: []interface{}{[]interface{}{int64(1), "hello"}},
+		},
+		{
+			name: "restore a table without soft_delete",
+			run: func(m sqlmapper.Mapper) error {
+				return m.Restore(ctx, "fortress", "books", []interface{}{"id"}, []interface{}{1})
+			},
+			want:    []interface{}{[]interface{}{int64(1), "hello"}},
+			wantErr: true,
+		},
+		{
+			name: "restore without filter",
+			run: func(m sqlmapper.Mapper) error {
+				if err := deleteNote(m); err != nil {
+					return err
+				}
+				return m.Restore(ctx, "fortress", "notes", nil, nil)
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMemoryStore(t)
+
+			err := tt.run(m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			q := notes
+			q.IncludeDeleted = tt.includeDeleted
+			_, got, err := m.Query(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreSoftDeleteKeepDeletedAt(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMemoryStore(t)
+
+	deletedAt := func() interface{} {
+		rows, err := db.Select("fortress", "notes", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("rows of notes = %v, want the deleted row", rows)
+		}
+		return rows[0][database.SoftDeleteColumn]
+	}
+
+	if err := m.Delete(ctx, "fortress", "notes", []interface{}{"id"}, []interface{}{1}); err != nil {
+		t.Fatal(err)
+	}
+	first, ok := deletedAt().(time.Time)
+	if !ok {
+		t.Fatalf("%s = %v, want a time", database.SoftDeleteColumn, deletedAt())
+	}
+
+	// a deleted row isn't matched by delete again, so it keeps its deletion time
+	if err := m.Delete(ctx, "fortress", "notes", []interface{}{"id"}, []interface{}{1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := deletedAt(); !memory.Equal(got, first) {
+		t.Errorf("%s = %v after delete again, want %v", database.SoftDeleteColumn, got, first)
+	}
+}
@@ -0,0 +1,556 @@
+package drivers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+func newTestMemoryStore(t *testing.T) (sqlmapper.Mapper, *memory.DB) {
+	return newTestMemoryStoreOf(t, testModelMap())
+}
+
+// testModelMap return models of test stores, a test can change them before making its store
+func testModelMap() map[string]map[string]database.Model {
+	return map[string]map[string]database.Model{
+		"fortress": {
+			"users": {
+				TableName:     "users",
+				VersionColumn: "version",
+				Columns: []database.Column{
+					{Name: "id", Type: "int", IsPrimary: true, IsGenerated: true},
+					{Name: "name", Type: "string"},
+					{Name: "region", Type: "string", IsNullable: true, Validation: database.Validation{Pattern: "^[a-z]+$"}},
+					{Name: "email", Type: "string", IsNullable: true, Mask: database.MaskPartial},
+					{Name: "version", Type: "int", IsNullable: true},
+				},
+				Relationship: []database.Relationship{
+					{Table: "books", Type: database.RelationshipHasMany, OnDelete: database.OnDeleteCascade},
+					{Table: "profiles", Type: database.RelationshipHasOne, OnDelete: database.OnDeleteCascade},
+				},
+			},
+			"profiles": {
+				TableName: "profiles",
+				Columns: []database.Column{
+					{Name: "id", Type: "int", IsPrimary: true, IsGenerated: true},
+					{Name: "user_id", Type: "int", ForeignKey: database.ForeignKey{Table: "users", ForeignColumn: "id"}},
+					{Name: "bio", Type: "string"},
+				},
+			},
+			"books": {
+				TableName: "books",
+				Columns: []database.Column{
+					{Name: "id", Type: "int", IsPrimary: true, IsGenerated: true},
+					{Name: "user_id", Type: "int", ForeignKey: database.ForeignKey{Table: "users", ForeignColumn: "id"}},
+					{Name: "title", Type: "string"},
+				},
+				Relationship: []database.Relationship{{Table: "users", Type: database.RelationshipBelongsTo}},
+			},
+			"notes": {
+				TableName:  "notes",
+				SoftDelete: true,
+				Columns: []database.Column{
+					{Name: "id", Type: "int", IsPrimary: true, IsGenerated: true},
+					{Name: "body", Type: "string"},
+					{Name: database.SoftDeleteColumn, Type: "timestamp", IsNullable: true},
+				},
+			},
+		},
+	}
+}
+
+// newTestMemoryStoreOf make a memory store of modelMap seeded with test rows
+func newTestMemoryStoreOf(t *testing.T, modelMap map[string]map[string]database.Model) (sqlmapper.Mapper, *memory.DB) {
+	db := memory.New(modelMap)
+	seed := []struct {
+		table string
+		row   memory.Row
+	}{
+		{"users", memory.Row{"name": "ann", "region": "north", "email": "ann@x.com", "version": 1}},
+		{"users", memory.Row{"name": "bob", "region": "south", "version": 1}},
+		{"users", memory.Row{"name": "cat", "region": "north", "version": 1}},
+		{"books", memory.Row{"user_id": 1, "title": "go"}},
+		{"books", memory.Row{"user_id": 1, "title": "sql"}},
+		{"books", memory.Row{"user_id": 2, "title": "yaml"}},
+		{"notes", memory.Row{"body": "hello"}},
+		{"profiles", memory.Row{"user_id": 1, "bio": "gopher"}},
+	}
+	for _, s := range seed {
+		if _, err := db.Insert("fortress", s.table, s.row); err != nil {
+			t.Fatalf("seed %s: %v", s.table, err)
+		}
+	}
+
+	return NewMemoryStore(db, modelMap), db
+}
+
+func TestMemoryStoreQuery(t *testing.T) {
+	users := sqlmapper.Query{SourceDatabase: "fortress", SourceTable: "users", Fields: []string{"id", "name"}}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		query   func(q sqlmapper.Query) sqlmapper.Query
+		want    []interface{}
+		wantErr bool
+	}{
+		{
+			name:  "all rows in insertion order",
+			query: func(q sqlmapper.Query) sqlmapper.Query { return q },
+			want: []interface{}{
+				[]interface{}{int64(1), "ann"},
+				[]interface{}{int64(2), "bob"},
+				[]interface{}{int64(3), "cat"},
+			},
+		},
+		{
+			name: "filter, order and limit",
+			query: func(q sqlmapper.Query) sqlmapper.Query {
+				q.Filter = sqlmapper.Filter{Operator: "=", ColumnName: "region", Value: "north"}
+				q.Order = []string{"name", "desc"}
+				q.Limit = 1
+				return q
+			},
+			want: []interface{}{[]interface{}{int64(3), "cat"}},
+		},
+		{
+			name: "ilike filter",
+			query: func(q sqlmapper.Query) sqlmapper.Query {
+				q.Filter = sqlmapper.Filter{Operator: "ilike", ColumnName: "name", Value: "B%"}
+				return q
+			},
+			want: []interface{}{[]interface{}{int64(2), "bob"}},
+		},
+		{
+			name: "has_many rows are included",
+			query: func(q sqlmapper.Query) sqlmapper.Query {
+				q.Filter = sqlmapper.Filter{Operator: "=", ColumnName: "id", Value: 1}
+				q.Include = []sqlmapper.Include{{Table: "books", Fields: []string{"title"}}}
+				return q
+			},
+			want: []interface{}{
+				[]interface{}{int64(1), "ann", []interface{}{[]interface{}{"go"}, []interface{}{"sql"}}},
+			},
+		},
+		{
+			name: "rows outside row filters are hidden",
+			ctx:  sqlmapper.WithRowFilter(ctx, regionFilter("south")),
+			query: func(q sqlmapper.Query) sqlmapper.Query {
+				return q
+			},
+			want: []interface{}{[]interface{}{int64(2), "bob"}},
+		},
+		{
+			name: "unknown column",
+			query: func(q sqlmapper.Query) sqlmapper.Query {
+				q.Order = []string{"age", "asc"}
+				return q
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMemoryStore(t)
+			qCtx := tt.ctx
+			if qCtx == nil {
+				qCtx = ctx
+			}
+
+			_, got, err := m.Query(qCtx, tt.query(users))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreWrite(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		run     func(m sqlmapper.Mapper) error
+		table   string
+		want    []memory.Row
+		wantErr bool
+	}{
+		{
+			name: "create generate primary key and write relate-data",
+			run: func(m sqlmapper.Mapper) error {
+				row := sqlmapper.RowData{
+					"name":  {Data: "dan"},
+					"books": {Data: []sqlmapper.RowData{{"title": {Data: "json"}}}},
+				}
+				if _, err := m.Create(ctx, "fortress", "users", row); err != nil {
+					return err
+				}
+				if !memory.Equal(row["id"].Data, 4) {
+					t.Errorf("Create() id = %v, want 4", row["id"].Data)
+				}
+				return nil
+			},
+			table: "books",
+			want: []memory.Row{
+				{"id": int64(1), "user_id": int64(1), "title": "go"},
+				{"id": int64(2), "user_id": int64(1), "title": "sql"},
+				{"id": int64(3), "user_id": int64(2), "title": "yaml"},
+				{"id": int64(4), "user_id": int64(4), "title": "json"},
+			},
+		},
+		{
+			name: "create ignore value of generated primary key",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Create(ctx, "fortress", "books", sqlmapper.RowData{"id": {Data: 10}, "user_id": {Data: 2}, "title": {Data: "toml"}})
+				return err
+			},
+			table: "books",
+			want: []memory.Row{
+				{"id": int64(1), "user_id": int64(1), "title": "go"},
+				{"id": int64(2), "user_id": int64(1), "title": "sql"},
+				{"id": int64(3), "user_id": int64(2), "title": "yaml"},
+				{"id": int64(4), "user_id": int64(2), "title": "toml"},
+			},
+		},
+		{
+			name: "create reject unknown foreign key",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Create(ctx, "fortress", "books", sqlmapper.RowData{"user_id": {Data: 9}, "title": {Data: "toml"}})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name: "update with stale version is a conflict",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Update(ctx, "fortress", "users", sqlmapper.RowData{"id": {Data: 1}, "name": {Data: "anna"}, "version": {Data: 2}})
+				if _, ok := err.(sqlmapper.ConflictError); !ok {
+					t.Errorf("Update() error = %v, want a ConflictError", err)
+				}
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name: "delete cascade to has_many rows",
+			run: func(m sqlmapper.Mapper) error {
+				return m.Delete(ctx, "fortress", "users", []interface{}{"id"}, []interface{}{1})
+			},
+			table: "books",
+			want:  []memory.Row{{"id": int64(3), "user_id": int64(2), "title": "yaml"}},
+		},
+		{
+			name: "delete rows outside row filters is forbidden",
+			run: func(m sqlmapper.Mapper) error {
+				return m.Delete(sqlmapper.WithRowFilter(ctx, regionFilter("south")), "fortress", "users", []interface{}{"id"}, []interface{}{1})
+			},
+			wantErr: true,
+		},
+		{
+			name: "delete rows inside row filters",
+			run: func(m sqlmapper.Mapper) error {
+				return m.Delete(sqlmapper.WithRowFilter(ctx, regionFilter("south")), "fortress", "users", []interface{}{"id"}, []interface{}{2})
+			},
+			table: "books",
+			want: []memory.Row{
+				{"id": int64(1), "user_id": int64(1), "title": "go"},
+				{"id": int64(2), "user_id": int64(1), "title": "sql"},
+			},
+		},
+		{
+			name: "update rows outside row filters is forbidden",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Update(sqlmapper.WithRowFilter(ctx, regionFilter("south")), "fortress", "users", sqlmapper.RowData{"id": {Data: 1}, "name": {Data: "anna"}, "version": {Data: 1}})
+				if _, ok := err.(sqlmapper.ForbiddenRowError); !ok {
+					t.Errorf("Update() error = %v, want a ForbiddenRowError", err)
+				}
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name: "update moving a row outside row filters is forbidden",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Update(sqlmapper.WithRowFilter(ctx, regionFilter("south")), "fortress", "users", sqlmapper.RowData{"id": {Data: 2}, "region": {Data: "north"}, "version": {Data: 1}})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name: "update rows inside row filters",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Update(sqlmapper.WithRowFilter(ctx, regionFilter("south")), "fortress", "users", sqlmapper.RowData{"id": {Data: 2}, "name": {Data: "bobby"}, "version": {Data: 1}})
+				return err
+			},
+			table: "users",
+			want: []memory.Row{
+				{"id": int64(1), "name": "ann", "region": "north", "email": "ann@x.com", "version": int64(1)},
+				{"id": int64(2), "name": "bobby", "region": "south", "version": int64(2)},
+				{"id": int64(3), "name": "cat", "region": "north", "version": int64(1)},
+			},
+		},
+		{
+			name: "soft-deleted rows are restored",
+			run: func(m sqlmapper.Mapper) error {
+				if err := m.Delete(ctx, "fortress", "notes", []interface{}{"id"}, []interface{}{1}); err != nil {
+					return err
+				}
+				return m.Restore(ctx, "fortress", "notes", []interface{}{"id"}, []interface{}{1})
+			},
+			table: "notes",
+			want:  []memory.Row{{"id": int64(1), "body": "hello", database.SoftDeleteColumn: nil}},
+		},
+		{
+			name: "restore reject unknown fields",
+			run: func(m sqlmapper.Mapper) error {
+				return m.Restore(ctx, "fortress", "notes", []interface{}{"id = 1 OR 1"}, []interface{}{1})
+			},
+			wantErr: true,
+		},
+		{
+			name: "upsert update conflicted row",
+			run: func(m sqlmapper.Mapper) error {
+				_, action, err := m.Upsert(ctx, "fortress", "books", sqlmapper.RowData{"id": {Data: 3}, "user_id": {Data: 2}, "title": {Data: "toml"}}, nil)
+				if action != sqlmapper.UpsertUpdated {
+					t.Errorf("Upsert() action = %v, want %v", action, sqlmapper.UpsertUpdated)
+				}
+				return err
+			},
+			table: "books",
+			want: []memory.Row{
+				{"id": int64(1), "user_id": int64(1), "title": "go"},
+				{"id": int64(2), "user_id": int64(1), "title": "sql"},
+				{"id": int64(3), "user_id": int64(2), "title": "toml"},
+			},
+		},
+		{
+			name: "failed transaction is rolled back",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Transaction(ctx, "fortress", []sqlmapper.Operation{
+					{Type: sqlmapper.OperationCreate, TableName: "books", Fields: []interface{}{"user_id", "title"}, Data: []interface{}{2, "csv"}},
+					{Type: sqlmapper.OperationCreate, TableName: "books", Fields: []interface{}{"user_id", "title"}, Data: []interface{}{9, "xml"}},
+				})
+				return err
+			},
+			table: "books",
+			want: []memory.Row{
+				{"id": int64(1), "user_id": int64(1), "title": "go"},
+				{"id": int64(2), "user_id": int64(1), "title": "sql"},
+				{"id": int64(3), "user_id": int64(2), "title": "yaml"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "create return missing and invalid fields in one error",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Create(ctx, "fortress", "users", sqlmapper.RowData{"region": {Data: "North"}})
+				want := sqlmapper.ValidationError{Fields: []sqlmapper.FieldError{
+					{Field: "name", Message: "is required"},
+					{Field: "region", Message: "must match pattern ^[a-z]+$"},
+				}}
+				if !reflect.DeepEqual(err, want) {
+					t.Errorf("Create() error = %v, want %v", err, want)
+				}
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name: "has_one update existing related row",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Update(ctx, "fortress", "users", sqlmapper.RowData{
+					"id":       {Data: 1},
+					"name":     {Data: "ann"},
+					"version":  {Data: 1},
+					"profiles": {Data: []sqlmapper.RowData{{"bio": {Data: "rustacean"}}}},
+				})
+				return err
+			},
+			table: "profiles",
+			want:  []memory.Row{{"id": int64(1), "user_id": int64(1), "bio": "rustacean"}},
+		},
+		{
+			name: "has_one replace related row of another key",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Update(ctx, "fortress", "users", sqlmapper.RowData{
+					"id":       {Data: 1},
+					"name":     {Data: "ann"},
+					"version":  {Data: 1},
+					"profiles": {Data: []sqlmapper.RowData{{"id": {Data: 7}, "bio": {Data: "rustacean"}}}},
+				})
+				return err
+			},
+			table: "profiles",
+			want:  []memory.Row{{"id": int64(2), "user_id": int64(1), "bio": "rustacean"}},
+		},
+		{
+			name: "has_one create related row of a new row",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Create(ctx, "fortress", "users", sqlmapper.RowData{
+					"name":     {Data: "dan"},
+					"profiles": {Data: []sqlmapper.RowData{{"bio": {Data: "pythonista"}}}},
+				})
+				return err
+			},
+			table: "profiles",
+			want: []memory.Row{
+				{"id": int64(1), "user_id": int64(1), "bio": "gopher"},
+				{"id": int64(2), "user_id": int64(4), "bio": "pythonista"},
+			},
+		},
+		{
+			name: "failed operation keep its index and error",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Transaction(ctx, "fortress", []sqlmapper.Operation{
+					{Type: sqlmapper.OperationCreate, TableName: "books", Fields: []interface{}{"user_id", "title"}, Data: []interface{}{2, "csv"}},
+					{Type: sqlmapper.OperationUpdate, TableName: "users", Fields: []interface{}{"id", "name", "version"}, Data: []interface{}{1, "anna", 2}},
+				})
+				opErr, ok := err.(sqlmapper.OperationError)
+				if !ok {
+					t.Fatalf("Transaction() error = %v, want an OperationError", err)
+				}
+				if _, ok := opErr.Err.(sqlmapper.ConflictError); !ok || opErr.Index != 1 || opErr.StatusCode() != http.StatusConflict {
+					t.Errorf("Transaction() error = %#v, want a conflict of operation 1", opErr)
+				}
+				return err
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, db := newTestMemoryStore(t)
+
+			err := tt.run(m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.table == "" {
+				return
+			}
+
+			got, err := db.Select("fortress", tt.table, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows of %s = %v, want %v", tt.table, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreMask(t *testing.T) {
+	masked := sqlmapper.WithMask(context.Background())
+	users := sqlmapper.Query{SourceDatabase: "fortress", SourceTable: "users", Fields: []string{"id", "email"}, Limit: 1}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		run     func(ctx context.Context, m sqlmapper.Mapper) (interface{}, error)
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "masked values are read",
+			ctx:  masked,
+			run: func(ctx context.Context, m sqlmapper.Mapper) (interface{}, error) {
+				_, data, err := m.Query(ctx, users)
+				return data, err
+			},
+			want: []interface{}{[]interface{}{int64(1), "*****.com"}},
+		},
+		{
+			name: "unmasked values are read without mask",
+			ctx:  context.Background(),
+			run: func(ctx context.Context, m sqlmapper.Mapper) (interface{}, error) {
+				_, data, err := m.Query(ctx, users)
+				return data, err
+			},
+			want: []interface{}{[]interface{}{int64(1), "ann@x.com"}},
+		},
+		{
+			name: "filter on masked column is rejected",
+			ctx:  masked,
+			run: func(ctx context.Context, m sqlmapper.Mapper) (interface{}, error) {
+				q := users
+				q.Filter = sqlmapper.Filter{Operator: "ilike", ColumnName: "email", Value: "a%"}
+				_, data, err := m.Query(ctx, q)
+				return data, err
+			},
+			wantErr: true,
+		},
+		{
+			name: "order on masked column is rejected",
+			ctx:  masked,
+			run: func(ctx context.Context, m sqlmapper.Mapper) (interface{}, error) {
+				q := users
+				q.Order = []string{"email", "asc"}
+				_, data, err := m.Query(ctx, q)
+				return data, err
+			},
+			wantErr: true,
+		},
+		{
+			name: "masked column can't be written",
+			ctx:  masked,
+			run: func(ctx context.Context, m sqlmapper.Mapper) (interface{}, error) {
+				return m.Create(ctx, "fortress", "users", sqlmapper.RowData{"name": {Data: "dan"}, "email": {Data: "dan@x.com"}})
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMemoryStore(t)
+
+			got, err := tt.run(tt.ctx, m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreCreateKeyNotGenerated(t *testing.T) {
+	ctx := context.Background()
+	modelMap := testModelMap()
+	m, db := newTestMemoryStoreOf(t, modelMap)
+
+	// models are read on every call, id of profiles isn't generated after rows are seeded
+	profiles := modelMap["fortress"]["profiles"]
+	profiles.Columns = append([]database.Column{{Name: "id", Type: "int", IsPrimary: true}}, profiles.Columns[1:]...)
+	modelMap["fortress"]["profiles"] = profiles
+
+	// an integer primary key which isn't generated by database is inserted like a uuid
+	if _, err := m.Create(ctx, "fortress", "profiles", sqlmapper.RowData{"id": {Data: 10}, "user_id": {Data: 3}, "bio": {Data: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create(ctx, "fortress", "profiles", sqlmapper.RowData{"user_id": {Data: 3}, "bio": {Data: "hey"}}); err == nil {
+		t.Error("Create() without a primary key which isn't generated should fail")
+	}
+
+	got, err := db.Select("fortress", "profiles", func(r memory.Row) bool { return memory.Equal(r["user_id"], 3) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []memory.Row{{"id": int64(10), "user_id": int64(3), "bio": "hi"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("profiles = %v, want %v", got, want)
+	}
+}
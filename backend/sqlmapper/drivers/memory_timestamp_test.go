@@ -0,0 +1,103 @@
+package drivers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// timestampModelMap return test models whose notes have managed timestamps
+func timestampModelMap() map[string]map[string]database.Model {
+	modelMap := testModelMap()
+	notes := modelMap["fortress"]["notes"]
+	notes.ManagedTimestamps = true
+	notes.Columns = append(notes.Columns,
+		database.Column{Name: database.CreatedAtColumn, Type: "timestamp"},
+		database.Column{Name: database.UpdatedAtColumn, Type: "timestamp"},
+	)
+	modelMap["fortress"]["notes"] = notes
+
+	return modelMap
+}
+
+func TestMemoryStoreManagedTimestamps(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMemoryStoreOf(t, timestampModelMap())
+
+	noteAt := func(id int) memory.Row {
+		rows, err := db.Select("fortress", "notes", func(r memory.Row) bool { return memory.Equal(r["id"], id) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("note %d = %v, want 1 row", id, rows)
+		}
+		return rows[0]
+	}
+
+	start := time.Now()
+	created, err := m.Create(ctx, "fortress", "notes", sqlmapper.RowData{"body": {Data: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createdAt, ok := created[database.CreatedAtColumn].Data.(time.Time)
+	if !ok || createdAt.Before(start) {
+		t.Fatalf("created row %s = %v, want current time", database.CreatedAtColumn, created[database.CreatedAtColumn].Data)
+	}
+	if got := created[database.UpdatedAtColumn].Data; !memory.Equal(got, createdAt) {
+		t.Errorf("created row %s = %v, want %v", database.UpdatedAtColumn, got, createdAt)
+	}
+	if got := noteAt(2)[database.CreatedAtColumn]; !memory.Equal(got, createdAt) {
+		t.Errorf("stored %s = %v, want %v", database.CreatedAtColumn, got, createdAt)
+	}
+
+	time.Sleep(time.Millisecond)
+	updated, err := m.Update(ctx, "fortress", "notes", sqlmapper.RowData{"id": {Data: 2}, "body": {Data: "hello"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	updatedAt, ok := updated[database.UpdatedAtColumn].Data.(time.Time)
+	if !ok || !updatedAt.After(createdAt) {
+		t.Errorf("updated row %s = %v, want a time after %v", database.UpdatedAtColumn, updated[database.UpdatedAtColumn].Data, createdAt)
+	}
+	if got := noteAt(2)[database.CreatedAtColumn]; !memory.Equal(got, createdAt) {
+		t.Errorf("%s = %v after update, want %v", database.CreatedAtColumn, got, createdAt)
+	}
+}
+
+func TestMemoryStoreManagedTimestampsRejectClientValues(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		run  func(m sqlmapper.Mapper) error
+	}{
+		{
+			name: "create with created_at",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Create(ctx, "fortress", "notes", sqlmapper.RowData{"body": {Data: "hi"}, database.CreatedAtColumn: {Data: time.Now()}})
+				return err
+			},
+		},
+		{
+			name: "update with updated_at",
+			run: func(m sqlmapper.Mapper) error {
+				_, err := m.Update(ctx, "fortress", "notes", sqlmapper.RowData{"id": {Data: 1}, database.UpdatedAtColumn: {Data: time.Now()}})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMemoryStoreOf(t, timestampModelMap())
+			if err := tt.run(m); err == nil {
+				t.Error("client value of a managed timestamp is accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package drivers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+)
+
+func TestMemoryStoreTransactionUpdateResult(t *testing.T) {
+	m, db := newTestMemoryStore(t)
+
+	// result of an update keep its primary key, so a later operation can reference to it
+	results, err := m.Transaction(context.Background(), "fortress", []sqlmapper.Operation{
+		{Type: sqlmapper.OperationUpdate, TableName: "users", Fields: []interface{}{"id", "name", "version"}, Data: []interface{}{2, "bobby", 1}},
+		{Type: sqlmapper.OperationCreate, TableName: "books", Fields: []interface{}{"user_id", "title"}, Data: []interface{}{map[string]interface{}{sqlmapper.RefKey: "0.id"}, "toml"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !memory.Equal(results[0]["id"].Data, 2) {
+		t.Errorf("id of update result = %v, want 2", results[0]["id"].Data)
+	}
+
+	books, err := db.Select("fortress", "books", func(r memory.Row) bool { return r["title"] == "toml" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 1 || !memory.Equal(books[0]["user_id"], 2) {
+		t.Errorf("book referencing updated row = %v, want user_id 2", books)
+	}
+}
@@ -0,0 +1,53 @@
+package drivers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+)
+
+func TestMemoryStoreUpdatePartialRow(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		row     sqlmapper.RowData
+		want    memory.Row
+		wantErr error
+	}{
+		{
+			// a restored revision send only changed columns, name isn't nullable
+			name: "columns which aren't sent keep their values",
+			row:  sqlmapper.RowData{"id": {Data: 2}, "region": {Data: "west"}, "version": {Data: 1}},
+			want: memory.Row{"id": int64(2), "name": "bob", "region": "west", "version": int64(2)},
+		},
+		{
+			name:    "non-nullable column sent as null",
+			row:     sqlmapper.RowData{"id": {Data: 2}, "name": {Data: nil}, "version": {Data: 1}},
+			want:    memory.Row{"id": int64(2), "name": "bob", "region": "south", "version": int64(1)},
+			wantErr: sqlmapper.ValidationError{Fields: []sqlmapper.FieldError{{Field: "name", Message: "is required"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, db := newTestMemoryStore(t)
+
+			_, err := m.Update(ctx, "fortress", "users", tt.row)
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Fatalf("Update() error = %v, want %v", err, tt.wantErr)
+			}
+
+			got, err := db.Select("fortress", "users", func(r memory.Row) bool { return memory.Equal(r["id"], 2) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || !reflect.DeepEqual(got[0], tt.want) {
+				t.Errorf("row = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
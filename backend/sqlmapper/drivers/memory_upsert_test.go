@@ -0,0 +1,90 @@
+package drivers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+func TestMemoryStoreUpsert(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		table      string
+		row        sqlmapper.RowData
+		conflict   []string
+		wantAction string
+		wantErr    bool
+		want       memory.Row // row of table after upsert, matched by id
+	}{
+		{
+			name:       "insert a new row at version 1",
+			table:      "users",
+			row:        sqlmapper.RowData{"name": {Data: "dan"}, "region": {Data: "west"}, "version": {Data: 7}},
+			conflict:   []string{"name"},
+			wantAction: sqlmapper.UpsertInserted,
+			want:       memory.Row{"id": int64(4), "name": "dan", "region": "west", "version": int64(1)},
+		},
+		{
+			name:       "update an existing row with its version",
+			table:      "users",
+			row:        sqlmapper.RowData{"name": {Data: "bob"}, "region": {Data: "west"}, "version": {Data: 1}},
+			conflict:   []string{"name"},
+			wantAction: sqlmapper.UpsertUpdated,
+			want:       memory.Row{"id": int64(2), "name": "bob", "region": "west", "version": int64(2)},
+		},
+		{
+			name:     "update an existing row with a stale version",
+			table:    "users",
+			row:      sqlmapper.RowData{"name": {Data: "bob"}, "region": {Data: "west"}, "version": {Data: 3}},
+			conflict: []string{"name"},
+			wantErr:  true,
+			want:     memory.Row{"id": int64(2), "name": "bob", "region": "south", "version": int64(1)},
+		},
+		{
+			name:     "update an existing row without version",
+			table:    "users",
+			row:      sqlmapper.RowData{"name": {Data: "bob"}, "region": {Data: "west"}},
+			conflict: []string{"name"},
+			wantErr:  true,
+			want:     memory.Row{"id": int64(2), "name": "bob", "region": "south", "version": int64(1)},
+		},
+		{
+			name:       "restore a soft-deleted row",
+			table:      "notes",
+			row:        sqlmapper.RowData{"id": {Data: 1}, "body": {Data: "back"}},
+			wantAction: sqlmapper.UpsertUpdated,
+			want:       memory.Row{"id": int64(1), "body": "back", database.SoftDeleteColumn: nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, db := newTestMemoryStore(t)
+			if err := m.Delete(ctx, "fortress", "notes", []interface{}{"id"}, []interface{}{1}); err != nil {
+				t.Fatal(err)
+			}
+
+			_, action, err := m.Upsert(ctx, "fortress", tt.table, tt.row, tt.conflict)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Upsert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if action != tt.wantAction {
+				t.Errorf("Upsert() action = %q, want %q", action, tt.wantAction)
+			}
+
+			got, err := db.Select("fortress", tt.table, func(r memory.Row) bool { return memory.Equal(r["id"], tt.want["id"]) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || !reflect.DeepEqual(got[0], tt.want) {
+				t.Errorf("row = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
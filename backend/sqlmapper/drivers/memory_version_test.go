@@ -0,0 +1,79 @@
+package drivers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+)
+
+func TestMemoryStoreUpdateVersion(t *testing.T) {
+	ctx := context.Background()
+	bob := memory.Row{"id": int64(2), "name": "bob", "region": "south", "version": int64(1)}
+
+	tests := []struct {
+		name        string
+		row         sqlmapper.RowData
+		wantVersion interface{}
+		want        memory.Row
+		wantErr     error
+	}{
+		{
+			name:        "update with current version bump it",
+			row:         sqlmapper.RowData{"id": {Data: 2}, "name": {Data: "bobby"}, "version": {Data: 1}},
+			wantVersion: int64(2),
+			want:        memory.Row{"id": int64(2), "name": "bobby", "region": "south", "version": int64(2)},
+		},
+		{
+			name: "update with stale version return current row",
+			row:  sqlmapper.RowData{"id": {Data: 2}, "name": {Data: "bobby"}, "version": {Data: 0}},
+			want: bob,
+			wantErr: sqlmapper.ConflictError{TableName: "users", Current: sqlmapper.RowData{
+				"id":      {Name: "id", Data: int64(2)},
+				"name":    {Name: "name", Data: "bob"},
+				"region":  {Name: "region", Data: "south"},
+				"email":   {Name: "email", Data: nil},
+				"version": {Name: "version", Data: int64(1)},
+			}},
+		},
+		{
+			name:    "update of a missing row isn't a conflict",
+			row:     sqlmapper.RowData{"id": {Data: 9}, "name": {Data: "bobby"}, "version": {Data: 1}},
+			want:    bob,
+			wantErr: errPrimaryKeyNotExist,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, db := newTestMemoryStore(t)
+
+			got, err := m.Update(ctx, "fortress", "users", tt.row)
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Fatalf("Update() error = %#v, want %#v", err, tt.wantErr)
+			}
+			if err == nil && !memory.Equal(got["version"].Data, tt.wantVersion) {
+				t.Errorf("Update() version = %v, want %v", got["version"].Data, tt.wantVersion)
+			}
+
+			rows, err := db.Select("fortress", "users", func(r memory.Row) bool { return memory.Equal(r["id"], 2) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 1 || !reflect.DeepEqual(rows[0], tt.want) {
+				t.Errorf("row = %v, want %v", rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreUpdateWithoutVersion(t *testing.T) {
+	m, _ := newTestMemoryStore(t)
+
+	_, err := m.Update(context.Background(), "fortress", "users", sqlmapper.RowData{"id": {Data: 2}, "name": {Data: "bobby"}})
+	if err == nil {
+		t.Error("Update() of a versioned table without expected version should fail")
+	}
+}
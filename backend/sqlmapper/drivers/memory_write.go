@@ -0,0 +1,1057 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/backend/sqlmapper/memory"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// inTx run fn in a transaction, its writes are rolled back when it fails
+func (s *memoryStore) inTx(fn func(tx *memory.Tx) error) error {
+	tx := s.db.Begin()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+
+	return nil
+}
+
+// toMemoryRow return values of a row without its relate-data
+func toMemoryRow(row sqlmapper.RowData) memory.Row {
+	res := make(memory.Row)
+	cols, data := row.ColumnsAndData()
+	for i, col := range cols {
+		res[col] = data[i]
+	}
+
+	return res
+}
+
+// fieldsMatch return matcher of rows whose fields equal to data
+func fieldsMatch(fields, data []interface{}) func(memory.Row) bool {
+	return func(r memory.Row) bool {
+		for i := range fields {
+			if !memory.Equal(r[fmt.Sprint(fields[i])], data[i]) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// insertRow insert a row in transaction, values of primary columns and returning columns are set back to row
+func (s *memoryStore) insertRow(tx *memory.Tx, dbName, tableName string, row sqlmapper.RowData, returning []string) error {
+	created, err := tx.Insert(dbName, tableName, toMemoryRow(row))
+	if err != nil {
+		return err
+	}
+
+	for _, col := range s.modelMap[dbName][tableName].Columns {
+		if col.IsPrimary || checkColumnFieldIsValid(returning, col.Name) == nil {
+			row[col.Name] = sqlmapper.ColData{Data: created[col.Name]}
+		}
+	}
+
+	return nil
+}
+
+// checkRowFilter reject a write of rows matching match when any of them is outside row filters of ctx
+func (s *memoryStore) checkRowFilter(ctx context.Context, db memorySelecter, dbName, tableName string, match func(memory.Row) bool) error {
+	filters, err := sqlmapper.RowFilters(ctx, dbName, tableName)
+	if err != nil || len(filters) == 0 {
+		return err
+	}
+
+	rows, err := db.Select(dbName, tableName, match)
+	if err != nil {
+		return err
+	}
+
+	allowed := filterMatch(filters)
+	for _, r := range rows {
+		if !allowed(r) {
+			return sqlmapper.ForbiddenRowError{TableName: tableName}
+		}
+	}
+
+	return nil
+}
+
+// checkDependentWrite check rows of tableName matching match, which are written by a nested write or
+// on_delete of another table, can be written: user can run action on tableName and rows are inside
+// row filters. Action isn't checked when no row matches
+func (s *memoryStore) checkDependentWrite(ctx context.Context, db memorySelecter, dbName, tableName, action string, match func(memory.Row) bool) error {
+	if err := sqlmapper.AuthorizeTable(ctx, dbName, tableName, action); err != nil {
+		rows, errSelect := db.Select(dbName, tableName, match)
+		if errSelect != nil {
+			return errSelect
+		}
+		if len(rows) > 0 {
+			return err
+		}
+	}
+
+	return s.checkRowFilter(ctx, db, dbName, tableName, match)
+}
+
+func (s *memoryStore) Create(ctx context.Context, dbName string, tableName string, row sqlmapper.RowData) (sqlmapper.RowData, error) {
+	if _, ok := s.modelMap[dbName]; !ok {
+		return nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	err := s.inTx(func(tx *memory.Tx) error {
+		return s.create(ctx, tx, dbName, tableName, row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return row, nil
+}
+
+func (s *memoryStore) create(ctx context.Context, tx *memory.Tx, dbName, tableName string, row sqlmapper.RowData) error {
+	if _, ok := s.modelMap[dbName][tableName]; !ok {
+		return fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+	s.clearGeneratedPrimaryKey(dbName, tableName, row)
+	if err := setRowFilterValues(ctx, dbName, tableName, row); err != nil {
+		return err
+	}
+
+	// rule violations are returned with missing columns found by verifyInput
+	invalid := s.invalidFields(dbName, tableName, row, "")
+
+	if err := s.setManagedTimestamps(dbName, tableName, row, database.CreatedAtColumn, database.UpdatedAtColumn); err != nil {
+		return err
+	}
+
+	if versionColumn := s.modelMap[dbName][tableName].VersionColumn; versionColumn != "" {
+		if _, ok := row[versionColumn]; !ok {
+			row[versionColumn] = sqlmapper.ColData{Data: 1}
+		}
+	}
+
+	relateRowData := row.RelateData()
+	if err := s.verifyRelationships(dbName, tableName, relateRowData); err != nil {
+		return err
+	}
+
+	// belongs_to rows are created first, they fill foreign keys of row
+	if err := s.writeBelongsTo(ctx, tx, dbName, tableName, row, relateRowData); err != nil {
+		return err
+	}
+
+	if err := withInvalidFields(verifyInput(ctx, row, dbName, tableName, s.modelMap[dbName]), invalid); err != nil {
+		return err
+	}
+	if err := checkRowValues(ctx, dbName, tableName, row); err != nil {
+		return err
+	}
+
+	if err := s.insertRow(tx, dbName, tableName, row, nil); err != nil {
+		return err
+	}
+	if err := s.recordRow(ctx, tx, dbName, tableName, sqlmapper.WriteCreate, nil, pickColumns(row, primaryColumnNames(s.modelMap[dbName][tableName]))); err != nil {
+		return err
+	}
+
+	// create relation data
+	return s.writeChildren(ctx, tx, dbName, tableName, row, relateRowData)
+}
+
+func (s *memoryStore) Update(ctx context.Context, dbName, tableName string, row sqlmapper.RowData) (sqlmapper.RowData, error) {
+	if _, ok := s.modelMap[dbName]; !ok {
+		return nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	err := s.inTx(func(tx *memory.Tx) error {
+		return s.update(ctx, tx, dbName, tableName, row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return row, nil
+}
+
+func (s *memoryStore) update(ctx context.Context, tx *memory.Tx, dbName, tableName string, row sqlmapper.RowData) error {
+	// rule violations are returned with missing columns found by verifyInput
+	invalid := s.invalidFields(dbName, tableName, row, "")
+
+	primaryKeyMap, err := s.getPrimaryKeyMap(row, dbName, tableName)
+	if err != nil {
+		return err
+	}
+	// a partial composite key would update many rows
+	for _, col := range s.modelMap[dbName][tableName].Columns {
+		if _, ok := primaryKeyMap[col.Name]; col.IsPrimary && !ok {
+			return fmt.Errorf("missing value of primary column %s", col.Name)
+		}
+	}
+	if err := s.setManagedTimestamps(dbName, tableName, row, database.UpdatedAtColumn); err != nil {
+		return err
+	}
+	if err := withInvalidFields(verifyUpdateInput(ctx, row, dbName, tableName, s.modelMap[dbName]), invalid); err != nil {
+		return err
+	}
+
+	match := rowMatch(primaryKeyMap)
+	existed, err := tx.Select(dbName, tableName, match)
+	if err != nil {
+		return err
+	}
+	if len(existed) == 0 {
+		return errPrimaryKeyNotExist
+	}
+
+	// row must be permitted before and after update
+	if err := s.checkRowFilter(ctx, tx, dbName, tableName, match); err != nil {
+		return err
+	}
+	if err := checkRowValues(ctx, dbName, tableName, row); err != nil {
+		return err
+	}
+	before, err := s.rowsToRecord(ctx, tx, dbName, tableName, match)
+	if err != nil {
+		return err
+	}
+
+	var version interface{}
+	if s.modelMap[dbName][tableName].VersionColumn != "" {
+		if version, err = s.checkAndBumpVersion(tx, dbName, tableName, row, primaryKeyMap); err != nil {
+			return err
+		}
+	}
+
+	relateRowData := row.RelateData()
+	if err := s.verifyRelationships(dbName, tableName, relateRowData); err != nil {
+		return err
+	}
+
+	// belongs_to rows are saved first, they fill foreign keys of row
+	if err := s.writeBelongsTo(ctx, tx, dbName, tableName, row, relateRowData); err != nil {
+		return err
+	}
+
+	if cols, _ := row.ColumnsAndData(); len(cols) > 0 {
+		if _, err := tx.Update(dbName, tableName, match, toMemoryRow(row)); err != nil {
+			return err
+		}
+	}
+
+	if version != nil {
+		row[s.modelMap[dbName][tableName].VersionColumn] = sqlmapper.ColData{Data: version}
+	}
+	if err := s.recordRows(ctx, tx, dbName, tableName, sqlmapper.WriteUpdate, before); err != nil {
+		return err
+	}
+
+	parent := make(sqlmapper.RowData)
+	for k, v := range row {
+		parent[k] = v
+	}
+	for k, v := range primaryKeyMap {
+		parent[k] = v
+	}
+
+	return s.writeChildren(ctx, tx, dbName, tableName, parent, relateRowData)
+}
+
+// checkAndBumpVersion increase version of a row if it still has the version sent by client,
+// otherwise a ConflictError with current row is returned. Expected version is removed from row
+func (s *memoryStore) checkAndBumpVersion(tx *memory.Tx, dbName, tableName string, row, primaryKeyMap sqlmapper.RowData) (interface{}, error) {
+	versionColumn := s.modelMap[dbName][tableName].VersionColumn
+	expected, ok := row[versionColumn]
+	if !ok {
+		return nil, fmt.Errorf("missing expected version, set column %s or If-Match header", versionColumn)
+	}
+	delete(row, versionColumn)
+
+	rows, err := tx.Select(dbName, tableName, rowMatch(primaryKeyMap))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errPrimaryKeyNotExist
+	}
+
+	if !memory.Equal(rows[0][versionColumn], expected.Data) {
+		return nil, sqlmapper.ConflictError{TableName: tableName, Current: s.storedRow(dbName, tableName, rows[0])}
+	}
+
+	version, err := strconv.ParseInt(memory.Key(rows[0][versionColumn]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("version column %s of table %s must be an integer", versionColumn, tableName)
+	}
+	version++
+
+	if _, err := tx.Update(dbName, tableName, rowMatch(primaryKeyMap), memory.Row{versionColumn: version}); err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// writeBelongsTo save parent rows of belongs_to relationships, foreign key columns of row are
+// filled with keys of saved rows. It must be called before row is written
+func (s *memoryStore) writeBelongsTo(ctx context.Context, tx *memory.Tx, dbName, tableName string, row sqlmapper.RowData, relateRowData map[string][]sqlmapper.RowData) error {
+	for relateTableName, rows := range relateRowData {
+		rel, err := s.getRelationship(dbName, tableName, relateTableName)
+		if err != nil {
+			return err
+		}
+		if rel.Type != database.RelationshipBelongsTo || len(rows) == 0 {
+			continue
+		}
+
+		cs, err := s.getForeignKeyColumns(dbName, relateTableName, tableName)
+		if err != nil {
+			return err
+		}
+
+		keys, err := s.saveRelatedRow(ctx, tx, dbName, relateTableName, rows[0], foreignColumns(cs))
+		if err != nil {
+			return err
+		}
+
+		for _, c := range cs {
+			row[c.Name] = keys[c.ForeignKey.ForeignColumn]
+		}
+	}
+
+	return nil
+}
+
+// writeChildren save related rows which reference to parent row, it must be called after parent row is written
+func (s *memoryStore) writeChildren(ctx context.Context, tx *memory.Tx, dbName, tableName string, parent sqlmapper.RowData, relateRowData map[string][]sqlmapper.RowData) error {
+	for relateTableName, rows := range relateRowData {
+		rel, err := s.getRelationship(dbName, tableName, relateTableName)
+		if err != nil {
+			return err
+		}
+
+		switch rel.Type {
+		case database.RelationshipHasMany:
+			err = s.writeHasMany(ctx, tx, dbName, tableName, parent, relateTableName, rows)
+		case database.RelationshipHasOne:
+			if len(rows) > 0 {
+				err = s.writeHasOne(ctx, tx, dbName, tableName, parent, relateTableName, rows[0])
+			}
+		case database.RelationshipManyToMany:
+			err = s.writeManyToMany(ctx, tx, dbName, tableName, parent, rel, rows)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStore) writeHasMany(ctx context.Context, tx *memory.Tx, dbName, parentTableName string, parent sqlmapper.RowData, tableName string, rows []sqlmapper.RowData) error {
+	cs, err := s.getForeignKeyColumns(dbName, parentTableName, tableName)
+	if err != nil {
+		return err
+	}
+
+	parentKeys, err := referencedKeys(parent, parentTableName, cs)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		for colName, colData := range parentKeys {
+			row[colName] = colData
+		}
+		if _, err := s.saveRelatedRow(ctx, tx, dbName, tableName, row, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeHasOne save the only related row of a has_one relationship. Without a primary key, row update
+// the existing related row of parent. Other related rows of parent are deleted, row replaces them
+func (s *memoryStore) writeHasOne(ctx context.Context, tx *memory.Tx, dbName, parentTableName string, parent sqlmapper.RowData, tableName string, row sqlmapper.RowData) error {
+	cs, err := s.getForeignKeyColumns(dbName, parentTableName, tableName)
+	if err != nil {
+		return err
+	}
+
+	parentKeys, err := referencedKeys(parent, parentTableName, cs)
+	if err != nil {
+		return err
+	}
+
+	primaryKeyMap, err := s.getPrimaryKeyMap(row, dbName, tableName)
+	if err != nil {
+		return err
+	}
+
+	match := rowMatch(parentKeys)
+	if len(primaryKeyMap) == 0 {
+		existMatch := match
+		if s.isSoftDelete(dbName, tableName) {
+			existMatch = andMatch(notDeleted, match)
+		}
+
+		existed, err := tx.Select(dbName, tableName, existMatch)
+		if err != nil {
+			return err
+		}
+		if len(existed) > 0 {
+			for _, col := range primaryColumnNames(s.modelMap[dbName][tableName]) {
+				primaryKeyMap[col] = sqlmapper.ColData{Data: existed[0][col]}
+			}
+		}
+	}
+
+	if len(primaryKeyMap) > 0 {
+		keep := rowMatch(primaryKeyMap)
+		match = andMatch(match, func(r memory.Row) bool { return !keep(r) })
+	}
+	// replaced rows are deleted as user deletes them
+	replaced := match
+	if s.isSoftDelete(dbName, tableName) {
+		replaced = andMatch(notDeleted, match)
+	}
+	if err := s.checkDependentWrite(ctx, tx, dbName, tableName, "delete", replaced); err != nil {
+		return err
+	}
+	if err := s.deleteWhere(ctx, tx, dbName, tableName, match, nil); err != nil {
+		return err
+	}
+
+	for colName, colData := range primaryKeyMap {
+		row[colName] = colData
+	}
+	for colName, colData := range parentKeys {
+		row[colName] = colData
+	}
+	_, err = s.saveRelatedRow(ctx, tx, dbName, tableName, row, nil)
+
+	return err
+}
+
+func (s *memoryStore) writeManyToMany(ctx context.Context, tx *memory.Tx, dbName, tableName string, parent sqlmapper.RowData, rel database.Relationship, rows []sqlmapper.RowData) error {
+	own, related, err := s.getJoinColumns(dbName, tableName, rel)
+	if err != nil {
+		return err
+	}
+
+	parentKeys, err := referencedKeys(parent, tableName, own)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		keys, err := s.saveRelatedRow(ctx, tx, dbName, rel.Table, row, foreignColumns(related))
+		if err != nil {
+			return err
+		}
+
+		link, err := referencedKeys(keys, rel.Table, related)
+		if err != nil {
+			return err
+		}
+		for colName, colData := range parentKeys {
+			link[colName] = colData
+		}
+
+		linked, err := tx.Select(dbName, rel.Through, rowMatch(link))
+		if err != nil {
+			return err
+		}
+		if len(linked) > 0 {
+			continue
+		}
+
+		created, err := tx.Insert(dbName, rel.Through, toMemoryRow(link))
+		if err != nil {
+			return err
+		}
+		if err := s.recordRow(ctx, tx, dbName, rel.Through, sqlmapper.WriteCreate, nil, s.primaryKeyOf(dbName, rel.Through, created)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveRelatedRow update a related row if its primary key existed, otherwise create it.
+// Values of keyColumns are returned to link the row with source row
+func (s *memoryStore) saveRelatedRow(ctx context.Context, tx *memory.Tx, dbName, tableName string, row sqlmapper.RowData, keyColumns []string) (sqlmapper.RowData, error) {
+	primaryKeyMap, err := s.getPrimaryKeyMap(row, dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var existed []memory.Row
+	if len(primaryKeyMap) > 0 {
+		if existed, err = tx.Select(dbName, tableName, rowMatch(primaryKeyMap)); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(existed) == 0 {
+		// primary key sent with a new row is kept, ex: uuid generated by client, a generated one isn't
+		for colName, colData := range primaryKeyMap {
+			row[colName] = colData
+		}
+		s.clearGeneratedPrimaryKey(dbName, tableName, row)
+		if err := sqlmapper.AuthorizeTable(ctx, dbName, tableName, "create"); err != nil {
+			return nil, err
+		}
+		if err := setRowFilterValues(ctx, dbName, tableName, row); err != nil {
+			return nil, err
+		}
+		if err := s.setManagedTimestamps(dbName, tableName, row, database.CreatedAtColumn, database.UpdatedAtColumn); err != nil {
+			return nil, err
+		}
+		if err := verifyInput(ctx, row, dbName, tableName, s.modelMap[dbName]); err != nil {
+			return nil, err
+		}
+		if err := checkRowValues(ctx, dbName, tableName, row); err != nil {
+			return nil, err
+		}
+		if err := s.insertRow(tx, dbName, tableName, row, keyColumns); err != nil {
+			return nil, err
+		}
+		if err := s.recordRow(ctx, tx, dbName, tableName, sqlmapper.WriteCreate, nil, pickColumns(row, primaryColumnNames(s.modelMap[dbName][tableName]))); err != nil {
+			return nil, err
+		}
+
+		return pickColumns(row, keyColumns), nil
+	}
+
+	// a linked row must be inside row filters too
+	if err := s.checkRowFilter(ctx, tx, dbName, tableName, rowMatch(primaryKeyMap)); err != nil {
+		return nil, err
+	}
+
+	// existing row is only linked when there is no column to update
+	if cols, _ := row.ColumnsAndData(); len(cols) > 0 {
+		if err := sqlmapper.AuthorizeTable(ctx, dbName, tableName, "update"); err != nil {
+			return nil, err
+		}
+		if err := s.setManagedTimestamps(dbName, tableName, row, database.UpdatedAtColumn); err != nil {
+			return nil, err
+		}
+		if err := verifyUpdateInput(ctx, row, dbName, tableName, s.modelMap[dbName]); err != nil {
+			return nil, err
+		}
+		if err := checkRowValues(ctx, dbName, tableName, row); err != nil {
+			return nil, err
+		}
+		before, err := s.rowsToRecord(ctx, tx, dbName, tableName, rowMatch(primaryKeyMap))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Update(dbName, tableName, rowMatch(primaryKeyMap), toMemoryRow(row)); err != nil {
+			return nil, err
+		}
+		if err := s.recordRows(ctx, tx, dbName, tableName, sqlmapper.WriteUpdate, before); err != nil {
+			return nil, err
+		}
+	}
+
+	for colName, colData := range primaryKeyMap {
+		row[colName] = colData
+	}
+
+	for _, col := range keyColumns {
+		if _, ok := row[col]; !ok {
+			row[col] = sqlmapper.ColData{Data: existed[0][col]}
+		}
+	}
+
+	return pickColumns(row, keyColumns), nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, dbName string, tableName string, fields, data []interface{}) error {
+	if _, ok := s.modelMap[dbName]; !ok {
+		return fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	// dependent rows are handled by on_delete of relationships in the same transaction
+	return s.inTx(func(tx *memory.Tx) error {
+		return s.delete(ctx, tx, dbName, tableName, fields, data)
+	})
+}
+
+func (s *memoryStore) delete(ctx context.Context, tx *memory.Tx, dbName string, tableName string, fields, data []interface{}) error {
+	match, err := s.deleteMatch(dbName, tableName, fields, data)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkRowFilter(ctx, tx, dbName, tableName, match); err != nil {
+		return err
+	}
+
+	return s.deleteWhere(ctx, tx, dbName, tableName, match, nil)
+}
+
+// deleteMatch make matcher of deleted rows from filter fields and data
+func (s *memoryStore) deleteMatch(dbName, tableName string, fields, data []interface{}) (func(memory.Row) bool, error) {
+	d, ok := s.modelMap[dbName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	if !tableExisted(tableName, d) {
+		return nil, fmt.Errorf("Table not exists")
+	}
+
+	if len(fields) != len(data) {
+		return nil, errors.New("Fields and data isn't match")
+	}
+
+	if len(fields) == 0 {
+		return nil, errors.New("missing filter of deleted rows")
+	}
+
+	colNames := database.Columns(d[tableName].Columns).Stored().Names()
+	for i := range fields {
+		if err := checkColumnFieldIsValid(colNames, fmt.Sprint(fields[i])); err != nil {
+			return nil, err
+		}
+	}
+
+	return fieldsMatch(fields, data), nil
+}
+
+// dependentMatch return matcher of rows of dependent referencing to rows
+func dependentMatch(d dependent, rows []memory.Row) func(memory.Row) bool {
+	keys := make(map[string]bool)
+	for _, r := range rows {
+		if key, ok := tupleKey(r, foreignColumns(d.columns)); ok {
+			keys[key] = true
+		}
+	}
+
+	cols := d.columnNames()
+	return func(r memory.Row) bool {
+		key, ok := tupleKey(r, cols)
+		return ok && keys[key]
+	}
+}
+
+// tupleKey return key of values of columns of a row, false is returned when a value is null
+func tupleKey(r memory.Row, cols []string) (string, bool) {
+	keys := []string{}
+	for _, col := range cols {
+		if r[col] == nil {
+			return "", false
+		}
+		keys = append(keys, memory.Key(r[col]))
+	}
+
+	return strings.Join(keys, "\x00"), true
+}
+
+// deleteWhere apply on_delete of dependent tables, then delete rows of tableName matching match.
+// path hold tables being deleted by cascade to stop a cycle
+func (s *memoryStore) deleteWhere(ctx context.Context, tx *memory.Tx, dbName, tableName string, match func(memory.Row) bool, path []string) error {
+	if checkColumnFieldIsValid(path, tableName) == nil {
+		return fmt.Errorf("cyclic cascade delete: %s -> %s", strings.Join(path, " -> "), tableName)
+	}
+	path = append(path, tableName)
+
+	softDelete := s.isSoftDelete(dbName, tableName)
+	if softDelete {
+		if err := s.checkSoftDeleteColumn(dbName, tableName); err != nil {
+			return err
+		}
+		match = andMatch(notDeleted, match)
+	}
+
+	deps, err := s.dependents(dbName, tableName)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Select(dbName, tableName, match)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deps {
+		depMatch := dependentMatch(d, rows)
+		// soft-deleted rows of dependent don't reference to deleted rows anymore
+		live := depMatch
+		if s.isSoftDelete(dbName, d.table) {
+			live = andMatch(notDeleted, depMatch)
+		}
+
+		switch d.onDelete {
+		case database.OnDeleteRestrict:
+			referencing, err := tx.Select(dbName, d.table, live)
+			if err != nil {
+				return err
+			}
+			if len(referencing) > 0 {
+				return fmt.Errorf("can't delete rows of %s, %d rows of %s reference to them", tableName, len(referencing), d.table)
+			}
+		case database.OnDeleteCascade:
+			// dependent rows are deleted or updated as user writes them
+			if err := s.checkDependentWrite(ctx, tx, dbName, d.table, "delete", live); err != nil {
+				return err
+			}
+			if err := s.deleteWhere(ctx, tx, dbName, d.table, depMatch, path); err != nil {
+				return err
+			}
+		case database.OnDeleteSetNull:
+			if err := s.checkDependentWrite(ctx, tx, dbName, d.table, "update", depMatch); err != nil {
+				return err
+			}
+			nulled, err := s.rowsToRecord(ctx, tx, dbName, d.table, depMatch)
+			if err != nil {
+				return err
+			}
+			values := make(memory.Row)
+			for _, col := range d.columnNames() {
+				values[col] = nil
+			}
+			if _, err := tx.Update(dbName, d.table, depMatch, values); err != nil {
+				return err
+			}
+			if err := s.recordRows(ctx, tx, dbName, d.table, sqlmapper.WriteUpdate, nulled); err != nil {
+				return err
+			}
+		}
+	}
+
+	if softDelete {
+		_, err = tx.Update(dbName, tableName, match, memory.Row{database.SoftDeleteColumn: time.Now()})
+	} else {
+		_, err = tx.Delete(dbName, tableName, match)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.recordRows(ctx, tx, dbName, tableName, sqlmapper.WriteDelete, rows)
+}
+
+// DeletePreview count rows would be deleted and rows of dependent tables, without changing data
+func (s *memoryStore) DeletePreview(ctx context.Context, dbName, tableName string, fields, data []interface{}) (sqlmapper.DeleteImpact, error) {
+	match, err := s.deleteMatch(dbName, tableName, fields, data)
+	if err != nil {
+		return sqlmapper.DeleteImpact{}, err
+	}
+
+	if err := s.checkRowFilter(ctx, s.db, dbName, tableName, match); err != nil {
+		return sqlmapper.DeleteImpact{}, err
+	}
+
+	return s.deleteImpact(s.db, dbName, dependent{table: tableName}, match, nil)
+}
+
+func (s *memoryStore) deleteImpact(db memorySelecter, dbName string, d dependent, match func(memory.Row) bool, path []string) (sqlmapper.DeleteImpact, error) {
+	res := sqlmapper.DeleteImpact{
+		Table:      d.table,
+		OnDelete:   d.onDelete,
+		Dependents: []sqlmapper.DeleteImpact{},
+	}
+	if len(d.columns) > 0 {
+		res.Columns = d.columnNames()
+	}
+
+	if s.isSoftDelete(dbName, d.table) {
+		match = andMatch(notDeleted, match)
+	}
+
+	rows, err := db.Select(dbName, d.table, match)
+	if err != nil {
+		return res, err
+	}
+	res.Count = len(rows)
+
+	// rows of a restrict or set_null dependent are kept, so their dependents aren't affected
+	if len(path) > 0 && d.onDelete != database.OnDeleteCascade {
+		return res, nil
+	}
+	if checkColumnFieldIsValid(path, d.table) == nil {
+		return res, fmt.Errorf("cyclic cascade delete: %s -> %s", strings.Join(path, " -> "), d.table)
+	}
+	path = append(path, d.table)
+
+	deps, err := s.dependents(dbName, d.table)
+	if err != nil {
+		return res, err
+	}
+
+	for _, dep := range deps {
+		impact, err := s.deleteImpact(db, dbName, dep, dependentMatch(dep, rows), path)
+		if err != nil {
+			return res, err
+		}
+		res.Dependents = append(res.Dependents, impact)
+	}
+
+	return res, nil
+}
+
+// Restore clear deleted_at of soft-deleted rows matching fields and data
+func (s *memoryStore) Restore(ctx context.Context, dbName, tableName string, fields, data []interface{}) error {
+	if _, ok := s.modelMap[dbName][tableName]; !ok {
+		return fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	if !s.isSoftDelete(dbName, tableName) {
+		return fmt.Errorf("table %s is not soft_delete", tableName)
+	}
+
+	if err := s.checkSoftDeleteColumn(dbName, tableName); err != nil {
+		return err
+	}
+
+	if len(fields) != len(data) {
+		return errors.New("Fields and data isn't match")
+	}
+
+	if len(fields) == 0 {
+		return errors.New("missing filter of restored rows")
+	}
+
+	match, err := s.deleteMatch(dbName, tableName, fields, data)
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(func(tx *memory.Tx) error {
+		if err := s.checkRowFilter(ctx, tx, dbName, tableName, match); err != nil {
+			return err
+		}
+
+		deleted := func(r memory.Row) bool { return !notDeleted(r) && match(r) }
+		before, err := s.rowsToRecord(ctx, tx, dbName, tableName, deleted)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Update(dbName, tableName, deleted, memory.Row{database.SoftDeleteColumn: nil}); err != nil {
+			return err
+		}
+
+		return s.recordRows(ctx, tx, dbName, tableName, sqlmapper.WriteRestore, before)
+	})
+}
+
+func (s *memoryStore) Upsert(ctx context.Context, dbName, tableName string, row sqlmapper.RowData, conflictColumns []string) (sqlmapper.RowData, string, error) {
+	return s.upsert(ctx, dbName, tableName, row, conflictColumns, operationHooks{})
+}
+
+// upsert update the row having values of conflict columns of row, or create row when it isn't existed.
+// Hooks of the path taken are run in the same transaction
+func (s *memoryStore) upsert(ctx context.Context, dbName, tableName string, row sqlmapper.RowData, conflictColumns []string, hooks operationHooks) (sqlmapper.RowData, string, error) {
+	if _, ok := s.modelMap[dbName]; !ok {
+		return nil, "", fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	key, err := s.conflictKey(dbName, tableName, row, conflictColumns)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var action string
+	err = s.inTx(func(tx *memory.Tx) error {
+		ctx := sqlmapper.WithTx(ctx, dbName, tx)
+
+		existed, err := tx.Select(dbName, tableName, rowMatch(key))
+		if err != nil {
+			return err
+		}
+
+		var primaryKeyMap sqlmapper.RowData
+		deleted := false
+		if len(existed) > 0 {
+			primaryKeyMap = make(sqlmapper.RowData)
+			for _, col := range primaryColumnNames(s.modelMap[dbName][tableName]) {
+				primaryKeyMap[col] = sqlmapper.ColData{Data: existed[0][col]}
+			}
+			deleted = s.isSoftDelete(dbName, tableName) && !notDeleted(existed[0])
+		}
+
+		row, action, err = upsertRow(ctx, s.modelMap[dbName][tableName], row, primaryKeyMap, deleted, hooks, operationWriter{
+			create: func(row sqlmapper.RowData) error { return s.create(ctx, tx, dbName, tableName, row) },
+			update: func(row sqlmapper.RowData) error { return s.update(ctx, tx, dbName, tableName, row) },
+		})
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return row, action, nil
+}
+
+// primaryColumnNames return names of primary columns of m
+func primaryColumnNames(m database.Model) []string {
+	res := []string{}
+	for _, col := range m.Columns {
+		if col.IsPrimary {
+			res = append(res, col.Name)
+		}
+	}
+
+	return res
+}
+
+func (s *memoryStore) Transaction(ctx context.Context, dbName string, ops []sqlmapper.Operation) ([]sqlmapper.RowData, error) {
+	return s.transaction(ctx, dbName, ops, operationHooks{})
+}
+
+func (s *memoryStore) transaction(ctx context.Context, dbName string, ops []sqlmapper.Operation, hooks operationHooks) ([]sqlmapper.RowData, error) {
+	if _, ok := s.modelMap[dbName]; !ok {
+		return nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	results := []sqlmapper.RowData{}
+	err := s.inTx(func(tx *memory.Tx) error {
+		ctx := sqlmapper.WithTx(ctx, dbName, tx)
+		for i, op := range ops {
+			res, err := s.runOperation(ctx, tx, dbName, op, results, hooks)
+			if err != nil {
+				return sqlmapper.OperationError{Index: i, Type: op.Type, TableName: op.TableName, Err: err}
+			}
+			results = append(results, res)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (s *memoryStore) runOperation(ctx context.Context, tx *memory.Tx, dbName string, op sqlmapper.Operation, results []sqlmapper.RowData, hooks operationHooks) (sqlmapper.RowData, error) {
+	data, err := sqlmapper.ResolveRefs(op.Data, results)
+	if err != nil {
+		return nil, err
+	}
+
+	var row sqlmapper.RowData
+	if op.Type != sqlmapper.OperationDelete {
+		row, err = sqlmapper.MakeRowData(op.Fields, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if hooks.before != nil {
+		if row, err = hooks.before(ctx, op, row); err != nil {
+			return nil, err
+		}
+	}
+
+	switch op.Type {
+	case sqlmapper.OperationCreate:
+		err = s.create(ctx, tx, dbName, op.TableName, row)
+	case sqlmapper.OperationUpdate:
+		// primary columns are kept in result, so a later operation can reference to them
+		keys := pickColumns(row, primaryColumnNames(s.modelMap[dbName][op.TableName]))
+		err = s.update(ctx, tx, dbName, op.TableName, row)
+		for colName, colData := range keys {
+			row[colName] = colData
+		}
+	case sqlmapper.OperationDelete:
+		err = s.delete(ctx, tx, dbName, op.TableName, op.Fields, data)
+	default:
+		err = fmt.Errorf("unknown operation type %q", op.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if hooks.after != nil {
+		return hooks.after(ctx, op, row)
+	}
+
+	return row, nil
+}
+
+func (s *memoryStore) BulkCreate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string) (sqlmapper.BulkResults, error) {
+	return s.bulkCreate(ctx, dbName, tableName, rows, mode, rowHooks{})
+}
+
+func (s *memoryStore) bulkCreate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string, hooks rowHooks) (sqlmapper.BulkResults, error) {
+	return s.bulk(ctx, dbName, len(rows), mode, hooks, func(ctx context.Context, tx *memory.Tx, i int) (sqlmapper.RowData, error) {
+		return rows[i], s.create(ctx, tx, dbName, tableName, rows[i])
+	})
+}
+
+func (s *memoryStore) BulkUpdate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string) (sqlmapper.BulkResults, error) {
+	return s.bulkUpdate(ctx, dbName, tableName, rows, mode, rowHooks{})
+}
+
+func (s *memoryStore) bulkUpdate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string, hooks rowHooks) (sqlmapper.BulkResults, error) {
+	return s.bulk(ctx, dbName, len(rows), mode, hooks, func(ctx context.Context, tx *memory.Tx, i int) (sqlmapper.RowData, error) {
+		return rows[i], s.update(ctx, tx, dbName, tableName, rows[i])
+	})
+}
+
+func (s *memoryStore) BulkDelete(ctx context.Context, dbName, tableName string, fields []interface{}, rows [][]interface{}, mode string) (sqlmapper.BulkResults, error) {
+	return s.bulkDelete(ctx, dbName, tableName, fields, rows, mode, rowHooks{})
+}
+
+func (s *memoryStore) bulkDelete(ctx context.Context, dbName, tableName string, fields []interface{}, rows [][]interface{}, mode string, hooks rowHooks) (sqlmapper.BulkResults, error) {
+	return s.bulk(ctx, dbName, len(rows), mode, hooks, func(ctx context.Context, tx *memory.Tx, i int) (sqlmapper.RowData, error) {
+		return nil, s.delete(ctx, tx, dbName, tableName, fields, rows[i])
+	})
+}
+
+// bulk run fn for n rows with their hooks in a single transaction, db_* calls of hooks run in it too
+func (s *memoryStore) bulk(ctx context.Context, dbName string, n int, mode string, hooks rowHooks, fn func(ctx context.Context, tx *memory.Tx, i int) (sqlmapper.RowData, error)) (sqlmapper.BulkResults, error) {
+	mode, err := sqlmapper.VerifyBulkMode(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := s.modelMap[dbName]; !ok {
+		return nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	tx := s.db.Begin()
+	ctx = sqlmapper.WithTx(ctx, dbName, tx)
+	res := sqlmapper.MakeBulkResults(n)
+	for i := 0; i < n; i++ {
+		// a savepoint undo writes of a failed row
+		if mode == sqlmapper.BulkBestEffort {
+			tx.Savepoint()
+		}
+
+		data, err := hooks.run(ctx, i, func() (sqlmapper.RowData, error) { return fn(ctx, tx, i) })
+		if err != nil {
+			res.Fail(i, mode, err)
+			if mode == sqlmapper.BulkAllOrNothing {
+				tx.Rollback()
+				return res, nil
+			}
+
+			tx.RollbackSavepoint()
+			continue
+		}
+
+		res[i].Status = sqlmapper.BulkStatusSuccess
+		res[i].Data = data
+
+		if mode == sqlmapper.BulkBestEffort {
+			tx.ReleaseSavepoint()
+		}
+	}
+	tx.Commit()
+
+	return res, nil
+}
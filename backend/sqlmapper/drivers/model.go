@@ -0,0 +1,11 @@
+package drivers
+
+import (
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// modelStore hold models of databases, it implements rules of models shared by stores,
+// ex: validations, relationships, managed timestamps and soft delete
+type modelStore struct {
+	modelMap map[string]map[string]database.Model
+}
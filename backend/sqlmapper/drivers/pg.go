@@ -1,29 +1,37 @@
 package drivers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/jinzhu/gorm"
-	"github.com/volatiletech/sqlboiler/strmangle"
 
+	backendConfig "github.com/dwarvesf/smithy/backend/config"
 	"github.com/dwarvesf/smithy/backend/sqlmapper"
 	"github.com/dwarvesf/smithy/common/database"
 )
 
+// errPrimaryKeyNotExist is returned when a row written by its primary key doesn't exist
+var errPrimaryKeyNotExist = errors.New("primary key is not exist")
+
 type pgStore struct {
-	db       map[string]*gorm.DB
-	modelMap map[string]map[string]database.Model
+	modelStore
+	db       func() map[string]*gorm.DB
+	replicas func() map[string]*backendConfig.Replicas
 }
 
-// NewPGStore .
-func NewPGStore(db map[string]*gorm.DB, modelMap map[string]map[string]database.Model) sqlmapper.Mapper {
+// NewPGStore . Reads of query, raw query and explain go to replicas of a database if it has any.
+// Connections are got from db and replicas on every call, so reopened connections are used
+func NewPGStore(db func() map[string]*gorm.DB, replicas func() map[string]*backendConfig.Replicas, modelMap map[string]map[string]database.Model) sqlmapper.Mapper {
 	return &pgStore{
-		db:       db,
-		modelMap: modelMap,
+		modelStore: modelStore{modelMap: modelMap},
+		db:         db,
+		replicas:   replicas,
 	}
 }
 
@@ -34,7 +42,9 @@ func (s *pgStore) addFilter(q sqlmapper.Query, db *gorm.DB) (*gorm.DB, error) {
 
 	switch q.Filter.Operator {
 	case "=":
-		return db.Where(q.Filter.ColumnName+" = ?", q.Filter.Value), nil
+		return db.Where(s.columnExpr(q.SourceDatabase, q.SourceTable, q.Filter.ColumnName)+" = ?", q.Filter.Value), nil
+	case "ilike":
+		return db.Where("CAST("+s.columnExpr(q.SourceDatabase, q.SourceTable, q.Filter.ColumnName)+" AS TEXT) ILIKE ?", q.Filter.Value), nil
 	default:
 		return db, fmt.Errorf("unknown filter operator %s", q.Filter.Operator)
 	}
@@ -57,43 +67,180 @@ func (s *pgStore) addOrder(q sqlmapper.Query, db *gorm.DB) (*gorm.DB, error) {
 	if len(q.Order) != 2 {
 		return db, fmt.Errorf("error require 2 elements: column name and 'asc' if ascending order, 'desc' if descending order")
 	}
-	return db.Order(q.OrderSequence()), nil
+	return db.Order(s.columnExpr(q.SourceDatabase, q.SourceTable, q.Order[0]) + " " + q.Order[1]), nil
 }
 
-func (s *pgStore) Query(q sqlmapper.Query) ([]string, []interface{}, error) {
-	db := s.db[q.SourceDatabase].Table(q.SourceTable).
-		Select(q.ColumnNames())
+// columnExpr return SQL expression reading a column of table, a computed column is read by its expression
+func (s *pgStore) columnExpr(dbName, tableName, colName string) string {
+	for _, col := range s.modelMap[dbName][tableName].Columns {
+		if col.Name == colName {
+			return col.Expr()
+		}
+	}
+
+	return colName
+}
 
-	db = s.addLimitOffset(q, db)
-	db, err := s.addFilter(q, db)
+func (s *pgStore) Query(ctx context.Context, q sqlmapper.Query) ([]string, []interface{}, error) {
+	data := []interface{}{}
+	err := s.queryRows(ctx, q, func(rows []interface{}) error {
+		data = append(data, rows...)
+		return nil
+	})
 	if err != nil {
 		return nil, nil, err
 	}
 
-	db, err = s.addOrder(q, db)
+	if len(data) == 0 {
+		return q.Columns(), nil, nil
+	}
+
+	return q.Columns(), data, nil
+}
+
+// includeBatchSize number of rows loading included tables together while streaming
+const includeBatchSize = 500
+
+// queryRows run a query and call fn with batches of rows, included tables are loaded for each batch
+func (s *pgStore) queryRows(ctx context.Context, q sqlmapper.Query, fn func(rows []interface{}) error) error {
+	if err := sqlmapper.CheckQuery(ctx, q); err != nil {
+		return err
+	}
+	if err := sqlmapper.CheckMaskedQuery(ctx, s.modelMap[q.SourceDatabase][q.SourceTable].Columns, q); err != nil {
+		return err
+	}
+
+	includes, err := s.makeIncludePlans(ctx, q)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
 
-	rows, err := db.Rows()
+	// rows are masked after included rows are matched, so keys of includes aren't masked
+	if masks := sqlmapper.MaskColumns(ctx, s.modelMap[q.SourceDatabase][q.SourceTable].Columns, q.Fields); masks != nil {
+		next := fn
+		fn = func(rows []interface{}) error {
+			for _, row := range rows {
+				sqlmapper.MaskRow(masks, row.([]interface{}))
+			}
+			return next(rows)
+		}
+	}
+
+	labels, err := s.makeLabelPlans(ctx, q)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
 
-	defer func() {
+	fields := fieldsWithLabelKeys(fieldsWithIncludeKeys(q.Fields, includes), labels)
+	rows, reader, err := s.queryReader(ctx, q.SourceDatabase, func(db *gorm.DB) (*sql.Rows, error) {
+		db, err := sqlmapper.WithContext(ctx, db)
 		if err != nil {
-			return
+			return nil, err
 		}
-		rows.Close()
-	}()
 
-	data, err := sqlmapper.SQLRowsToRows(rows)
+		db = db.Table(q.SourceTable).
+			Select(strings.Join(database.Columns(s.modelMap[q.SourceDatabase][q.SourceTable].Columns).SelectExprs(fields), ", "))
+
+		if !q.IncludeDeleted && s.isSoftDelete(q.SourceDatabase, q.SourceTable) {
+			db = db.Where(database.SoftDeleteColumn + " IS NULL")
+		}
+
+		db = s.addLimitOffset(q, db)
+		db, err = s.addFilter(q, db)
+		if err != nil {
+			return nil, err
+		}
+
+		db, err = s.addOrder(q, db)
+		if err != nil {
+			return nil, err
+		}
+
+		db, err = s.addRowFilters(ctx, q, db)
+		if err != nil {
+			return nil, err
+		}
 
-	return q.Columns(), data, err
+		return db.Rows()
+	})
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if len(includes) == 0 && len(labels) == 0 {
+		return sqlmapper.ScanRows(rows, func(row []interface{}) error {
+			return fn([]interface{}{row})
+		})
+	}
+
+	flush := func(batch []interface{}) error {
+		// labels are loaded first, keys of labels are removed by includeRows
+		labelValues, err := s.labelRows(ctx, reader, q.SourceDatabase, fields, labels, batch)
+		if err != nil {
+			return err
+		}
+
+		data, err := s.includeRows(ctx, reader, fields, len(q.Fields), includes, batch)
+		if err != nil {
+			return err
+		}
+
+		for i := range data {
+			data[i] = append(data[i].([]interface{}), labelValues[i]...)
+		}
+		return fn(data)
+	}
+
+	batch := []interface{}{}
+	err = sqlmapper.ScanRows(rows, func(row []interface{}) error {
+		batch = append(batch, row)
+		if len(batch) < includeBatchSize {
+			return nil
+		}
+
+		err := flush(batch)
+		batch = []interface{}{}
+		return err
+	})
+	if err != nil || len(batch) == 0 {
+		return err
+	}
+
+	// rows are read already, so fn stopping the stream at the last batch isn't an error like in ScanRows
+	if err := flush(batch); err != sqlmapper.ErrStopStream {
+		return err
+	}
+
+	return nil
 }
 
-func (s *pgStore) RawQuery(dbName string, sql string) ([]string, []database.Column, []interface{}, error) {
-	rows, err := s.db[dbName].Raw(sql).Rows()
+func (s *pgStore) StreamQuery(ctx context.Context, q sqlmapper.Query, w sqlmapper.RowWriter) error {
+	cols, err := s.ColumnMetadata(q)
+	if err != nil {
+		return err
+	}
+	for i, c := range sqlmapper.MaskColumns(ctx, cols, q.Fields) {
+		cols[i].ReadOnly = cols[i].ReadOnly || c != nil
+	}
+
+	if err := w.WriteHeader(q.ResultColumns(q.Columns()), cols); err != nil {
+		return err
+	}
+
+	// ErrStopStream of w is handled by queryRows
+	return s.queryRows(ctx, q, func(rows []interface{}) error {
+		for _, row := range rows {
+			if err := w.WriteRow(row.([]interface{})); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *pgStore) RawQuery(ctx context.Context, dbName string, sql string) ([]string, []database.Column, []interface{}, error) {
+	rows, err := s.queryRaw(ctx, dbName, sql)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -116,11 +263,72 @@ func (s *pgStore) RawQuery(dbName string, sql string) ([]string, []database.Colu
 	}
 
 	data, err := sqlmapper.SQLRowsToRows(rows)
+	if masks := sqlmapper.MaskColumns(ctx, s.maskedColumns(dbName), cols); masks != nil {
+		for _, row := range data {
+			sqlmapper.MaskRow(masks, row.([]interface{}))
+		}
+	}
 
 	return cols, colMeta, data, err
 }
 
-func (s *pgStore) ColumnMetadata(q sqlmapper.Query) ([]database.Column, error) {
+func (s *pgStore) StreamRawQuery(ctx context.Context, dbName string, sql string, w sqlmapper.RowWriter) error {
+	rows, err := s.queryRaw(ctx, dbName, sql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	colMeta, err := s.ColumnMetadataByRows(rows)
+	if err != nil {
+		return err
+	}
+
+	if err := w.WriteHeader(cols, colMeta); err != nil {
+		return err
+	}
+
+	masks := sqlmapper.MaskColumns(ctx, s.maskedColumns(dbName), cols)
+	return sqlmapper.ScanRows(rows, func(row []interface{}) error {
+		sqlmapper.MaskRow(masks, row)
+		return w.WriteRow(row)
+	})
+}
+
+// maskedColumns return masked columns of all tables of dbName, columns of a raw query are masked
+// by name since their tables aren't known
+func (s *modelStore) maskedColumns(dbName string) []database.Column {
+	res := []database.Column{}
+	for _, m := range s.modelMap[dbName] {
+		for _, col := range m.Columns {
+			if col.Mask != "" {
+				res = append(res, col)
+			}
+		}
+	}
+
+	return res
+}
+
+// queryRaw run a raw sql with reader of dbName
+func (s *pgStore) queryRaw(ctx context.Context, dbName string, query string) (*sql.Rows, error) {
+	if _, ok := s.db()[dbName]; !ok {
+		return nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	rows, _, err := s.queryReader(ctx, dbName, func(db *gorm.DB) (*sql.Rows, error) {
+		return db.DB().QueryContext(ctx, query)
+	})
+
+	return rows, err
+}
+
+func (s *modelStore) ColumnMetadata(q sqlmapper.Query) ([]database.Column, error) {
 	m, ok := s.modelMap[q.SourceDatabase][q.SourceTable]
 	if !ok {
 		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", q.SourceDatabase, q.SourceTable)
@@ -159,7 +367,7 @@ func (s *pgStore) ColumnMetadataByRows(rows *sql.Rows) ([]database.Column, error
 	return colMeta, nil
 }
 
-func (s *pgStore) getRelationshipType(dbName string, tableName string, relateTableName string) (string, error) {
+func (s *modelStore) getRelationshipType(dbName string, tableName string, relateTableName string) (string, error) {
 	model, ok := s.modelMap[dbName][tableName]
 	if !ok {
 		return "", fmt.Errorf("uknown table_name %s", tableName)
@@ -177,7 +385,7 @@ func (s *pgStore) getRelationshipType(dbName string, tableName string, relateTab
 	return relationshipType, nil
 }
 
-func (s *pgStore) getForeignKeyColumn(dbName string, tableName string, relateTableName string) (*database.Column, error) {
+func (s *modelStore) getForeignKeyColumn(dbName string, tableName string, relateTableName string) (*database.Column, error) {
 	m, ok := s.modelMap[dbName][relateTableName]
 	if !ok {
 		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, relateTableName)
@@ -199,141 +407,134 @@ func (s *pgStore) getForeignKeyColumn(dbName string, tableName string, relateTab
 	return c, nil
 }
 
-func (s *pgStore) Create(dbName string, tableName string, row sqlmapper.RowData) (sqlmapper.RowData, error) {
-	d, ok := s.modelMap[dbName]
-	if !ok {
+func (s *pgStore) Create(ctx context.Context, dbName string, tableName string, row sqlmapper.RowData) (sqlmapper.RowData, error) {
+	if _, ok := s.modelMap[dbName]; !ok {
 		return nil, fmt.Errorf("uknown database_name %s", dbName)
 	}
-	// clear primary columns
-	if _, err := s.getPrimaryKeyMap(row, dbName, tableName); err != nil {
+
+	sqlmapper.MarkWritten(ctx, dbName)
+	tx, err := s.db()[dbName].DB().BeginTx(ctx, nil)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := verifyInput(row, tableName, d); err != nil {
+	if err = s.create(ctx, tx, dbName, tableName, row); err != nil {
+		if errRollBack := tx.Rollback(); errRollBack != nil {
+			return nil, errRollBack
+		}
 		return nil, err
 	}
 
-	tx, err := s.db[dbName].DB().Begin()
-	if err != nil {
+	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
 
-	cols, data := row.ColumnsAndData()
-
-	phs := strmangle.Placeholders(true, len(cols), 1, 1)
-	sqlQuery := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING id;",
-		tableName,
-		strings.Join(cols, ","),
-		phs)
+	return row, nil
+}
 
-	stmt, err := tx.Prepare(sqlQuery)
-	if err != nil {
-		return nil, err
+func (s *pgStore) create(ctx context.Context, tx *sql.Tx, dbName, tableName string, row sqlmapper.RowData) error {
+	m, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
 	}
-	defer stmt.Close()
-
-	// update id if create success
-	var id int
-	err = stmt.QueryRow(data...).Scan(&id)
-	if err != nil {
-		err = tx.Rollback()
-		return nil, err
+	s.clearGeneratedPrimaryKey(dbName, tableName, row)
+	if err := setRowFilterValues(ctx, dbName, tableName, row); err != nil {
+		return err
 	}
 
-	row["id"] = sqlmapper.ColData{Data: id}
-	// create relation data
-	relateRowData := row.RelateData()
-	for relateTableName, rows := range relateRowData {
-		relationship, err := s.getRelationshipType(dbName, tableName, relateTableName)
-		if err != nil {
-			return nil, err
-		}
+	// rule violations are returned with missing columns found by verifyInput
+	invalid := s.invalidFields(dbName, tableName, row, "")
 
-		switch relationship {
-		case "has_many":
-			err = s.createWithHasMany(tx, dbName, tableName, id, relateTableName, rows)
-			if err != nil {
-				err = tx.Rollback()
-				return nil, err
-			}
-		default:
-		}
+	if err := s.setManagedTimestamps(dbName, tableName, row, database.CreatedAtColumn, database.UpdatedAtColumn); err != nil {
+		return err
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return nil, err
+	if versionColumn := s.modelMap[dbName][tableName].VersionColumn; versionColumn != "" {
+		if _, ok := row[versionColumn]; !ok {
+			row[versionColumn] = sqlmapper.ColData{Data: 1}
+		}
 	}
 
-	return row, nil
-}
-
-func (s *pgStore) createWithHasMany(tx *sql.Tx, dbName string, parentTableName string, parentID int, tableName string, datas []sqlmapper.RowData) error {
-	for _, row := range datas {
-		// find relate column
-		c, err := s.getForeignKeyColumn(dbName, parentTableName, tableName)
-		if c == nil {
-			return err
-		}
+	relateRowData := row.RelateData()
+	if err := s.verifyRelationships(dbName, tableName, relateRowData); err != nil {
+		return err
+	}
 
-		cols, data := row.ColumnsAndData()
-		cols = append(cols, c.Name)
-		data = append(data, parentID)
+	// belongs_to rows are created first, they fill foreign keys of row
+	if err := s.writeBelongsTo(ctx, tx, dbName, tableName, row, relateRowData); err != nil {
+		return err
+	}
 
-		phs := strmangle.Placeholders(true, len(cols), 1, 1)
-		sqlQuery := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING id;",
-			tableName,
-			strings.Join(cols, ","),
-			phs)
+	if err := withInvalidFields(verifyInput(ctx, row, dbName, tableName, s.modelMap[dbName]), invalid); err != nil {
+		return err
+	}
+	if err := checkRowValues(ctx, dbName, tableName, row); err != nil {
+		return err
+	}
 
-		stmt, err := tx.Prepare(sqlQuery)
-		if err != nil {
-			return err
+	// primary columns sent by client are inserted (ex: uuid, slug), generated ones are returned
+	returning := []string{}
+	for _, col := range m.Columns {
+		if col.IsPrimary {
+			returning = append(returning, col.Name)
 		}
-		defer stmt.Close()
+	}
 
-		// update id if create success
-		var id int
-		err = stmt.QueryRow(data...).Scan(&id)
-		if err != nil {
-			return err
+	if err := insertRow(ctx, tx, tableName, row, returning); err != nil {
+		return err
+	}
+	if col := s.generatedPrimaryColumn(dbName, tableName); col != "" {
+		if id, ok := row[col].Data.(int64); ok {
+			row[col] = sqlmapper.ColData{Data: int(id)}
 		}
-
-		row["id"] = sqlmapper.ColData{Data: id}
+	}
+	if err := s.recordRow(ctx, tx, dbName, tableName, sqlmapper.WriteCreate, nil, pickColumns(row, returning)); err != nil {
+		return err
 	}
 
-	return nil
+	// create relation data
+	return s.writeChildren(ctx, tx, dbName, tableName, row, relateRowData)
 }
 
-func (s *pgStore) Delete(dbName string, tableName string, fields, data []interface{}) error {
-	d, ok := s.modelMap[dbName]
-	if !ok {
+// sqlQueryer is implemented by both *sql.DB and *sql.Tx
+type sqlQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *pgStore) Delete(ctx context.Context, dbName string, tableName string, fields, data []interface{}) error {
+	if _, ok := s.db()[dbName]; !ok {
 		return fmt.Errorf("uknown database_name %s", dbName)
 	}
 
-	if !tableExisted(tableName, d) {
-		return fmt.Errorf("Table not exists")
+	sqlmapper.MarkWritten(ctx, dbName)
+	// dependent rows are handled by on_delete of relationships in the same transaction
+	tx, err := s.db()[dbName].DB().BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
 
-	execPostfix := fmt.Sprintf("DELETE FROM %s WHERE", tableName)
-
-	if len(fields) != len(data) {
-		return errors.New("Fields and data isn't match")
+	if err = s.delete(ctx, tx, dbName, tableName, fields, data); err != nil {
+		if errRollBack := tx.Rollback(); errRollBack != nil {
+			return errRollBack
+		}
+		return err
 	}
 
-	param := []string{}
+	return tx.Commit()
+}
 
-	numberOfParam := len(fields)
-	for i := 0; i < numberOfParam; i++ {
-		param = append(param, fmt.Sprintf("%v='%v'", fields[i], data[i]))
+func (s *pgStore) delete(ctx context.Context, tx *sql.Tx, dbName string, tableName string, fields, data []interface{}) error {
+	where, args, err := s.deleteCondition(dbName, tableName, fields, data)
+	if err != nil {
+		return err
 	}
 
-	exec := fmt.Sprintf("%s %s", execPostfix, strings.Join(param, " AND "))
-
-	if _, err := s.db[dbName].DB().Exec(exec); err != nil {
-		return fmt.Errorf("%v", err)
+	if err := s.checkRowFilter(ctx, tx, dbName, tableName, where, args); err != nil {
+		return err
 	}
-	return nil
+
+	return s.deleteWhere(ctx, tx, dbName, tableName, where, args, nil)
 }
 
 func tableExisted(tableName string, modalList map[string]database.Model) bool {
@@ -345,7 +546,17 @@ func tableExisted(tableName string, modalList map[string]database.Model) bool {
 	return false
 }
 
-func verifyInput(d sqlmapper.RowData, tableName string, modelList map[string]database.Model) error {
+func verifyInput(ctx context.Context, d sqlmapper.RowData, dbName, tableName string, modelList map[string]database.Model) error {
+	return verifyColumns(ctx, d, dbName, tableName, modelList, false)
+}
+
+// verifyUpdateInput check columns of an updated row like verifyInput, but only sent columns
+// are checked, a missing column keeps its value
+func verifyUpdateInput(ctx context.Context, d sqlmapper.RowData, dbName, tableName string, modelList map[string]database.Model) error {
+	return verifyColumns(ctx, d, dbName, tableName, modelList, true)
+}
+
+func verifyColumns(ctx context.Context, d sqlmapper.RowData, dbName, tableName string, modelList map[string]database.Model, partial bool) error {
 	// name data_type nullable primary_key
 	if len(d) <= 0 {
 		return errors.New("rowData is empty")
@@ -359,17 +570,20 @@ func verifyInput(d sqlmapper.RowData, tableName string, modelList map[string]dat
 
 			cols, _ := d.ColumnsAndData()
 
-			// create field valid
+			// create field valid, all missing fields are returned in a ValidationError
+			missing := []sqlmapper.FieldError{}
 			for _, column := range table.Columns {
 				// file id database will auto generate, so bypass check id
-				if column.IsPrimary {
+				if column.IsPrimary || column.IsComputed() {
 					continue
 				}
 
-				if !column.IsNullable {
-					if err := checkColumnFieldIsValid(cols, column.Name); err != nil {
-						return err
-					}
+				if column.IsNullable {
+					continue
+				}
+				colData, ok := d[column.Name]
+				if (!ok && !partial) || (ok && colData.Data == nil) {
+					missing = append(missing, sqlmapper.FieldError{Field: column.Name, Message: "is required"})
 				}
 			}
 			//field invalid
@@ -378,6 +592,18 @@ func verifyInput(d sqlmapper.RowData, tableName string, modelList map[string]dat
 				if err := checkColumnFieldIsValid(agentColumns, name); err != nil {
 					return err
 				}
+				if err := checkColumnIsStored(table, name); err != nil {
+					return err
+				}
+				if err := checkColumnIsUnmasked(ctx, table, name); err != nil {
+					return err
+				}
+				if err := checkColumnIsWritable(ctx, dbName, table, name); err != nil {
+					return err
+				}
+			}
+			if len(missing) > 0 {
+				return sqlmapper.ValidationError{Fields: missing}
 			}
 
 			break
@@ -390,6 +616,44 @@ func verifyInput(d sqlmapper.RowData, tableName string, modelList map[string]dat
 	return nil
 }
 
+// withInvalidFields merge fields violating validation rules into err of verifyInput,
+// so missing and invalid fields of a row are returned in one ValidationError
+func withInvalidFields(err error, fields []sqlmapper.FieldError) error {
+	if err == nil {
+		if len(fields) > 0 {
+			return sqlmapper.ValidationError{Fields: fields}
+		}
+		return nil
+	}
+
+	verr, ok := err.(sqlmapper.ValidationError)
+	if !ok {
+		return err
+	}
+
+	return sqlmapper.ValidationError{Fields: append(append([]sqlmapper.FieldError{}, verr.Fields...), fields...)}
+}
+
+// invalidFields check validation rules of columns for a row and its relate-data
+func (s *modelStore) invalidFields(dbName, tableName string, row sqlmapper.RowData, prefix string) []sqlmapper.FieldError {
+	res := sqlmapper.ValidateRow(row, s.modelMap[dbName][tableName].Columns, prefix)
+
+	relateRowData := row.RelateData()
+	relateTableNames := []string{}
+	for relateTableName := range relateRowData {
+		relateTableNames = append(relateTableNames, relateTableName)
+	}
+	sort.Strings(relateTableNames)
+
+	for _, relateTableName := range relateTableNames {
+		for i, r := range relateRowData[relateTableName] {
+			res = append(res, s.invalidFields(dbName, relateTableName, r, fmt.Sprintf("%s%s[%d].", prefix, relateTableName, i))...)
+		}
+	}
+
+	return res
+}
+
 func checkColumnFieldIsValid(inputColumns []string, colName string) error {
 	err := true
 	for _, name := range inputColumns {
@@ -405,99 +669,194 @@ func checkColumnFieldIsValid(inputColumns []string, colName string) error {
 	return nil
 }
 
-func (s *pgStore) Update(dbName, tableName string, row sqlmapper.RowData) (sqlmapper.RowData, error) {
-	d, ok := s.modelMap[dbName]
-	if !ok {
+// checkColumnIsUnmasked reject a value of a masked column when user sees it masked
+func checkColumnIsUnmasked(ctx context.Context, m database.Model, colName string) error {
+	if sqlmapper.MaskColumns(ctx, m.Columns, []string{colName}) != nil {
+		return fmt.Errorf("column %s of table %s is masked, it can't be set", colName, m.TableName)
+	}
+
+	return nil
+}
+
+// checkColumnIsWritable reject a value of a column user has no write grant on,
+// primary keys identify the row so they aren't checked
+func checkColumnIsWritable(ctx context.Context, dbName string, m database.Model, colName string) error {
+	for _, col := range m.Columns {
+		if col.Name == colName && col.IsPrimary {
+			return nil
+		}
+	}
+
+	if !sqlmapper.CanWrite(ctx, dbName, m.TableName, colName) {
+		return sqlmapper.ForbiddenColumnError{TableName: m.TableName, Column: colName, Action: "written"}
+	}
+
+	return nil
+}
+
+// checkColumnIsStored reject a value of a computed column, it is read-only
+func checkColumnIsStored(m database.Model, colName string) error {
+	for _, col := range m.Columns {
+		if col.Name == colName && col.IsComputed() {
+			return fmt.Errorf("column %s of table %s is computed, it can't be set", colName, m.TableName)
+		}
+	}
+
+	return nil
+}
+
+func (s *pgStore) Update(ctx context.Context, dbName, tableName string, row sqlmapper.RowData) (sqlmapper.RowData, error) {
+	if _, ok := s.modelMap[dbName]; !ok {
 		return nil, fmt.Errorf("uknown database_name %s", dbName)
 	}
 
-	primaryKeyMap, err := s.getPrimaryKeyMap(row, dbName, tableName)
+	sqlmapper.MarkWritten(ctx, dbName)
+	tx, err := s.db()[dbName].DB().BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	if err := verifyInput(row, tableName, d); err != nil {
+
+	if err = s.update(ctx, tx, dbName, tableName, row); err != nil {
+		if errRollBack := tx.Rollback(); errRollBack != nil {
+			return nil, errRollBack
+		}
 		return nil, err
 	}
-	if exist, _ := s.isPrimaryKeyExist(dbName, tableName, primaryKeyMap); !exist {
-		return nil, errors.New("primary key is not exist")
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
 	}
-	tx, err := s.db[dbName].DB().Begin()
+	return row, nil
+}
+
+func (s *pgStore) update(ctx context.Context, tx *sql.Tx, dbName, tableName string, row sqlmapper.RowData) error {
+	// rule violations are returned with missing columns found by verifyInput
+	invalid := s.invalidFields(dbName, tableName, row, "")
 
+	primaryKeyMap, err := s.getPrimaryKeyMap(row, dbName, tableName)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if err = s.handleUpdate(tx, row, primaryKeyMap, dbName, tableName); err != nil {
-		return nil, err
+	// a partial composite key would update many rows
+	for _, col := range s.modelMap[dbName][tableName].Columns {
+		if _, ok := primaryKeyMap[col.Name]; col.IsPrimary && !ok {
+			return fmt.Errorf("missing value of primary column %s", col.Name)
+		}
 	}
-	relationalRowData := row.RelateData()
-	for relateTableName, rows := range relationalRowData {
-		relationship, err := s.getRelationshipType(dbName, tableName, relateTableName)
-		if err != nil {
-			return nil, err
+	if err := s.setManagedTimestamps(dbName, tableName, row, database.UpdatedAtColumn); err != nil {
+		return err
+	}
+	if err := withInvalidFields(verifyUpdateInput(ctx, row, dbName, tableName, s.modelMap[dbName]), invalid); err != nil {
+		return err
+	}
+	exist, err := s.isPrimaryKeyExist(ctx, tx, tableName, primaryKeyMap)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return errPrimaryKeyNotExist
+	}
+
+	// row must be permitted before and after update
+	where, args := primaryKeyCondition(primaryKeyMap)
+	if err := s.checkRowFilter(ctx, tx, dbName, tableName, where, args); err != nil {
+		return err
+	}
+	if err := checkRowValues(ctx, dbName, tableName, row); err != nil {
+		return err
+	}
+	before, err := s.rowsToRecord(ctx, tx, dbName, tableName, where, args)
+	if err != nil {
+		return err
+	}
+
+	var version interface{}
+	if s.modelMap[dbName][tableName].VersionColumn != "" {
+		// version is bumped first, so the row is locked until transaction end
+		if version, err = s.checkAndBumpVersion(ctx, tx, dbName, tableName, row, primaryKeyMap); err != nil {
+			return err
 		}
+	}
 
-		switch relationship {
-		case "has_many":
-			err = s.updateWithHasMany(tx, dbName, relateTableName, rows, d)
-			if err != nil {
-				if errRoolBack := tx.Rollback(); errRoolBack != nil {
-					return nil, errRoolBack
-				}
-				return nil, err
-			}
-		default:
+	relateRowData := row.RelateData()
+	if err := s.verifyRelationships(dbName, tableName, relateRowData); err != nil {
+		return err
+	}
+
+	// belongs_to rows are saved first, they fill foreign keys of row
+	if err := s.writeBelongsTo(ctx, tx, dbName, tableName, row, relateRowData); err != nil {
+		return err
+	}
+
+	if cols, _ := row.ColumnsAndData(); len(cols) > 0 {
+		if err := s.handleUpdate(ctx, tx, row, primaryKeyMap, dbName, tableName); err != nil {
+			return err
 		}
 	}
 
-	if err = tx.Commit(); err != nil {
-		return nil, err
+	if version != nil {
+		row[s.modelMap[dbName][tableName].VersionColumn] = sqlmapper.ColData{Data: version}
+	}
+	if err := s.recordRows(ctx, tx, dbName, tableName, sqlmapper.WriteUpdate, before); err != nil {
+		return err
 	}
-	return row, nil
-}
-func (s *pgStore) isPrimaryKeyExist(dbName, tableName string, primaryKeyMap sqlmapper.RowData) (bool, error) {
-	data := struct {
-		Result bool
-	}{}
 
-	params := []string{}
-	for colName, colData := range primaryKeyMap {
-		params = append(params, fmt.Sprintf("%s = '%v'", colName, colData.Data))
+	parent := make(sqlmapper.RowData)
+	for k, v := range row {
+		parent[k] = v
+	}
+	for k, v := range primaryKeyMap {
+		parent[k] = v
 	}
-	execQuery := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s) as result", tableName, strings.Join(params, " AND "))
 
-	return data.Result, s.db[dbName].Raw(execQuery).Scan(&data).Error
+	return s.writeChildren(ctx, tx, dbName, tableName, parent, relateRowData)
 }
 
-func (s *pgStore) isIDNotExist(tableName, colName string, id interface{}) (bool, error) {
+// isPrimaryKeyExist check a row of primaryKeyMap existed, it is run in tx to see rows written before in tx
+func (s *pgStore) isPrimaryKeyExist(ctx context.Context, tx *sql.Tx, tableName string, primaryKeyMap sqlmapper.RowData) (bool, error) {
+	where, args := primaryKeyCondition(primaryKeyMap)
+
+	var exist bool
+	err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s)", tableName, where), args...).Scan(&exist)
+
+	return exist, err
+}
+
+func (s *pgStore) isIDNotExist(ctx context.Context, dbName, tableName, colName string, id interface{}) (bool, error) {
 	data := struct {
 		Result bool
 	}{}
 
-	execQuery := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s = %v) as result", tableName, colName, id)
+	execQuery := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s = ?) as result", tableName, colName)
+
+	db, err := sqlmapper.WithContext(ctx, s.db()[dbName])
+	if err != nil {
+		return false, err
+	}
 
-	return data.Result, s.db[tableName].Raw(execQuery).Scan(&data).Error
+	return data.Result, db.Raw(execQuery, id).Scan(&data).Error
 }
 
-func (s *pgStore) handleUpdate(tx *sql.Tx, row, primaryKeyMap sqlmapper.RowData, dbName, tableName string) error {
+func (s *pgStore) handleUpdate(ctx context.Context, tx *sql.Tx, row, primaryKeyMap sqlmapper.RowData, dbName, tableName string) error {
 	cols, data := row.ColumnsAndData()
 	foreignColumns, err := s.getRelationalColumns(dbName, tableName)
 	if err != nil {
 		return err
 	}
 	if foreignColumns != nil {
-		if err := s.isForeignKeyExist(cols, data, foreignColumns); err != nil {
+		if err := s.isForeignKeyExist(ctx, dbName, cols, data, foreignColumns); err != nil {
 			return err
 		}
 	}
 
-	if err := s.execUpdateSQL(tx, primaryKeyMap, data, cols, tableName); err != nil {
+	if err := s.execUpdateSQL(ctx, tx, primaryKeyMap, data, cols, tableName); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (s *pgStore) isPrimaryKey(dbName, colName, tableName string) bool {
+func (s *modelStore) isPrimaryKey(dbName, colName, tableName string) bool {
 	columns := s.modelMap[dbName][tableName].Columns
 	for _, col := range columns {
 		if col.IsPrimary && col.Name == colName {
@@ -507,7 +866,27 @@ func (s *pgStore) isPrimaryKey(dbName, colName, tableName string) bool {
 	return false
 }
 
-func (s *pgStore) getRelationalColumns(dbName, tableName string) ([]database.Column, error) {
+// generatedPrimaryColumn return primary column whose value is generated by database, ex: serial id,
+// it is marked by agent from schema of table. Empty is returned when primary key of table isn't generated
+func (s *modelStore) generatedPrimaryColumn(dbName, tableName string) string {
+	for _, col := range s.modelMap[dbName][tableName].Columns {
+		if col.IsPrimary && col.IsGenerated {
+			return col.Name
+		}
+	}
+
+	return ""
+}
+
+// clearGeneratedPrimaryKey remove value of a generated primary column sent by client, so it doesn't
+// collide with values generated later
+func (s *modelStore) clearGeneratedPrimaryKey(dbName, tableName string, row sqlmapper.RowData) {
+	if col := s.generatedPrimaryColumn(dbName, tableName); col != "" {
+		delete(row, col)
+	}
+}
+
+func (s *modelStore) getRelationalColumns(dbName, tableName string) ([]database.Column, error) {
 	m, ok := s.modelMap[dbName][tableName]
 	if !ok {
 		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
@@ -522,7 +901,7 @@ func (s *pgStore) getRelationalColumns(dbName, tableName string) ([]database.Col
 	return c, nil
 }
 
-func (s *pgStore) getPrimaryKeyMap(row sqlmapper.RowData, dbName, tableName string) (sqlmapper.RowData, error) {
+func (s *modelStore) getPrimaryKeyMap(row sqlmapper.RowData, dbName, tableName string) (sqlmapper.RowData, error) {
 	if _, ok := s.modelMap[dbName][tableName]; !ok {
 		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
 	}
@@ -536,11 +915,11 @@ func (s *pgStore) getPrimaryKeyMap(row sqlmapper.RowData, dbName, tableName stri
 	return primaryKeyMap, nil
 }
 
-func (s *pgStore) isForeignKeyExist(cols []string, data []interface{}, foreignColumns []database.Column) error {
+func (s *pgStore) isForeignKeyExist(ctx context.Context, dbName string, cols []string, data []interface{}, foreignColumns []database.Column) error {
 	for index, colName := range cols {
 		for _, foreignColumn := range foreignColumns {
 			if colName == foreignColumn.Name {
-				if exist, err := s.isIDNotExist(foreignColumn.ForeignKey.Table, foreignColumn.ForeignKey.ForeignColumn, data[index]); !exist {
+				if exist, err := s.isIDNotExist(ctx, dbName, foreignColumn.ForeignKey.Table, foreignColumn.ForeignKey.ForeignColumn, data[index]); !exist {
 					return err
 				}
 			}
@@ -549,15 +928,17 @@ func (s *pgStore) isForeignKeyExist(cols []string, data []interface{}, foreignCo
 	return nil
 }
 
-func (s *pgStore) execUpdateSQL(tx *sql.Tx, primaryKeyMap sqlmapper.RowData, data []interface{}, cols []string, tableName string) error {
+func (s *pgStore) execUpdateSQL(ctx context.Context, tx *sql.Tx, primaryKeyMap sqlmapper.RowData, data []interface{}, cols []string, tableName string) error {
 	rowQuery := make([]string, len(cols))
 	for i := 0; i < len(cols); i++ {
 		rowQuery[i] = fmt.Sprintf("%s = $%d", cols[i], i+1)
 	}
 
 	params := []string{}
+	args := append([]interface{}{}, data...)
 	for colName, colData := range primaryKeyMap {
-		params = append(params, fmt.Sprintf("%s = '%v'", colName, colData.Data))
+		args = append(args, colData.Data)
+		params = append(params, fmt.Sprintf("%s = $%d", colName, len(args)))
 	}
 
 	execQuery := fmt.Sprintf("UPDATE %s SET %s WHERE %s",
@@ -565,12 +946,12 @@ func (s *pgStore) execUpdateSQL(tx *sql.Tx, primaryKeyMap sqlmapper.RowData, dat
 		strings.Join(rowQuery, ","),
 		strings.Join(params, " AND "))
 
-	stmt, err := tx.Prepare(execQuery)
+	stmt, err := tx.PrepareContext(ctx, execQuery)
 	if err != nil {
 		return err
 	}
 
-	if _, err := stmt.Exec(data...); err != nil {
+	if _, err := stmt.ExecContext(ctx, args...); err != nil {
 		return err
 	}
 	defer stmt.Close()
@@ -578,33 +959,70 @@ func (s *pgStore) execUpdateSQL(tx *sql.Tx, primaryKeyMap sqlmapper.RowData, dat
 	return nil
 }
 
-func (s *pgStore) updateWithHasMany(tx *sql.Tx, dbName, tableName string, rows []sqlmapper.RowData, d map[string]database.Model) error {
-	for _, row := range rows {
-		primaryKeyMap, err := s.getPrimaryKeyMap(row, dbName, tableName)
-		if err != nil {
-			return err
-		}
-		if err := verifyInput(row, tableName, d); err != nil {
-			return err
-		}
-		if exist, _ := s.isPrimaryKeyExist(dbName, tableName, primaryKeyMap); !exist {
-			if _, err := s.Create(dbName, tableName, row); err != nil {
-				return err
-			}
-		}
-		if err := s.handleUpdate(tx, row, primaryKeyMap, dbName, tableName); err != nil {
-			return err
+// FindRows find rows whose columns equal to values of where, all stored columns of model are returned.
+// Soft-deleted rows are included
+func (s *pgStore) FindRows(ctx context.Context, dbName, tableName string, where sqlmapper.RowData) ([]sqlmapper.RowData, error) {
+	m, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+	if len(where) == 0 {
+		return nil, errors.New("missing condition to find rows")
+	}
+
+	cols := database.Columns(m.Columns).Stored().Names()
+	whereCols := where.Columns()
+	sort.Strings(whereCols)
+
+	params := []string{}
+	data := []interface{}{}
+	for _, colName := range whereCols {
+		if err := checkColumnFieldIsValid(cols, colName); err != nil {
+			return nil, err
 		}
+
+		data = append(data, where[colName].Data)
+		params = append(params, fmt.Sprintf("%s = $%d", colName, len(data)))
 	}
-	return nil
+
+	cond, filterArgs, err := s.rowFilterCondition(ctx, dbName, tableName, len(data))
+	if err != nil {
+		return nil, err
+	}
+	if cond != "" {
+		params = append(params, cond)
+		data = append(data, filterArgs...)
+	}
+
+	rows, err := s.db()[dbName].DB().QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+		strings.Join(cols, ", "),
+		tableName,
+		strings.Join(params, " AND ")), data...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []sqlmapper.RowData{}
+	err = sqlmapper.ScanRows(rows, func(row []interface{}) error {
+		r := make(sqlmapper.RowData)
+		for i, col := range cols {
+			r[col] = sqlmapper.ColData{Name: col, Data: normalizeScanned(row[i])}
+		}
+		res = append(res, r)
+		return nil
+	})
+
+	return res, err
 }
 
-func (s *pgStore) Explain(dbName string, sql string) (interface{}, error) {
+func (s *pgStore) Explain(ctx context.Context, dbName string, sql string) (interface{}, error) {
 	exec := fmt.Sprintf("EXPLAIN (FORMAT JSON) %s", sql)
-	rows, err := s.db[dbName].DB().Query(exec)
+	rows, err := s.queryRaw(ctx, dbName, exec)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	var queryPlan string
 	for rows.Next() {
@@ -0,0 +1,125 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// rowHooks are run around the write of each row of a bulk operation inside its transaction, a failed
+// hook fail its row like a failed write, so rows are reported committed only when their hooks passed
+type rowHooks struct {
+	before func(ctx context.Context, i int) error
+	after  func(ctx context.Context, i int, data sqlmapper.RowData) (sqlmapper.RowData, error)
+}
+
+// run write row i between its hooks
+func (h rowHooks) run(ctx context.Context, i int, write func() (sqlmapper.RowData, error)) (sqlmapper.RowData, error) {
+	if h.before != nil {
+		if err := h.before(ctx, i); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := write()
+	if err != nil || h.after == nil {
+		return data, err
+	}
+
+	return h.after(ctx, i, data)
+}
+
+// bulkWriter is implemented by stores can run bulk operations with hooks in their transaction
+type bulkWriter interface {
+	bulkCreate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string, hooks rowHooks) (sqlmapper.BulkResults, error)
+	bulkUpdate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string, hooks rowHooks) (sqlmapper.BulkResults, error)
+	bulkDelete(ctx context.Context, dbName, tableName string, fields []interface{}, rows [][]interface{}, mode string, hooks rowHooks) (sqlmapper.BulkResults, error)
+}
+
+func (s *pgStore) BulkCreate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string) (sqlmapper.BulkResults, error) {
+	return s.bulkCreate(ctx, dbName, tableName, rows, mode, rowHooks{})
+}
+
+func (s *pgStore) bulkCreate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string, hooks rowHooks) (sqlmapper.BulkResults, error) {
+	return s.bulk(ctx, dbName, len(rows), mode, hooks, func(ctx context.Context, tx *sql.Tx, i int) (sqlmapper.RowData, error) {
+		return rows[i], s.create(ctx, tx, dbName, tableName, rows[i])
+	})
+}
+
+func (s *pgStore) BulkUpdate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string) (sqlmapper.BulkResults, error) {
+	return s.bulkUpdate(ctx, dbName, tableName, rows, mode, rowHooks{})
+}
+
+func (s *pgStore) bulkUpdate(ctx context.Context, dbName, tableName string, rows []sqlmapper.RowData, mode string, hooks rowHooks) (sqlmapper.BulkResults, error) {
+	return s.bulk(ctx, dbName, len(rows), mode, hooks, func(ctx context.Context, tx *sql.Tx, i int) (sqlmapper.RowData, error) {
+		return rows[i], s.update(ctx, tx, dbName, tableName, rows[i])
+	})
+}
+
+func (s *pgStore) BulkDelete(ctx context.Context, dbName, tableName string, fields []interface{}, rows [][]interface{}, mode string) (sqlmapper.BulkResults, error) {
+	return s.bulkDelete(ctx, dbName, tableName, fields, rows, mode, rowHooks{})
+}
+
+func (s *pgStore) bulkDelete(ctx context.Context, dbName, tableName string, fields []interface{}, rows [][]interface{}, mode string, hooks rowHooks) (sqlmapper.BulkResults, error) {
+	return s.bulk(ctx, dbName, len(rows), mode, hooks, func(ctx context.Context, tx *sql.Tx, i int) (sqlmapper.RowData, error) {
+		return nil, s.delete(ctx, tx, dbName, tableName, fields, rows[i])
+	})
+}
+
+// bulk run fn for n rows with their hooks in a single transaction, db_* calls of hooks run in it too
+func (s *pgStore) bulk(ctx context.Context, dbName string, n int, mode string, hooks rowHooks, fn func(ctx context.Context, tx *sql.Tx, i int) (sqlmapper.RowData, error)) (sqlmapper.BulkResults, error) {
+	mode, err := sqlmapper.VerifyBulkMode(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := s.db()[dbName]; !ok {
+		return nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	sqlmapper.MarkWritten(ctx, dbName)
+	tx, err := s.db()[dbName].DB().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = sqlmapper.WithTx(ctx, dbName, tx)
+	res := sqlmapper.MakeBulkResults(n)
+	for i := 0; i < n; i++ {
+		// a savepoint keep the transaction usable after a failed row
+		if mode == sqlmapper.BulkBestEffort {
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT bulk_row"); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+
+		data, err := hooks.run(ctx, i, func() (sqlmapper.RowData, error) { return fn(ctx, tx, i) })
+		if err != nil {
+			res.Fail(i, mode, err)
+			if mode == sqlmapper.BulkAllOrNothing {
+				return res, tx.Rollback()
+			}
+
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT bulk_row"); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			continue
+		}
+
+		res[i].Status = sqlmapper.BulkStatusSuccess
+		res[i].Data = data
+
+		if mode == sqlmapper.BulkBestEffort {
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT bulk_row"); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+	}
+
+	return res, tx.Commit()
+}
@@ -0,0 +1,264 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// dependent is a table having columns referencing to a deleted table
+type dependent struct {
+	table    string
+	columns  []database.Column
+	onDelete string
+}
+
+// condition return condition of dependent rows referencing to rows of tableName matching where
+func (d dependent) condition(tableName, where string) string {
+	cols, foreignCols := []string{}, []string{}
+	for _, c := range d.columns {
+		cols = append(cols, c.Name)
+		foreignCols = append(foreignCols, c.ForeignKey.ForeignColumn)
+	}
+
+	return fmt.Sprintf("(%s) IN (SELECT %s FROM %s WHERE %s)",
+		strings.Join(cols, ", "),
+		strings.Join(foreignCols, ", "),
+		tableName,
+		where)
+}
+
+func (d dependent) columnNames() []string {
+	return database.Columns(d.columns).Names()
+}
+
+// dependents return tables referencing to tableName, on_delete is taken from relationships
+// of tableName, other foreign keys are left to database
+func (s *modelStore) dependents(dbName, tableName string) ([]dependent, error) {
+	m, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	onDelete := make(map[string]string)
+	for _, rel := range m.Relationship {
+		switch rel.Type {
+		case database.RelationshipHasMany, database.RelationshipHasOne:
+			onDelete[rel.Table] = rel.OnDelete
+		case database.RelationshipManyToMany:
+			onDelete[rel.Through] = rel.OnDelete
+		}
+	}
+
+	res := []dependent{}
+	for name, model := range s.modelMap[dbName] {
+		cols := []database.Column{}
+		for _, col := range model.Columns {
+			if col.ForeignKey.Table == tableName && col.ForeignKey.ForeignColumn != "" {
+				cols = append(cols, col)
+			}
+		}
+		if len(cols) == 0 {
+			continue
+		}
+
+		switch onDelete[name] {
+		case "", database.OnDeleteRestrict, database.OnDeleteCascade, database.OnDeleteSetNull:
+		default:
+			return nil, fmt.Errorf("unknown on_delete %q of relationship between %s and %s", onDelete[name], tableName, name)
+		}
+
+		res = append(res, dependent{table: name, columns: cols, onDelete: onDelete[name]})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].table < res[j].table })
+
+	return res, nil
+}
+
+// deleteCondition make condition of deleted rows from filter fields and data
+func (s *pgStore) deleteCondition(dbName, tableName string, fields, data []interface{}) (string, []interface{}, error) {
+	d, ok := s.modelMap[dbName]
+	if !ok {
+		return "", nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	if !tableExisted(tableName, d) {
+		return "", nil, fmt.Errorf("Table not exists")
+	}
+
+	if len(fields) != len(data) {
+		return "", nil, errors.New("Fields and data isn't match")
+	}
+
+	if len(fields) == 0 {
+		return "", nil, errors.New("missing filter of deleted rows")
+	}
+
+	colNames := database.Columns(d[tableName].Columns).Stored().Names()
+	params := []string{}
+	for i := range fields {
+		if err := checkColumnFieldIsValid(colNames, fmt.Sprint(fields[i])); err != nil {
+			return "", nil, err
+		}
+		params = append(params, fmt.Sprintf("%v = $%d", fields[i], i+1))
+	}
+
+	return strings.Join(params, " AND "), data, nil
+}
+
+// deleteWhere apply on_delete of dependent tables, then delete rows of tableName matching where.
+// path hold tables being deleted by cascade to stop a cycle
+func (s *pgStore) deleteWhere(ctx context.Context, tx *sql.Tx, dbName, tableName, where string, args []interface{}, path []string) error {
+	if checkColumnFieldIsValid(path, tableName) == nil {
+		return fmt.Errorf("cyclic cascade delete: %s -> %s", strings.Join(path, " -> "), tableName)
+	}
+	path = append(path, tableName)
+
+	softDelete := s.isSoftDelete(dbName, tableName)
+	if softDelete {
+		if err := s.checkSoftDeleteColumn(dbName, tableName); err != nil {
+			return err
+		}
+		where = fmt.Sprintf("%s IS NULL AND %s", database.SoftDeleteColumn, where)
+	}
+
+	deps, err := s.dependents(dbName, tableName)
+	if err != nil {
+		return err
+	}
+
+	before, err := s.rowsToRecord(ctx, tx, dbName, tableName, where, args)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deps {
+		cond := d.condition(tableName, where)
+		// soft-deleted rows of dependent don't reference to deleted rows anymore
+		live := cond
+		if s.isSoftDelete(dbName, d.table) {
+			live = fmt.Sprintf("%s IS NULL AND %s", database.SoftDeleteColumn, cond)
+		}
+
+		switch d.onDelete {
+		case database.OnDeleteRestrict:
+			count, err := countRows(ctx, tx, d.table, live, args)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("can't delete rows of %s, %d rows of %s reference to them", tableName, count, d.table)
+			}
+		case database.OnDeleteCascade:
+			// dependent rows are deleted or updated as user writes them
+			if err := s.checkDependentWrite(ctx, tx, dbName, d.table, "delete", live, args); err != nil {
+				return err
+			}
+			if err := s.deleteWhere(ctx, tx, dbName, d.table, cond, args, path); err != nil {
+				return err
+			}
+		case database.OnDeleteSetNull:
+			if err := s.checkDependentWrite(ctx, tx, dbName, d.table, "update", cond, args); err != nil {
+				return err
+			}
+			nulled, err := s.rowsToRecord(ctx, tx, dbName, d.table, cond, args)
+			if err != nil {
+				return err
+			}
+			sets := []string{}
+			for _, col := range d.columnNames() {
+				sets = append(sets, col+" = NULL")
+			}
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET %s WHERE %s", d.table, strings.Join(sets, ", "), cond), args...); err != nil {
+				return err
+			}
+			if err := s.recordRows(ctx, tx, dbName, d.table, sqlmapper.WriteUpdate, nulled); err != nil {
+				return err
+			}
+		}
+	}
+
+	exec := fmt.Sprintf("DELETE FROM %s WHERE %s", tableName, where)
+	if softDelete {
+		exec = fmt.Sprintf("UPDATE %s SET %s = now() WHERE %s", tableName, database.SoftDeleteColumn, where)
+	}
+
+	if _, err := tx.ExecContext(ctx, exec, args...); err != nil {
+		return fmt.Errorf("%v", err)
+	}
+
+	return s.recordRows(ctx, tx, dbName, tableName, sqlmapper.WriteDelete, before)
+}
+
+// DeletePreview count rows would be deleted and rows of dependent tables, without changing data
+func (s *pgStore) DeletePreview(ctx context.Context, dbName, tableName string, fields, data []interface{}) (sqlmapper.DeleteImpact, error) {
+	where, args, err := s.deleteCondition(dbName, tableName, fields, data)
+	if err != nil {
+		return sqlmapper.DeleteImpact{}, err
+	}
+
+	if err := s.checkRowFilter(ctx, s.db()[dbName].DB(), dbName, tableName, where, args); err != nil {
+		return sqlmapper.DeleteImpact{}, err
+	}
+
+	return s.deleteImpact(ctx, s.db()[dbName].DB(), dbName, dependent{table: tableName}, where, args, nil)
+}
+
+func (s *pgStore) deleteImpact(ctx context.Context, db sqlQueryer, dbName string, d dependent, where string, args []interface{}, path []string) (sqlmapper.DeleteImpact, error) {
+	res := sqlmapper.DeleteImpact{
+		Table:      d.table,
+		OnDelete:   d.onDelete,
+		Dependents: []sqlmapper.DeleteImpact{},
+	}
+	if len(d.columns) > 0 {
+		res.Columns = d.columnNames()
+	}
+
+	if s.isSoftDelete(dbName, d.table) {
+		where = fmt.Sprintf("%s IS NULL AND %s", database.SoftDeleteColumn, where)
+	}
+
+	count, err := countRows(ctx, db, d.table, where, args)
+	if err != nil {
+		return res, err
+	}
+	res.Count = count
+
+	// rows of a restrict or set_null dependent are kept, so their dependents aren't affected
+	if len(path) > 0 && d.onDelete != database.OnDeleteCascade {
+		return res, nil
+	}
+	if checkColumnFieldIsValid(path, d.table) == nil {
+		return res, fmt.Errorf("cyclic cascade delete: %s -> %s", strings.Join(path, " -> "), d.table)
+	}
+	path = append(path, d.table)
+
+	deps, err := s.dependents(dbName, d.table)
+	if err != nil {
+		return res, err
+	}
+
+	for _, dep := range deps {
+		impact, err := s.deleteImpact(ctx, db, dbName, dep, dep.condition(d.table, where), args, path)
+		if err != nil {
+			return res, err
+		}
+		res.Dependents = append(res.Dependents, impact)
+	}
+
+	return res, nil
+}
+
+func countRows(ctx context.Context, db sqlQueryer, tableName, where string, args []interface{}) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", tableName, where), args...).Scan(&count)
+
+	return count, err
+}
@@ -0,0 +1,246 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// includePlan describe how rows of an included table are matched with rows of source table
+type includePlan struct {
+	sqlmapper.Include
+	dbName       string
+	relationship string
+	localColumn  string // column of source table
+	remoteColumn string // column of included table
+	order        []string
+	skipDeleted  bool               // included table is soft_delete and deleted rows aren't requested
+	selects      []string           // expressions selecting fields, computed columns are named as fields
+	masks        []*database.Column // masks of fields, nil when no field is masked
+	filters      []sqlmapper.Filter // row filters of included table
+}
+
+func (s *modelStore) makeIncludePlans(ctx context.Context, q sqlmapper.Query) ([]includePlan, error) {
+	res := []includePlan{}
+	for _, inc := range q.Include {
+		m, ok := s.modelMap[q.SourceDatabase][inc.Table]
+		if !ok {
+			return nil, fmt.Errorf("uknown database_name/table_name %s/%s", q.SourceDatabase, inc.Table)
+		}
+
+		if len(inc.Fields) == 0 {
+			return nil, fmt.Errorf("missing fields of included table %s", inc.Table)
+		}
+
+		incQuery := sqlmapper.Query{Fields: inc.Fields}
+		if _, err := incQuery.ColumnMetadata(m.Columns); err != nil {
+			return nil, err
+		}
+
+		relationship, err := s.getRelationshipType(q.SourceDatabase, q.SourceTable, inc.Table)
+		if err != nil {
+			return nil, err
+		}
+
+		filters, err := sqlmapper.RowFilters(ctx, q.SourceDatabase, inc.Table)
+		if err != nil {
+			return nil, err
+		}
+
+		p := includePlan{
+			Include:      inc,
+			dbName:       q.SourceDatabase,
+			relationship: relationship,
+			skipDeleted:  m.SoftDelete && !q.IncludeDeleted,
+			selects:      database.Columns(m.Columns).SelectExprs(inc.Fields),
+			masks:        sqlmapper.MaskColumns(ctx, m.Columns, inc.Fields),
+			filters:      filters,
+		}
+		switch relationship {
+		case database.RelationshipHasMany:
+			c, err := s.getForeignKeyColumn(q.SourceDatabase, q.SourceTable, inc.Table)
+			if err != nil {
+				return nil, err
+			}
+			p.localColumn, p.remoteColumn = c.ForeignKey.ForeignColumn, c.Name
+		case database.RelationshipBelongsTo:
+			c, err := s.getForeignKeyColumn(q.SourceDatabase, inc.Table, q.SourceTable)
+			if err != nil {
+				return nil, err
+			}
+			p.localColumn, p.remoteColumn = c.Name, c.ForeignKey.ForeignColumn
+		default:
+			return nil, fmt.Errorf("table %s can't be included in table %s, relationship must be has_many or belongs_to", inc.Table, q.SourceTable)
+		}
+
+		if p.localColumn == "" || p.remoteColumn == "" {
+			return nil, fmt.Errorf("missing foreign_column in foreign key between %s and %s", q.SourceTable, inc.Table)
+		}
+
+		for _, col := range m.Columns {
+			if col.IsPrimary {
+				p.order = append(p.order, col.Name)
+			}
+		}
+
+		res = append(res, p)
+	}
+
+	return res, nil
+}
+
+// fieldsWithIncludeKeys return fields need to be selected in source table,
+// columns which only used to match included rows are put at the end
+func fieldsWithIncludeKeys(fields []string, includes []includePlan) []string {
+	res := append([]string{}, fields...)
+	for _, inc := range includes {
+		if err := checkColumnFieldIsValid(res, inc.localColumn); err != nil {
+			res = append(res, inc.localColumn)
+		}
+	}
+
+	return res
+}
+
+// includeRows load included tables with one query per table, then append related rows
+// to each row of data. Columns after numberOfFields are removed from result
+func (s *pgStore) includeRows(ctx context.Context, db *gorm.DB, fields []string, numberOfFields int, includes []includePlan, data []interface{}) ([]interface{}, error) {
+	fieldIdx := make(map[string]int)
+	for i, f := range fields {
+		fieldIdx[f] = i
+	}
+
+	relatedRows := make([]map[string][]interface{}, len(includes))
+	for i, inc := range includes {
+		keys := []interface{}{}
+		existed := make(map[string]bool)
+		for _, d := range data {
+			key := d.([]interface{})[fieldIdx[inc.localColumn]]
+			if key == nil || existed[includeKey(key)] {
+				continue
+			}
+			existed[includeKey(key)] = true
+			keys = append(keys, key)
+		}
+
+		rows, err := s.queryIncluded(ctx, db, inc, keys)
+		if err != nil {
+			return nil, err
+		}
+		relatedRows[i] = rows
+	}
+
+	res := []interface{}{}
+	for _, d := range data {
+		row := d.([]interface{})
+		tmp := append([]interface{}{}, row[:numberOfFields]...)
+		for i, inc := range includes {
+			var related []interface{}
+			if key := row[fieldIdx[inc.localColumn]]; key != nil {
+				related = relatedRows[i][includeKey(key)]
+			}
+
+			if inc.relationship == database.RelationshipBelongsTo {
+				if len(related) > 0 {
+					tmp = append(tmp, related[0])
+				} else {
+					tmp = append(tmp, nil)
+				}
+				continue
+			}
+
+			if related == nil {
+				related = []interface{}{}
+			}
+			tmp = append(tmp, related)
+		}
+
+		res = append(res, tmp)
+	}
+
+	return res, nil
+}
+
+// queryIncluded query rows of included table by keys, result is grouped by key
+func (s *pgStore) queryIncluded(ctx context.Context, db *gorm.DB, inc includePlan, keys []interface{}) (map[string][]interface{}, error) {
+	res := make(map[string][]interface{})
+	if len(keys) == 0 {
+		return res, nil
+	}
+
+	cols := strings.Join(inc.Fields, ", ")
+	selects := strings.Join(inc.selects, ", ")
+	order := ""
+	if len(inc.order) > 0 {
+		order = "ORDER BY " + strings.Join(inc.order, ", ")
+	}
+
+	where := fmt.Sprintf("%s IN (?)", inc.remoteColumn)
+	if inc.skipDeleted {
+		where += fmt.Sprintf(" AND %s IS NULL", database.SoftDeleteColumn)
+	}
+	args := []interface{}{keys}
+	for _, f := range inc.filters {
+		where += fmt.Sprintf(" AND %s = ?", s.columnExpr(inc.dbName, inc.Table, f.ColumnName))
+		args = append(args, f.Value)
+	}
+
+	sqlQuery := fmt.Sprintf("SELECT %s, %s AS include_key FROM %s WHERE %s %s",
+		selects,
+		inc.remoteColumn,
+		inc.Table,
+		where,
+		order)
+
+	if inc.Limit > 0 && inc.relationship == database.RelationshipHasMany {
+		// limit related rows for each key with a window function, so it still be a single query
+		sqlQuery = fmt.Sprintf("SELECT %s, include_key FROM (SELECT %s, %s AS include_key, ROW_NUMBER() OVER (PARTITION BY %s %s) AS include_row_number FROM %s WHERE %s) AS included WHERE include_row_number <= %d ORDER BY include_row_number",
+			cols,
+			selects,
+			inc.remoteColumn,
+			inc.remoteColumn,
+			order,
+			inc.Table,
+			where,
+			inc.Limit)
+	}
+
+	db, err := sqlmapper.WithContext(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Raw(sqlQuery, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	data, err := sqlmapper.SQLRowsToRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	numberOfFields := len(inc.Fields)
+	for _, d := range data {
+		row := d.([]interface{})
+		key := includeKey(row[numberOfFields])
+		sqlmapper.MaskRow(inc.masks, row[:numberOfFields])
+		res[key] = append(res[key], row[:numberOfFields])
+	}
+
+	return res, nil
+}
+
+func includeKey(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+
+	return fmt.Sprint(v)
+}
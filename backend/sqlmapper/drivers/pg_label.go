@@ -0,0 +1,152 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// labelPlan describe how labels of a foreign key column are loaded from its referenced table
+type labelPlan struct {
+	column      string // foreign key column of source table
+	table       string // referenced table
+	keyColumn   string // referenced column
+	labelColumn string // name_display_column of referenced table
+	mask        *database.Column
+	filters     []sqlmapper.Filter // row filters of referenced table
+}
+
+func (s *modelStore) makeLabelPlans(ctx context.Context, q sqlmapper.Query) ([]labelPlan, error) {
+	res := []labelPlan{}
+	for _, col := range q.Labels {
+		fk, labelColumn, err := sqlmapper.LabelSource(s.modelMap[q.SourceDatabase], q.SourceTable, col)
+		if err != nil {
+			return nil, err
+		}
+
+		if !sqlmapper.CanRead(ctx, q.SourceDatabase, q.SourceTable, col) {
+			return nil, sqlmapper.ForbiddenColumnError{TableName: q.SourceTable, Column: col, Action: "read"}
+		}
+		if !sqlmapper.CanRead(ctx, q.SourceDatabase, fk.Table, labelColumn) {
+			return nil, sqlmapper.ForbiddenColumnError{TableName: fk.Table, Column: labelColumn, Action: "read"}
+		}
+
+		filters, err := sqlmapper.RowFilters(ctx, q.SourceDatabase, fk.Table)
+		if err != nil {
+			return nil, err
+		}
+
+		p := labelPlan{
+			column:      col,
+			table:       fk.Table,
+			keyColumn:   fk.ForeignColumn,
+			labelColumn: labelColumn,
+			filters:     filters,
+		}
+		if masks := sqlmapper.MaskColumns(ctx, s.modelMap[q.SourceDatabase][fk.Table].Columns, []string{labelColumn}); masks != nil {
+			p.mask = masks[0]
+		}
+
+		res = append(res, p)
+	}
+
+	return res, nil
+}
+
+// fieldsWithLabelKeys append foreign key columns of labels which aren't selected to fields
+func fieldsWithLabelKeys(fields []string, labels []labelPlan) []string {
+	res := append([]string{}, fields...)
+	for _, l := range labels {
+		if err := checkColumnFieldIsValid(res, l.column); err != nil {
+			res = append(res, l.column)
+		}
+	}
+
+	return res
+}
+
+// labelRows load labels of foreign keys of rows of data with one query per label,
+// labels of a row are returned in order of labels
+func (s *pgStore) labelRows(ctx context.Context, db *gorm.DB, dbName string, fields []string, labels []labelPlan, data []interface{}) ([][]interface{}, error) {
+	fieldIdx := make(map[string]int)
+	for i, f := range fields {
+		fieldIdx[f] = i
+	}
+
+	res := make([][]interface{}, len(data))
+	for _, l := range labels {
+		keys := []interface{}{}
+		existed := make(map[string]bool)
+		for _, d := range data {
+			key := d.([]interface{})[fieldIdx[l.column]]
+			if key == nil || existed[includeKey(key)] {
+				continue
+			}
+			existed[includeKey(key)] = true
+			keys = append(keys, key)
+		}
+
+		values, err := s.queryLabels(ctx, db, dbName, l, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, d := range data {
+			var v interface{}
+			if key := d.([]interface{})[fieldIdx[l.column]]; key != nil {
+				v = values[includeKey(key)]
+			}
+			res[i] = append(res[i], v)
+		}
+	}
+
+	return res, nil
+}
+
+// queryLabels query labels of referenced rows by keys
+func (s *pgStore) queryLabels(ctx context.Context, db *gorm.DB, dbName string, l labelPlan, keys []interface{}) (map[string]interface{}, error) {
+	res := make(map[string]interface{})
+	if len(keys) == 0 {
+		return res, nil
+	}
+
+	where := fmt.Sprintf("%s IN (?)", l.keyColumn)
+	args := []interface{}{keys}
+	for _, f := range l.filters {
+		where += fmt.Sprintf(" AND %s = ?", s.columnExpr(dbName, l.table, f.ColumnName))
+		args = append(args, f.Value)
+	}
+
+	db, err := sqlmapper.WithContext(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Raw(fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s",
+		l.keyColumn,
+		strings.Join(database.Columns(s.modelMap[dbName][l.table].Columns).SelectExprs([]string{l.labelColumn}), ", "),
+		l.table,
+		where), args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	data, err := sqlmapper.SQLRowsToRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range data {
+		row := d.([]interface{})
+		sqlmapper.MaskRow([]*database.Column{nil, l.mask}, row)
+		res[includeKey(row[0])] = row[1]
+	}
+
+	return res, nil
+}
@@ -0,0 +1,78 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// selectRows return all stored columns of rows of tableName matching where
+func (s *pgStore) selectRows(ctx context.Context, db sqlQueryer, dbName, tableName, where string, args []interface{}) ([]sqlmapper.RowData, error) {
+	cols := database.Columns(s.modelMap[dbName][tableName].Columns).Stored().Names()
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(cols, ", "), tableName, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []sqlmapper.RowData{}
+	err = sqlmapper.ScanRows(rows, func(row []interface{}) error {
+		r := make(sqlmapper.RowData)
+		for i, col := range cols {
+			r[col] = sqlmapper.ColData{Name: col, Data: normalizeScanned(row[i])}
+		}
+		res = append(res, r)
+		return nil
+	})
+
+	return res, err
+}
+
+// rowsToRecord read rows of tableName matching where before they are written, they are locked so
+// their values can't change until the write. Nothing is read when writes of ctx aren't recorded
+func (s *pgStore) rowsToRecord(ctx context.Context, tx *sql.Tx, dbName, tableName, where string, args []interface{}) ([]sqlmapper.RowData, error) {
+	if !sqlmapper.RecordsWrites(ctx) {
+		return nil, nil
+	}
+
+	return s.selectRows(ctx, tx, dbName, tableName, where+" FOR UPDATE", args)
+}
+
+// recordRows record rows read by rowsToRecord after they are written by action
+func (s *pgStore) recordRows(ctx context.Context, tx *sql.Tx, dbName, tableName, action string, before []sqlmapper.RowData) error {
+	pks := primaryColumnNames(s.modelMap[dbName][tableName])
+	for _, row := range before {
+		if err := s.recordRow(ctx, tx, dbName, tableName, action, row, pickColumns(row, pks)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordRow read a row by its primary key after it is written by action, then record it with its
+// values before the write, before is nil for a created row. Rows of tables without primary key aren't recorded
+func (s *pgStore) recordRow(ctx context.Context, tx *sql.Tx, dbName, tableName, action string, before, primaryKeyMap sqlmapper.RowData) error {
+	pks := primaryColumnNames(s.modelMap[dbName][tableName])
+	if !sqlmapper.RecordsWrites(ctx) || len(pks) == 0 || len(primaryKeyMap) != len(pks) {
+		return nil
+	}
+
+	after, err := s.findRow(ctx, tx, dbName, tableName, primaryKeyMap)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	return sqlmapper.RecordWrite(ctx, sqlmapper.RowWrite{
+		DBName:    dbName,
+		TableName: tableName,
+		Action:    action,
+		Before:    before,
+		After:     after,
+	})
+}
@@ -0,0 +1,543 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/volatiletech/sqlboiler/strmangle"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+func (s *modelStore) getRelationship(dbName, tableName, relateTableName string) (database.Relationship, error) {
+	model, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return database.Relationship{}, fmt.Errorf("uknown table_name %s", tableName)
+	}
+
+	for _, rel := range model.Relationship {
+		if rel.Table == relateTableName {
+			return rel, nil
+		}
+	}
+
+	return database.Relationship{}, fmt.Errorf("table %s doesn't have relationship with table %s", tableName, relateTableName)
+}
+
+// verifyRelationships check all relate-data of a row can be written before starting a transaction
+func (s *modelStore) verifyRelationships(dbName, tableName string, relateRowData map[string][]sqlmapper.RowData) error {
+	for relateTableName, rows := range relateRowData {
+		rel, err := s.getRelationship(dbName, tableName, relateTableName)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := s.modelMap[dbName][relateTableName]; !ok {
+			return fmt.Errorf("uknown database_name/table_name %s/%s", dbName, relateTableName)
+		}
+
+		var cs []database.Column
+		switch rel.Type {
+		case database.RelationshipHasMany:
+			cs, err = s.getForeignKeyColumns(dbName, tableName, relateTableName)
+		case database.RelationshipHasOne, database.RelationshipBelongsTo:
+			if len(rows) > 1 {
+				return fmt.Errorf("%s relationship between %s and %s accept only 1 row", rel.Type, tableName, relateTableName)
+			}
+			if rel.Type == database.RelationshipHasOne {
+				cs, err = s.getForeignKeyColumns(dbName, tableName, relateTableName)
+			} else {
+				cs, err = s.getForeignKeyColumns(dbName, relateTableName, tableName)
+			}
+		case database.RelationshipManyToMany:
+			if rel.Through == "" {
+				return fmt.Errorf("missing through table of many_to_many relationship between %s and %s", tableName, relateTableName)
+			}
+			_, _, err = s.getJoinColumns(dbName, tableName, rel)
+		default:
+			return fmt.Errorf("unknown relationship type %q between %s and %s", rel.Type, tableName, relateTableName)
+		}
+		if err != nil {
+			return fmt.Errorf("%s relationship between %s and %s: %v", rel.Type, tableName, relateTableName, err)
+		}
+
+		for _, c := range cs {
+			if c.ForeignKey.ForeignColumn == "" {
+				return fmt.Errorf("missing foreign_column of column %s", c.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// getForeignKeyColumns return all columns of relateTableName referencing to tableName,
+// there are many columns when referenced key is composite
+func (s *modelStore) getForeignKeyColumns(dbName, tableName, relateTableName string) ([]database.Column, error) {
+	m, ok := s.modelMap[dbName][relateTableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, relateTableName)
+	}
+
+	res := []database.Column{}
+	for _, col := range m.Columns {
+		if col.ForeignKey.Table == tableName {
+			res = append(res, col)
+		}
+	}
+
+	if len(res) == 0 {
+		return nil, errors.New("Can't find foreign key column")
+	}
+
+	return res, nil
+}
+
+// foreignColumns return referenced columns of foreign key columns
+func foreignColumns(cs []database.Column) []string {
+	res := []string{}
+	for _, c := range cs {
+		res = append(res, c.ForeignKey.ForeignColumn)
+	}
+
+	return res
+}
+
+// getJoinColumns return columns of through table referencing to source table and related table
+func (s *modelStore) getJoinColumns(dbName, tableName string, rel database.Relationship) ([]database.Column, []database.Column, error) {
+	if _, ok := s.modelMap[dbName][rel.Through]; !ok {
+		return nil, nil, fmt.Errorf("uknown through table %s", rel.Through)
+	}
+
+	own, err := s.getForeignKeyColumns(dbName, tableName, rel.Through)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	related, err := s.getForeignKeyColumns(dbName, rel.Table, rel.Through)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, o := range own {
+		for _, r := range related {
+			if o.Name == r.Name {
+				return nil, nil, fmt.Errorf("through table %s must have different foreign key columns for %s and %s", rel.Through, tableName, rel.Table)
+			}
+		}
+	}
+
+	for _, c := range append(append([]database.Column{}, own...), related...) {
+		if c.ForeignKey.ForeignColumn == "" {
+			return nil, nil, fmt.Errorf("missing foreign_column in foreign keys of through table %s", rel.Through)
+		}
+	}
+
+	return own, related, nil
+}
+
+// writeBelongsTo save parent rows of belongs_to relationships, foreign key columns of row are
+// filled with keys of saved rows. It must be called before row is written
+func (s *pgStore) writeBelongsTo(ctx context.Context, tx *sql.Tx, dbName, tableName string, row sqlmapper.RowData, relateRowData map[string][]sqlmapper.RowData) error {
+	for relateTableName, rows := range relateRowData {
+		rel, err := s.getRelationship(dbName, tableName, relateTableName)
+		if err != nil {
+			return err
+		}
+		if rel.Type != database.RelationshipBelongsTo || len(rows) == 0 {
+			continue
+		}
+
+		cs, err := s.getForeignKeyColumns(dbName, relateTableName, tableName)
+		if err != nil {
+			return err
+		}
+
+		keys, err := s.saveRelatedRow(ctx, tx, dbName, relateTableName, rows[0], foreignColumns(cs))
+		if err != nil {
+			return err
+		}
+
+		for _, c := range cs {
+			row[c.Name] = keys[c.ForeignKey.ForeignColumn]
+		}
+	}
+
+	return nil
+}
+
+// writeChildren save related rows which reference to parent row, it must be called after parent row is written
+func (s *pgStore) writeChildren(ctx context.Context, tx *sql.Tx, dbName, tableName string, parent sqlmapper.RowData, relateRowData map[string][]sqlmapper.RowData) error {
+	for relateTableName, rows := range relateRowData {
+		rel, err := s.getRelationship(dbName, tableName, relateTableName)
+		if err != nil {
+			return err
+		}
+
+		switch rel.Type {
+		case database.RelationshipHasMany:
+			err = s.writeHasMany(ctx, tx, dbName, tableName, parent, relateTableName, rows)
+		case database.RelationshipHasOne:
+			if len(rows) > 0 {
+				err = s.writeHasOne(ctx, tx, dbName, tableName, parent, relateTableName, rows[0])
+			}
+		case database.RelationshipManyToMany:
+			err = s.writeManyToMany(ctx, tx, dbName, tableName, parent, rel, rows)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// referencedKeys return values of parent's columns referenced by foreign key columns
+func referencedKeys(parent sqlmapper.RowData, parentTableName string, cs []database.Column) (sqlmapper.RowData, error) {
+	res := make(sqlmapper.RowData)
+	for _, c := range cs {
+		key, ok := parent[c.ForeignKey.ForeignColumn]
+		if !ok {
+			return nil, fmt.Errorf("missing value of column %s in table %s", c.ForeignKey.ForeignColumn, parentTableName)
+		}
+		res[c.Name] = sqlmapper.ColData{Data: key.Data}
+	}
+
+	return res, nil
+}
+
+func (s *pgStore) writeHasMany(ctx context.Context, tx *sql.Tx, dbName, parentTableName string, parent sqlmapper.RowData, tableName string, rows []sqlmapper.RowData) error {
+	cs, err := s.getForeignKeyColumns(dbName, parentTableName, tableName)
+	if err != nil {
+		return err
+	}
+
+	parentKeys, err := referencedKeys(parent, parentTableName, cs)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		for colName, colData := range parentKeys {
+			row[colName] = colData
+		}
+		if _, err := s.saveRelatedRow(ctx, tx, dbName, tableName, row, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeHasOne save the only related row of a has_one relationship. Without a primary key, row update
+// the existing related row of parent. Other related rows of parent are deleted, row replaces them
+func (s *pgStore) writeHasOne(ctx context.Context, tx *sql.Tx, dbName, parentTableName string, parent sqlmapper.RowData, tableName string, row sqlmapper.RowData) error {
+	cs, err := s.getForeignKeyColumns(dbName, parentTableName, tableName)
+	if err != nil {
+		return err
+	}
+
+	parentKeys, err := referencedKeys(parent, parentTableName, cs)
+	if err != nil {
+		return err
+	}
+
+	primaryKeyMap, err := s.getPrimaryKeyMap(row, dbName, tableName)
+	if err != nil {
+		return err
+	}
+
+	where, args := primaryKeyCondition(parentKeys)
+	if len(primaryKeyMap) == 0 {
+		existWhere := where
+		if s.isSoftDelete(dbName, tableName) {
+			existWhere = fmt.Sprintf("%s IS NULL AND %s", database.SoftDeleteColumn, where)
+		}
+
+		pks := primaryColumnNames(s.modelMap[dbName][tableName])
+		values := make([]interface{}, len(pks))
+		pointers := make([]interface{}, len(pks))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s LIMIT 1 FOR UPDATE", strings.Join(pks, ", "), tableName, existWhere), args...).Scan(pointers...)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return err
+		default:
+			for i, col := range pks {
+				primaryKeyMap[col] = sqlmapper.ColData{Data: normalizeScanned(values[i])}
+			}
+		}
+	}
+
+	if len(primaryKeyMap) > 0 {
+		cols := primaryKeyMap.Columns()
+		sort.Strings(cols)
+
+		keep := []string{}
+		for _, col := range cols {
+			args = append(args, primaryKeyMap[col].Data)
+			keep = append(keep, fmt.Sprintf("%s = $%d", col, len(args)))
+		}
+		where = fmt.Sprintf("%s AND NOT (%s)", where, strings.Join(keep, " AND "))
+	}
+	// replaced rows are deleted as user deletes them
+	replaced := where
+	if s.isSoftDelete(dbName, tableName) {
+		replaced = fmt.Sprintf("%s IS NULL AND %s", database.SoftDeleteColumn, where)
+	}
+	if err := s.checkDependentWrite(ctx, tx, dbName, tableName, "delete", replaced, args); err != nil {
+		return err
+	}
+	if err := s.deleteWhere(ctx, tx, dbName, tableName, where, args, nil); err != nil {
+		return err
+	}
+
+	for colName, colData := range primaryKeyMap {
+		row[colName] = colData
+	}
+	for colName, colData := range parentKeys {
+		row[colName] = colData
+	}
+	_, err = s.saveRelatedRow(ctx, tx, dbName, tableName, row, nil)
+
+	return err
+}
+
+func (s *pgStore) writeManyToMany(ctx context.Context, tx *sql.Tx, dbName, tableName string, parent sqlmapper.RowData, rel database.Relationship, rows []sqlmapper.RowData) error {
+	own, related, err := s.getJoinColumns(dbName, tableName, rel)
+	if err != nil {
+		return err
+	}
+
+	parentKeys, err := referencedKeys(parent, tableName, own)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		keys, err := s.saveRelatedRow(ctx, tx, dbName, rel.Table, row, foreignColumns(related))
+		if err != nil {
+			return err
+		}
+
+		link, err := referencedKeys(keys, rel.Table, related)
+		if err != nil {
+			return err
+		}
+		for colName, colData := range parentKeys {
+			link[colName] = colData
+		}
+
+		cols, data := link.ColumnsAndData()
+		params := []string{}
+		for i, col := range cols {
+			params = append(params, fmt.Sprintf("%s = $%d", col, i+1))
+		}
+
+		var linked bool
+		err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s)", rel.Through, strings.Join(params, " AND ")), data...).Scan(&linked)
+		if err != nil {
+			return err
+		}
+		if linked {
+			continue
+		}
+
+		pks := primaryColumnNames(s.modelMap[dbName][rel.Through])
+		if err := insertRow(ctx, tx, rel.Through, link, pks); err != nil {
+			return err
+		}
+		if err := s.recordRow(ctx, tx, dbName, rel.Through, sqlmapper.WriteCreate, nil, pickColumns(link, pks)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveRelatedRow update a related row if its primary key existed, otherwise create it. Table permission
+// and row filters of related table are checked like a create or update of it.
+// Values of keyColumns are returned to link the row with source row
+func (s *pgStore) saveRelatedRow(ctx context.Context, tx *sql.Tx, dbName, tableName string, row sqlmapper.RowData, keyColumns []string) (sqlmapper.RowData, error) {
+	primaryKeyMap, err := s.getPrimaryKeyMap(row, dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	exist := false
+	if len(primaryKeyMap) > 0 {
+		exist, err = s.isPrimaryKeyExist(ctx, tx, tableName, primaryKeyMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !exist {
+		// primary key sent with a new row is kept, ex: uuid generated by client, a generated one isn't
+		for colName, colData := range primaryKeyMap {
+			row[colName] = colData
+		}
+		s.clearGeneratedPrimaryKey(dbName, tableName, row)
+		if err := sqlmapper.AuthorizeTable(ctx, dbName, tableName, "create"); err != nil {
+			return nil, err
+		}
+		if err := setRowFilterValues(ctx, dbName, tableName, row); err != nil {
+			return nil, err
+		}
+		if err := s.setManagedTimestamps(dbName, tableName, row, database.CreatedAtColumn, database.UpdatedAtColumn); err != nil {
+			return nil, err
+		}
+		if err := verifyInput(ctx, row, dbName, tableName, s.modelMap[dbName]); err != nil {
+			return nil, err
+		}
+		if err := checkRowValues(ctx, dbName, tableName, row); err != nil {
+			return nil, err
+		}
+
+		returning := []string{}
+		for _, col := range s.modelMap[dbName][tableName].Columns {
+			if col.IsPrimary || checkColumnFieldIsValid(keyColumns, col.Name) == nil {
+				returning = append(returning, col.Name)
+			}
+		}
+		if err := insertRow(ctx, tx, tableName, row, returning); err != nil {
+			return nil, err
+		}
+		if err := s.recordRow(ctx, tx, dbName, tableName, sqlmapper.WriteCreate, nil, pickColumns(row, primaryColumnNames(s.modelMap[dbName][tableName]))); err != nil {
+			return nil, err
+		}
+
+		return pickColumns(row, keyColumns), nil
+	}
+
+	// a linked row must be inside row filters too
+	where, args := primaryKeyCondition(primaryKeyMap)
+	if err := s.checkRowFilter(ctx, tx, dbName, tableName, where, args); err != nil {
+		return nil, err
+	}
+
+	// existing row is only linked when there is no column to update
+	if cols, _ := row.ColumnsAndData(); len(cols) > 0 {
+		if err := sqlmapper.AuthorizeTable(ctx, dbName, tableName, "update"); err != nil {
+			return nil, err
+		}
+		if err := s.setManagedTimestamps(dbName, tableName, row, database.UpdatedAtColumn); err != nil {
+			return nil, err
+		}
+		cols, data := row.ColumnsAndData()
+		if err := verifyUpdateInput(ctx, row, dbName, tableName, s.modelMap[dbName]); err != nil {
+			return nil, err
+		}
+		if err := checkRowValues(ctx, dbName, tableName, row); err != nil {
+			return nil, err
+		}
+		before, err := s.rowsToRecord(ctx, tx, dbName, tableName, where, args)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.execUpdateSQL(ctx, tx, primaryKeyMap, data, cols, tableName); err != nil {
+			return nil, err
+		}
+		if err := s.recordRows(ctx, tx, dbName, tableName, sqlmapper.WriteUpdate, before); err != nil {
+			return nil, err
+		}
+	}
+
+	for colName, colData := range primaryKeyMap {
+		row[colName] = colData
+	}
+
+	missing := []string{}
+	for _, col := range keyColumns {
+		if _, ok := row[col]; !ok {
+			missing = append(missing, col)
+		}
+	}
+
+	if len(missing) > 0 {
+		params := []string{}
+		data := []interface{}{}
+		for colName, colData := range primaryKeyMap {
+			data = append(data, colData.Data)
+			params = append(params, fmt.Sprintf("%s = $%d", colName, len(data)))
+		}
+
+		values := make([]interface{}, len(missing))
+		pointers := make([]interface{}, len(missing))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(missing, ", "), tableName, strings.Join(params, " AND ")), data...).Scan(pointers...)
+		if err != nil {
+			return nil, err
+		}
+		for i, col := range missing {
+			row[col] = sqlmapper.ColData{Data: normalizeScanned(values[i])}
+		}
+	}
+
+	return pickColumns(row, keyColumns), nil
+}
+
+// pickColumns return a row containing only given columns
+func pickColumns(row sqlmapper.RowData, columns []string) sqlmapper.RowData {
+	res := make(sqlmapper.RowData)
+	for _, col := range columns {
+		if colData, ok := row[col]; ok {
+			res[col] = colData
+		}
+	}
+
+	return res
+}
+
+// insertRow insert a row in transaction, values of returning columns are set back to row
+func insertRow(ctx context.Context, tx *sql.Tx, tableName string, row sqlmapper.RowData, returning []string) error {
+	cols, data := row.ColumnsAndData()
+
+	phs := strmangle.Placeholders(true, len(cols), 1, 1)
+	sqlQuery := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		tableName,
+		strings.Join(cols, ","),
+		phs)
+	if len(returning) == 0 {
+		_, err := tx.ExecContext(ctx, sqlQuery, data...)
+		return err
+	}
+	sqlQuery += " RETURNING " + strings.Join(returning, ",")
+
+	values := make([]interface{}, len(returning))
+	pointers := make([]interface{}, len(returning))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	if err := tx.QueryRowContext(ctx, sqlQuery, data...).Scan(pointers...); err != nil {
+		return err
+	}
+
+	for i, col := range returning {
+		row[col] = sqlmapper.ColData{Data: normalizeScanned(values[i])}
+	}
+
+	return nil
+}
+
+// normalizeScanned convert text value scanned as []byte (ex: uuid) to string
+func normalizeScanned(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+
+	return v
+}
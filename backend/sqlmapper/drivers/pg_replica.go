@@ -0,0 +1,43 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// reader return connection reading dbName: a healthy replica, or primary when dbName has no
+// healthy replica or is already written in session of ctx
+func (s *pgStore) reader(ctx context.Context, dbName string) *gorm.DB {
+	if sqlmapper.IsWritten(ctx, dbName) {
+		return s.db()[dbName]
+	}
+
+	if replica := s.replicas()[dbName].Pick(); replica != nil {
+		return replica
+	}
+
+	return s.db()[dbName]
+}
+
+// queryReader open rows of a read query with reader of dbName, connection used is returned so
+// related queries go to the same database. When a replica fails the query and doesn't answer
+// a ping, it is marked down and query is run again on primary
+func (s *pgStore) queryReader(ctx context.Context, dbName string, query func(db *gorm.DB) (*sql.Rows, error)) (*sql.Rows, *gorm.DB, error) {
+	db := s.reader(ctx, dbName)
+	rows, err := query(db)
+	if err == nil || db == s.db()[dbName] || ctx.Err() != nil {
+		return rows, db, err
+	}
+
+	if db.DB().PingContext(ctx) == nil {
+		return nil, db, err
+	}
+	s.replicas()[dbName].MarkDown(db)
+
+	rows, err = query(s.db()[dbName])
+	return rows, s.db()[dbName], err
+}
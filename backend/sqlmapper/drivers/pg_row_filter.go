@@ -0,0 +1,136 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// rowFilterCondition return condition of rows allowed by row filters of ctx, an empty condition is
+// returned when table isn't filtered. Placeholders are numbered after n args
+func (s *pgStore) rowFilterCondition(ctx context.Context, dbName, tableName string, n int) (string, []interface{}, error) {
+	filters, err := sqlmapper.RowFilters(ctx, dbName, tableName)
+	if err != nil || len(filters) == 0 {
+		return "", nil, err
+	}
+
+	params := []string{}
+	args := []interface{}{}
+	for _, f := range filters {
+		args = append(args, f.Value)
+		params = append(params, fmt.Sprintf("%s = $%d", s.columnExpr(dbName, tableName, f.ColumnName), n+len(args)))
+	}
+
+	return strings.Join(params, " AND "), args, nil
+}
+
+// addRowFilters restrict a query to rows allowed by row filters of ctx
+func (s *pgStore) addRowFilters(ctx context.Context, q sqlmapper.Query, db *gorm.DB) (*gorm.DB, error) {
+	filters, err := sqlmapper.RowFilters(ctx, q.SourceDatabase, q.SourceTable)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range filters {
+		db = db.Where(s.columnExpr(q.SourceDatabase, q.SourceTable, f.ColumnName)+" = ?", f.Value)
+	}
+
+	return db, nil
+}
+
+// checkRowFilter reject a write of rows matching where when any of them is outside row filters of ctx.
+// Matched rows are locked until transaction end, so they can't be moved outside filters before written
+func (s *pgStore) checkRowFilter(ctx context.Context, db sqlQueryer, dbName, tableName, where string, args []interface{}) error {
+	cond, filterArgs, err := s.rowFilterCondition(ctx, dbName, tableName, len(args))
+	if err != nil || cond == "" {
+		return err
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT COALESCE(%s, false) FROM %s WHERE %s FOR UPDATE", cond, tableName, where), append(append([]interface{}{}, args...), filterArgs...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var allowed bool
+		if err := rows.Scan(&allowed); err != nil {
+			return err
+		}
+		if !allowed {
+			return sqlmapper.ForbiddenRowError{TableName: tableName}
+		}
+	}
+
+	return rows.Err()
+}
+
+// checkDependentWrite check rows of tableName matching where, which are written by a nested write or
+// on_delete of another table, can be written: user can run action on tableName and rows are inside
+// row filters. Action isn't checked when no row matches
+func (s *pgStore) checkDependentWrite(ctx context.Context, tx *sql.Tx, dbName, tableName, action, where string, args []interface{}) error {
+	if err := sqlmapper.AuthorizeTable(ctx, dbName, tableName, action); err != nil {
+		count, errCount := countRows(ctx, tx, tableName, where, args)
+		if errCount != nil {
+			return errCount
+		}
+		if count > 0 {
+			return err
+		}
+	}
+
+	return s.checkRowFilter(ctx, tx, dbName, tableName, where, args)
+}
+
+// setRowFilterValues set columns of filters of ctx missing in a created row, so the row is created inside row filters
+func setRowFilterValues(ctx context.Context, dbName, tableName string, row sqlmapper.RowData) error {
+	filters, err := sqlmapper.RowFilters(ctx, dbName, tableName)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range filters {
+		if _, ok := row[f.ColumnName]; !ok {
+			row[f.ColumnName] = sqlmapper.ColData{Data: f.Value}
+		}
+	}
+
+	return nil
+}
+
+// checkRowValues reject a row written with values moving it outside row filters of ctx
+func checkRowValues(ctx context.Context, dbName, tableName string, row sqlmapper.RowData) error {
+	filters, err := sqlmapper.RowFilters(ctx, dbName, tableName)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range filters {
+		if v, ok := row[f.ColumnName]; ok && fmt.Sprint(v.Data) != fmt.Sprint(f.Value) {
+			return sqlmapper.ForbiddenRowError{TableName: tableName}
+		}
+	}
+
+	return nil
+}
+
+// primaryKeyCondition make condition of a row from its primary columns
+func primaryKeyCondition(primaryKeyMap sqlmapper.RowData) (string, []interface{}) {
+	cols := primaryKeyMap.Columns()
+	sort.Strings(cols)
+
+	params := []string{}
+	args := []interface{}{}
+	for _, col := range cols {
+		args = append(args, primaryKeyMap[col].Data)
+		params = append(params, fmt.Sprintf("%s = $%d", col, len(args)))
+	}
+
+	return strings.Join(params, " AND "), args
+}
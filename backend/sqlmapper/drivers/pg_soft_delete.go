@@ -0,0 +1,88 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+func (s *modelStore) isSoftDelete(dbName, tableName string) bool {
+	return s.modelMap[dbName][tableName].SoftDelete
+}
+
+func (s *modelStore) checkSoftDeleteColumn(dbName, tableName string) error {
+	colNames := database.Columns(s.modelMap[dbName][tableName].Columns).Names()
+	if err := checkColumnFieldIsValid(colNames, database.SoftDeleteColumn); err != nil {
+		return fmt.Errorf("soft_delete table %s must have column %s", tableName, database.SoftDeleteColumn)
+	}
+
+	return nil
+}
+
+// Restore clear deleted_at of soft-deleted rows matching fields and data
+func (s *pgStore) Restore(ctx context.Context, dbName, tableName string, fields, data []interface{}) error {
+	if _, ok := s.modelMap[dbName][tableName]; !ok {
+		return fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	if !s.isSoftDelete(dbName, tableName) {
+		return fmt.Errorf("table %s is not soft_delete", tableName)
+	}
+
+	if err := s.checkSoftDeleteColumn(dbName, tableName); err != nil {
+		return err
+	}
+
+	if len(fields) != len(data) {
+		return errors.New("Fields and data isn't match")
+	}
+
+	if len(fields) == 0 {
+		return errors.New("missing filter of restored rows")
+	}
+
+	// fields are checked with columns of model, they are put in SQL
+	where, args, err := s.deleteCondition(dbName, tableName, fields, data)
+	if err != nil {
+		return err
+	}
+
+	sqlmapper.MarkWritten(ctx, dbName)
+	// rows checked by row filters are locked until they are restored
+	tx, err := s.db()[dbName].DB().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := s.restore(ctx, tx, dbName, tableName, where, args); err != nil {
+		if errRollBack := tx.Rollback(); errRollBack != nil {
+			return errRollBack
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *pgStore) restore(ctx context.Context, tx *sql.Tx, dbName, tableName, where string, args []interface{}) error {
+	if err := s.checkRowFilter(ctx, tx, dbName, tableName, where, args); err != nil {
+		return err
+	}
+
+	where = fmt.Sprintf("%s IS NOT NULL AND %s", database.SoftDeleteColumn, where)
+	before, err := s.rowsToRecord(ctx, tx, dbName, tableName, where, args)
+	if err != nil {
+		return err
+	}
+
+	exec := fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s", tableName, database.SoftDeleteColumn, where)
+	if _, err := tx.ExecContext(ctx, exec, args...); err != nil {
+		return err
+	}
+
+	return s.recordRows(ctx, tx, dbName, tableName, sqlmapper.WriteRestore, before)
+}
@@ -3,6 +3,7 @@
 package drivers
 
 import (
+	"context"
 	"math"
 	"reflect"
 	"sort"
@@ -304,12 +305,12 @@ func Test_pgStore_Query(t *testing.T) {
 						t.Fatalf("Failed to migrate table by error %v", err)
 					}
 				}
-				s = NewPGStore(cfgEmpty.DBs(), cfgEmpty.ModelMap)
+				s = NewPGStore(cfgEmpty.DBs, cfgEmpty.Replicas, cfgEmpty.ModelMap)
 			} else {
-				s = NewPGStore(cfg.DBs(), cfg.ModelMap)
+				s = NewPGStore(cfg.DBs, cfg.Replicas, cfg.ModelMap)
 			}
 
-			got, got1, err := s.Query(*tt.args)
+			got, got1, err := s.Query(context.Background(), *tt.args)
 			if err != nil {
 				if !tt.wantErr {
 					t.Errorf("pgStore.Query() error = %v, wantErr %v", err, tt.wantErr)
@@ -474,12 +475,12 @@ func Test_pgStore_Delete(t *testing.T) {
 						t.Fatalf("Failed to migrate table by error %v", err)
 					}
 				}
-				s = NewPGStore(cfgEmpty.DBs(), cfgEmpty.ModelMap)
+				s = NewPGStore(cfgEmpty.DBs, cfgEmpty.Replicas, cfgEmpty.ModelMap)
 			} else {
-				s = NewPGStore(cfg.DBs(), cfg.ModelMap)
+				s = NewPGStore(cfg.DBs, cfg.Replicas, cfg.ModelMap)
 			}
 
-			err := s.Delete(tt.args.databaseName, tt.tableName, tt.args.fields, tt.args.data)
+			err := s.Delete(context.Background(), tt.args.databaseName, tt.tableName, tt.args.fields, tt.args.data)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("pgStore.Delete() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -580,8 +581,8 @@ func Test_pgStore_Create(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewPGStore(cfg.DBs(), cfg.ModelMap)
-			got, err := s.Create(tt.args.databaseName, tt.tableName, tt.args.data)
+			s := NewPGStore(cfg.DBs, cfg.Replicas, cfg.ModelMap)
+			got, err := s.Create(context.Background(), tt.args.databaseName, tt.tableName, tt.args.data)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("pgStore.Create() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -711,8 +712,8 @@ func Test_pgStore_Update(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewPGStore(cfg.DBs(), cfg.ModelMap)
-			got, err := s.Update(tt.args.databaseName, tt.tableName, tt.args.d)
+			s := NewPGStore(cfg.DBs, cfg.Replicas, cfg.ModelMap)
+			got, err := s.Update(context.Background(), tt.args.databaseName, tt.tableName, tt.args.d)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("pgStore.Update() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -784,8 +785,8 @@ func Test_pgStore_RawQuery(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewPGStore(cfg.DBs(), cfg.ModelMap)
-			_, _, got, err := s.RawQuery(tt.args.dbName, tt.args.sql)
+			s := NewPGStore(cfg.DBs, cfg.Replicas, cfg.ModelMap)
+			_, _, got, err := s.RawQuery(context.Background(), tt.args.dbName, tt.args.sql)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("pgStore.RawQuery() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -0,0 +1,47 @@
+package drivers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// managedTimestampColumns return created_at/updated_at columns of table managed by server
+func (s *modelStore) managedTimestampColumns(dbName, tableName string) []string {
+	m := s.modelMap[dbName][tableName]
+	if !m.ManagedTimestamps {
+		return nil
+	}
+
+	res := []string{}
+	colNames := database.Columns(m.Columns).Names()
+	for _, col := range []string{database.CreatedAtColumn, database.UpdatedAtColumn} {
+		if err := checkColumnFieldIsValid(colNames, col); err == nil {
+			res = append(res, col)
+		}
+	}
+
+	return res
+}
+
+// setManagedTimestamps reject client values of managed timestamp columns,
+// then fill setColumns of them with current time
+func (s *modelStore) setManagedTimestamps(dbName, tableName string, row sqlmapper.RowData, setColumns ...string) error {
+	managed := s.managedTimestampColumns(dbName, tableName)
+	for _, col := range managed {
+		if _, ok := row[col]; ok {
+			return fmt.Errorf("column %s of table %s is managed by server, it can't be set", col, tableName)
+		}
+	}
+
+	now := time.Now()
+	for _, col := range setColumns {
+		if err := checkColumnFieldIsValid(managed, col); err == nil {
+			row[col] = sqlmapper.ColData{Data: now}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// operationHooks are run around each operation of a transaction, nil hooks are skipped.
+// ctx of hooks carries the transaction, so db_* calls of hooks run in it
+type operationHooks struct {
+	before func(ctx context.Context, op sqlmapper.Operation, row sqlmapper.RowData) (sqlmapper.RowData, error)
+	after  func(ctx context.Context, op sqlmapper.Operation, row sqlmapper.RowData) (sqlmapper.RowData, error)
+}
+
+// transactor is implemented by stores can run operations with hooks in a transaction
+type transactor interface {
+	transaction(ctx context.Context, dbName string, ops []sqlmapper.Operation, hooks operationHooks) ([]sqlmapper.RowData, error)
+}
+
+func (s *pgStore) Transaction(ctx context.Context, dbName string, ops []sqlmapper.Operation) ([]sqlmapper.RowData, error) {
+	return s.transaction(ctx, dbName, ops, operationHooks{})
+}
+
+func (s *pgStore) transaction(ctx context.Context, dbName string, ops []sqlmapper.Operation, hooks operationHooks) ([]sqlmapper.RowData, error) {
+	if _, ok := s.db()[dbName]; !ok {
+		return nil, fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	sqlmapper.MarkWritten(ctx, dbName)
+	tx, err := s.db()[dbName].DB().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx = sqlmapper.WithTx(ctx, dbName, tx)
+
+	results := []sqlmapper.RowData{}
+	for i, op := range ops {
+		res, err := s.runOperation(ctx, tx, dbName, op, results, hooks)
+		if err != nil {
+			if errRollBack := tx.Rollback(); errRollBack != nil {
+				return nil, errRollBack
+			}
+			return nil, sqlmapper.OperationError{Index: i, Type: op.Type, TableName: op.TableName, Err: err}
+		}
+		results = append(results, res)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (s *pgStore) runOperation(ctx context.Context, tx *sql.Tx, dbName string, op sqlmapper.Operation, results []sqlmapper.RowData, hooks operationHooks) (sqlmapper.RowData, error) {
+	data, err := sqlmapper.ResolveRefs(op.Data, results)
+	if err != nil {
+		return nil, err
+	}
+
+	var row sqlmapper.RowData
+	if op.Type != sqlmapper.OperationDelete {
+		row, err = sqlmapper.MakeRowData(op.Fields, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if hooks.before != nil {
+		if row, err = hooks.before(ctx, op, row); err != nil {
+			return nil, err
+		}
+	}
+
+	switch op.Type {
+	case sqlmapper.OperationCreate:
+		err = s.create(ctx, tx, dbName, op.TableName, row)
+	case sqlmapper.OperationUpdate:
+		// primary columns are kept in result, so a later operation can reference to them
+		keys := pickColumns(row, primaryColumnNames(s.modelMap[dbName][op.TableName]))
+		err = s.update(ctx, tx, dbName, op.TableName, row)
+		for colName, colData := range keys {
+			row[colName] = colData
+		}
+	case sqlmapper.OperationDelete:
+		err = s.delete(ctx, tx, dbName, op.TableName, op.Fields, data)
+	default:
+		err = fmt.Errorf("unknown operation type %q", op.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if hooks.after != nil {
+		return hooks.after(ctx, op, row)
+	}
+
+	return row, nil
+}
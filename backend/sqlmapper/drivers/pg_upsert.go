@@ -0,0 +1,210 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// getConflictColumns return columns used as conflict target of an upsert, primary columns are used by default
+func getConflictColumns(m database.Model, conflictColumns []string) ([]string, error) {
+	if len(conflictColumns) == 0 {
+		for _, col := range m.Columns {
+			if col.IsPrimary {
+				conflictColumns = append(conflictColumns, col.Name)
+			}
+		}
+	}
+
+	if len(conflictColumns) == 0 {
+		return nil, fmt.Errorf("table %s doesn't have primary key, conflict_columns is required", m.TableName)
+	}
+
+	colNames := database.Columns(m.Columns).Stored().Names()
+	for _, col := range conflictColumns {
+		if err := checkColumnFieldIsValid(colNames, col); err != nil {
+			return nil, err
+		}
+	}
+
+	return conflictColumns, nil
+}
+
+// upserter is implemented by stores can run an upsert with hooks in its transaction
+type upserter interface {
+	upsert(ctx context.Context, dbName, tableName string, row sqlmapper.RowData, conflictColumns []string, hooks operationHooks) (sqlmapper.RowData, string, error)
+}
+
+// conflictKey return values of conflict columns of a row of an upsert
+func (s *modelStore) conflictKey(dbName, tableName string, row sqlmapper.RowData, conflictColumns []string) (sqlmapper.RowData, error) {
+	m, ok := s.modelMap[dbName][tableName]
+	if !ok {
+		return nil, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	conflictColumns, err := getConflictColumns(m, conflictColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(row.RelateData()) > 0 {
+		return nil, errors.New("upsert doesn't support relate-data")
+	}
+
+	if len(primaryColumnNames(m)) == 0 {
+		return nil, fmt.Errorf("table %s doesn't have primary key, its rows can't be updated by upsert", tableName)
+	}
+
+	key := pickColumns(row, conflictColumns)
+	for _, col := range conflictColumns {
+		if _, ok := key[col]; !ok {
+			return nil, fmt.Errorf("missing value of conflict column %s", col)
+		}
+	}
+
+	return key, nil
+}
+
+func (s *pgStore) Upsert(ctx context.Context, dbName, tableName string, row sqlmapper.RowData, conflictColumns []string) (sqlmapper.RowData, string, error) {
+	return s.upsert(ctx, dbName, tableName, row, conflictColumns, operationHooks{})
+}
+
+// upsert update the row having values of conflict columns of row, or create row when it isn't existed.
+// The existing row is locked until the write ends, a row inserted by another transaction meanwhile
+// makes the create fail on its unique constraint instead of being overwritten. Hooks of the path
+// taken are run in the same transaction
+func (s *pgStore) upsert(ctx context.Context, dbName, tableName string, row sqlmapper.RowData, conflictColumns []string, hooks operationHooks) (sqlmapper.RowData, string, error) {
+	if _, ok := s.db()[dbName]; !ok {
+		return nil, "", fmt.Errorf("uknown database_name %s", dbName)
+	}
+
+	key, err := s.conflictKey(dbName, tableName, row, conflictColumns)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sqlmapper.MarkWritten(ctx, dbName)
+	tx, err := s.db()[dbName].DB().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	ctx = sqlmapper.WithTx(ctx, dbName, tx)
+
+	primaryKeyMap, deleted, err := s.lockConflictedRow(ctx, tx, dbName, tableName, key)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", err
+	}
+
+	row, action, err := upsertRow(ctx, s.modelMap[dbName][tableName], row, primaryKeyMap, deleted, hooks, operationWriter{
+		create: func(row sqlmapper.RowData) error { return s.create(ctx, tx, dbName, tableName, row) },
+		update: func(row sqlmapper.RowData) error { return s.update(ctx, tx, dbName, tableName, row) },
+	})
+	if err != nil {
+		if errRollBack := tx.Rollback(); errRollBack != nil {
+			return nil, "", errRollBack
+		}
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+
+	return row, action, nil
+}
+
+// lockConflictedRow lock the row matching key until transaction end, its primary key is returned,
+// nil is returned when no row matches. deleted tell the row is soft-deleted
+func (s *pgStore) lockConflictedRow(ctx context.Context, tx *sql.Tx, dbName, tableName string, key sqlmapper.RowData) (sqlmapper.RowData, bool, error) {
+	pks := primaryColumnNames(s.modelMap[dbName][tableName])
+	selects := append([]string{}, pks...)
+	if s.isSoftDelete(dbName, tableName) {
+		selects = append(selects, database.SoftDeleteColumn+" IS NOT NULL")
+	} else {
+		selects = append(selects, "false")
+	}
+
+	values := make([]interface{}, len(pks))
+	pointers := make([]interface{}, len(pks)+1)
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	var deleted bool
+	pointers[len(pks)] = &deleted
+
+	where, args := primaryKeyCondition(key)
+	err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s LIMIT 1 FOR UPDATE", strings.Join(selects, ", "), tableName, where), args...).Scan(pointers...)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	res := make(sqlmapper.RowData)
+	for i, col := range pks {
+		res[col] = sqlmapper.ColData{Data: normalizeScanned(values[i])}
+	}
+
+	return res, deleted, nil
+}
+
+// operationWriter write a row of an upsert in its transaction
+type operationWriter struct {
+	create func(row sqlmapper.RowData) error
+	update func(row sqlmapper.RowData) error
+}
+
+// upsertRow create row, or update the existing row having primaryKeyMap like an update: its version
+// is checked and bumped, a soft-deleted row is restored. Hooks of the write are run around it
+func upsertRow(ctx context.Context, m database.Model, row, primaryKeyMap sqlmapper.RowData, deleted bool, hooks operationHooks, w operationWriter) (sqlmapper.RowData, string, error) {
+	op := sqlmapper.Operation{Type: sqlmapper.OperationCreate, TableName: m.TableName}
+	action := sqlmapper.UpsertInserted
+	if primaryKeyMap != nil {
+		op.Type, action = sqlmapper.OperationUpdate, sqlmapper.UpsertUpdated
+	}
+
+	var err error
+	if hooks.before != nil {
+		if row, err = hooks.before(ctx, op, row); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if op.Type == sqlmapper.OperationCreate {
+		// expected version is only checked by an update, a new row starts at version 1
+		if m.VersionColumn != "" {
+			delete(row, m.VersionColumn)
+		}
+		err = w.create(row)
+	} else {
+		for colName, colData := range primaryKeyMap {
+			row[colName] = colData
+		}
+		if deleted {
+			row[database.SoftDeleteColumn] = sqlmapper.ColData{Data: nil}
+		}
+		err = w.update(row)
+		// primary columns are kept in result like a create
+		for colName, colData := range primaryKeyMap {
+			row[colName] = colData
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if hooks.after != nil {
+		if row, err = hooks.after(ctx, op, row); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return row, action, nil
+}
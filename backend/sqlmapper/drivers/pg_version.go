@@ -0,0 +1,67 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+)
+
+// checkAndBumpVersion increase version of a row if it still has the version sent by client,
+// otherwise a ConflictError with current row is returned. Expected version is removed from row
+func (s *pgStore) checkAndBumpVersion(ctx context.Context, tx *sql.Tx, dbName, tableName string, row, primaryKeyMap sqlmapper.RowData) (interface{}, error) {
+	versionColumn := s.modelMap[dbName][tableName].VersionColumn
+	expected, ok := row[versionColumn]
+	if !ok {
+		return nil, fmt.Errorf("missing expected version, set column %s or If-Match header", versionColumn)
+	}
+	delete(row, versionColumn)
+
+	params := []string{fmt.Sprintf("%s = $1", versionColumn)}
+	data := []interface{}{expected.Data}
+	for colName, colData := range primaryKeyMap {
+		data = append(data, colData.Data)
+		params = append(params, fmt.Sprintf("%s = $%d", colName, len(data)))
+	}
+
+	var version interface{}
+	err := tx.QueryRowContext(ctx, fmt.Sprintf("UPDATE %s SET %s = %s + 1 WHERE %s RETURNING %s",
+		tableName,
+		versionColumn,
+		versionColumn,
+		strings.Join(params, " AND "),
+		versionColumn), data...).Scan(&version)
+	if err == sql.ErrNoRows {
+		// current row is read in tx, a row deleted before the update is reported as missing instead of a conflict
+		current, err := s.findRow(ctx, tx, dbName, tableName, primaryKeyMap)
+		if err == sql.ErrNoRows {
+			return nil, errPrimaryKeyNotExist
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, sqlmapper.ConflictError{TableName: tableName, Current: current}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return normalizeScanned(version), nil
+}
+
+// findRow return all stored columns of a row by its primary key, sql.ErrNoRows is returned when it doesn't exist
+func (s *pgStore) findRow(ctx context.Context, db sqlQueryer, dbName, tableName string, primaryKeyMap sqlmapper.RowData) (sqlmapper.RowData, error) {
+	where, args := primaryKeyCondition(primaryKeyMap)
+	rows, err := s.selectRows(ctx, db, dbName, tableName, where, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return rows[0], nil
+}
@@ -0,0 +1,50 @@
+package drivers
+
+import (
+	"context"
+
+	"github.com/dwarvesf/smithy/backend/sqlmapper"
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// VerifyRow check a row of model the way create, or update when update is true, checks it before
+// writing, so a dry run reports the errors a write would return. row isn't changed and nothing is written
+func VerifyRow(ctx context.Context, dbName string, model database.Model, row sqlmapper.RowData, update bool) error {
+	s := &modelStore{modelMap: map[string]map[string]database.Model{dbName: {model.TableName: model}}}
+	tableName := model.TableName
+
+	d := sqlmapper.RowData{}
+	for k, v := range row {
+		d[k] = v
+	}
+
+	if update {
+		invalid := s.invalidFields(dbName, tableName, d, "")
+		if err := s.setManagedTimestamps(dbName, tableName, d, database.UpdatedAtColumn); err != nil {
+			return err
+		}
+		if err := withInvalidFields(verifyUpdateInput(ctx, d, dbName, tableName, s.modelMap[dbName]), invalid); err != nil {
+			return err
+		}
+		return checkRowValues(ctx, dbName, tableName, d)
+	}
+
+	s.clearGeneratedPrimaryKey(dbName, tableName, d)
+	if err := setRowFilterValues(ctx, dbName, tableName, d); err != nil {
+		return err
+	}
+	invalid := s.invalidFields(dbName, tableName, d, "")
+	if err := s.setManagedTimestamps(dbName, tableName, d, database.CreatedAtColumn, database.UpdatedAtColumn); err != nil {
+		return err
+	}
+	if model.VersionColumn != "" {
+		if _, ok := d[model.VersionColumn]; !ok {
+			d[model.VersionColumn] = sqlmapper.ColData{Data: 1}
+		}
+	}
+	if err := withInvalidFields(verifyInput(ctx, d, dbName, tableName, s.modelMap[dbName]), invalid); err != nil {
+		return err
+	}
+
+	return checkRowValues(ctx, dbName, tableName, d)
+}
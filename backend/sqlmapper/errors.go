@@ -0,0 +1,21 @@
+package sqlmapper
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ConflictError is returned when a row was modified after the version the client read
+type ConflictError struct {
+	TableName string
+	Current   RowData // current row in database
+}
+
+func (e ConflictError) Error() string {
+	return fmt.Sprintf("row of table %s was modified by another request", e.TableName)
+}
+
+// StatusCode return status 409 for a conflict
+func (ConflictError) StatusCode() int {
+	return http.StatusConflict
+}
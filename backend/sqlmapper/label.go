@@ -0,0 +1,44 @@
+package sqlmapper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// LabelSuffix is appended to a foreign key column to name its label in query results, ex: author_id.label
+const LabelSuffix = ".label"
+
+// LabelSource return referenced table of a foreign key column, its referenced column and column
+// labeling its rows, the name_display_column of referenced table
+func LabelSource(models map[string]database.Model, tableName, column string) (database.ForeignKey, string, error) {
+	m, ok := models[tableName]
+	if !ok {
+		return database.ForeignKey{}, "", fmt.Errorf("table %s doesn't exist", tableName)
+	}
+
+	for _, col := range m.Columns {
+		if col.Name != column {
+			continue
+		}
+
+		if col.ForeignKey.Table == "" || col.ForeignKey.ForeignColumn == "" {
+			return database.ForeignKey{}, "", fmt.Errorf("column %s of table %s isn't a foreign key", column, tableName)
+		}
+
+		ref, ok := models[col.ForeignKey.Table]
+		if !ok || ref.NameDisplayColumn == "" {
+			return database.ForeignKey{}, "", fmt.Errorf("table %s has no name_display_column to label column %s", col.ForeignKey.Table, column)
+		}
+
+		return col.ForeignKey, ref.NameDisplayColumn, nil
+	}
+
+	return database.ForeignKey{}, "", fmt.Errorf("unknown field %s ", column)
+}
+
+// EscapeLike escape wildcards of s, so it is matched literally in a LIKE pattern
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
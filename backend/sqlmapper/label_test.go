@@ -0,0 +1,49 @@
+package sqlmapper
+
+import (
+	"testing"
+
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+func TestLabelSource(t *testing.T) {
+	models := map[string]database.Model{
+		"books": {TableName: "books", Columns: []database.Column{
+			{Name: "id", IsPrimary: true},
+			{Name: "author_id", ForeignKey: database.ForeignKey{Table: "authors", ForeignColumn: "id"}},
+			{Name: "shelf_id", ForeignKey: database.ForeignKey{Table: "shelves", ForeignColumn: "id"}},
+		}},
+		"authors": {TableName: "authors", NameDisplayColumn: "name"},
+		"shelves": {TableName: "shelves"},
+	}
+
+	tests := []struct {
+		name      string
+		column    string
+		wantTable string
+		wantLabel string
+		wantErr   bool
+	}{
+		{name: "foreign key", column: "author_id", wantTable: "authors", wantLabel: "name"},
+		{name: "referenced table without name_display_column", column: "shelf_id", wantErr: true},
+		{name: "not a foreign key", column: "id", wantErr: true},
+		{name: "unknown column", column: "title", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fk, label, err := LabelSource(models, "books", tt.column)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LabelSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if fk.Table != tt.wantTable || label != tt.wantLabel {
+				t.Errorf("LabelSource() = %s, %s, want %s, %s", fk.Table, label, tt.wantTable, tt.wantLabel)
+			}
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got, want := EscapeLike(`50%_off\`), `50\%\_off\\`; got != want {
+		t.Errorf("EscapeLike() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package sqlmapper
+
+import (
+	"context"
+
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+type maskKey struct{}
+
+// WithMask return a context whose reads have values of masked columns masked,
+// masked columns can't be written in it
+func WithMask(ctx context.Context) context.Context {
+	return context.WithValue(ctx, maskKey{}, true)
+}
+
+// IsMasked check masked columns are masked in ctx
+func IsMasked(ctx context.Context) bool {
+	masked, _ := ctx.Value(maskKey{}).(bool)
+	return masked
+}
+
+// MaskColumns return masked columns of fields in ctx by index of field, nil is returned
+// when no field is masked
+func MaskColumns(ctx context.Context, columns []database.Column, fields []string) []*database.Column {
+	if !IsMasked(ctx) {
+		return nil
+	}
+
+	colMap := database.Columns(columns).GroupByName()
+	res := make([]*database.Column, len(fields))
+	masked := false
+	for i, f := range fields {
+		if cols, ok := colMap[f]; ok && cols[0].Mask != "" {
+			res[i] = &cols[0]
+			masked = true
+		}
+	}
+	if !masked {
+		return nil
+	}
+
+	return res
+}
+
+// MaskRow mask values of row in place, row[i] is masked by masks[i]
+func MaskRow(masks []*database.Column, row []interface{}) {
+	for i, c := range masks {
+		if c != nil && i < len(row) {
+			row[i] = c.MaskValue(row[i])
+		}
+	}
+}
+
+// CheckMaskedQuery reject a filter or an order of q on a masked column in ctx,
+// they would reveal masked values. columns are columns of source table of q
+func CheckMaskedQuery(ctx context.Context, columns []database.Column, q Query) error {
+	fields := []string{}
+	if !q.Filter.IsZero() {
+		fields = append(fields, q.Filter.ColumnName)
+	}
+	if len(q.Order) > 0 {
+		fields = append(fields, q.Order[0])
+	}
+
+	for i, c := range MaskColumns(ctx, columns, fields) {
+		if c != nil {
+			return ForbiddenColumnError{TableName: q.SourceTable, Column: fields[i], Action: "filtered or sorted"}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,524 @@
+// Package memory keep rows of databases in memory, tables and columns are taken from a model map.
+// It is used to run the dashboard without a database, ex: in tests and demos
+package memory
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// Row a row of a table by column name, a column missing in row is null
+type Row map[string]interface{}
+
+func (r Row) copy() Row {
+	res := make(Row, len(r))
+	for k, v := range r {
+		res[k] = v
+	}
+
+	return res
+}
+
+type table struct {
+	rows []Row
+	seq  map[string]int64 // last generated value of generated integer primary columns
+}
+
+// DB in-memory databases of a model map. Primary keys and foreign keys of models are enforced,
+// generated integer primary columns without value are generated. It is safe for concurrent use
+type DB struct {
+	mu       sync.RWMutex
+	modelMap map[string]map[string]database.Model
+	tables   map[string]*table // by tableKey
+}
+
+// New create empty databases of modelMap, modelMap is read on every call, so models updated
+// in place are used
+func New(modelMap map[string]map[string]database.Model) *DB {
+	return &DB{
+		modelMap: modelMap,
+		tables:   make(map[string]*table),
+	}
+}
+
+func tableKey(dbName, tableName string) string {
+	return dbName + "." + tableName
+}
+
+// Model return model of a table
+func (db *DB) Model(dbName, tableName string) (database.Model, error) {
+	m, ok := db.modelMap[dbName][tableName]
+	if !ok {
+		return database.Model{}, fmt.Errorf("uknown database_name/table_name %s/%s", dbName, tableName)
+	}
+
+	return m, nil
+}
+
+func (db *DB) table(dbName, tableName string) *table {
+	t, ok := db.tables[tableKey(dbName, tableName)]
+	if !ok {
+		t = &table{seq: make(map[string]int64)}
+		db.tables[tableKey(dbName, tableName)] = t
+	}
+
+	return t
+}
+
+// Begin start a transaction, writes of a transaction are seen by others immediately
+// and undone when it is rolled back
+func (db *DB) Begin() *Tx {
+	return &Tx{db: db, logs: [][]undo{{}}}
+}
+
+// Select return copies of rows of a table matching match, all rows are returned when match is nil.
+// Rows are in insertion order
+func (db *DB) Select(dbName, tableName string, match func(Row) bool) ([]Row, error) {
+	return db.autoCommit().Select(dbName, tableName, match)
+}
+
+// Insert insert a row, generated primary keys are returned in a copy of row
+func (db *DB) Insert(dbName, tableName string, row Row) (Row, error) {
+	return db.autoCommit().Insert(dbName, tableName, row)
+}
+
+// Update set values of rows matching match, number of updated rows is returned
+func (db *DB) Update(dbName, tableName string, match func(Row) bool, values Row) (int, error) {
+	return db.autoCommit().Update(dbName, tableName, match, values)
+}
+
+// Delete delete rows matching match, number of deleted rows is returned
+func (db *DB) Delete(dbName, tableName string, match func(Row) bool) (int, error) {
+	return db.autoCommit().Delete(dbName, tableName, match)
+}
+
+// autoCommit return a transaction whose writes can't be rolled back
+func (db *DB) autoCommit() *Tx {
+	return &Tx{db: db}
+}
+
+// Tx a transaction of DB. Each row it writes is logged, rollback undo only those rows,
+// so rows written by others meanwhile are kept
+type Tx struct {
+	db   *DB
+	logs [][]undo // one log for transaction and each savepoint
+}
+
+// kinds of write of an undo
+const (
+	undoInsert = iota
+	undoUpdate
+	undoDelete
+)
+
+// undo revert a write of a row
+type undo struct {
+	kind  int
+	table *table
+	row   Row // written row, rows are found by identity
+	old   Row // values of an updated row before update
+	index int // position of a deleted row after rows deleted before it
+}
+
+// log record an undo in last savepoint, db.mu must be locked
+func (tx *Tx) log(u undo) {
+	if len(tx.logs) == 0 {
+		return
+	}
+
+	tx.logs[len(tx.logs)-1] = append(tx.logs[len(tx.logs)-1], u)
+}
+
+// undo revert writes of log, latest first
+func (tx *Tx) undo(log []undo) {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	for i := len(log) - 1; i >= 0; i-- {
+		u := log[i]
+		switch u.kind {
+		case undoInsert:
+			for j, r := range u.table.rows {
+				if isSameRow(r, u.row) {
+					u.table.rows = append(u.table.rows[:j:j], u.table.rows[j+1:]...)
+					break
+				}
+			}
+		case undoUpdate:
+			for k := range u.row {
+				delete(u.row, k)
+			}
+			for k, v := range u.old {
+				u.row[k] = v
+			}
+		case undoDelete:
+			idx := u.index
+			if idx > len(u.table.rows) {
+				idx = len(u.table.rows)
+			}
+			rows := append([]Row{}, u.table.rows[:idx]...)
+			rows = append(rows, u.row)
+			u.table.rows = append(rows, u.table.rows[idx:]...)
+		}
+	}
+}
+
+// Savepoint start a savepoint, writes after it can be rolled back by RollbackSavepoint
+func (tx *Tx) Savepoint() {
+	tx.logs = append(tx.logs, []undo{})
+}
+
+// RollbackSavepoint undo writes after last savepoint and remove it
+func (tx *Tx) RollbackSavepoint() {
+	if len(tx.logs) < 2 {
+		return
+	}
+
+	tx.undo(tx.logs[len(tx.logs)-1])
+	tx.logs = tx.logs[:len(tx.logs)-1]
+}
+
+// ReleaseSavepoint remove last savepoint, its writes are kept in transaction
+func (tx *Tx) ReleaseSavepoint() {
+	if len(tx.logs) < 2 {
+		return
+	}
+
+	last := tx.logs[len(tx.logs)-1]
+	tx.logs = tx.logs[:len(tx.logs)-1]
+	tx.logs[len(tx.logs)-1] = append(tx.logs[len(tx.logs)-1], last...)
+}
+
+// Commit keep writes of transaction
+func (tx *Tx) Commit() {
+	tx.logs = nil
+}
+
+// Rollback undo all writes of transaction, generated primary keys aren't reused like sequences of postgres
+func (tx *Tx) Rollback() {
+	if len(tx.logs) == 0 {
+		return
+	}
+
+	for i := len(tx.logs) - 1; i >= 0; i-- {
+		tx.undo(tx.logs[i])
+	}
+	tx.logs = nil
+}
+
+// Select return copies of rows of a table matching match, all rows are returned when match is nil
+func (tx *Tx) Select(dbName, tableName string, match func(Row) bool) ([]Row, error) {
+	if _, err := tx.db.Model(dbName, tableName); err != nil {
+		return nil, err
+	}
+
+	tx.db.mu.RLock()
+	defer tx.db.mu.RUnlock()
+
+	res := []Row{}
+	if t, ok := tx.db.tables[tableKey(dbName, tableName)]; ok {
+		for _, r := range t.rows {
+			if match == nil || match(r) {
+				res = append(res, r.copy())
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Insert insert a row, generated primary keys are returned in a copy of row
+func (tx *Tx) Insert(dbName, tableName string, row Row) (Row, error) {
+	m, err := tx.db.Model(dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := makeRow(m, row)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	t := tx.db.table(dbName, tableName)
+	for _, col := range m.Columns {
+		if !col.IsPrimary || r[col.Name] != nil {
+			continue
+		}
+		if !col.IsGenerated || col.Type != "int" {
+			return nil, fmt.Errorf("null value in primary column %s of table %s", col.Name, tableName)
+		}
+		r[col.Name] = t.nextID(col.Name)
+	}
+
+	if err := tx.checkPrimaryKey(m, t, r, nil); err != nil {
+		return nil, err
+	}
+	if err := tx.checkForeignKeys(dbName, m, r); err != nil {
+		return nil, err
+	}
+
+	t.rows = append(t.rows, r)
+	tx.log(undo{kind: undoInsert, table: t, row: r})
+
+	return r.copy(), nil
+}
+
+// nextID generate a value of an integer primary column greater than all its values
+func (t *table) nextID(column string) int64 {
+	id := t.seq[column]
+	for _, r := range t.rows {
+		if n, ok := toFloat(r[column]); ok && int64(n) > id {
+			id = int64(n)
+		}
+	}
+	t.seq[column] = id + 1
+
+	return id + 1
+}
+
+// Update set values of rows matching match, number of updated rows is returned
+func (tx *Tx) Update(dbName, tableName string, match func(Row) bool, values Row) (int, error) {
+	m, err := tx.db.Model(dbName, tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := makeRow(m, values)
+	if err != nil {
+		return 0, err
+	}
+
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	if err := tx.checkForeignKeys(dbName, m, v); err != nil {
+		return 0, err
+	}
+
+	t := tx.db.table(dbName, tableName)
+	updated := []Row{}
+	for _, r := range t.rows {
+		if match == nil || match(r) {
+			updated = append(updated, r)
+		}
+	}
+	if len(updated) == 0 {
+		return 0, nil
+	}
+
+	for _, r := range updated {
+		next := r.copy()
+		for k, val := range v {
+			next[k] = val
+		}
+		if err := tx.checkPrimaryKey(m, t, next, r); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, r := range updated {
+		tx.log(undo{kind: undoUpdate, table: t, row: r, old: r.copy()})
+		for k, val := range v {
+			r[k] = val
+		}
+	}
+
+	return len(updated), nil
+}
+
+// Delete delete rows matching match, number of deleted rows is returned.
+// Rows referenced by foreign keys of other rows can't be deleted
+func (tx *Tx) Delete(dbName, tableName string, match func(Row) bool) (int, error) {
+	if _, err := tx.db.Model(dbName, tableName); err != nil {
+		return 0, err
+	}
+
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	t := tx.db.table(dbName, tableName)
+	kept, deleted := []Row{}, []Row{}
+	logs := []undo{}
+	for _, r := range t.rows {
+		if match == nil || match(r) {
+			deleted = append(deleted, r)
+			logs = append(logs, undo{kind: undoDelete, table: t, row: r, index: len(kept)})
+			continue
+		}
+		kept = append(kept, r)
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+
+	if err := tx.checkReferences(dbName, tableName, deleted); err != nil {
+		return 0, err
+	}
+
+	t.rows = kept
+	for _, u := range logs {
+		tx.log(u)
+	}
+
+	return len(deleted), nil
+}
+
+// makeRow convert values of row to types of columns, unknown and computed columns are rejected
+func makeRow(m database.Model, row Row) (Row, error) {
+	cols := make(map[string]database.Column)
+	for _, col := range m.Columns {
+		cols[col.Name] = col
+	}
+
+	res := make(Row)
+	for k, v := range row {
+		col, ok := cols[k]
+		if !ok {
+			return nil, fmt.Errorf("column %s of table %s does not exist", k, m.TableName)
+		}
+		if col.IsComputed() {
+			return nil, fmt.Errorf("column %s of table %s is computed, it can't be set", k, m.TableName)
+		}
+
+		val, err := convertValue(v, col)
+		if err != nil {
+			return nil, err
+		}
+		res[k] = val
+	}
+
+	return res, nil
+}
+
+// primaryColumns return names of primary columns of m
+func primaryColumns(m database.Model) []string {
+	res := []string{}
+	for _, col := range m.Columns {
+		if col.IsPrimary {
+			res = append(res, col.Name)
+		}
+	}
+
+	return res
+}
+
+// checkPrimaryKey reject row when another row of table has the same primary key,
+// self is the row being updated. db.mu must be locked
+func (tx *Tx) checkPrimaryKey(m database.Model, t *table, row, self Row) error {
+	pk := primaryColumns(m)
+	if len(pk) == 0 {
+		return nil
+	}
+
+	for _, r := range t.rows {
+		if self != nil && isSameRow(r, self) {
+			continue
+		}
+
+		duplicated := true
+		for _, col := range pk {
+			if !Equal(r[col], row[col]) {
+				duplicated = false
+				break
+			}
+		}
+		if duplicated {
+			return fmt.Errorf("duplicate key value violates primary key of table %s", m.TableName)
+		}
+	}
+
+	return nil
+}
+
+// isSameRow check 2 rows are the same map
+func isSameRow(a, b Row) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// checkForeignKeys reject values of foreign key columns not referencing any row, tables
+// not in model map aren't checked. db.mu must be locked
+func (tx *Tx) checkForeignKeys(dbName string, m database.Model, row Row) error {
+	for _, col := range m.Columns {
+		fk := col.ForeignKey
+		v, ok := row[col.Name]
+		if !ok || v == nil || fk.Table == "" || fk.ForeignColumn == "" {
+			continue
+		}
+		if _, ok := tx.db.modelMap[dbName][fk.Table]; !ok {
+			continue
+		}
+
+		found := false
+		if t, ok := tx.db.tables[tableKey(dbName, fk.Table)]; ok {
+			for _, r := range t.rows {
+				if Equal(r[fk.ForeignColumn], v) {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("insert or update on table %s violates foreign key of column %s: %v is not present in table %s", m.TableName, col.Name, v, fk.Table)
+		}
+	}
+
+	return nil
+}
+
+// checkReferences reject deleting rows referenced by foreign keys of other rows. db.mu must be locked
+func (tx *Tx) checkReferences(dbName, tableName string, deleted []Row) error {
+	names := []string{}
+	for name := range tx.db.modelMap[dbName] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t, ok := tx.db.tables[tableKey(dbName, name)]
+		if !ok {
+			continue
+		}
+
+		for _, col := range tx.db.modelMap[dbName][name].Columns {
+			fk := col.ForeignKey
+			if fk.Table != tableName || fk.ForeignColumn == "" {
+				continue
+			}
+
+			keys := make(map[string]bool)
+			for _, r := range deleted {
+				if r[fk.ForeignColumn] != nil {
+					keys[Key(r[fk.ForeignColumn])] = true
+				}
+			}
+
+			for _, r := range t.rows {
+				if name == tableName && containsRow(deleted, r) {
+					continue
+				}
+				if r[col.Name] != nil && keys[Key(r[col.Name])] {
+					return fmt.Errorf("delete on table %s violates foreign key of column %s of table %s", tableName, col.Name, name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func containsRow(rows []Row, row Row) bool {
+	for _, r := range rows {
+		if isSameRow(r, row) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+var testModelMap = map[string]map[string]database.Model{
+	"fortress": {
+		"users": {
+			TableName: "users",
+			Columns: []database.Column{
+				{Name: "id", Type: "int", IsPrimary: true, IsGenerated: true},
+				{Name: "name", Type: "string"},
+				{Name: "age", Type: "int", IsNullable: true},
+			},
+		},
+		"books": {
+			TableName: "books",
+			Columns: []database.Column{
+				{Name: "id", Type: "int", IsPrimary: true, IsGenerated: true},
+				{Name: "user_id", Type: "int", ForeignKey: database.ForeignKey{Table: "users", ForeignColumn: "id"}},
+			},
+		},
+	},
+}
+
+func newTestDB(t *testing.T) *DB {
+	db := New(testModelMap)
+	for _, r := range []Row{
+		{"name": "ann", "age": 30},
+		{"name": "bob", "age": "25"},
+		{"name": "Cat"},
+	} {
+		if _, err := db.Insert("fortress", "users", r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Insert("fortress", "books", Row{"user_id": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		wantCols []string
+		want     [][]interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "comparison and order",
+			sql:      "SELECT id, name AS n FROM users WHERE age >= 25 ORDER BY age DESC",
+			wantCols: []string{"id", "n"},
+			want:     [][]interface{}{{int64(1), "ann"}, {int64(2), "bob"}},
+		},
+		{
+			name:     "in, ilike and null",
+			sql:      "SELECT name FROM users WHERE id IN (2, 3) AND (name ILIKE 'c%' OR age IS NULL);",
+			wantCols: []string{"name"},
+			want:     [][]interface{}{{"Cat"}},
+		},
+		{
+			name:     "limit and offset",
+			sql:      "SELECT * FROM users WHERE NOT name = 'ann' LIMIT 1 OFFSET 1",
+			wantCols: []string{"id", "name", "age"},
+			want:     [][]interface{}{{int64(3), "Cat", nil}},
+		},
+		{
+			name:    "unknown column",
+			sql:     "SELECT email FROM users",
+			wantErr: true,
+		},
+		{
+			name:    "only select is supported",
+			sql:     "DELETE FROM users",
+			wantErr: true,
+		},
+	}
+
+	db := newTestDB(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSelect(tt.sql)
+			if err == nil {
+				var cols []string
+				var rows [][]interface{}
+				cols, rows, err = db.Run("fortress", s)
+				if err == nil && (!reflect.DeepEqual(cols, tt.wantCols) || !reflect.DeepEqual(rows, tt.want)) {
+					t.Errorf("Run() = %v %v, want %v %v", cols, rows, tt.wantCols, tt.want)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTx(t *testing.T) {
+	db := newTestDB(t)
+
+	if _, err := db.Delete("fortress", "users", func(r Row) bool { return Equal(r["id"], 1) }); err == nil {
+		t.Error("Delete() of a referenced row should fail")
+	}
+	if _, err := db.Insert("fortress", "users", Row{"id": 2, "name": "dan"}); err == nil {
+		t.Error("Insert() of a duplicate primary key should fail")
+	}
+
+	tx := db.Begin()
+	if _, err := tx.Insert("fortress", "users", Row{"name": "dan"}); err != nil {
+		t.Fatal(err)
+	}
+	tx.Savepoint()
+	if _, err := tx.Update("fortress", "users", nil, Row{"age": 1}); err != nil {
+		t.Fatal(err)
+	}
+	tx.RollbackSavepoint()
+
+	rows, _ := tx.Select("fortress", "users", func(r Row) bool { return Equal(r["age"], 1) })
+	if len(rows) != 0 {
+		t.Errorf("rows updated after savepoint = %v, want none", rows)
+	}
+
+	if _, err := tx.Delete("fortress", "users", func(r Row) bool { return Equal(r["id"], 2) || Equal(r["id"], 3) }); err != nil {
+		t.Fatal(err)
+	}
+	// rows written by others during transaction are kept after rollback
+	if _, err := db.Update("fortress", "users", func(r Row) bool { return Equal(r["id"], 1) }, Row{"age": 31}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Insert("fortress", "users", Row{"name": "eve"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tx.Rollback()
+	rows, _ = db.Select("fortress", "users", nil)
+	names := []interface{}{}
+	for _, r := range rows {
+		names = append(names, r["name"])
+	}
+	if want := []interface{}{"ann", "bob", "Cat", "eve"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("rows after rollback = %v, want %v", names, want)
+	}
+	if !Equal(rows[0]["age"], 31) {
+		t.Errorf("age updated by another request = %v, want 31", rows[0]["age"])
+	}
+
+	// generated keys aren't reused after rollback
+	created, err := db.Insert("fortress", "users", Row{"name": "fay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(created["id"], 6) {
+		t.Errorf("Insert() id = %v, want 6", created["id"])
+	}
+}
@@ -0,0 +1,608 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// Condition a parsed WHERE condition, it supports comparisons (=, !=, <>, <, <=, >, >=) of a column
+// with a literal, [NOT] LIKE, [NOT] ILIKE, [NOT] IN, IS [NOT] NULL, AND, OR, NOT and parentheses
+type Condition struct {
+	match   func(Row) bool
+	columns []string
+}
+
+// Match check row matches condition, an empty condition matches all rows
+func (c Condition) Match(r Row) bool {
+	return c.match == nil || c.match(r)
+}
+
+// Columns return columns used by condition
+func (c Condition) Columns() []string {
+	return c.columns
+}
+
+// Order order of rows by a column
+type Order struct {
+	Column string
+	Desc   bool
+}
+
+// Field a selected column, Name is its alias or column name
+type Field struct {
+	Column string
+	Name   string
+}
+
+// Select a parsed statement: SELECT fields FROM table [WHERE condition] [ORDER BY column [ASC|DESC], ...]
+// [LIMIT n] [OFFSET n]. Fields are columns with optional aliases or *
+type Select struct {
+	Fields []Field // nil when all columns are selected by *
+	Table  string
+	Where  Condition
+	Order  []Order
+	Limit  int // no limit when it is negative
+	Offset int
+}
+
+// ParseCondition parse a WHERE condition, ex: "name = 'smithy' AND age >= 18"
+func ParseCondition(s string) (Condition, error) {
+	p, err := newParser(s)
+	if err != nil {
+		return Condition{}, err
+	}
+	if p.done() {
+		return Condition{}, nil
+	}
+
+	c, err := p.or()
+	if err != nil {
+		return Condition{}, err
+	}
+	if !p.done() {
+		return Condition{}, fmt.Errorf("syntax error at or near %q", p.peek().text)
+	}
+
+	return c, nil
+}
+
+// ParseSelect parse a SELECT statement of a single table, a trailing semicolon is ignored
+func ParseSelect(s string) (Select, error) {
+	p, err := newParser(strings.TrimSuffix(strings.TrimSpace(s), ";"))
+	if err != nil {
+		return Select{}, err
+	}
+
+	res := Select{Limit: -1}
+	if !p.keyword("SELECT") {
+		return res, errors.New(`statement must begin with "SELECT"`)
+	}
+
+	if p.symbol("*") {
+		res.Fields = nil
+	} else {
+		for {
+			col, err := p.ident()
+			if err != nil {
+				return res, err
+			}
+			f := Field{Column: col, Name: col}
+			if p.keyword("AS") {
+				if f.Name, err = p.ident(); err != nil {
+					return res, err
+				}
+			}
+			res.Fields = append(res.Fields, f)
+
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+
+	if !p.keyword("FROM") {
+		return res, errors.New(`missing "FROM"`)
+	}
+	if res.Table, err = p.ident(); err != nil {
+		return res, err
+	}
+
+	if p.keyword("WHERE") {
+		if res.Where, err = p.or(); err != nil {
+			return res, err
+		}
+	}
+
+	if p.keyword("ORDER") {
+		if !p.keyword("BY") {
+			return res, errors.New(`missing "BY" after "ORDER"`)
+		}
+		for {
+			col, err := p.ident()
+			if err != nil {
+				return res, err
+			}
+			o := Order{Column: col}
+			if p.keyword("DESC") {
+				o.Desc = true
+			} else {
+				p.keyword("ASC")
+			}
+			res.Order = append(res.Order, o)
+
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+
+	if p.keyword("LIMIT") {
+		if res.Limit, err = p.integer(); err != nil {
+			return res, err
+		}
+	}
+	if p.keyword("OFFSET") {
+		if res.Offset, err = p.integer(); err != nil {
+			return res, err
+		}
+	}
+
+	if !p.done() {
+		return res, fmt.Errorf("syntax error at or near %q", p.peek().text)
+	}
+
+	return res, nil
+}
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(s string) ([]token, error) {
+	res := []token{}
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'':
+			// '' is an escaped quote
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, errors.New("unterminated quoted string")
+				}
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						b.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			res = append(res, token{kind: tokenString, text: b.String()})
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			if j >= len(runes) {
+				return nil, errors.New("unterminated quoted identifier")
+			}
+			res = append(res, token{kind: tokenIdent, text: string(runes[i+1 : j])})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			res = append(res, token{kind: tokenNumber, text: string(runes[i:j])})
+			i = j
+		case isIdentRune(r):
+			j := i
+			for j < len(runes) && (isIdentRune(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			res = append(res, token{kind: tokenIdent, text: string(runes[i:j])})
+			i = j
+		default:
+			text := string(r)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "<=", ">=", "<>", "!=":
+					text = two
+				}
+			}
+			if !strings.Contains("=<>!(),*", text[:1]) {
+				return nil, fmt.Errorf("syntax error at or near %q", text)
+			}
+			res = append(res, token{kind: tokenSymbol, text: text})
+			i += len([]rune(text))
+		}
+	}
+
+	return res, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func newParser(s string) (*parser, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parser{tokens: tokens}, nil
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: tokenSymbol}
+	}
+
+	return p.tokens[p.pos]
+}
+
+// keyword consume next token if it is keyword k, case is ignored
+func (p *parser) keyword(k string) bool {
+	if t := p.peek(); t.kind == tokenIdent && strings.EqualFold(t.text, k) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) symbol(s string) bool {
+	if t := p.peek(); t.kind == tokenSymbol && t.text == s {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+// keywords can't be used as column names without quotes
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "AND": true, "OR": true, "NOT": true, "ORDER": true,
+	"BY": true, "LIMIT": true, "OFFSET": true, "AS": true, "IS": true, "NULL": true, "IN": true,
+	"LIKE": true, "ILIKE": true,
+}
+
+// ident consume a column or table name, a qualifier is removed, ex: users.name is name
+func (p *parser) ident() (string, error) {
+	t := p.peek()
+	if t.kind != tokenIdent || keywords[strings.ToUpper(t.text)] {
+		return "", fmt.Errorf("syntax error at or near %q", t.text)
+	}
+	p.pos++
+
+	if i := strings.LastIndex(t.text, "."); i >= 0 {
+		return t.text[i+1:], nil
+	}
+
+	return t.text, nil
+}
+
+func (p *parser) integer() (int, error) {
+	t := p.peek()
+	n, err := strconv.Atoi(t.text)
+	if t.kind != tokenNumber || err != nil || n < 0 {
+		return 0, fmt.Errorf("syntax error at or near %q", t.text)
+	}
+	p.pos++
+
+	return n, nil
+}
+
+// literal consume a value: a quoted string, a number, TRUE, FALSE or NULL
+func (p *parser) literal() (interface{}, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenString:
+		p.pos++
+		return t.text, nil
+	case t.kind == tokenNumber:
+		p.pos++
+		if n, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return n, nil
+		}
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", t.text)
+		}
+		return n, nil
+	case p.keyword("TRUE"):
+		return true, nil
+	case p.keyword("FALSE"):
+		return false, nil
+	case p.keyword("NULL"):
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("syntax error at or near %q", t.text)
+}
+
+func (p *parser) or() (Condition, error) {
+	left, err := p.and()
+	if err != nil {
+		return left, err
+	}
+
+	for p.keyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return right, err
+		}
+		l, r := left, right
+		left = Condition{
+			match:   func(row Row) bool { return l.Match(row) || r.Match(row) },
+			columns: append(append([]string{}, l.columns...), r.columns...),
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) and() (Condition, error) {
+	left, err := p.not()
+	if err != nil {
+		return left, err
+	}
+
+	for p.keyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return right, err
+		}
+		l, r := left, right
+		left = Condition{
+			match:   func(row Row) bool { return l.Match(row) && r.Match(row) },
+			columns: append(append([]string{}, l.columns...), r.columns...),
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) not() (Condition, error) {
+	if !p.keyword("NOT") {
+		return p.primary()
+	}
+
+	c, err := p.not()
+	if err != nil {
+		return c, err
+	}
+
+	return Condition{match: func(row Row) bool { return !c.Match(row) }, columns: c.columns}, nil
+}
+
+func (p *parser) primary() (Condition, error) {
+	if p.symbol("(") {
+		c, err := p.or()
+		if err != nil {
+			return c, err
+		}
+		if !p.symbol(")") {
+			return c, errors.New(`missing ")"`)
+		}
+		return c, nil
+	}
+
+	col, err := p.ident()
+	if err != nil {
+		return Condition{}, err
+	}
+	columns := []string{col}
+
+	if p.keyword("IS") {
+		negate := p.keyword("NOT")
+		if !p.keyword("NULL") {
+			return Condition{}, fmt.Errorf("syntax error at or near %q", p.peek().text)
+		}
+		return Condition{match: func(r Row) bool { return (r[col] == nil) != negate }, columns: columns}, nil
+	}
+
+	negate := p.keyword("NOT")
+	switch {
+	case p.keyword("IN"):
+		if !p.symbol("(") {
+			return Condition{}, errors.New(`missing "(" after "IN"`)
+		}
+		values := []interface{}{}
+		for {
+			v, err := p.literal()
+			if err != nil {
+				return Condition{}, err
+			}
+			values = append(values, v)
+			if !p.symbol(",") {
+				break
+			}
+		}
+		if !p.symbol(")") {
+			return Condition{}, errors.New(`missing ")"`)
+		}
+		return Condition{match: func(r Row) bool {
+			if r[col] == nil {
+				return false
+			}
+			for _, v := range values {
+				if Equal(r[col], v) {
+					return !negate
+				}
+			}
+			return negate
+		}, columns: columns}, nil
+	case p.keyword("LIKE"), p.keyword("ILIKE"):
+		fold := strings.EqualFold(p.tokens[p.pos-1].text, "ILIKE")
+		v, err := p.literal()
+		if err != nil {
+			return Condition{}, err
+		}
+		pattern := fmt.Sprint(v)
+		return Condition{match: func(r Row) bool {
+			return r[col] != nil && v != nil && Like(r[col], pattern, fold) != negate
+		}, columns: columns}, nil
+	case negate:
+		return Condition{}, fmt.Errorf("syntax error at or near %q", p.peek().text)
+	}
+
+	op := p.peek()
+	if op.kind != tokenSymbol {
+		return Condition{}, fmt.Errorf("syntax error at or near %q", op.text)
+	}
+	p.pos++
+
+	v, err := p.literal()
+	if err != nil {
+		return Condition{}, err
+	}
+
+	var cmp func(int) bool
+	switch op.text {
+	case "=":
+		cmp = func(c int) bool { return c == 0 }
+	case "!=", "<>":
+		cmp = func(c int) bool { return c != 0 }
+	case "<":
+		cmp = func(c int) bool { return c < 0 }
+	case "<=":
+		cmp = func(c int) bool { return c <= 0 }
+	case ">":
+		cmp = func(c int) bool { return c > 0 }
+	case ">=":
+		cmp = func(c int) bool { return c >= 0 }
+	default:
+		return Condition{}, fmt.Errorf("syntax error at or near %q", op.text)
+	}
+
+	// a comparison with null is never true
+	return Condition{match: func(r Row) bool {
+		return r[col] != nil && v != nil && cmp(Compare(r[col], v))
+	}, columns: columns}, nil
+}
+
+// Sort sort rows by orders, rows with equal values keep their order
+func Sort(rows []Row, orders []Order) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, o := range orders {
+			c := Compare(rows[i][o.Column], rows[j][o.Column])
+			if c == 0 {
+				continue
+			}
+			if o.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// CheckColumns reject names which aren't columns of m
+func CheckColumns(m database.Model, names []string) error {
+	colNames := database.Columns(m.Columns).Names()
+	for _, name := range names {
+		found := false
+		for _, col := range colNames {
+			if col == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("column %s of table %s does not exist", name, m.TableName)
+		}
+	}
+
+	return nil
+}
+
+// Run run a select on a table of dbName, names of result columns and rows are returned.
+// Computed columns are null, their SQL expressions can't be run in memory
+func (db *DB) Run(dbName string, s Select) ([]string, [][]interface{}, error) {
+	m, err := db.Model(dbName, s.Table)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fields := s.Fields
+	if fields == nil {
+		for _, name := range database.Columns(m.Columns).Names() {
+			fields = append(fields, Field{Column: name, Name: name})
+		}
+	}
+
+	used := append([]string{}, s.Where.Columns()...)
+	for _, f := range fields {
+		used = append(used, f.Column)
+	}
+	for _, o := range s.Order {
+		used = append(used, o.Column)
+	}
+	if err := CheckColumns(m, used); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := db.Select(dbName, s.Table, s.Where.Match)
+	if err != nil {
+		return nil, nil, err
+	}
+	Sort(rows, s.Order)
+
+	if s.Offset >= len(rows) {
+		rows = nil
+	} else {
+		rows = rows[s.Offset:]
+	}
+	if s.Limit >= 0 && s.Limit < len(rows) {
+		rows = rows[:s.Limit]
+	}
+
+	names := []string{}
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+
+	res := [][]interface{}{}
+	for _, r := range rows {
+		values := []interface{}{}
+		for _, f := range fields {
+			values = append(values, r[f.Column])
+		}
+		res = append(res, values)
+	}
+
+	return names, res, nil
+}
@@ -0,0 +1,266 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+// convertValue convert a value written to a column to type of column, so rows hold
+// the same types as rows scanned from postgres: int64, float64, bool, string and time.Time
+func convertValue(v interface{}, col database.Column) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+
+	switch col.Type {
+	case "int":
+		if n, ok := toFloat(v); ok && n == math.Trunc(n) {
+			return int64(n), nil
+		}
+		if s, ok := v.(string); ok {
+			if n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+				return n, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid input value %v of integer column %s", v, col.Name)
+	case "float":
+		if n, ok := toFloat(v); ok {
+			return n, nil
+		}
+		if s, ok := v.(string); ok {
+			if n, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return n, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid input value %v of float column %s", v, col.Name)
+	case "bool":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		if b, err := strconv.ParseBool(fmt.Sprint(v)); err == nil {
+			return b, nil
+		}
+		return nil, fmt.Errorf("invalid input value %v of boolean column %s", v, col.Name)
+	case "timestamp":
+		if t, ok := toTime(v); ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("invalid input value %v of timestamp column %s", v, col.Name)
+	case "string":
+		switch val := v.(type) {
+		case string:
+			return val, nil
+		case time.Time:
+			return val.Format(time.RFC3339Nano), nil
+		}
+		return fmt.Sprint(v), nil
+	}
+
+	if n, ok := v.(json.Number); ok {
+		return n.String(), nil
+	}
+
+	return v, nil
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// timeLayouts layouts of timestamps accepted in strings
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		for _, layout := range timeLayouts {
+			if res, err := time.Parse(layout, strings.TrimSpace(t)); err == nil {
+				return res, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Compare compare 2 values of columns, numbers are compared by value, so 1 and 1.0 are equal.
+// Values of different types are compared as text. Null is greater than all values, it is
+// sorted last in ascending order like postgres
+func Compare(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			return compareFloat(x, y)
+		}
+	}
+
+	if x, ok := toTime(a); ok {
+		if y, ok := toTime(b); ok {
+			switch {
+			case x.Before(y):
+				return -1
+			case x.After(y):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	if x, ok := a.(bool); ok {
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case !x:
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return strings.Compare(text(a), text(b))
+}
+
+func compareFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+
+	return 0
+}
+
+// Equal check 2 values are equal, null isn't equal to any value like in SQL
+func Equal(a, b interface{}) bool {
+	return a != nil && b != nil && Compare(a, b) == 0
+}
+
+// Key return a text of a value, equal values have equal keys, so it can be used as a map key
+func Key(v interface{}) string {
+	if n, ok := toFloat(v); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	if t, ok := v.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+
+	return text(v)
+}
+
+func text(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// Like match value with a LIKE pattern, % match any characters, _ match a character
+// and \ escape next character. Case is ignored when fold is true, like ILIKE
+func Like(v interface{}, pattern string, fold bool) bool {
+	s := []rune(text(v))
+	p := []rune(pattern)
+	if fold {
+		s = []rune(strings.ToLower(string(s)))
+		p = []rune(strings.ToLower(string(p)))
+	}
+
+	return like(s, p)
+}
+
+func like(s, p []rune) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '%':
+			for len(p) > 0 && p[0] == '%' {
+				p = p[1:]
+			}
+			if len(p) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if like(s[i:], p) {
+					return true
+				}
+			}
+			return false
+		case '_':
+			if len(s) == 0 {
+				return false
+			}
+		case '\\':
+			if len(p) > 1 {
+				p = p[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != p[0] {
+				return false
+			}
+		}
+		s, p = s[1:], p[1:]
+	}
+
+	return len(s) == 0
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,44 @@
+package sqlmapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// RowFilter decide rows of tables a user can access, a row is accessible when its columns
+// equal to values of all filters of its table
+type RowFilter interface {
+	RowFilters(dbName, tableName string) ([]Filter, error)
+}
+
+type rowFilterKey struct{}
+
+// WithRowFilter return a context whose queries, updates and deletes are restricted to rows allowed by f
+func WithRowFilter(ctx context.Context, f RowFilter) context.Context {
+	return context.WithValue(ctx, rowFilterKey{}, f)
+}
+
+// RowFilters get filters of table in ctx, nil is returned when ctx has no row filter
+func RowFilters(ctx context.Context, dbName, tableName string) ([]Filter, error) {
+	f, ok := ctx.Value(rowFilterKey{}).(RowFilter)
+	if !ok {
+		return nil, nil
+	}
+
+	return f.RowFilters(dbName, tableName)
+}
+
+// ForbiddenRowError is returned when a row outside row filters of user is written
+type ForbiddenRowError struct {
+	TableName string
+}
+
+func (e ForbiddenRowError) Error() string {
+	return fmt.Sprintf("row of table %s is outside rows permitted to user", e.TableName)
+}
+
+// StatusCode return status 403 for a forbidden row
+func (ForbiddenRowError) StatusCode() int {
+	return http.StatusForbidden
+}
@@ -0,0 +1,44 @@
+package sqlmapper
+
+import (
+	"context"
+	"sync"
+)
+
+type sessionKey struct{}
+
+// session remember databases written during a request
+type session struct {
+	sync.Mutex
+	written map[string]bool
+}
+
+// NewSession return a context carrying a session, once a database is written in the session
+// later reads of it go to primary instead of a replica, so a request can read its own writes
+func NewSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{written: make(map[string]bool)})
+}
+
+// MarkWritten record that dbName is written in session of ctx, it does nothing when ctx has no session
+func MarkWritten(ctx context.Context, dbName string) {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	if !ok {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.written[dbName] = true
+}
+
+// IsWritten check dbName is written in session of ctx
+func IsWritten(ctx context.Context, dbName string) bool {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	if !ok {
+		return false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	return s.written[dbName]
+}
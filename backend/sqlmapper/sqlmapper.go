@@ -1,6 +1,7 @@
 package sqlmapper
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -12,27 +13,73 @@ import (
 	"github.com/dwarvesf/smithy/common/database"
 )
 
-// Mapper interface for mapping query from sql to corresponding database engine
+// Mapper interface for mapping query from sql to corresponding database engine,
+// statements run by a method are cancelled when its ctx is done
 type Mapper interface {
-	Create(dbName, tableName string, d RowData) (RowData, error)
-	Update(dbName, tableName string, d RowData) (RowData, error)
-	Delete(dbName, tableName string, fields, data []interface{}) error
-	Query(Query) ([]string, []interface{}, error)
-	RawQuery(dbName string, sql string) ([]string, []database.Column, []interface{}, error)
+	Create(ctx context.Context, dbName, tableName string, d RowData) (RowData, error)
+	Update(ctx context.Context, dbName, tableName string, d RowData) (RowData, error)
+	Delete(ctx context.Context, dbName, tableName string, fields, data []interface{}) error
+	Upsert(ctx context.Context, dbName, tableName string, d RowData, conflictColumns []string) (RowData, string, error)
+	Restore(ctx context.Context, dbName, tableName string, fields, data []interface{}) error
+	DeletePreview(ctx context.Context, dbName, tableName string, fields, data []interface{}) (DeleteImpact, error)
+	Transaction(ctx context.Context, dbName string, ops []Operation) ([]RowData, error)
+	BulkCreate(ctx context.Context, dbName, tableName string, rows []RowData, mode string) (BulkResults, error)
+	BulkUpdate(ctx context.Context, dbName, tableName string, rows []RowData, mode string) (BulkResults, error)
+	BulkDelete(ctx context.Context, dbName, tableName string, fields []interface{}, rows [][]interface{}, mode string) (BulkResults, error)
+	Query(ctx context.Context, q Query) ([]string, []interface{}, error)
+	FindRows(ctx context.Context, dbName, tableName string, where RowData) ([]RowData, error)
+	RawQuery(ctx context.Context, dbName string, sql string) ([]string, []database.Column, []interface{}, error)
 	ColumnMetadata(Query) ([]database.Column, error)
 	ColumnMetadataByRows(*sql.Rows) ([]database.Column, error)
-	Explain(dbName string, sql string) (interface{}, error)
+	Explain(ctx context.Context, dbName string, sql string) (interface{}, error)
+	StreamQuery(ctx context.Context, q Query, w RowWriter) error
+	StreamRawQuery(ctx context.Context, dbName string, sql string, w RowWriter) error
+}
+
+// RowWriter receive result of a query row by row, so rows aren't buffered in memory
+type RowWriter interface {
+	WriteHeader(columns []string, cols []database.Column) error
+	WriteRow(row []interface{}) error
+}
+
+// ErrStopStream is returned by a RowWriter to stop reading rows without error, ex: max rows is reached
+var ErrStopStream = errors.New("stop stream")
+
+// Actions taken by an upsert
+const (
+	UpsertInserted = "inserted"
+	UpsertUpdated  = "updated"
+)
+
+// DeleteImpact number of rows of a table affected by a delete, dependents of
+// cascaded tables are listed recursively
+type DeleteImpact struct {
+	Table      string         `json:"table"`
+	Columns    []string       `json:"columns,omitempty"`   // columns referencing to parent table
+	OnDelete   string         `json:"on_delete,omitempty"` // empty when database decides
+	Count      int            `json:"count"`
+	Dependents []DeleteImpact `json:"dependents"`
 }
 
 // Query contain query data for a query request
 type Query struct {
-	SourceDatabase string   `json:"-"`
-	SourceTable    string   `json:"-"`
-	Fields         []string `json:"fields"`
-	Filter         Filter   `json:"filter"`
-	Offset         int      `json:"offset"`
-	Limit          int      `json:"limit"`
-	Order          []string `json:"order"` // 2 elements: "columnName" and "asc" if ascending order, "desc" if descending order
+	SourceDatabase string    `json:"-"`
+	SourceTable    string    `json:"-"`
+	Fields         []string  `json:"fields"`
+	Filter         Filter    `json:"filter"`
+	Offset         int       `json:"offset"`
+	Limit          int       `json:"limit"`
+	Order          []string  `json:"order"` // 2 elements: "columnName" and "asc" if ascending order, "desc" if descending order
+	Include        []Include `json:"include"`
+	IncludeDeleted bool      `json:"include_deleted"` // include soft-deleted rows
+	Labels         []string  `json:"labels"`          // foreign key columns whose labels are returned after includes
+}
+
+// Include describe a related table loaded along with the rows of a query
+type Include struct {
+	Table  string   `json:"table"`
+	Fields []string `json:"fields"`
+	Limit  int      `json:"limit"` // max number of related rows for each row, only used by has_many
 }
 
 // QueryPlan .
@@ -58,6 +105,35 @@ func (q *Query) Columns() []string {
 	return q.Fields
 }
 
+// ResultColumns return columns of a query result, an included table is named by its table,
+// its fields are returned by IncludeFields
+func (q *Query) ResultColumns(columns []string) []string {
+	res := append([]string{}, columns...)
+	for _, inc := range q.Include {
+		res = append(res, inc.Table)
+	}
+
+	for _, label := range q.Labels {
+		res = append(res, label+LabelSuffix)
+	}
+
+	return res
+}
+
+// IncludeFields return fields of included tables by table name, nil when nothing is included
+func (q *Query) IncludeFields() map[string][]string {
+	if len(q.Include) == 0 {
+		return nil
+	}
+
+	res := make(map[string][]string)
+	for _, inc := range q.Include {
+		res[inc.Table] = inc.Fields
+	}
+
+	return res
+}
+
 // ColumnMetadata convert query to column spec
 func (q *Query) ColumnMetadata(columns []database.Column) ([]database.Column, error) {
 	res := []database.Column{}
@@ -76,7 +152,7 @@ func (q *Query) ColumnMetadata(columns []database.Column) ([]database.Column, er
 
 // Filter containt filter
 type Filter struct {
-	Operator   string      `json:"operator"` // "="
+	Operator   string      `json:"operator"` // "=" or "ilike", value of ilike is a pattern
 	ColumnName string      `json:"column_name"`
 	Value      interface{} `json:"value"`
 }
@@ -101,8 +177,7 @@ func (r RowData) ColumnsAndData() ([]string, []interface{}) {
 	cols := []string{}
 	data := []interface{}{}
 	for k, v := range r {
-		t := reflect.TypeOf(v.Data).String()
-		if t != "[]sqlmapper.RowData" {
+		if _, ok := v.Data.([]RowData); !ok {
 			cols = append(cols, k)
 			data = append(data, v.Data)
 		}
@@ -115,9 +190,8 @@ func (r RowData) ColumnsAndData() ([]string, []interface{}) {
 func (r RowData) RelateData() map[string][]RowData {
 	relateRows := make(map[string][]RowData)
 	for k, v := range r {
-		t := reflect.TypeOf(v.Data).String()
-		if t == "[]sqlmapper.RowData" {
-			relateRows[k] = v.Data.([]RowData)
+		if rows, ok := v.Data.([]RowData); ok {
+			relateRows[k] = rows
 		}
 	}
 
@@ -221,16 +295,30 @@ func makeRowDataSet(columns []database.Column) RowData {
 // SQLRowsToRows return rows from sql.Rows
 func SQLRowsToRows(rows *sql.Rows) ([]interface{}, error) {
 	var res []interface{}
-	columns, _ := rows.Columns()
+	err := ScanRows(rows, func(row []interface{}) error {
+		res = append(res, row)
+		return nil
+	})
+
+	return res, err
+}
+
+// ScanRows call fn for each row without buffering rows, scan errors are returned.
+// fn can return ErrStopStream to stop reading rows without error
+func ScanRows(rows *sql.Rows, fn func(row []interface{}) error) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
 	for rows.Next() {
 		row := make([]interface{}, len(columns))
 		for idx := range columns {
 			row[idx] = new(metalScanner)
 		}
 
-		err := rows.Scan(row...)
-		if err != nil {
-			fmt.Println(err)
+		if err := rows.Scan(row...); err != nil {
+			return err
 		}
 
 		tmp := []interface{}{}
@@ -239,10 +327,15 @@ func SQLRowsToRows(rows *sql.Rows) ([]interface{}, error) {
 			tmp = append(tmp, scanner.value)
 		}
 
-		res = append(res, tmp)
+		if err := fn(tmp); err != nil {
+			if err == ErrStopStream {
+				return nil
+			}
+			return err
+		}
 	}
 
-	return res, nil
+	return rows.Err()
 }
 
 type metalScanner struct {
@@ -0,0 +1,28 @@
+package sqlmapper
+
+import (
+	"context"
+)
+
+// TablePermission decide actions (query, create, update, delete) a user can run on tables, it is checked
+// for tables included by a query and tables written by a nested write or an on_delete of another table
+type TablePermission interface {
+	AuthorizeTable(dbName, tableName, action string) error
+}
+
+type tablePermissionKey struct{}
+
+// WithTablePermission return a context whose includes, nested writes and on_delete writes are restricted to tables allowed by p
+func WithTablePermission(ctx context.Context, p TablePermission) context.Context {
+	return context.WithValue(ctx, tablePermissionKey{}, p)
+}
+
+// AuthorizeTable check action can be run on table in ctx, every action is allowed when ctx has no table permission
+func AuthorizeTable(ctx context.Context, dbName, tableName, action string) error {
+	p, ok := ctx.Value(tablePermissionKey{}).(TablePermission)
+	if !ok {
+		return nil
+	}
+
+	return p.AuthorizeTable(dbName, tableName, action)
+}
@@ -0,0 +1,98 @@
+package sqlmapper
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Types of operation in a transaction
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// RefKey is the key of a reference to result of an earlier operation, ex: {"$ref": "0.id"}
+const RefKey = "$ref"
+
+// Operation is a create, update or delete in a transaction. Fields and data of delete are its filter
+type Operation struct {
+	Type      string        `json:"type"`
+	TableName string        `json:"table_name"`
+	Fields    []interface{} `json:"fields"`
+	Data      []interface{} `json:"data"`
+}
+
+// ResolveRefs replace references in data by values of earlier operation results
+func ResolveRefs(data []interface{}, results []RowData) ([]interface{}, error) {
+	res := make([]interface{}, len(data))
+	for i, d := range data {
+		v, err := resolveRef(d, results)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = v
+	}
+
+	return res, nil
+}
+
+func resolveRef(v interface{}, results []RowData) (interface{}, error) {
+	switch t := v.(type) {
+	case []interface{}:
+		return ResolveRefs(t, results)
+	case map[string]interface{}:
+		ref, ok := t[RefKey].(string)
+		if !ok || len(t) != 1 {
+			return v, nil
+		}
+
+		parts := strings.SplitN(ref, ".", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid reference %s, it must be <operation index>.<column>", ref)
+		}
+
+		idx, err := strconv.Atoi(parts[0])
+		if err != nil || idx < 0 || idx >= len(results) {
+			return nil, fmt.Errorf("invalid reference %s, it must point to an earlier operation", ref)
+		}
+
+		colData, ok := results[idx][parts[1]]
+		if !ok {
+			return nil, fmt.Errorf("invalid reference %s, operation %d has no column %s", ref, idx, parts[1])
+		}
+
+		return colData.Data, nil
+	default:
+		return v, nil
+	}
+}
+
+// OperationError is returned when an operation of a transaction failed, Err is the error of
+// the operation so its status and fields are kept
+type OperationError struct {
+	Index     int
+	Type      string
+	TableName string
+	Err       error
+}
+
+func (e OperationError) Error() string {
+	return fmt.Sprintf("operation %d (%s %s): %v", e.Index, e.Type, e.TableName, e.Err)
+}
+
+// StatusCode return status of error of the operation, 500 when it has no status
+func (e OperationError) StatusCode() int {
+	if sc, ok := e.Err.(interface{ StatusCode() int }); ok {
+		return sc.StatusCode()
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Unwrap return error of the operation
+func (e OperationError) Unwrap() error {
+	return e.Err
+}
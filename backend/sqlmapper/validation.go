@@ -0,0 +1,173 @@
+package sqlmapper
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// patterns cache compiled patterns of validation rules, a pattern is compiled once
+// for all values of its column
+var patterns sync.Map
+
+type compiledPattern struct {
+	re  *regexp.Regexp
+	err error
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if p, ok := patterns.Load(pattern); ok {
+		return p.(compiledPattern).re, p.(compiledPattern).err
+	}
+
+	re, err := regexp.Compile(pattern)
+	patterns.Store(pattern, compiledPattern{re: re, err: err})
+
+	return re, err
+}
+
+// FieldError describe an invalid value of a field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError hold all invalid fields of a request
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e ValidationError) Error() string {
+	msgs := []string{}
+	for _, f := range e.Fields {
+		msgs = append(msgs, fmt.Sprintf("%s %s", f.Field, f.Message))
+	}
+
+	return "invalid fields: " + strings.Join(msgs, "; ")
+}
+
+// StatusCode return status 400 for invalid fields
+func (ValidationError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ValidateRow check values of a row with validation rules of columns,
+// prefix is prepended to field names, ex: "books[0]."
+func ValidateRow(row RowData, columns []database.Column, prefix string) []FieldError {
+	res := []FieldError{}
+	for _, col := range columns {
+		colData, ok := row[col.Name]
+		if !ok || colData.Data == nil {
+			continue
+		}
+
+		for _, msg := range validateValue(colData.Data, col.Validation) {
+			res = append(res, FieldError{Field: prefix + col.Name, Message: msg})
+		}
+	}
+
+	return res
+}
+
+func validateValue(v interface{}, rule database.Validation) []string {
+	res := []string{}
+
+	if rule.Min != nil || rule.Max != nil {
+		n, ok := toFloat(v)
+		switch {
+		case !ok:
+			res = append(res, "must be a number")
+		case rule.Min != nil && n < *rule.Min:
+			res = append(res, fmt.Sprintf("must be greater than or equal to %v", *rule.Min))
+		case rule.Max != nil && n > *rule.Max:
+			res = append(res, fmt.Sprintf("must be less than or equal to %v", *rule.Max))
+		}
+	}
+
+	needString := rule.MinLength != nil || rule.MaxLength != nil || rule.Pattern != "" || rule.Format != ""
+	s, isString := v.(string)
+	if needString && !isString {
+		return append(res, "must be a string")
+	}
+
+	if rule.MinLength != nil && utf8.RuneCountInString(s) < *rule.MinLength {
+		res = append(res, fmt.Sprintf("must have at least %d characters", *rule.MinLength))
+	}
+
+	if rule.MaxLength != nil && utf8.RuneCountInString(s) > *rule.MaxLength {
+		res = append(res, fmt.Sprintf("must have at most %d characters", *rule.MaxLength))
+	}
+
+	if rule.Pattern != "" {
+		re, err := compilePattern(rule.Pattern)
+		if err != nil {
+			res = append(res, fmt.Sprintf("has invalid pattern %s: %v", rule.Pattern, err))
+		} else if !re.MatchString(s) {
+			res = append(res, fmt.Sprintf("must match pattern %s", rule.Pattern))
+		}
+	}
+
+	if rule.Format != "" && !isValidFormat(s, rule.Format) {
+		res = append(res, fmt.Sprintf("must be a valid %s", rule.Format))
+	}
+
+	if len(rule.Enum) > 0 {
+		found := false
+		for _, e := range rule.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, fmt.Sprintf("must be one of %v", rule.Enum))
+		}
+	}
+
+	return res
+}
+
+func isValidFormat(s, format string) bool {
+	switch format {
+	case database.FormatEmail:
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case database.FormatURL:
+		u, err := url.ParseRequestURI(s)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	case database.FormatUUID:
+		return uuidRegexp.MatchString(s)
+	default:
+		return false
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,99 @@
+package sqlmapper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dwarvesf/smithy/common/database"
+)
+
+func TestValidateRow(t *testing.T) {
+	min, max := float64(18), float64(60)
+	minLength, maxLength := 3, 5
+	columns := []database.Column{
+		{Name: "age", Validation: database.Validation{Min: &min, Max: &max}},
+		{Name: "name", Validation: database.Validation{MinLength: &minLength, MaxLength: &maxLength}},
+		{Name: "code", Validation: database.Validation{Pattern: "^[A-Z]+$"}},
+		{Name: "status", Validation: database.Validation{Enum: []interface{}{"active", "inactive"}}},
+		{Name: "email", Validation: database.Validation{Format: database.FormatEmail}},
+		{Name: "website", Validation: database.Validation{Format: database.FormatURL}},
+		{Name: "token", Validation: database.Validation{Format: database.FormatUUID}},
+	}
+
+	type args struct {
+		row RowData
+	}
+	tests := []struct {
+		name string
+		args args
+		want []FieldError
+	}{
+		{
+			name: "valid row",
+			args: args{
+				RowData{
+					"age":     ColData{Data: float64(20)},
+					"name":    ColData{Data: "Hieu"},
+					"code":    ColData{Data: "ABC"},
+					"status":  ColData{Data: "active"},
+					"email":   ColData{Data: "hieu@dwarvesv.com"},
+					"website": ColData{Data: "https://dwarves.foundation"},
+					"token":   ColData{Data: "0e4d5b0a-7c1c-4e5b-9a57-3b9d3d4b1f2a"},
+				},
+			},
+			want: []FieldError{},
+		},
+		{
+			name: "missing and null values are skipped",
+			args: args{
+				RowData{
+					"age": ColData{Data: nil},
+				},
+			},
+			want: []FieldError{},
+		},
+		{
+			name: "all invalid fields are listed",
+			args: args{
+				RowData{
+					"age":     ColData{Data: float64(10)},
+					"name":    ColData{Data: "Hieu Phan"},
+					"code":    ColData{Data: "abc"},
+					"status":  ColData{Data: "deleted"},
+					"email":   ColData{Data: "hieu"},
+					"website": ColData{Data: "dwarves"},
+					"token":   ColData{Data: "123"},
+				},
+			},
+			want: []FieldError{
+				{Field: "age", Message: "must be greater than or equal to 18"},
+				{Field: "name", Message: "must have at most 5 characters"},
+				{Field: "code", Message: "must match pattern ^[A-Z]+$"},
+				{Field: "status", Message: "must be one of [active inactive]"},
+				{Field: "email", Message: "must be a valid email"},
+				{Field: "website", Message: "must be a valid url"},
+				{Field: "token", Message: "must be a valid uuid"},
+			},
+		},
+		{
+			name: "wrong type",
+			args: args{
+				RowData{
+					"age":  ColData{Data: "old"},
+					"name": ColData{Data: float64(1)},
+				},
+			},
+			want: []FieldError{
+				{Field: "age", Message: "must be a number"},
+				{Field: "name", Message: "must be a string"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateRow(tt.args.row, columns, ""); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateRow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
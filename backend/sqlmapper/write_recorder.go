@@ -0,0 +1,52 @@
+package sqlmapper
+
+import (
+	"context"
+)
+
+// Actions of a written row
+const (
+	WriteCreate  = "create"
+	WriteUpdate  = "update"
+	WriteDelete  = "delete"
+	WriteRestore = "restore"
+)
+
+// RowWrite a row written by a mapper with all its stored columns, Before is nil for a created row
+// and After is nil for a deleted row
+type RowWrite struct {
+	DBName    string
+	TableName string
+	Action    string
+	Before    RowData
+	After     RowData
+}
+
+// WriteRecorder record rows written by a mapper, including rows written by relationships, on_delete
+// and hooks. It is called inside transaction of the write, so an error fails and rolls back the write
+type WriteRecorder interface {
+	RecordWrite(ctx context.Context, w RowWrite) error
+}
+
+type writeRecorderKey struct{}
+
+// WithWriteRecorder return a context whose written rows are recorded by r
+func WithWriteRecorder(ctx context.Context, r WriteRecorder) context.Context {
+	return context.WithValue(ctx, writeRecorderKey{}, r)
+}
+
+// RecordsWrites check ctx has a write recorder, written rows aren't read without it
+func RecordsWrites(ctx context.Context) bool {
+	_, ok := ctx.Value(writeRecorderKey{}).(WriteRecorder)
+	return ok
+}
+
+// RecordWrite record w by write recorder of ctx, it does nothing when ctx has no write recorder
+func RecordWrite(ctx context.Context, w RowWrite) error {
+	r, ok := ctx.Value(writeRecorderKey{}).(WriteRecorder)
+	if !ok {
+		return nil
+	}
+
+	return r.RecordWrite(ctx, w)
+}
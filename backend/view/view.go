@@ -1,6 +1,7 @@
 package view
 
 import (
+	"context"
 	"errors"
 	"strings"
 	"time"
@@ -13,11 +14,12 @@ type View struct {
 	ID           int       `json:"id"`
 	SQL          string    `json:"sql"`
 	DatabaseName string    `json:"database_name"`
+	CacheTTL     int       `json:"cache_ttl"` // seconds, result is cached when query cache is enabled. Not cached when it is 0
 	CreatedAt    time.Time `json:"created_at"`
 }
 
 // Validate validate a view
-func (s *View) Validate(m sqlmapper.Mapper) (interface{}, error) {
+func (s *View) Validate(ctx context.Context, m sqlmapper.Mapper) (interface{}, error) {
 	// just get first view
 	SQLs := strings.Split(s.SQL, ";")
 	s.SQL = strings.TrimSpace(SQLs[0])
@@ -26,7 +28,7 @@ func (s *View) Validate(m sqlmapper.Mapper) (interface{}, error) {
 		return nil, errors.New(`view must be begining with "SELECT"`)
 	}
 
-	q, err := m.Explain(s.DatabaseName, s.SQL)
+	q, err := m.Explain(ctx, s.DatabaseName, s.SQL)
 	if err != nil {
 		return nil, err
 	}
@@ -3,6 +3,7 @@
 package view
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -25,7 +26,7 @@ func TestView_Validate(t *testing.T) {
 		}
 	}
 
-	s := drivers.NewPGStore(cfg.DBs(), cfg.ModelMap)
+	s := drivers.NewPGStore(cfg.DBs, cfg.Replicas, cfg.ModelMap)
 
 	type fields struct {
 		ID           int
@@ -105,7 +106,7 @@ func TestView_Validate(t *testing.T) {
 				DatabaseName: tt.fields.DatabaseName,
 				CreatedAt:    tt.fields.CreatedAt,
 			}
-			_, err := s.Validate(tt.args.m)
+			_, err := s.Validate(context.Background(), tt.args.m)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("View.Validate() error = %v, wantErr %v", err, tt.wantErr)
 				return
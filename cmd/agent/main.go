@@ -33,6 +33,11 @@ func main() {
 		panic(err)
 	}
 
+	err = agent.MarkGeneratedColumns(cfg)
+	if err != nil {
+		panic(err)
+	}
+
 	r.Get("/agent", handler.Expose(cfg))
 
 	errs := make(chan error)
@@ -67,6 +67,8 @@ func main() {
 		panic(err)
 	}
 
+	go cfg.CheckDBs(backendConfig.HealthCheckInterval)
+
 	var h http.Handler
 	{
 		h = serviceHttp.NewHTTPHandler(
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// importOptions options of import command
+type importOptions struct {
+	url       string
+	token     string
+	database  string
+	table     string
+	file      string
+	format    string
+	mode      string
+	mapping   []string // file_column=table_column
+	dryRun    bool
+	batchSize int
+}
+
+// runImport send a file to import endpoint of dashboard, so rows go through ACL and hooks of the table
+func runImport(opts importOptions) error {
+	f, err := os.Open(opts.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+
+	part, err := w.CreateFormFile("file", filepath.Base(opts.file))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return err
+	}
+
+	mapping := make(map[string]string)
+	for _, m := range opts.mapping {
+		parts := strings.SplitN(m, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid mapping %s, mapping must be file_column=table_column", m)
+		}
+		mapping[parts[0]] = parts[1]
+	}
+
+	fields := map[string]string{
+		"format":  opts.format,
+		"mode":    opts.mode,
+		"dry_run": strconv.FormatBool(opts.dryRun),
+	}
+	if opts.batchSize > 0 {
+		fields["batch_size"] = strconv.Itoa(opts.batchSize)
+	}
+	if len(mapping) > 0 {
+		b, err := json.Marshal(mapping)
+		if err != nil {
+			return err
+		}
+		fields["mapping"] = string(b)
+	}
+
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/databases/%s/table/%s/import", strings.TrimSuffix(opts.url, "/"), opts.database, opts.table)
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+opts.token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	result := map[string]interface{}{}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid response of dashboard with status %s: %v", res.Status, err)
+	}
+
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if msg, ok := result["error"]; ok {
+		// rows imported before the error are committed, their summary is printed
+		if _, ok := result["summary"]; ok {
+			fmt.Println(string(out))
+		}
+		return fmt.Errorf("import failed: %v", msg)
+	}
+	fmt.Println(string(out))
+
+	return nil
+}
@@ -33,6 +33,7 @@ func main() {
 		configFile     string
 		configFilePath string
 		forceCreate    bool
+		importOpts     importOptions
 	)
 
 	var cmdAgentMigrate = &cobra.Command{
@@ -120,8 +121,20 @@ func main() {
 		},
 	}
 
+	var cmdImport = &cobra.Command{
+		Use:   "import",
+		Short: "Import a csv or ndjson file into a table",
+		Long:  `import send a csv or ndjson file to dashboard, rows are validated and written with ACL and hooks of the table`,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runImport(importOpts); err != nil {
+				log.Fatalln(err)
+			}
+		},
+	}
+
 	var rootCmd = &cobra.Command{Use: "smithy"}
-	rootCmd.AddCommand(cmdAgentMigrate, cmdGenerate)
+	rootCmd.AddCommand(cmdAgentMigrate, cmdGenerate, cmdImport)
 	cmdGenerate.AddCommand(cmdPSK)
 	cmdGenerate.AddCommand(cmdGenerateUser)
 
@@ -130,6 +143,16 @@ func main() {
 	cmdGenerateUser.Flags().StringVarP(&configFile, "config-file", "c", "example_agent_config.yaml", "put your name of config file here, with extension")
 	cmdGenerateUser.Flags().BoolVarP(&forceCreate, "force-create", "f", false, "put your name of config file here, with extension")
 	cmdPSK.Flags().StringVarP(&configFilePath, "config-file", "c", "", "put your name of config file here, with extension")
+	cmdImport.Flags().StringVarP(&importOpts.url, "url", "u", "http://localhost:2999", "url of dashboard")
+	cmdImport.Flags().StringVarP(&importOpts.token, "token", "t", "", "access token of your account")
+	cmdImport.Flags().StringVarP(&importOpts.database, "database", "d", "", "name of database")
+	cmdImport.Flags().StringVarP(&importOpts.table, "table", "", "", "name of table")
+	cmdImport.Flags().StringVarP(&importOpts.file, "file", "f", "", "path of csv or ndjson file")
+	cmdImport.Flags().StringVarP(&importOpts.format, "format", "", "", "csv or ndjson, detected by file extension by default")
+	cmdImport.Flags().StringVarP(&importOpts.mode, "mode", "m", "insert", "insert, update or upsert rows")
+	cmdImport.Flags().StringSliceVarP(&importOpts.mapping, "mapping", "", nil, "map a column of file to a column of table, ex: \"Full name=name\"")
+	cmdImport.Flags().BoolVarP(&importOpts.dryRun, "dry-run", "", false, "validate rows without writing them")
+	cmdImport.Flags().IntVarP(&importOpts.batchSize, "batch-size", "", 0, "number of rows written in a batch")
 
 	err := rootCmd.Execute()
 	if err != nil {
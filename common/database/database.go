@@ -1,7 +1,12 @@
 package database
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 )
 
 // HookType for hooks
@@ -70,12 +75,42 @@ type Model struct {
 	NameDisplayColumn string         `yaml:"name_display_column" json:"name_display_column"`
 	Hooks             Hooks          `yaml:"hooks" json:"hooks"`
 	Relationship      []Relationship `yaml:"relationships" json:"relationships"`
+	SoftDelete        bool           `yaml:"soft_delete" json:"soft_delete"`               // delete set deleted_at column instead of removing rows
+	ManagedTimestamps bool           `yaml:"managed_timestamps" json:"managed_timestamps"` // created_at/updated_at columns are set by server
+	VersionColumn     string         `yaml:"version_column" json:"version_column"`         // integer column used for optimistic locking on update
+	CacheTTL          int            `yaml:"cache_ttl" json:"cache_ttl"`                   // seconds, queries are cached when query cache is enabled. Not cached when it is 0
 }
 
+// Columns of managed timestamps
+const (
+	CreatedAtColumn = "created_at"
+	UpdatedAtColumn = "updated_at"
+)
+
+// SoftDeleteColumn column marking a row is deleted in a soft_delete model
+const SoftDeleteColumn = "deleted_at"
+
+// Relationship types
+const (
+	RelationshipHasMany    = "has_many"
+	RelationshipHasOne     = "has_one"
+	RelationshipBelongsTo  = "belongs_to"
+	RelationshipManyToMany = "many_to_many"
+)
+
+// Actions on dependent rows when a row is deleted
+const (
+	OnDeleteRestrict = "restrict"
+	OnDeleteCascade  = "cascade"
+	OnDeleteSetNull  = "set_null"
+)
+
 // Relationship relationship between tables
 type Relationship struct {
-	Table string `yaml:"table" json:"table"`
-	Type  string `yaml:"type" json:"type"`
+	Table    string `yaml:"table" json:"table"`
+	Type     string `yaml:"type" json:"type"`
+	Through  string `yaml:"through" json:"through,omitempty"`     // join table of many_to_many relationship
+	OnDelete string `yaml:"on_delete" json:"on_delete,omitempty"` // restrict, cascade or set_null, database decides when it is empty
 }
 
 // AddHook add hook to model base on hookType
@@ -238,12 +273,104 @@ func (ms Models) ModelByTableName() map[string]Model {
 // Column store information of a column
 type Column struct {
 	Name         string     `yaml:"name" json:"name"`
+	DisplayName  string     `yaml:"display_name" json:"display_name,omitempty"`
 	Type         string     `yaml:"type" json:"type"`
 	Tags         string     `yaml:"tags" json:"tags"`
 	IsNullable   bool       `yaml:"is_nullable" json:"is_nullable"`
 	IsPrimary    bool       `yaml:"is_primary" json:"is_primary"`
+	IsGenerated  bool       `yaml:"is_generated" json:"is_generated"` // value is generated by database (serial or identity column), set by agent from schema of table
 	DefaultValue string     `yaml:"default_value" json:"default_value"`
 	ForeignKey   ForeignKey `yaml:"foreign_key" json:"foreign_key,omitempty"`
+	Validation   Validation `yaml:"validation" json:"validation,omitempty"`
+	Computed     string     `yaml:"computed" json:"computed,omitempty"` // SQL expression of a read-only column which isn't stored in table, ex: first_name || ' ' || last_name
+	Mask         string     `yaml:"mask" json:"mask,omitempty"`         // full, partial or hash, values are masked for users not in a group with unmask
+	ReadOnly     bool       `yaml:"-" json:"-"`                         // column can't be written by user reading it, computed columns are always read-only
+}
+
+// Masks of a column
+const (
+	MaskFull    = "full"    // value is replaced by ****
+	MaskPartial = "partial" // only last MaskPartialVisible characters are shown
+	MaskHash    = "hash"    // value is replaced by its sha256, equal values still have equal hashes
+)
+
+// MaskPartialVisible number of last characters shown by a partial mask
+const MaskPartialVisible = 4
+
+// Formats of a validation
+const (
+	FormatEmail = "email"
+	FormatURL   = "url"
+	FormatUUID  = "uuid"
+)
+
+// Validation rules of a column value, checked before writing a row. Empty rules are skipped
+type Validation struct {
+	Min       *float64      `yaml:"min" json:"min,omitempty"`
+	Max       *float64      `yaml:"max" json:"max,omitempty"`
+	MinLength *int          `yaml:"min_length" json:"min_length,omitempty"`
+	MaxLength *int          `yaml:"max_length" json:"max_length,omitempty"`
+	Pattern   string        `yaml:"pattern" json:"pattern,omitempty"`
+	Enum      []interface{} `yaml:"enum" json:"enum,omitempty"`
+	Format    string        `yaml:"format" json:"format,omitempty"` // email, url or uuid
+}
+
+// Title return display name of column, name is used when display name is empty
+func (c Column) Title() string {
+	if c.DisplayName != "" {
+		return c.DisplayName
+	}
+
+	return c.Name
+}
+
+// IsComputed check value of column is computed by an expression instead of stored in table
+func (c Column) IsComputed() bool {
+	return c.Computed != ""
+}
+
+// Expr return SQL expression reading column
+func (c Column) Expr() string {
+	if c.IsComputed() {
+		return "(" + c.Computed + ")"
+	}
+
+	return c.Name
+}
+
+// MarshalJSON add read_only to column, a computed column can't be written
+func (c Column) MarshalJSON() ([]byte, error) {
+	type column Column
+	return json.Marshal(struct {
+		column
+		ReadOnly bool `json:"read_only"`
+	}{column(c), c.ReadOnly || c.IsComputed()})
+}
+
+// MaskValue return value masked by mask of column, null stays null
+func (c Column) MaskValue(v interface{}) interface{} {
+	if v == nil || c.Mask == "" {
+		return v
+	}
+
+	str := fmt.Sprint(v)
+	if b, ok := v.([]byte); ok {
+		str = string(b)
+	}
+
+	switch c.Mask {
+	case MaskPartial:
+		runes := []rune(str)
+		if len(runes) <= MaskPartialVisible {
+			return strings.Repeat("*", len(runes))
+		}
+		return strings.Repeat("*", len(runes)-MaskPartialVisible) + string(runes[len(runes)-MaskPartialVisible:])
+	case MaskHash:
+		sum := sha256.Sum256([]byte(str))
+		return hex.EncodeToString(sum[:])
+	default:
+		return "****"
+	}
 }
 
 // ForeignKey foreign key of a column
@@ -269,6 +396,34 @@ func (cols Columns) GroupByName() map[string][]Column {
 	return res
 }
 
+// Stored return columns stored in table, computed columns are skipped
+func (cols Columns) Stored() Columns {
+	res := Columns{}
+	for _, col := range cols {
+		if !col.IsComputed() {
+			res = append(res, col)
+		}
+	}
+
+	return res
+}
+
+// SelectExprs return expressions selecting columns by names, a computed column is selected
+// by its expression named as column. Unknown names are kept
+func (cols Columns) SelectExprs(names []string) []string {
+	colMap := cols.GroupByName()
+	res := []string{}
+	for _, name := range names {
+		if c, ok := colMap[name]; ok && c[0].IsComputed() {
+			res = append(res, c[0].Expr()+" AS "+name)
+			continue
+		}
+		res = append(res, name)
+	}
+
+	return res
+}
+
 // Names return names of all columns
 func (cols Columns) Names() []string {
 	res := []string{}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumnsSelectExprs(t *testing.T) {
+	cols := Columns{
+		{Name: "id", Type: "int"},
+		{Name: "full_name", Type: "string", Computed: "first_name || ' ' || last_name"},
+	}
+
+	got := cols.SelectExprs([]string{"id", "full_name", "unknown"})
+	want := []string{"id", "(first_name || ' ' || last_name) AS full_name", "unknown"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Columns.SelectExprs() = %v, want %v", got, want)
+	}
+
+	if names := cols.Stored().Names(); !reflect.DeepEqual(names, []string{"id"}) {
+		t.Errorf("Columns.Stored() = %v, want [id]", names)
+	}
+}
+
+func TestColumnMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		col  Column
+		want string
+	}{
+		{
+			name: "stored column",
+			col:  Column{Name: "id"},
+			want: `"read_only":false`,
+		},
+		{
+			name: "computed column",
+			col:  Column{Name: "full_name", Computed: "first_name || last_name"},
+			want: `"read_only":true`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.col)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if !strings.Contains(string(b), tt.want) || !strings.Contains(string(b), `"name":"`+tt.col.Name+`"`) {
+				t.Errorf("json.Marshal() = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnMaskValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		mask  string
+		value interface{}
+		want  interface{}
+	}{
+		{name: "no mask", mask: "", value: "0912345678", want: "0912345678"},
+		{name: "full", mask: MaskFull, value: "0912345678", want: "****"},
+		{name: "partial", mask: MaskPartial, value: []byte("0912345678"), want: "******5678"},
+		{name: "partial of short value", mask: MaskPartial, value: "abc", want: "***"},
+		{name: "hash", mask: MaskHash, value: "abc", want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{name: "null", mask: MaskFull, value: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Column{Name: "phone", Mask: tt.mask}
+			if got := c.MaskValue(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Column.MaskValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
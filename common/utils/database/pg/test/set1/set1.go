@@ -74,11 +74,11 @@ func ACLUsersTable(t *testing.T, cfg *backendConfig.Config) func() {
 	return func() {
 		cfg.DBUsername = "postgres"
 		cfg.DBPassword = "example"
-		err := cfg.UpdateDB()
-		if err != nil {
-			t.Fatalf("Fail to update connection. %s", err.Error())
-		}
+		cfg.UpdateDB()
 		for _, dbase := range cfg.Databases {
+			if err := cfg.DBError(dbase.DBName); err != nil {
+				t.Fatalf("Fail to update connection. %s", err.Error())
+			}
 			db := cfg.DB(dbase.DBName)
 			db.Exec(fmt.Sprintf("REASSIGN OWNED BY %s TO postgres;", username))
 			db.Exec(fmt.Sprintf("DROP OWNED BY %s;", username))
@@ -100,9 +100,10 @@ func CreateModelList() []database.Model {
 			ACL:       "crd",
 			Columns: []database.Column{
 				{
-					Name:      "id",
-					Type:      "int",
-					IsPrimary: true,
+					Name:        "id",
+					Type:        "int",
+					IsPrimary:   true,
+					IsGenerated: true,
 				},
 				{
 					Name:       "name",